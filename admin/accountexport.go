@@ -0,0 +1,98 @@
+package admin
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"time"
+
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/store"
+)
+
+// AccountExport writes a gzipped tar file to w with the configuration of the
+// account and all its messages, for moving an account to another mox instance.
+//
+// The archive contains "account.json" with the config.Account, in the same format
+// as ConfigExportJSON (secrets are redacted), and the mailboxes in maildir format
+// under "messages/". To import the account, add the account to domains.conf with
+// the settings from account.json (e.g. through "mox config account add" and
+// editing the configuration), then import the messages with "mox import maildir"
+// for each mailbox.
+//
+// The messages are exported from a single read-only transaction on the account
+// database, giving a consistent view. Other accounts are not affected.
+func AccountExport(ctx context.Context, account string, w io.Writer) (rerr error) {
+	log := pkglog.WithContext(ctx)
+	defer func() {
+		if rerr != nil {
+			log.Errorx("exporting account", rerr)
+		}
+	}()
+
+	accConf, ok := mox.Conf.Account(account)
+	if !ok {
+		return requestErrorf(ErrCodeAccountNotFound, "account does not exist")
+	}
+	v, err := configJSONValue(reflect.ValueOf(accConf), "")
+	if err != nil {
+		return fmt.Errorf("preparing account config: %v", err)
+	}
+	accJSON, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return fmt.Errorf("marshal account config: %v", err)
+	}
+	accJSON = append(accJSON, '\n')
+
+	acc, err := store.OpenAccount(log, account, false)
+	if err != nil {
+		return fmt.Errorf("open account: %v", err)
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account after export")
+	}()
+
+	gzw := gzip.NewWriter(w)
+	archiver := store.TarArchiver{Writer: tar.NewWriter(gzw)}
+
+	f, err := archiver.Create("account.json", int64(len(accJSON)), time.Now())
+	if err != nil {
+		return fmt.Errorf("adding account config to archive: %v", err)
+	}
+	if _, err := f.Write(accJSON); err != nil {
+		return fmt.Errorf("writing account config to archive: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing account config in archive: %v", err)
+	}
+
+	if err := store.ExportMessages(ctx, log, acc.DB, acc.Dir, prefixArchiver{archiver, "messages/"}, true, "", nil, true); err != nil {
+		return fmt.Errorf("exporting messages: %v", err)
+	}
+
+	if err := archiver.Close(); err != nil {
+		return fmt.Errorf("closing tar: %v", err)
+	}
+	if err := gzw.Close(); err != nil {
+		return fmt.Errorf("closing gzip: %v", err)
+	}
+
+	log.Info("account exported", slog.String("account", account))
+	return nil
+}
+
+// prefixArchiver adds files to an archiver with a prefix prepended to their names.
+type prefixArchiver struct {
+	store.Archiver
+	prefix string
+}
+
+func (a prefixArchiver) Create(name string, size int64, mtime time.Time) (io.WriteCloser, error) {
+	return a.Archiver.Create(a.prefix+name, size, mtime)
+}
@@ -3,7 +3,9 @@ package admin
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
 	"crypto/ed25519"
+	"crypto/elliptic"
 	cryptorand "crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -15,25 +17,80 @@ import (
 	"maps"
 	"os"
 	"path/filepath"
+	"reflect"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mjl-/bstore"
 
 	"github.com/mjl-/mox/config"
 	"github.com/mjl-/mox/dns"
 	"github.com/mjl-/mox/junk"
 	"github.com/mjl-/mox/mlog"
 	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/moxio"
 	"github.com/mjl-/mox/mtasts"
 	"github.com/mjl-/mox/queue"
 	"github.com/mjl-/mox/smtp"
 	"github.com/mjl-/mox/store"
+	"github.com/mjl-/mox/webapi"
 )
 
 var pkglog = mlog.New("admin", nil)
 
 var ErrRequest = errors.New("bad request")
 
+// Codes for RequestError, for mapping errors to fields in user interfaces.
+const (
+	ErrCodeInvalid          = "invalid" // Malformed parameter, e.g. an address or selector.
+	ErrCodeDomainExists     = "domain-exists"
+	ErrCodeDomainNotFound   = "domain-not-found"
+	ErrCodeAccountExists    = "account-exists"
+	ErrCodeAccountNotFound  = "account-not-found"
+	ErrCodeSelectorExists   = "selector-exists"
+	ErrCodeSelectorNotFound = "selector-not-found"
+	ErrCodeAddressInUse     = "address-in-use"
+	ErrCodeAddressNotFound  = "address-not-found"
+	ErrCodeAliasExists      = "alias-exists"
+	ErrCodeAliasNotFound    = "alias-not-found"
+	ErrCodeExists           = "exists"    // Other object already exists, e.g. a tls public key or suppression.
+	ErrCodeNotFound         = "not-found" // Other object does not exist, e.g. a listener or ruleset.
+
+	// Data of an account is still present on disk, e.g. an account directory of a
+	// removed account. Not the same as ErrCodeAccountExists, the account isn't in the
+	// config.
+	ErrCodeAccountDataExists = "account-data-exists"
+
+	// Request conflicts with current state, e.g. a domain that is still referenced or
+	// messages in the queue.
+	ErrCodeConflict = "conflict"
+)
+
+// RequestError is an ErrRequest with a machine-readable code, one of the ErrCode*
+// constants, so callers can tell which parameter was the problem. Use errors.As
+// to get the code. RequestError matches ErrRequest with errors.Is.
+type RequestError struct {
+	Code    string
+	Message string
+}
+
+func (e RequestError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrRequest, e.Message)
+}
+
+func (e RequestError) Unwrap() error {
+	return ErrRequest
+}
+
+// requestErrorf returns a RequestError with code and a formatted message.
+func requestErrorf(code, format string, args ...any) error {
+	return RequestError{code, fmt.Sprintf(format, args...)}
+}
+
 // MakeDKIMEd25519Key returns a PEM buffer containing an ed25519 key for use
 // with DKIM.
 // selector and domain can be empty. If not, they are used in the note.
@@ -62,6 +119,35 @@ func MakeDKIMEd25519Key(selector, domain dns.Domain) ([]byte, error) {
 	return b.Bytes(), nil
 }
 
+// MakeDKIMECDSAKey returns a PEM buffer containing an ecdsa key with curve P-256
+// for use with DKIM. ECDSA is not standardized for DKIM, only rsa and ed25519
+// are, so signatures are only verified by parties that have implemented it.
+// selector and domain can be empty. If not, they are used in the note.
+func MakeDKIMECDSAKey(selector, domain dns.Domain) ([]byte, error) {
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generating key: %w", err)
+	}
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(privKey)
+	if err != nil {
+		return nil, fmt.Errorf("marshal key: %w", err)
+	}
+
+	block := &pem.Block{
+		Type: "PRIVATE KEY",
+		Headers: map[string]string{
+			"Note": dkimKeyNote("ecdsa-p256", selector, domain),
+		},
+		Bytes: pkcs8,
+	}
+	b := &bytes.Buffer{}
+	if err := pem.Encode(b, block); err != nil {
+		return nil, fmt.Errorf("encoding pem: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
 func dkimKeyNote(kind string, selector, domain dns.Domain) string {
 	s := kind + " dkim private key"
 	var zero dns.Domain
@@ -72,13 +158,64 @@ func dkimKeyNote(kind string, selector, domain dns.Domain) string {
 	return s
 }
 
-// MakeDKIMRSAKey returns a PEM buffer containing an rsa key for use with
+// ParseDKIMKeyNote parses the "Note" header in a PEM-encoded DKIM private key as
+// generated by mox, returning the kind of key (e.g. "rsa-2048", "ed25519"), when
+// it was generated, and the selector and domain if they were included. The key
+// itself is not parsed.
+func ParseDKIMKeyNote(pemData []byte) (kind string, generatedAt time.Time, selector, domain string, rerr error) {
+	p, _ := pem.Decode(pemData)
+	if p == nil {
+		return "", time.Time{}, "", "", requestErrorf(ErrCodeInvalid, "no pem block")
+	}
+	note, ok := p.Headers["Note"]
+	if !ok {
+		return "", time.Time{}, "", "", requestErrorf(ErrCodeInvalid, "no note header in pem block")
+	}
+	s, ts, ok := strings.Cut(note, ", generated by mox on ")
+	if !ok {
+		return "", time.Time{}, "", "", requestErrorf(ErrCodeInvalid, "note %q not generated by mox", note)
+	}
+	generatedAt, err := time.Parse(time.RFC3339, ts)
+	if err != nil {
+		return "", time.Time{}, "", "", requestErrorf(ErrCodeInvalid, "parsing time in note: %v", err)
+	}
+	kind, rest, ok := strings.Cut(s, " dkim private key")
+	if !ok || kind == "" {
+		return "", time.Time{}, "", "", requestErrorf(ErrCodeInvalid, "no key kind in note %q", note)
+	}
+	if rest != "" {
+		name, ok := strings.CutPrefix(rest, " for ")
+		if ok {
+			selector, domain, ok = strings.Cut(name, "._domainkey.")
+		}
+		if !ok || selector == "" || domain == "" {
+			return "", time.Time{}, "", "", requestErrorf(ErrCodeInvalid, "malformed selector and domain in note %q", note)
+		}
+	}
+	return kind, generatedAt, selector, domain, nil
+}
+
+// MakeDKIMRSAKey returns a PEM buffer containing a 2048 bit rsa key for use with
 // DKIM.
 // selector and domain can be empty. If not, they are used in the note.
 func MakeDKIMRSAKey(selector, domain dns.Domain) ([]byte, error) {
 	// 2048 bits seems reasonable in 2022, 1024 is on the low side, larger
 	// keys may not fit in UDP DNS response.
-	privKey, err := rsa.GenerateKey(cryptorand.Reader, 2048)
+	return MakeDKIMRSAKeyBits(selector, domain, 2048)
+}
+
+// MakeDKIMRSAKeyBits is like MakeDKIMRSAKey, but generates a key of bits in
+// size, which must be one of 1024, 2048, 3072 or 4096. Records for keys larger
+// than 2048 bits may not fit in a UDP DNS response, and 1024 bit keys are
+// considered weak.
+func MakeDKIMRSAKeyBits(selector, domain dns.Domain, bits int) ([]byte, error) {
+	switch bits {
+	case 1024, 2048, 3072, 4096:
+	default:
+		return nil, fmt.Errorf("rsa key size must be 1024, 2048, 3072 or 4096 bits, not %d", bits)
+	}
+
+	privKey, err := rsa.GenerateKey(cryptorand.Reader, bits)
 	if err != nil {
 		return nil, fmt.Errorf("generating key: %w", err)
 	}
@@ -91,7 +228,7 @@ func MakeDKIMRSAKey(selector, domain dns.Domain) ([]byte, error) {
 	block := &pem.Block{
 		Type: "PRIVATE KEY",
 		Headers: map[string]string{
-			"Note": dkimKeyNote("rsa-2048", selector, domain),
+			"Note": dkimKeyNote(fmt.Sprintf("rsa-%d", bits), selector, domain),
 		},
 		Bytes: pkcs8,
 	}
@@ -102,8 +239,32 @@ func MakeDKIMRSAKey(selector, domain dns.Domain) ([]byte, error) {
 	return b.Bytes(), nil
 }
 
-// MakeAccountConfig returns a new account configuration for an email address.
+// AccountTemplate holds defaults for new accounts, as created by
+// MakeAccountConfigWith. Fields that are nil get the built-in defaults of
+// MakeAccountConfig.
+type AccountTemplate struct {
+	RejectsMailbox     *string // Empty string disables the rejects mailbox.
+	JunkFilter         *config.JunkFilter
+	AutomaticJunkFlags *config.AutomaticJunkFlags
+	SubjectPass        *config.SubjectPass
+	NoCustomPassword   *bool
+}
+
+// NewAccountTemplate is the template used by MakeAccountConfig, and so for
+// accounts created through DomainAdd and AccountAdd. It should be set during
+// startup, before accounts are created.
+var NewAccountTemplate AccountTemplate
+
+// MakeAccountConfig returns a new account configuration for an email address,
+// with defaults from NewAccountTemplate.
 func MakeAccountConfig(addr smtp.Address) config.Account {
+	return MakeAccountConfigWith(addr, NewAccountTemplate)
+}
+
+// MakeAccountConfigWith returns a new account configuration for an email address,
+// with defaults from tmpl, falling back to the built-in defaults for fields not
+// set in tmpl.
+func MakeAccountConfigWith(addr smtp.Address, tmpl AccountTemplate) config.Account {
 	account := config.Account{
 		Domain: addr.Domain.Name(),
 		Destinations: map[string]config.Destination{
@@ -126,9 +287,30 @@ func MakeAccountConfig(addr smtp.Address) config.Account {
 	account.AutomaticJunkFlags.JunkMailboxRegexp = "^(junk|spam)"
 	account.AutomaticJunkFlags.NeutralMailboxRegexp = "^(inbox|neutral|postmaster|dmarc|tlsrpt|rejects)"
 	account.SubjectPass.Period = 12 * time.Hour
+
+	if tmpl.RejectsMailbox != nil {
+		account.RejectsMailbox = *tmpl.RejectsMailbox
+	}
+	if tmpl.JunkFilter != nil {
+		// Copy, accounts must not share the junk filter config.
+		jf := *tmpl.JunkFilter
+		account.JunkFilter = &jf
+	}
+	if tmpl.AutomaticJunkFlags != nil {
+		account.AutomaticJunkFlags = *tmpl.AutomaticJunkFlags
+	}
+	if tmpl.SubjectPass != nil {
+		account.SubjectPass = *tmpl.SubjectPass
+	}
+	if tmpl.NoCustomPassword != nil {
+		account.NoCustomPassword = *tmpl.NoCustomPassword
+	}
 	return account
 }
 
+// writeFile creates a new file at path with data. The file and its directory are
+// synced to disk before returning, so files referenced from the config, like
+// private keys, are present after a crash.
 func writeFile(log mlog.Log, path string, data []byte) error {
 	os.MkdirAll(filepath.Dir(path), 0770)
 
@@ -147,18 +329,101 @@ func writeFile(log mlog.Log, path string, data []byte) error {
 	if _, err := f.Write(data); err != nil {
 		return fmt.Errorf("writing file %s: %s", path, err)
 	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("sync file %s: %v", path, err)
+	}
 	if err := f.Close(); err != nil {
 		return fmt.Errorf("close file: %v", err)
 	}
 	f = nil
+	if err := moxio.SyncDir(log, filepath.Dir(path)); err != nil {
+		xerr := os.Remove(path)
+		log.Check(xerr, "removing file after error", slog.String("path", path))
+		return fmt.Errorf("sync directory of %s: %v", path, err)
+	}
+	return nil
+}
+
+// diskSpaceMinimum is the free disk space required in the config directory before
+// writing DKIM key files. The key files are small, this only catches full disks.
+const diskSpaceMinimum = 1024 * 1024
+
+// diskAvailable returns the available space for the file system holding dir,
+// and whether it is known. Variable for tests.
+var diskAvailable = diskAvailableBytes
+
+// checkDiskSpace returns an ErrRequest if the file system holding dir does not
+// have enough space for key files. If the available space cannot be determined,
+// no error is returned and writing the files will fail if the disk is full.
+func checkDiskSpace(log mlog.Log, dir string) error {
+	avail, known, err := diskAvailable(dir)
+	if err != nil {
+		log.Debugx("checking available disk space", err, slog.String("dir", dir))
+		return nil
+	} else if !known {
+		return nil
+	} else if avail < diskSpaceMinimum {
+		return requestErrorf(ErrCodeConflict, "insufficient disk space for key files, %d bytes available in %s", avail, dir)
+	}
 	return nil
 }
 
+// MakeDomainConfigOpts holds optional settings for MakeDomainConfig. Empty
+// fields get default values.
+type MakeDomainConfigOpts struct {
+	DMARCLocalpart  string // Default "dmarcreports".
+	DMARCMailbox    string // Default "DMARC".
+	TLSRPTLocalpart string // Default "tlsreports".
+	TLSRPTMailbox   string // Default "TLSRPT".
+
+	// If set, no DKIM keys are generated and the domain gets an empty DKIM config.
+	// Messages can still be received for the domain. Cannot be combined with
+	// dkimDualSign.
+	NoDKIM bool
+}
+
 // MakeDomainConfig makes a new config for a domain, creating DKIM keys, using
-// accountName for DMARC and TLS reports.
-func MakeDomainConfig(ctx context.Context, domain, hostname dns.Domain, accountName string, withMTASTS bool) (config.Domain, []string, error) {
+// accountName for DMARC and TLS reports, with addresses and mailboxes from opts.
+//
+// By default, two RSA DKIM keys are created, and messages are signed with the
+// first. With dkimDualSign, an ed25519 and an RSA key are created, and messages
+// are signed with both: verifiers that support ed25519 can use that signature,
+// others the RSA signature. With opts.NoDKIM, no keys are created.
+func MakeDomainConfig(ctx context.Context, domain, hostname dns.Domain, accountName string, withMTASTS, dkimDualSign bool, opts MakeDomainConfigOpts) (config.Domain, []string, error) {
 	log := pkglog.WithContext(ctx)
 
+	if opts.NoDKIM && dkimDualSign {
+		return config.Domain{}, nil, requestErrorf(ErrCodeInvalid, "cannot skip dkim keys and dual sign")
+	}
+
+	reportAddress := func(kind, localpart, deflocalpart, mailbox, defmailbox string) (string, string, error) {
+		if localpart == "" {
+			localpart = deflocalpart
+		} else if _, err := smtp.ParseLocalpart(localpart); err != nil {
+			return "", "", requestErrorf(ErrCodeInvalid, "invalid %s localpart %q: %v", kind, localpart, err)
+		}
+		if mailbox == "" {
+			mailbox = defmailbox
+		} else if _, _, err := store.CheckMailboxName(mailbox, true); err != nil {
+			return "", "", requestErrorf(ErrCodeInvalid, "invalid %s mailbox %q: %v", kind, mailbox, err)
+		}
+		return localpart, mailbox, nil
+	}
+	dmarcLocalpart, dmarcMailbox, err := reportAddress("dmarc", opts.DMARCLocalpart, "dmarcreports", opts.DMARCMailbox, "DMARC")
+	if err != nil {
+		return config.Domain{}, nil, err
+	}
+	tlsrptLocalpart, tlsrptMailbox, err := reportAddress("tlsrpt", opts.TLSRPTLocalpart, "tlsreports", opts.TLSRPTMailbox, "TLSRPT")
+	if err != nil {
+		return config.Domain{}, nil, err
+	}
+
+	if !opts.NoDKIM {
+		if err := checkDiskSpace(log, filepath.Dir(mox.ConfigDynamicPath)); err != nil {
+			return config.Domain{}, nil, err
+		}
+	}
+
 	now := time.Now()
 	year := now.Format("2006")
 	timestamp := now.Format("20060102T150405")
@@ -175,9 +440,15 @@ func MakeDomainConfig(ctx context.Context, domain, hostname dns.Domain, accountN
 		Selectors: map[string]config.Selector{},
 	}
 
+	// Generating keys can take a while, so we check if we should stop before
+	// generating and writing keys. Files already written are removed by the deferred
+	// cleanup.
 	addSelector := func(kind, name string, privKey []byte) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		record := fmt.Sprintf("%s._domainkey.%s", name, domain.ASCII)
-		keyPath := filepath.Join("dkim", fmt.Sprintf("%s.%s.%s.privatekey.pkcs8.pem", record, timestamp, kind))
+		keyPath := dkimKeyPath(record, timestamp, kind)
 		p := mox.ConfigDynamicDirPath(keyPath)
 		if err := writeFile(log, p, privKey); err != nil {
 			return err
@@ -187,13 +458,16 @@ func MakeDomainConfig(ctx context.Context, domain, hostname dns.Domain, accountN
 			// Example from RFC has 5 day between signing and expiration. ../rfc/6376:1393
 			// Expiration is not intended as antireplay defense, but it may help. ../rfc/6376:1340
 			// Messages in the wild have been observed with 2 hours and 1 year expiration.
-			Expiration:     "72h",
+			Expiration:     dkimExpiration(DKIMLifetimeDefault),
 			PrivateKeyFile: keyPath,
 		}
 		return nil
 	}
 
 	addRSA := func(name string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		key, err := MakeDKIMRSAKey(dns.Domain{ASCII: name}, domain)
 		if err != nil {
 			return fmt.Errorf("making dkim rsa private key: %s", err)
@@ -201,17 +475,37 @@ func MakeDomainConfig(ctx context.Context, domain, hostname dns.Domain, accountN
 		return addSelector("rsa2048", name, key)
 	}
 
-	if err := addRSA(year + "a"); err != nil {
-		return config.Domain{}, nil, err
-	}
-	if err := addRSA(year + "b"); err != nil {
-		return config.Domain{}, nil, err
-	}
+	switch {
+	case opts.NoDKIM:
+		// No keys. Messages from the domain are not signed until selectors are added,
+		// e.g. with DKIMAdd.
+	case dkimDualSign:
+		key, err := MakeDKIMEd25519Key(dns.Domain{ASCII: year + "a"}, domain)
+		if err != nil {
+			return config.Domain{}, nil, fmt.Errorf("making dkim ed25519 private key: %s", err)
+		}
+		if err := addSelector("ed25519", year+"a", key); err != nil {
+			return config.Domain{}, nil, err
+		}
+		if err := addRSA(year + "b"); err != nil {
+			return config.Domain{}, nil, err
+		}
 
-	// We sign with the first two. In case they are misused, the switch to the other
-	// keys is easy, just change the config. Operators should make the public key field
-	// of the misused keys empty in the DNS records to disable the misused keys.
-	confDKIM.Sign = []string{year + "a"}
+		// Both signatures are added to messages.
+		confDKIM.Sign = []string{year + "a", year + "b"}
+	default:
+		if err := addRSA(year + "a"); err != nil {
+			return config.Domain{}, nil, err
+		}
+		if err := addRSA(year + "b"); err != nil {
+			return config.Domain{}, nil, err
+		}
+
+		// We sign with the first two. In case they are misused, the switch to the other
+		// keys is easy, just change the config. Operators should make the public key field
+		// of the misused keys empty in the DNS records to disable the misused keys.
+		confDKIM.Sign = []string{year + "a"}
+	}
 
 	confDomain := config.Domain{
 		ClientSettingsDomain:       "mail." + domain.Name(),
@@ -219,20 +513,22 @@ func MakeDomainConfig(ctx context.Context, domain, hostname dns.Domain, accountN
 		DKIM:                       confDKIM,
 		DMARC: &config.DMARC{
 			Account:   accountName,
-			Localpart: "dmarcreports",
-			Mailbox:   "DMARC",
+			Localpart: dmarcLocalpart,
+			Mailbox:   dmarcMailbox,
 		},
 		TLSRPT: &config.TLSRPT{
 			Account:   accountName,
-			Localpart: "tlsreports",
-			Mailbox:   "TLSRPT",
+			Localpart: tlsrptLocalpart,
+			Mailbox:   tlsrptMailbox,
 		},
 	}
 
 	if withMTASTS {
 		confDomain.MTASTS = &config.MTASTS{
-			PolicyID: time.Now().UTC().Format("20060102T150405"),
-			Mode:     mtasts.ModeEnforce,
+			PolicyID: mtastsPolicyID(""),
+			// We start out in testing mode. Once TLS reports show deliveries succeed, the
+			// mode should be changed to enforce, see MTASTSModeSave.
+			Mode: mtasts.ModeTesting,
 			// We start out with 24 hour, and warn in the admin interface that users should
 			// increase it to weeks once the setup works.
 			MaxAge: 24 * time.Hour,
@@ -246,8 +542,215 @@ func MakeDomainConfig(ctx context.Context, domain, hostname dns.Domain, accountN
 	return confDomain, rpaths, nil
 }
 
+// makeDomainConfigLike makes a new config for domain with the settings of
+// template. For each DKIM selector of template, a new key of the same type is
+// generated, with the same selector name and signing parameters. Reports for
+// addresses in the new domain are delivered to accountName. Aliases are not
+// copied.
+func makeDomainConfigLike(ctx context.Context, domain dns.Domain, accountName string, template config.Domain) (config.Domain, []string, error) {
+	log := pkglog.WithContext(ctx)
+
+	timestamp := time.Now().Format("20060102T150405")
+
+	var paths []string
+	defer func() {
+		for _, p := range paths {
+			err := os.Remove(p)
+			log.Check(err, "removing path for domain config", slog.String("path", p))
+		}
+	}()
+
+	confDKIM := config.DKIM{
+		Selectors: map[string]config.Selector{},
+		Sign:      slices.Clone(template.DKIM.Sign),
+	}
+	for name, sel := range template.DKIM.Selectors {
+		if err := ctx.Err(); err != nil {
+			return config.Domain{}, nil, err
+		}
+
+		var algorithm string
+		var bits int
+		switch {
+		case sel.Algorithm == "ed25519":
+			algorithm = "ed25519"
+		case sel.Algorithm == "ecdsa-p256":
+			algorithm = "ecdsa"
+		case strings.HasPrefix(sel.Algorithm, "rsa-"):
+			algorithm = "rsa"
+			var err error
+			bits, err = strconv.Atoi(strings.TrimPrefix(sel.Algorithm, "rsa-"))
+			if err != nil {
+				return config.Domain{}, nil, fmt.Errorf("parsing rsa key size for selector %q: %v", name, err)
+			}
+		default:
+			return config.Domain{}, nil, fmt.Errorf("unknown algorithm %q for selector %q", sel.Algorithm, name)
+		}
+
+		selector := dns.Domain{ASCII: name}
+		if err := checkDKIMSelector(selector, domain); err != nil {
+			return config.Domain{}, nil, requestErrorf(ErrCodeInvalid, "selector %q for new domain: %v", name, err)
+		}
+		privKey, kind, err := dkimMakeKey(selector, domain, algorithm, bits)
+		if err != nil {
+			return config.Domain{}, nil, fmt.Errorf("making dkim key for selector %q: %v", name, err)
+		}
+		record := fmt.Sprintf("%s._domainkey.%s", name, domain.ASCII)
+		keyPath := dkimKeyPath(record, timestamp, kind)
+		p := mox.ConfigDynamicDirPath(keyPath)
+		if err := writeFile(log, p, privKey); err != nil {
+			return config.Domain{}, nil, err
+		}
+		paths = append(paths, p)
+		confDKIM.Selectors[name] = config.Selector{
+			Hash:             sel.Hash,
+			Canonicalization: sel.Canonicalization,
+			Headers:          slices.Clone(sel.Headers),
+			DontSealHeaders:  sel.DontSealHeaders,
+			Expiration:       sel.Expiration,
+			PrivateKeyFile:   keyPath,
+		}
+	}
+
+	confDomain := config.Domain{
+		LocalpartCatchallSeparator:  template.LocalpartCatchallSeparator,
+		LocalpartCatchallSeparators: slices.Clone(template.LocalpartCatchallSeparators),
+		LocalpartCaseSensitive:      template.LocalpartCaseSensitive,
+		DKIM:                        confDKIM,
+		Routes:                      slices.Clone(template.Routes),
+		Footer:                      template.Footer,
+		DNSBLs:                      slices.Clone(template.DNSBLs),
+	}
+	if template.ClientSettingsDomain != "" {
+		confDomain.ClientSettingsDomain = "mail." + domain.Name()
+	}
+
+	// Reporting addresses without explicit domain are in the new domain, and are
+	// delivered to the account for the new domain.
+	if template.DMARC != nil {
+		dmarc := *template.DMARC
+		if dmarc.Domain == "" {
+			dmarc.Account = accountName
+		}
+		confDomain.DMARC = &dmarc
+	}
+	if template.TLSRPT != nil {
+		tlsrpt := *template.TLSRPT
+		if tlsrpt.Domain == "" {
+			tlsrpt.Account = accountName
+		}
+		confDomain.TLSRPT = &tlsrpt
+	}
+	if template.MTASTS != nil {
+		mtastsConf := *template.MTASTS
+		mtastsConf.PolicyID = mtastsPolicyID("")
+		mtastsConf.MX = slices.Clone(template.MTASTS.MX)
+		confDomain.MTASTS = &mtastsConf
+	}
+
+	rpaths := paths
+	paths = nil
+
+	return confDomain, rpaths, nil
+}
+
+// checkDKIMSelector checks that selector consists of valid DNS labels, and that
+// the DNS name for its DKIM record for domain is not too long.
+func checkDKIMSelector(selector, domain dns.Domain) error {
+	if selector.ASCII == "" {
+		return errors.New("empty selector")
+	}
+	for _, label := range strings.Split(selector.ASCII, ".") {
+		if label == "" {
+			return errors.New("empty label in selector")
+		} else if len(label) > 63 {
+			return fmt.Errorf("label %q in selector longer than 63 characters", label)
+		} else if strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return fmt.Errorf("label %q in selector starts or ends with hyphen", label)
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return fmt.Errorf("label %q in selector contains invalid character %q", label, c)
+			}
+		}
+	}
+	record := fmt.Sprintf("%s._domainkey.%s", selector.ASCII, domain.ASCII)
+	if len(record) > 253 {
+		return fmt.Errorf("dns name for dkim record %q longer than 253 characters", record)
+	}
+	return nil
+}
+
+// checkDKIMHeaders checks a list of header fields to sign with DKIM: names must
+// be valid header field names without duplicates, and "From" must be present. An
+// empty list is valid, the default headers are signed.
+func checkDKIMHeaders(headers []string) error {
+	if len(headers) == 0 {
+		return nil
+	}
+	seen := map[string]bool{}
+	for _, h := range headers {
+		if h == "" {
+			return errors.New("empty header field name")
+		}
+		// ../rfc/5322:1689, semicolon is not allowed in DKIM h= tag ../rfc/6376:643
+		for _, c := range h {
+			if c <= ' ' || c >= 0x7f || c == ':' || c == ';' {
+				return fmt.Errorf("header field name %q contains invalid character %q", h, c)
+			}
+		}
+		lh := strings.ToLower(h)
+		if seen[lh] {
+			return fmt.Errorf("duplicate header field name %q", h)
+		}
+		seen[lh] = true
+	}
+	if !seen["from"] {
+		return errors.New("header field From must always be signed")
+	}
+	return nil
+}
+
+// Bounds for the lifetime of DKIM signatures, i.e. the time between signing and
+// expiration, and the default lifetime for new selectors.
+const (
+	DKIMLifetimeMin     = 5 * time.Minute
+	DKIMLifetimeMax     = 365 * 24 * time.Hour
+	DKIMLifetimeDefault = 72 * time.Hour
+)
+
+// checkDKIMLifetime checks a signature lifetime is 0, for no expiration, or
+// between DKIMLifetimeMin and DKIMLifetimeMax. Very short lifetimes cause
+// signatures to expire before delivery, very long lifetimes defeat the purpose.
+func checkDKIMLifetime(lifetime time.Duration) error {
+	if lifetime != 0 && (lifetime < DKIMLifetimeMin || lifetime > DKIMLifetimeMax) {
+		return requestErrorf(ErrCodeInvalid, "signature lifetime must be 0 for no expiration, or between %v and %v", DKIMLifetimeMin, DKIMLifetimeMax)
+	}
+	return nil
+}
+
+// dkimExpiration returns the Expiration value for a selector with a signature
+// lifetime, empty for no expiration.
+func dkimExpiration(lifetime time.Duration) string {
+	if lifetime == 0 {
+		return ""
+	}
+	s := lifetime.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
 // DKIMAdd adds a DKIM selector for a domain, generating a key and writing it to disk.
-func DKIMAdd(ctx context.Context, domain, selector dns.Domain, algorithm, hash string, headerRelaxed, bodyRelaxed, seal bool, headers []string, lifetime time.Duration) (rerr error) {
+// Algorithm is "rsa", "ed25519" or "ecdsa". For rsa, bits is the key size, with 0
+// for the default of 2048 bits. For other algorithms, bits must be 0. Lifetime is
+// 0 for signatures without expiration, or between DKIMLifetimeMin and
+// DKIMLifetimeMax.
+func DKIMAdd(ctx context.Context, domain, selector dns.Domain, algorithm string, bits int, hash string, headerRelaxed, bodyRelaxed, seal bool, headers []string, lifetime time.Duration) (rerr error) {
 	log := pkglog.WithContext(ctx)
 	defer func() {
 		if rerr != nil {
@@ -257,27 +760,37 @@ func DKIMAdd(ctx context.Context, domain, selector dns.Domain, algorithm, hash s
 		}
 	}()
 
+	if err := checkDKIMSelector(selector, domain); err != nil {
+		return requestErrorf(ErrCodeInvalid, "invalid selector: %v", err)
+	}
+	if err := checkDKIMHeaders(headers); err != nil {
+		return requestErrorf(ErrCodeInvalid, "invalid headers: %v", err)
+	}
+
 	switch hash {
 	case "sha256", "sha1":
 	default:
-		return fmt.Errorf("%w: unknown hash algorithm %q", ErrRequest, hash)
+		return requestErrorf(ErrCodeInvalid, "unknown hash algorithm %q", hash)
 	}
 
-	var privKey []byte
-	var err error
-	var kind string
-	switch algorithm {
-	case "rsa":
-		privKey, err = MakeDKIMRSAKey(selector, domain)
-		kind = "rsa2048"
-	case "ed25519":
-		privKey, err = MakeDKIMEd25519Key(selector, domain)
-		kind = "ed25519"
-	default:
-		err = fmt.Errorf("unknown algorithm")
+	if bits != 0 && algorithm != "rsa" {
+		return requestErrorf(ErrCodeInvalid, "key size can only be specified for rsa keys")
+	}
+	if err := checkDKIMLifetime(lifetime); err != nil {
+		return err
+	}
+
+	// Check for conflicts before spending time generating a key. Checked again
+	// below with the lock held, the config may have changed in the mean time.
+	if d, ok := mox.Conf.Domain(domain); !ok {
+		return requestErrorf(ErrCodeDomainNotFound, "domain does not exist")
+	} else if _, ok := d.DKIM.Selectors[selector.Name()]; ok {
+		return requestErrorf(ErrCodeSelectorExists, "selector already exists for domain")
 	}
+
+	privKey, kind, err := dkimMakeKey(selector, domain, algorithm, bits)
 	if err != nil {
-		return fmt.Errorf("%w: making dkim key: %v", ErrRequest, err)
+		return requestErrorf(ErrCodeInvalid, "making dkim key: %v", err)
 	}
 
 	// Only take lock now, we don't want to hold it while generating a key.
@@ -286,16 +799,16 @@ func DKIMAdd(ctx context.Context, domain, selector dns.Domain, algorithm, hash s
 	c := mox.Conf.Dynamic
 	d, ok := c.Domains[domain.Name()]
 	if !ok {
-		return fmt.Errorf("%w: domain does not exist", ErrRequest)
+		return requestErrorf(ErrCodeDomainNotFound, "domain does not exist")
 	}
 
 	if _, ok := d.DKIM.Selectors[selector.Name()]; ok {
-		return fmt.Errorf("%w: selector already exists for domain", ErrRequest)
+		return requestErrorf(ErrCodeSelectorExists, "selector already exists for domain")
 	}
 
 	record := fmt.Sprintf("%s._domainkey.%s", selector.ASCII, domain.ASCII)
 	timestamp := time.Now().Format("20060102T150405")
-	keyPath := filepath.Join("dkim", fmt.Sprintf("%s.%s.%s.privatekey.pkcs8.pem", record, timestamp, kind))
+	keyPath := dkimKeyPath(record, timestamp, kind)
 	p := mox.ConfigDynamicDirPath(keyPath)
 	if err := writeFile(log, p, privKey); err != nil {
 		return fmt.Errorf("writing key file: %v", err)
@@ -316,7 +829,7 @@ func DKIMAdd(ctx context.Context, domain, selector dns.Domain, algorithm, hash s
 		},
 		Headers:         headers,
 		DontSealHeaders: !seal,
-		Expiration:      lifetime.String(),
+		Expiration:      dkimExpiration(lifetime),
 		PrivateKeyFile:  keyPath,
 	}
 
@@ -339,6 +852,121 @@ func DKIMAdd(ctx context.Context, domain, selector dns.Domain, algorithm, hash s
 	return nil
 }
 
+// dkimMakeKey generates a DKIM private key for DKIMAdd. Variable for tests.
+var dkimMakeKey = makeDKIMKey
+
+// makeDKIMKey generates a private key for algorithm, returning the PEM-encoded
+// key and the kind of key for use in the file name.
+func makeDKIMKey(selector, domain dns.Domain, algorithm string, bits int) (privKey []byte, kind string, err error) {
+	switch algorithm {
+	case "rsa":
+		if bits == 0 {
+			bits = 2048
+		}
+		privKey, err = MakeDKIMRSAKeyBits(selector, domain, bits)
+		kind = fmt.Sprintf("rsa%d", bits)
+	case "ed25519":
+		privKey, err = MakeDKIMEd25519Key(selector, domain)
+		kind = "ed25519"
+	case "ecdsa":
+		privKey, err = MakeDKIMECDSAKey(selector, domain)
+		kind = "ecdsa-p256"
+	default:
+		err = fmt.Errorf("unknown algorithm")
+	}
+	return
+}
+
+// DKIMParams are the signing parameters of a DKIM selector, as for DKIMAdd.
+type DKIMParams struct {
+	Hash          string // "sha256" or "sha1".
+	HeaderRelaxed bool
+	BodyRelaxed   bool
+	Seal          bool          // Sign headers also when they are absent, preventing their addition.
+	Headers       []string      // Header fields to sign. If empty, the default headers are signed.
+	Lifetime      time.Duration // If > 0, signatures expire after this duration. See DKIMAdd.
+}
+
+// DKIMSelectorUpdate changes the signing parameters of an existing DKIM selector
+// for a domain. The private key is kept.
+func DKIMSelectorUpdate(ctx context.Context, domain, selector dns.Domain, params DKIMParams) error {
+	if err := checkDKIMHeaders(params.Headers); err != nil {
+		return requestErrorf(ErrCodeInvalid, "invalid headers: %v", err)
+	}
+	switch params.Hash {
+	case "sha256", "sha1":
+	default:
+		return requestErrorf(ErrCodeInvalid, "unknown hash algorithm %q", params.Hash)
+	}
+	if err := checkDKIMLifetime(params.Lifetime); err != nil {
+		return err
+	}
+
+	return DomainSave(ctx, domain.Name(), func(d *config.Domain) error {
+		osel, ok := d.DKIM.Selectors[selector.Name()]
+		if !ok {
+			return requestErrorf(ErrCodeSelectorNotFound, "selector does not exist for domain")
+		}
+		nsel := config.Selector{
+			Hash: params.Hash,
+			Canonicalization: config.Canonicalization{
+				HeaderRelaxed: params.HeaderRelaxed,
+				BodyRelaxed:   params.BodyRelaxed,
+			},
+			Headers:         params.Headers,
+			DontSealHeaders: !params.Seal,
+			Expiration:      dkimExpiration(params.Lifetime),
+			PrivateKeyFile:  osel.PrivateKeyFile,
+			Disabled:        osel.Disabled,
+		}
+		d.DKIM.Selectors = maps.Clone(d.DKIM.Selectors)
+		d.DKIM.Selectors[selector.Name()] = nsel
+		return nil
+	})
+}
+
+// DKIMSignSelectorsSave sets the selectors that messages from the domain are
+// signed with to exactly selectors, e.g. to start signing with a selector added
+// with DKIMAdd once its DNS record has been published. All selectors must exist
+// for the domain. An empty list stops DKIM signing for the domain.
+func DKIMSignSelectorsSave(ctx context.Context, domain dns.Domain, selectors []string) error {
+	return DomainSave(ctx, domain.Name(), func(d *config.Domain) error {
+		seen := map[string]bool{}
+		for _, sel := range selectors {
+			if _, ok := d.DKIM.Selectors[sel]; !ok {
+				return requestErrorf(ErrCodeSelectorNotFound, "selector %q does not exist for domain", sel)
+			}
+			if seen[sel] {
+				return requestErrorf(ErrCodeInvalid, "duplicate selector %q", sel)
+			}
+			seen[sel] = true
+		}
+		if len(selectors) == 0 {
+			d.DKIM.Sign = nil
+		} else {
+			d.DKIM.Sign = slices.Clone(selectors)
+		}
+		return nil
+	})
+}
+
+// DKIMSelectorDisable disables or enables a DKIM selector of a domain. Messages
+// are not signed with a disabled selector, even if it is listed in the
+// selectors to sign with. The key is kept, so signing can be resumed by enabling
+// the selector again. For permanently stopping use of a key, use DKIMRemove.
+func DKIMSelectorDisable(ctx context.Context, domain, selector dns.Domain, disabled bool) error {
+	return DomainSave(ctx, domain.Name(), func(d *config.Domain) error {
+		sel, ok := d.DKIM.Selectors[selector.Name()]
+		if !ok {
+			return requestErrorf(ErrCodeSelectorNotFound, "selector does not exist for domain")
+		}
+		sel.Disabled = disabled
+		d.DKIM.Selectors = maps.Clone(d.DKIM.Selectors)
+		d.DKIM.Selectors[selector.Name()] = sel
+		return nil
+	})
+}
+
 // DKIMRemove removes the selector from the domain, moving the key file out of the way.
 func DKIMRemove(ctx context.Context, domain, selector dns.Domain) (rerr error) {
 	log := pkglog.WithContext(ctx)
@@ -355,12 +983,12 @@ func DKIMRemove(ctx context.Context, domain, selector dns.Domain) (rerr error) {
 	c := mox.Conf.Dynamic
 	d, ok := c.Domains[domain.Name()]
 	if !ok {
-		return fmt.Errorf("%w: domain does not exist", ErrRequest)
+		return requestErrorf(ErrCodeDomainNotFound, "domain does not exist")
 	}
 
 	sel, ok := d.DKIM.Selectors[selector.Name()]
 	if !ok {
-		return fmt.Errorf("%w: selector does not exist for domain", ErrRequest)
+		return requestErrorf(ErrCodeSelectorNotFound, "selector does not exist for domain")
 	}
 
 	nsels := map[string]config.Selector{}
@@ -396,29 +1024,212 @@ func DKIMRemove(ctx context.Context, domain, selector dns.Domain) (rerr error) {
 	return nil
 }
 
-// DomainAdd adds the domain to the domains config, rewriting domains.conf and
-// marking it loaded.
-//
-// accountName is used for DMARC/TLS report and potentially for the postmaster address.
-// If the account does not exist, it is created with localpart. Localpart must be
-// set only if the account does not yet exist.
-func DomainAdd(ctx context.Context, disabled bool, domain dns.Domain, accountName string, localpart smtp.Localpart) (rerr error) {
+// DKIMKeyRotate replaces the private key of an existing DKIM selector with a
+// freshly generated key of the same algorithm (and size for rsa). The hash,
+// canonicalization and other settings of the selector are kept. The old key file
+// is moved to subdirectory "old" if no longer used by other selectors. The DNS
+// record for the selector must be updated with the new public key.
+func DKIMKeyRotate(ctx context.Context, domain, selector dns.Domain) (rerr error) {
 	log := pkglog.WithContext(ctx)
 	defer func() {
 		if rerr != nil {
-			log.Errorx("adding domain", rerr,
-				slog.Any("disabled", disabled),
+			log.Errorx("rotating dkim key", rerr,
 				slog.Any("domain", domain),
-				slog.String("account", accountName),
-				slog.Any("localpart", localpart))
+				slog.Any("selector", selector))
+		}
+	}()
+
+	// Generate the new key before taking the lock.
+	domConf, ok := mox.Conf.Domain(domain)
+	if !ok {
+		return requestErrorf(ErrCodeDomainNotFound, "domain does not exist")
+	}
+	osel, ok := domConf.DKIM.Selectors[selector.Name()]
+	if !ok {
+		return requestErrorf(ErrCodeSelectorNotFound, "selector does not exist for domain")
+	}
+
+	var privKey []byte
+	var err error
+	var kind string
+	switch k := osel.Key.(type) {
+	case *rsa.PrivateKey:
+		bits := k.N.BitLen()
+		privKey, err = MakeDKIMRSAKeyBits(selector, domain, bits)
+		kind = fmt.Sprintf("rsa%d", bits)
+	case ed25519.PrivateKey:
+		privKey, err = MakeDKIMEd25519Key(selector, domain)
+		kind = "ed25519"
+	case *ecdsa.PrivateKey:
+		privKey, err = MakeDKIMECDSAKey(selector, domain)
+		kind = "ecdsa-p256"
+	default:
+		err = fmt.Errorf("unknown private key type %T", osel.Key)
+	}
+	if err != nil {
+		return requestErrorf(ErrCodeInvalid, "making dkim key: %v", err)
+	}
+
+	defer mox.Conf.DynamicLockUnlock()()
+
+	c := mox.Conf.Dynamic
+	d, ok := c.Domains[domain.Name()]
+	if !ok {
+		return requestErrorf(ErrCodeDomainNotFound, "domain does not exist")
+	}
+	sel, ok := d.DKIM.Selectors[selector.Name()]
+	if !ok {
+		return requestErrorf(ErrCodeSelectorNotFound, "selector does not exist for domain")
+	} else if sel.PrivateKeyFile != osel.PrivateKeyFile {
+		return requestErrorf(ErrCodeConflict, "selector changed while generating key, try again")
+	}
+
+	record := fmt.Sprintf("%s._domainkey.%s", selector.ASCII, domain.ASCII)
+	timestamp := time.Now().Format("20060102T150405")
+	keyPath := dkimKeyPath(record, timestamp, kind)
+	p := mox.ConfigDynamicDirPath(keyPath)
+	if err := writeFile(log, p, privKey); err != nil {
+		return fmt.Errorf("writing key file: %v", err)
+	}
+	removePath := p
+	defer func() {
+		if removePath != "" {
+			err := os.Remove(removePath)
+			log.Check(err, "removing path for dkim key", slog.String("path", removePath))
+		}
+	}()
+
+	nsel := sel
+	nsel.PrivateKeyFile = keyPath
+	nd := d
+	nd.DKIM.Selectors = map[string]config.Selector{}
+	maps.Copy(nd.DKIM.Selectors, d.DKIM.Selectors)
+	nd.DKIM.Selectors[selector.Name()] = nsel
+	nc := c
+	nc.Domains = map[string]config.Domain{}
+	maps.Copy(nc.Domains, c.Domains)
+	nc.Domains[domain.Name()] = nd
+
+	if err := mox.WriteDynamicLocked(ctx, log, nc); err != nil {
+		return fmt.Errorf("writing domains.conf: %w", err)
+	}
+	removePath = "" // Prevent cleanup of key file.
+
+	usedKeyPaths := gatherUsedKeysPaths(nc)
+	moveAwayKeys(log, map[string]config.Selector{selector.Name(): sel}, usedKeyPaths)
+
+	log.Info("dkim key rotated", slog.Any("domain", domain), slog.Any("selector", selector))
+	return nil
+}
+
+// DomainAdd adds the domain to the domains config, rewriting domains.conf and
+// marking it loaded.
+//
+// accountName is used for DMARC/TLS report and potentially for the postmaster address.
+// If the account does not exist, it is created with localpart. Localpart must be
+// set only if the account does not yet exist.
+func DomainAdd(ctx context.Context, disabled bool, domain dns.Domain, accountName string, localpart smtp.Localpart) (rerr error) {
+	return DomainAddMulti(ctx, []DomainAddSpec{{Disabled: disabled, Domain: domain, AccountName: accountName, Localpart: localpart}})
+}
+
+// DomainAddLike adds a domain like DomainAdd, but with the settings of the
+// existing templateDomain instead of defaults: the catchall separators, DMARC,
+// TLSRPT and MTA-STS settings, routes, footer and DNSBLs are copied. For each DKIM
+// selector of templateDomain, a new key of the same type is generated, with the
+// same selector name and signing parameters, so the DKIM DNS records for the new
+// domain have the same names under the new domain but with new public keys. The
+// MTA-STS policy gets a new ID. Aliases are not copied.
+func DomainAddLike(ctx context.Context, newDomain, templateDomain dns.Domain, accountName string, localpart smtp.Localpart) (rerr error) {
+	return DomainAddMulti(ctx, []DomainAddSpec{{Domain: newDomain, AccountName: accountName, Localpart: localpart, Like: templateDomain}})
+}
+
+// DomainAddWithRecords adds a domain like DomainAdd, and returns the DNS records
+// to publish for the new domain, as DomainRecords, with the remaining parameters
+// passed to DomainRecords.
+func DomainAddWithRecords(ctx context.Context, disabled bool, domain dns.Domain, accountName string, localpart smtp.Localpart, hasDNSSEC bool, certIssuerDomainName, acmeAccountURI string) ([]string, error) {
+	if err := DomainAdd(ctx, disabled, domain, accountName, localpart); err != nil {
+		return nil, err
+	}
+	domConf, ok := mox.Conf.Domain(domain)
+	if !ok {
+		return nil, fmt.Errorf("domain not present after adding")
+	}
+	records, err := DomainRecords(domConf, domain, hasDNSSEC, certIssuerDomainName, acmeAccountURI)
+	if err != nil {
+		return nil, fmt.Errorf("dns records for new domain: %v", err)
+	}
+	return records, nil
+}
+
+// DomainAddSpec describes a domain to add with DomainAddMulti. The fields are as
+// the parameters of DomainAdd.
+type DomainAddSpec struct {
+	Disabled    bool
+	Domain      dns.Domain
+	AccountName string
+	Localpart   smtp.Localpart // Only for an account that does not yet exist, possibly created by an earlier spec.
+
+	DKIMDualSign bool                 // Create ed25519 and RSA DKIM keys and sign with both, see MakeDomainConfig.
+	Opts         MakeDomainConfigOpts // Addresses and mailboxes for reports, see MakeDomainConfig.
+
+	// If set, an existing domain whose settings are copied, see DomainAddLike.
+	// Cannot be combined with DKIMDualSign and Opts.
+	Like dns.Domain
+}
+
+// DomainAddMulti adds multiple domains, like DomainAdd, but with a single
+// rewrite of domains.conf. All domains are validated before DKIM keys are
+// generated. If adding any domain fails, no domain is added, domains.conf is left
+// untouched and the generated DKIM key files are removed.
+func DomainAddMulti(ctx context.Context, specs []DomainAddSpec) (rerr error) {
+	log := pkglog.WithContext(ctx)
+	defer func() {
+		if rerr != nil {
+			log.Errorx("adding domains", rerr, slog.Any("specs", specs))
 		}
 	}()
 
+	if len(specs) == 0 {
+		return requestErrorf(ErrCodeInvalid, "no domains to add")
+	}
+
 	defer mox.Conf.DynamicLockUnlock()()
 
 	c := mox.Conf.Dynamic
-	if _, ok := c.Domains[domain.Name()]; ok {
-		return fmt.Errorf("%w: domain already present", ErrRequest)
+
+	// Validate all domains before generating any keys.
+	seen := map[string]bool{}
+	newAccounts := map[string]bool{}
+	for _, spec := range specs {
+		name := spec.Domain.Name()
+		if name == "" {
+			return requestErrorf(ErrCodeInvalid, "empty domain")
+		}
+		if _, ok := c.Domains[name]; ok || seen[name] {
+			return requestErrorf(ErrCodeDomainExists, "domain %s already present", name)
+		}
+		seen[name] = true
+
+		if spec.Like.Name() != "" {
+			if _, ok := c.Domains[spec.Like.Name()]; !ok {
+				return requestErrorf(ErrCodeDomainNotFound, "template domain %s does not exist", spec.Like.Name())
+			}
+			if spec.DKIMDualSign || spec.Opts != (MakeDomainConfigOpts{}) {
+				return requestErrorf(ErrCodeInvalid, "cannot combine template domain with dkim or report options")
+			}
+		}
+
+		_, ok := c.Accounts[spec.AccountName]
+		ok = ok || newAccounts[spec.AccountName]
+		if ok && spec.Localpart != "" {
+			return requestErrorf(ErrCodeAccountExists, "account already exists (leave localpart empty when using an existing account)")
+		} else if !ok && spec.Localpart == "" {
+			return requestErrorf(ErrCodeAccountNotFound, "account does not yet exist (specify a localpart)")
+		} else if spec.AccountName == "" {
+			return requestErrorf(ErrCodeInvalid, "account name is empty")
+		} else if !ok {
+			newAccounts[spec.AccountName] = true
+		}
 	}
 
 	// Compose new config without modifying existing data structures. If we fail, we
@@ -426,6 +1237,8 @@ func DomainAdd(ctx context.Context, disabled bool, domain dns.Domain, accountNam
 	nc := c
 	nc.Domains = map[string]config.Domain{}
 	maps.Copy(nc.Domains, c.Domains)
+	nc.Accounts = map[string]config.Account{}
+	maps.Copy(nc.Accounts, c.Accounts)
 
 	// Only enable mta-sts for domain if there is a listener with mta-sts.
 	var withMTASTS bool
@@ -436,42 +1249,50 @@ func DomainAdd(ctx context.Context, disabled bool, domain dns.Domain, accountNam
 		}
 	}
 
-	confDomain, cleanupFiles, err := MakeDomainConfig(ctx, domain, mox.Conf.Static.HostnameDomain, accountName, withMTASTS)
-	if err != nil {
-		return fmt.Errorf("preparing domain config: %v", err)
-	}
+	var cleanupFiles []string
 	defer func() {
 		for _, f := range cleanupFiles {
 			err := os.Remove(f)
 			log.Check(err, "cleaning up file after error", slog.String("path", f))
 		}
 	}()
-	confDomain.Disabled = disabled
-
-	if _, ok := c.Accounts[accountName]; ok && localpart != "" {
-		return fmt.Errorf("%w: account already exists (leave localpart empty when using an existing account)", ErrRequest)
-	} else if !ok && localpart == "" {
-		return fmt.Errorf("%w: account does not yet exist (specify a localpart)", ErrRequest)
-	} else if accountName == "" {
-		return fmt.Errorf("%w: account name is empty", ErrRequest)
-	} else if !ok {
-		nc.Accounts[accountName] = MakeAccountConfig(smtp.NewAddress(localpart, domain))
-	} else if accountName != mox.Conf.Static.Postmaster.Account {
-		nacc := nc.Accounts[accountName]
-		nd := map[string]config.Destination{}
-		maps.Copy(nd, nacc.Destinations)
-		pmaddr := smtp.NewAddress("postmaster", domain)
-		nd[pmaddr.String()] = config.Destination{}
-		nacc.Destinations = nd
-		nc.Accounts[accountName] = nacc
-	}
-
-	nc.Domains[domain.Name()] = confDomain
+
+	for _, spec := range specs {
+		var confDomain config.Domain
+		var files []string
+		var err error
+		if spec.Like.Name() != "" {
+			confDomain, files, err = makeDomainConfigLike(ctx, spec.Domain, spec.AccountName, c.Domains[spec.Like.Name()])
+		} else {
+			confDomain, files, err = MakeDomainConfig(ctx, spec.Domain, mox.Conf.Static.HostnameDomain, spec.AccountName, withMTASTS, spec.DKIMDualSign, spec.Opts)
+		}
+		cleanupFiles = append(cleanupFiles, files...)
+		if err != nil {
+			return fmt.Errorf("preparing domain config for %s: %w", spec.Domain, err)
+		}
+		confDomain.Disabled = spec.Disabled
+
+		if _, ok := nc.Accounts[spec.AccountName]; !ok {
+			nc.Accounts[spec.AccountName] = MakeAccountConfig(smtp.NewAddress(spec.Localpart, spec.Domain))
+		} else if spec.AccountName != mox.Conf.Static.Postmaster.Account {
+			nacc := nc.Accounts[spec.AccountName]
+			nd := map[string]config.Destination{}
+			maps.Copy(nd, nacc.Destinations)
+			pmaddr := smtp.NewAddress("postmaster", spec.Domain)
+			nd[pmaddr.String()] = config.Destination{}
+			nacc.Destinations = nd
+			nc.Accounts[spec.AccountName] = nacc
+		}
+
+		nc.Domains[spec.Domain.Name()] = confDomain
+	}
 
 	if err := mox.WriteDynamicLocked(ctx, log, nc); err != nil {
 		return fmt.Errorf("writing domains.conf: %w", err)
 	}
-	log.Info("domain added", slog.Any("domain", domain), slog.Bool("disabled", disabled))
+	for _, spec := range specs {
+		log.Info("domain added", slog.Any("domain", spec.Domain), slog.Bool("disabled", spec.Disabled))
+	}
 	cleanupFiles = nil // All good, don't cleanup.
 	return nil
 }
@@ -480,30 +1301,104 @@ func DomainAdd(ctx context.Context, disabled bool, domain dns.Domain, accountNam
 //
 // No accounts are removed, also not when they still reference this domain.
 func DomainRemove(ctx context.Context, domain dns.Domain) (rerr error) {
+	return domainRemove(ctx, domain, false)
+}
+
+// DomainRemoveCleanupReferences removes domain like DomainRemove, and also
+// removes references to the domain from the remaining config, in the same
+// rewrite of domains.conf: account destinations (including catchall addresses)
+// and login addresses for the domain, alias members with an address in the
+// domain, and DMARC and TLSRPT reporting configurations of other domains with a
+// reporting address in the domain. If an account would have no addresses left,
+// or has the domain as its default domain, the domain is not removed.
+func DomainRemoveCleanupReferences(ctx context.Context, domain dns.Domain) (rerr error) {
+	return domainRemove(ctx, domain, true)
+}
+
+func domainRemove(ctx context.Context, domain dns.Domain, cleanup bool) (rerr error) {
 	log := pkglog.WithContext(ctx)
 	defer func() {
 		if rerr != nil {
-			log.Errorx("removing domain", rerr, slog.Any("domain", domain))
+			log.Errorx("removing domain", rerr, slog.Any("domain", domain), slog.Bool("cleanup", cleanup))
 		}
 	}()
 
 	defer mox.Conf.DynamicLockUnlock()()
 
+	domConf, nc, err := domainRemovePrepare(ctx, domain)
+	if err != nil {
+		return err
+	}
+	if cleanup {
+		if err := domainRemoveReferences(&nc, domain); err != nil {
+			return err
+		}
+	}
+
+	if err := mox.WriteDynamicLocked(ctx, log, nc); err != nil {
+		return fmt.Errorf("writing domains.conf: %w", err)
+	}
+
+	// Move away any DKIM private keys to a subdirectory "old". But only if
+	// they are not in use by other domains.
+	usedKeyPaths := gatherUsedKeysPaths(nc)
+	moveAwayKeys(log, domConf.DKIM.Selectors, usedKeyPaths)
+
+	log.Info("domain removed", slog.Any("domain", domain))
+	return nil
+}
+
+// DomainRemoveCheck checks whether domain can be removed with DomainRemove,
+// without removing it. It returns the DKIM private key files (paths as in the
+// config) that would be moved to a subdirectory "old" on removal.
+func DomainRemoveCheck(ctx context.Context, domain dns.Domain) ([]string, error) {
+	log := pkglog.WithContext(ctx)
+
+	defer mox.Conf.DynamicLockUnlock()()
+
+	domConf, nc, err := domainRemovePrepare(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	// Checking the config stores updated accounts in the accounts map, which must not
+	// affect the active config.
+	nc.Accounts = maps.Clone(nc.Accounts)
+	if err := mox.CheckDynamicLocked(ctx, log, nc); err != nil {
+		return nil, requestErrorf(ErrCodeInvalid, "checking new config: %v", err)
+	}
+
+	var keyFiles []string
+	usedKeyPaths := gatherUsedKeysPaths(nc)
+	for _, sel := range domConf.DKIM.Selectors {
+		if sel.PrivateKeyFile == "" || usedKeyPaths[filepath.Clean(sel.PrivateKeyFile)] || slices.Contains(keyFiles, sel.PrivateKeyFile) {
+			continue
+		}
+		keyFiles = append(keyFiles, sel.PrivateKeyFile)
+	}
+	slices.Sort(keyFiles)
+	return keyFiles, nil
+}
+
+// domainRemovePrepare checks the preconditions for removing domain, and returns
+// its current config and the new config without the domain.
+//
+// Must be called with config lock held.
+func domainRemovePrepare(ctx context.Context, domain dns.Domain) (config.Domain, config.Dynamic, error) {
 	c := mox.Conf.Dynamic
 	domConf, ok := c.Domains[domain.Name()]
 	if !ok {
-		return fmt.Errorf("%w: domain does not exist", ErrRequest)
+		return config.Domain{}, config.Dynamic{}, requestErrorf(ErrCodeDomainNotFound, "domain does not exist")
 	}
 
 	// Check that the domain isn't referenced in a TLS public key.
 	tlspubkeys, err := store.TLSPublicKeyList(ctx, "")
 	if err != nil {
-		return fmt.Errorf("%w: listing tls public keys: %s", ErrRequest, err)
+		return config.Domain{}, config.Dynamic{}, fmt.Errorf("listing tls public keys: %s", err)
 	}
 	atdom := "@" + domain.Name()
 	for _, tpk := range tlspubkeys {
 		if strings.HasSuffix(tpk.LoginAddress, atdom) {
-			return fmt.Errorf("%w: domain is still referenced in tls public key by login address %q of account %q, change or remove it first", ErrRequest, tpk.LoginAddress, tpk.Account)
+			return config.Domain{}, config.Dynamic{}, requestErrorf(ErrCodeConflict, "domain is still referenced in tls public key by login address %q of account %q, change or remove it first", tpk.LoginAddress, tpk.Account)
 		}
 	}
 
@@ -517,99 +1412,455 @@ func DomainRemove(ctx context.Context, domain dns.Domain) (rerr error) {
 			nc.Domains[name] = d
 		}
 	}
-
-	if err := mox.WriteDynamicLocked(ctx, log, nc); err != nil {
-		return fmt.Errorf("writing domains.conf: %w", err)
-	}
-
-	// Move away any DKIM private keys to a subdirectory "old". But only if
-	// they are not in use by other domains.
-	usedKeyPaths := gatherUsedKeysPaths(nc)
-	moveAwayKeys(log, domConf.DKIM.Selectors, usedKeyPaths)
-
-	log.Info("domain removed", slog.Any("domain", domain))
-	return nil
+	return domConf, nc, nil
 }
 
-func gatherUsedKeysPaths(nc config.Dynamic) map[string]bool {
-	usedKeyPaths := map[string]bool{}
-	for _, dc := range nc.Domains {
-		for _, sel := range dc.DKIM.Selectors {
-			usedKeyPaths[filepath.Clean(sel.PrivateKeyFile)] = true
+// domainRemoveReferences removes references to domain from accounts and other
+// domains in nc. Modified maps are copied, the current config is not changed.
+func domainRemoveReferences(nc *config.Dynamic, domain dns.Domain) error {
+	name := domain.Name()
+	inDomain := func(addr string) bool {
+		_, d, ok := strings.Cut(addr, "@")
+		return ok && strings.TrimPrefix(d, ".") == name
+	}
+
+	nc.Accounts = maps.Clone(nc.Accounts)
+	for accName, acc := range nc.Accounts {
+		// Changing the default domain would change the meaning of destinations that are
+		// only a localpart (deprecated), so we leave that to the admin.
+		if acc.Domain == name {
+			return requestErrorf(ErrCodeConflict, "domain is the default domain of account %q, change it first", accName)
+		}
+		if !slices.ContainsFunc(slices.Collect(maps.Keys(acc.Destinations)), inDomain) && !slices.ContainsFunc(acc.FromIDLoginAddresses, inDomain) {
+			continue
+		}
+		dests := map[string]config.Destination{}
+		for addr, dest := range acc.Destinations {
+			if !inDomain(addr) {
+				dests[addr] = dest
+			}
+		}
+		if len(dests) == 0 {
+			return requestErrorf(ErrCodeConflict, "account %q would have no addresses left, remove the account first", accName)
 		}
+		acc.Destinations = dests
+		acc.FromIDLoginAddresses = slices.DeleteFunc(slices.Clone(acc.FromIDLoginAddresses), inDomain)
+		nc.Accounts[accName] = acc
 	}
-	return usedKeyPaths
-}
 
-func moveAwayKeys(log mlog.Log, sels map[string]config.Selector, usedKeyPaths map[string]bool) {
-	for _, sel := range sels {
-		if sel.PrivateKeyFile == "" || usedKeyPaths[filepath.Clean(sel.PrivateKeyFile)] {
-			continue
+	nc.Domains = maps.Clone(nc.Domains)
+	for domName, dom := range nc.Domains {
+		var changed bool
+		if dom.DMARC != nil && dom.DMARC.Domain == name {
+			dom.DMARC = nil
+			changed = true
 		}
-		src := mox.ConfigDirPath(sel.PrivateKeyFile)
-		dst := mox.ConfigDirPath(filepath.Join(filepath.Dir(sel.PrivateKeyFile), "old", filepath.Base(sel.PrivateKeyFile)))
-		_, err := os.Stat(dst)
-		if err == nil {
-			err = fmt.Errorf("destination already exists")
-		} else if os.IsNotExist(err) {
-			os.MkdirAll(filepath.Dir(dst), 0770)
-			err = os.Rename(src, dst)
+		if dom.TLSRPT != nil && dom.TLSRPT.Domain == name {
+			dom.TLSRPT = nil
+			changed = true
 		}
-		if err != nil {
-			log.Errorx("renaming dkim private key file for removed domain", err, slog.String("src", src), slog.String("dst", dst))
+		var aliasesChanged bool
+		aliases := map[string]config.Alias{}
+		for lp, a := range dom.Aliases {
+			if !slices.ContainsFunc(a.Addresses, inDomain) {
+				aliases[lp] = a
+				continue
+			}
+			aliasesChanged = true
+			// An alias without members is not valid, so it is removed.
+			a.Addresses = slices.DeleteFunc(slices.Clone(a.Addresses), inDomain)
+			if len(a.Addresses) > 0 {
+				aliases[lp] = a
+			}
+		}
+		if aliasesChanged {
+			dom.Aliases = aliases
+			changed = true
+		}
+		if changed {
+			nc.Domains[domName] = dom
 		}
 	}
+	return nil
 }
 
-// DomainSave calls xmodify with a shallow copy of the domain config. xmodify
-// can modify the config, but must clone all referencing data it changes.
-// xmodify may employ panic-based error handling. After xmodify returns, the
-// modified config is verified, saved and takes effect.
-func DomainSave(ctx context.Context, domainName string, xmodify func(config *config.Domain) error) (rerr error) {
+// DomainRename renames domain oldDomain to newDomain, keeping its configuration,
+// including its DKIM keys. References to the domain are rewritten in the same
+// change of domains.conf: account destinations (including catchall addresses),
+// login addresses and default domains of accounts, alias members and allowed
+// senders, and DMARC and TLSRPT reporting addresses. A client settings domain
+// that is oldDomain or a subdomain of it is moved to newDomain. Routes and web
+// handlers are not changed.
+//
+// The domain is not renamed if the delivery queue has messages with a sender in
+// the domain, or if a TLS public key has a login address in the domain. Messages
+// in accounts are not changed.
+//
+// DNS records must be published for newDomain, see DomainRecords. The DKIM
+// selectors keep their keys, but their public keys must be published under
+// newDomain.
+func DomainRename(ctx context.Context, oldDomain, newDomain dns.Domain) (rerr error) {
 	log := pkglog.WithContext(ctx)
 	defer func() {
 		if rerr != nil {
-			log.Errorx("saving domain config", rerr)
+			log.Errorx("renaming domain", rerr, slog.Any("olddomain", oldDomain), slog.Any("newdomain", newDomain))
 		}
 	}()
 
+	if newDomain.IsZero() {
+		return requestErrorf(ErrCodeInvalid, "new domain required")
+	} else if newDomain == oldDomain {
+		return requestErrorf(ErrCodeInvalid, "new domain is the same as old domain")
+	} else if oldDomain == mox.Conf.Static.HostnameDomain {
+		return requestErrorf(ErrCodeConflict, "cannot rename domain of mail host, configured in mox.conf")
+	}
+
 	defer mox.Conf.DynamicLockUnlock()()
 
-	nc := mox.Conf.Dynamic            // Shallow copy.
-	dom, ok := nc.Domains[domainName] // dom is a shallow copy.
+	c := mox.Conf.Dynamic
+	domConf, ok := c.Domains[oldDomain.Name()]
 	if !ok {
-		return fmt.Errorf("%w: domain not present", ErrRequest)
+		return requestErrorf(ErrCodeDomainNotFound, "domain does not exist")
+	}
+	if _, ok := c.Domains[newDomain.Name()]; ok {
+		return requestErrorf(ErrCodeDomainExists, "new domain already exists")
 	}
 
-	if err := xmodify(&dom); err != nil {
-		return err
+	// Queued messages are signed and sent with the configuration of their sender
+	// domain, which would no longer exist.
+	msgs, err := queue.List(ctx, queue.Filter{SenderDomain: oldDomain.Name()}, queue.Sort{})
+	if err != nil {
+		return fmt.Errorf("listing messages in queue: %v", err)
+	} else if len(msgs) > 0 {
+		return requestErrorf(ErrCodeConflict, "message delivery queue contains %d message(s) from the domain, deliver or drop them first", len(msgs))
+	}
+
+	// Check that the domain isn't referenced in a TLS public key.
+	tlspubkeys, err := store.TLSPublicKeyList(ctx, "")
+	if err != nil {
+		return fmt.Errorf("listing tls public keys: %s", err)
+	}
+	atdom := "@" + oldDomain.Name()
+	for _, tpk := range tlspubkeys {
+		if strings.HasSuffix(tpk.LoginAddress, atdom) {
+			return requestErrorf(ErrCodeConflict, "domain is still referenced in tls public key by login address %q of account %q, change or remove it first", tpk.LoginAddress, tpk.Account)
+		}
+	}
+
+	oname, nname := oldDomain.Name(), newDomain.Name()
+	// rename returns addr with the domain replaced if it is oldDomain, including for
+	// catchall addresses.
+	rename := func(addr string) (string, bool) {
+		i := strings.LastIndex(addr, "@")
+		if i < 0 {
+			return addr, false
+		}
+		switch addr[i+1:] {
+		case oname:
+			return addr[:i+1] + nname, true
+		case "." + oname:
+			return addr[:i+1] + "." + nname, true
+		}
+		return addr, false
+	}
+	renameList := func(l []string) ([]string, bool) {
+		var changed bool
+		nl := make([]string, len(l))
+		for i, addr := range l {
+			var ok bool
+			nl[i], ok = rename(addr)
+			changed = changed || ok
+		}
+		return nl, changed
 	}
 
 	// Compose new config without modifying existing data structures. If we fail, we
 	// leave no trace.
+	nc := c
+	nc.Accounts = maps.Clone(c.Accounts)
+	for accName, acc := range c.Accounts {
+		var changed bool
+		dests := map[string]config.Destination{}
+		for addr, dest := range acc.Destinations {
+			naddr, ok := rename(addr)
+			dests[naddr] = dest
+			changed = changed || ok
+		}
+		fromID, fromIDChanged := renameList(acc.FromIDLoginAddresses)
+		if !changed && !fromIDChanged && acc.Domain != oname {
+			continue
+		}
+		acc.Destinations = dests
+		if fromIDChanged {
+			acc.FromIDLoginAddresses = fromID
+		}
+		if acc.Domain == oname {
+			acc.Domain = nname
+		}
+		nc.Accounts[accName] = acc
+	}
+
 	nc.Domains = map[string]config.Domain{}
-	maps.Copy(nc.Domains, mox.Conf.Dynamic.Domains)
-	nc.Domains[domainName] = dom
+	for name, dom := range c.Domains {
+		if name == oname {
+			name = nname
+			if strings.HasSuffix("."+domConf.ClientSettingsDNSDomain.Name(), "."+oname) {
+				dom.ClientSettingsDomain = strings.TrimSuffix(domConf.ClientSettingsDNSDomain.Name(), oname) + nname
+			}
+		}
+		if dom.DMARC != nil && dom.DMARC.Domain == oname {
+			dmarc := *dom.DMARC
+			dmarc.Domain = nname
+			dom.DMARC = &dmarc
+		}
+		if dom.TLSRPT != nil && dom.TLSRPT.Domain == oname {
+			tlsrpt := *dom.TLSRPT
+			tlsrpt.Domain = nname
+			dom.TLSRPT = &tlsrpt
+		}
+		var aliasesChanged bool
+		aliases := map[string]config.Alias{}
+		for lp, a := range dom.Aliases {
+			addrs, addrsChanged := renameList(a.Addresses)
+			allow, allowChanged := renameList(a.AllowMsgFromAddresses)
+			if addrsChanged {
+				a.Addresses = addrs
+				a.ParsedAddresses = nil
+			}
+			if allowChanged {
+				a.AllowMsgFromAddresses = allow
+				a.ParsedAllowMsgFromAddresses = nil
+			}
+			aliasesChanged = aliasesChanged || addrsChanged || allowChanged
+			aliases[lp] = a
+		}
+		if aliasesChanged {
+			dom.Aliases = aliases
+		}
+		nc.Domains[name] = dom
+	}
 
 	if err := mox.WriteDynamicLocked(ctx, log, nc); err != nil {
 		return fmt.Errorf("writing domains.conf: %w", err)
 	}
-
-	log.Info("domain saved")
+	log.Info("domain renamed", slog.Any("olddomain", oldDomain), slog.Any("newdomain", newDomain))
 	return nil
 }
 
-// ConfigSave calls xmodify with a shallow copy of the dynamic config. xmodify
-// can modify the config, but must clone all referencing data it changes.
-// xmodify may employ panic-based error handling. After xmodify returns, the
-// modified config is verified, saved and takes effect.
-func ConfigSave(ctx context.Context, xmodify func(config *config.Dynamic)) (rerr error) {
-	log := pkglog.WithContext(ctx)
-	defer func() {
-		if rerr != nil {
-			log.Errorx("saving config", rerr)
-		}
-	}()
+func gatherUsedKeysPaths(nc config.Dynamic) map[string]bool {
+	usedKeyPaths := map[string]bool{}
+	for _, dc := range nc.Domains {
+		for _, sel := range dc.DKIM.Selectors {
+			usedKeyPaths[filepath.Clean(sel.PrivateKeyFile)] = true
+		}
+	}
+	return usedKeyPaths
+}
+
+// DKIMSharedKeys returns the DKIM private key files (paths as in the config) that
+// are referenced by more than one selector, e.g. by multiple domains. Values are
+// the sorted DNS names of the selectors referencing the key, of the form
+// <selector>._domainkey.<domain>. Shared keys are not moved away when a domain
+// using it is removed.
+func DKIMSharedKeys(ctx context.Context) (map[string][]string, error) {
+	c := mox.Conf.DynamicConfig()
+	refs := map[string][]string{}
+	for domName, dc := range c.Domains {
+		for selName, sel := range dc.DKIM.Selectors {
+			if sel.PrivateKeyFile == "" {
+				continue
+			}
+			p := filepath.Clean(sel.PrivateKeyFile)
+			refs[p] = append(refs[p], fmt.Sprintf("%s._domainkey.%s", selName, domName))
+		}
+	}
+	shared := map[string][]string{}
+	for p, l := range refs {
+		if len(l) > 1 {
+			slices.Sort(l)
+			shared[p] = l
+		}
+	}
+	return shared, nil
+}
+
+// DKIMSelectorInfo describes a DKIM selector of a domain, for auditing key
+// rotation.
+type DKIMSelectorInfo struct {
+	Domain         dns.Domain
+	Selector       dns.Domain
+	Algorithm      string    // "ed25519", "rsa-<bits>", "ecdsa-p256", from the private key.
+	Created        time.Time // From the timestamp in the key file name, or the Note header of the key. Zero if unknown.
+	Note           string    // Note header of the PEM-encoded private key, as added by mox when generating keys.
+	Expiration     string    // As in config, empty for no expiration.
+	Sign           bool      // Whether the selector is listed for signing messages.
+	Disabled       bool      // Whether the selector is disabled, preventing signing even if listed in Sign.
+	PrivateKeyFile string    // As in config.
+}
+
+// DKIMSelectorList returns all DKIM selectors of all domains, sorted by domain
+// and selector name.
+func DKIMSelectorList(ctx context.Context) ([]DKIMSelectorInfo, error) {
+	c := mox.Conf.DynamicConfig()
+	var l []DKIMSelectorInfo
+	for domName, dc := range c.Domains {
+		dom, err := dns.ParseDomain(domName)
+		if err != nil {
+			return nil, fmt.Errorf("parsing domain %q: %v", domName, err)
+		}
+		for selName, sel := range dc.DKIM.Selectors {
+			info := DKIMSelectorInfo{
+				Domain:         dom,
+				Selector:       sel.Domain,
+				Algorithm:      sel.Algorithm,
+				Expiration:     sel.Expiration,
+				Sign:           slices.Contains(dc.DKIM.Sign, selName),
+				Disabled:       sel.Disabled,
+				PrivateKeyFile: sel.PrivateKeyFile,
+			}
+			if info.Selector.IsZero() {
+				info.Selector, err = dns.ParseDomain(selName)
+				if err != nil {
+					return nil, fmt.Errorf("parsing selector %q for domain %s: %v", selName, domName, err)
+				}
+			}
+			buf, err := os.ReadFile(mox.ConfigDynamicDirPath(sel.PrivateKeyFile))
+			if err != nil {
+				return nil, fmt.Errorf("reading private key for selector %s of domain %s: %v", selName, domName, err)
+			}
+			if b, _ := pem.Decode(buf); b != nil {
+				info.Note = b.Headers["Note"]
+			}
+			info.Created = dkimKeyCreated(sel.PrivateKeyFile, info.Note)
+			l = append(l, info)
+		}
+	}
+	slices.SortFunc(l, func(a, b DKIMSelectorInfo) int {
+		if c := strings.Compare(a.Domain.Name(), b.Domain.Name()); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Selector.Name(), b.Selector.Name())
+	})
+	return l, nil
+}
+
+// dkimKeyPath returns a path, relative to the config directory, for a new DKIM
+// private key file for record that is not in use and wasn't moved away to "old"
+// before. If a key file for the same record, timestamp and kind exists, e.g. when
+// rotating a key twice within a second, a sequence number is added after the
+// timestamp.
+func dkimKeyPath(record, timestamp, kind string) string {
+	exists := func(p string) bool {
+		_, err := os.Stat(mox.ConfigDynamicDirPath(p))
+		return err == nil
+	}
+	keyPath := filepath.Join("dkim", fmt.Sprintf("%s.%s.%s.privatekey.pkcs8.pem", record, timestamp, kind))
+	for i := 2; ; i++ {
+		if !exists(keyPath) && !exists(filepath.Join(filepath.Dir(keyPath), "old", filepath.Base(keyPath))) {
+			return keyPath
+		}
+		keyPath = filepath.Join("dkim", fmt.Sprintf("%s.%s.%d.%s.privatekey.pkcs8.pem", record, timestamp, i, kind))
+	}
+}
+
+// dkimKeyCreated returns the time a DKIM key was created, based on the timestamp
+// in key files generated by mox ("<selector>._domainkey.<domain>.<timestamp>.<kind>.privatekey.pkcs8.pem"),
+// or the time in the Note header. Zero if unknown.
+func dkimKeyCreated(keyPath, note string) time.Time {
+	name := strings.TrimSuffix(filepath.Base(keyPath), ".privatekey.pkcs8.pem")
+	t := strings.Split(name, ".")
+	for i := len(t) - 1; i >= 0; i-- {
+		if tm, err := time.ParseInLocation("20060102T150405", t[i], time.Local); err == nil {
+			return tm
+		}
+	}
+	if _, s, ok := strings.Cut(note, ", generated by mox on "); ok {
+		if tm, err := time.Parse(time.RFC3339, s); err == nil {
+			return tm
+		}
+	}
+	return time.Time{}
+}
+
+func moveAwayKeys(log mlog.Log, sels map[string]config.Selector, usedKeyPaths map[string]bool) {
+	for _, sel := range sels {
+		if sel.PrivateKeyFile == "" || usedKeyPaths[filepath.Clean(sel.PrivateKeyFile)] {
+			continue
+		}
+		src := mox.ConfigDirPath(sel.PrivateKeyFile)
+		dst := mox.ConfigDirPath(filepath.Join(filepath.Dir(sel.PrivateKeyFile), "old", filepath.Base(sel.PrivateKeyFile)))
+		// If a file with the same name was moved away before, e.g. for a removed
+		// selector with the same name, add a timestamp, and a sequence number if needed.
+		_, err := os.Stat(dst)
+		if err == nil {
+			base := dst + "." + time.Now().Format("20060102T150405")
+			dst = base
+			for i := 1; err == nil; i++ {
+				_, err = os.Stat(dst)
+				if err == nil {
+					dst = fmt.Sprintf("%s.%d", base, i)
+				}
+			}
+		}
+		if os.IsNotExist(err) {
+			os.MkdirAll(filepath.Dir(dst), 0770)
+			err = os.Rename(src, dst)
+		}
+		if err != nil {
+			log.Errorx("renaming dkim private key file for removed domain", err, slog.String("src", src), slog.String("dst", dst))
+		}
+	}
+}
+
+// DomainSave calls xmodify with a shallow copy of the domain config. xmodify
+// can modify the config, but must clone all referencing data it changes.
+// xmodify may employ panic-based error handling. After xmodify returns, the
+// modified config is verified, saved and takes effect.
+func DomainSave(ctx context.Context, domainName string, xmodify func(config *config.Domain) error) (rerr error) {
+	log := pkglog.WithContext(ctx)
+	defer func() {
+		if rerr != nil {
+			log.Errorx("saving domain config", rerr)
+		}
+	}()
+
+	defer mox.Conf.DynamicLockUnlock()()
+
+	nc := mox.Conf.Dynamic            // Shallow copy.
+	dom, ok := nc.Domains[domainName] // dom is a shallow copy.
+	if !ok {
+		return requestErrorf(ErrCodeDomainNotFound, "domain not present")
+	}
+
+	if err := xmodify(&dom); err != nil {
+		return err
+	}
+
+	// Compose new config without modifying existing data structures. If we fail, we
+	// leave no trace.
+	nc.Domains = map[string]config.Domain{}
+	maps.Copy(nc.Domains, mox.Conf.Dynamic.Domains)
+	nc.Domains[domainName] = dom
+
+	if err := mox.WriteDynamicLocked(ctx, log, nc); err != nil {
+		return fmt.Errorf("writing domains.conf: %w", err)
+	}
+
+	log.Info("domain saved")
+	return nil
+}
+
+// ConfigSave calls xmodify with a shallow copy of the dynamic config. xmodify
+// can modify the config, but must clone all referencing data it changes.
+// xmodify may employ panic-based error handling. After xmodify returns, the
+// modified config is verified, saved and takes effect.
+func ConfigSave(ctx context.Context, xmodify func(config *config.Dynamic)) (rerr error) {
+	log := pkglog.WithContext(ctx)
+	defer func() {
+		if rerr != nil {
+			log.Errorx("saving config", rerr)
+		}
+	}()
 
 	defer mox.Conf.DynamicLockUnlock()()
 
@@ -624,6 +1875,96 @@ func ConfigSave(ctx context.Context, xmodify func(config *config.Dynamic)) (rerr
 	return nil
 }
 
+// ConfigValidate calls xmodify with a shallow copy of the dynamic config, like
+// ConfigSave, and verifies the modified config, but does not save it or make it
+// take effect. An error wrapping mox.ErrConfig is returned if the modified config
+// is not valid.
+func ConfigValidate(ctx context.Context, xmodify func(config *config.Dynamic)) error {
+	log := pkglog.WithContext(ctx)
+
+	defer mox.Conf.DynamicLockUnlock()()
+
+	nc := mox.Conf.Dynamic // Shallow copy.
+	xmodify(&nc)
+
+	// Verifying sets derived fields in the domains and accounts, they must not end up
+	// in the active config.
+	nc.Domains = maps.Clone(nc.Domains)
+	for name, d := range nc.Domains {
+		d.Aliases = maps.Clone(d.Aliases)
+		nc.Domains[name] = d
+	}
+	nc.Accounts = maps.Clone(nc.Accounts)
+
+	return mox.CheckDynamicLocked(ctx, log, nc)
+}
+
+// UndoLast reverts the most recent change to the dynamic config (domains.conf)
+// made while running, by writing the config as it was before that change.
+//
+// Only a single change is kept. UndoLast refuses if there is no previous config,
+// e.g. after a restart or after domains.conf was changed externally, and if the
+// previous config does not match the current state of the file system: accounts
+// that were added and already have an account directory, or accounts that were
+// removed and have their data scheduled for removal. Such changes must be reverted
+// through a regular config change or a restore from backup instead.
+func UndoLast(ctx context.Context) (rerr error) {
+	log := pkglog.WithContext(ctx)
+	defer func() {
+		if rerr != nil {
+			log.Errorx("undoing last config change", rerr)
+		}
+	}()
+
+	defer mox.Conf.DynamicLockUnlock()()
+
+	prev, ok := mox.Conf.DynamicPreviousLocked()
+	if !ok {
+		return requestErrorf(ErrCodeConflict, "no previous config change to undo")
+	}
+	cur := mox.Conf.Dynamic
+
+	for name := range cur.Accounts {
+		if _, ok := prev.Accounts[name]; ok {
+			continue
+		}
+		// Account was added. If it has been opened, its directory would be left behind,
+		// preventing the account from being added again.
+		accountDir := filepath.Join(mox.DataDirPath("accounts"), name)
+		if _, err := os.Stat(accountDir); err == nil {
+			return requestErrorf(ErrCodeAccountDataExists, "added account %q already has account directory %q, remove account instead", name, accountDir)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("stat account directory %q: %v", accountDir, err)
+		}
+	}
+	for name := range prev.Accounts {
+		if _, ok := cur.Accounts[name]; ok {
+			continue
+		}
+		// Account was removed. Its data is removed when the last reference is gone, and
+		// cannot be brought back by changing the config.
+		err := store.AuthDB.Get(ctx, &store.AccountRemove{AccountName: name})
+		if err == nil {
+			return requestErrorf(ErrCodeAccountDataExists, "data of removed account %q is scheduled for removal, restore from backup instead", name)
+		} else if !errors.Is(err, bstore.ErrAbsent) {
+			return fmt.Errorf("checking scheduled removal of account %q: %v", name, err)
+		}
+		accountDir := filepath.Join(mox.DataDirPath("accounts"), name)
+		if _, err := os.Stat(accountDir); err != nil {
+			return requestErrorf(ErrCodeAccountDataExists, "account directory %q of removed account %q: %v, restore from backup instead", accountDir, name, err)
+		}
+	}
+
+	if err := mox.WriteDynamicLocked(ctx, log, prev); err != nil {
+		return fmt.Errorf("writing domains.conf: %w", err)
+	}
+	// Don't allow undoing the undo.
+	mox.Conf.DynamicPreviousClearLocked()
+
+	log.Info("last config change undone")
+	return nil
+}
+
 // AccountAdd adds an account and an initial address and reloads the configuration.
 //
 // The new account does not have a password, so cannot yet log in. Email can be
@@ -640,27 +1981,41 @@ func AccountAdd(ctx context.Context, account, address string) (rerr error) {
 
 	addr, err := smtp.ParseAddress(address)
 	if err != nil {
-		return fmt.Errorf("%w: parsing email address: %v", ErrRequest, err)
+		return requestErrorf(ErrCodeInvalid, "parsing email address: %v", err)
 	}
 
 	defer mox.Conf.DynamicLockUnlock()()
 
 	c := mox.Conf.Dynamic
 	if _, ok := c.Accounts[account]; ok {
-		return fmt.Errorf("%w: account already present", ErrRequest)
+		return requestErrorf(ErrCodeAccountExists, "account already present")
 	}
 
 	// Ensure the directory does not exist, e.g. due to pending account removal, or an
-	// otherwise failed cleanup.
+	// otherwise failed cleanup. A directory without files, e.g. left behind by an
+	// interrupted earlier attempt at creating the account, is removed.
 	accountDir := filepath.Join(mox.DataDirPath("accounts"), account)
+	var staleDir bool
 	if _, err := os.Stat(accountDir); err == nil {
-		return fmt.Errorf("%w: account directory %q already/still exists", ErrRequest, accountDir)
+		if empty, err := dirWithoutFiles(accountDir); err != nil {
+			return fmt.Errorf("checking existing account directory %q: %v", accountDir, err)
+		} else if !empty {
+			return requestErrorf(ErrCodeAccountDataExists, "account directory %q already/still exists", accountDir)
+		}
+		staleDir = true
 	} else if !errors.Is(err, fs.ErrNotExist) {
-		return fmt.Errorf(`%w: stat account directory %q, expected "does not exist": %v`, ErrRequest, accountDir, err)
+		return requestErrorf(ErrCodeAccountDataExists, `stat account directory %q, expected "does not exist": %v`, accountDir, err)
 	}
 
 	if err := checkAddressAvailable(addr); err != nil {
-		return fmt.Errorf("%w: address not available: %v", ErrRequest, err)
+		return requestErrorf(ErrCodeAddressInUse, "address not available: %v", err)
+	}
+
+	if staleDir {
+		if err := os.RemoveAll(accountDir); err != nil {
+			return fmt.Errorf("removing empty stale account directory %q: %v", accountDir, err)
+		}
+		log.Info("removed empty stale account directory", slog.String("account", account), slog.String("dir", accountDir))
 	}
 
 	// Compose new config without modifying existing data structures. If we fail, we
@@ -677,12 +2032,105 @@ func AccountAdd(ctx context.Context, account, address string) (rerr error) {
 	return nil
 }
 
+// dirWithoutFiles returns whether dir, and its subdirectories, contain no files.
+func dirWithoutFiles(dir string) (bool, error) {
+	errFile := errors.New("file found")
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return errFile
+		}
+		return nil
+	})
+	if err == errFile {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// AccountAddWithPassword adds an account like AccountAdd, and sets its initial
+// password. If the password cannot be set, e.g. because it is too short, the
+// account is removed from the configuration again. As with AccountAdd, the
+// account has NoCustomPassword set, so the user cannot change the password.
+//
+// With an empty password, the account is added without password, like AccountAdd,
+// and cannot yet log in.
+func AccountAddWithPassword(ctx context.Context, account, address, password string) (rerr error) {
+	if err := AccountAdd(ctx, account, address); err != nil || password == "" {
+		return err
+	}
+
+	log := pkglog.WithContext(ctx)
+	defer func() {
+		if rerr != nil {
+			log.Errorx("setting password for new account", rerr, slog.String("account", account))
+		}
+	}()
+
+	acc, err := store.OpenAccount(log, account, false)
+	if err != nil {
+		xerr := accountAddRollback(ctx, log, account, nil)
+		log.Check(xerr, "removing new account after error")
+		return fmt.Errorf("open new account: %v", err)
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	if err := acc.SetPassword(log, password); err != nil {
+		xerr := accountAddRollback(ctx, log, account, acc)
+		log.Check(xerr, "removing new account after error")
+		return fmt.Errorf("setting password: %v", err)
+	}
+	return nil
+}
+
+// accountAddRollback removes a just added account from the configuration, and
+// schedules removal of its files if acc is not nil.
+func accountAddRollback(ctx context.Context, log mlog.Log, account string, acc *store.Account) error {
+	defer mox.Conf.DynamicLockUnlock()()
+
+	c := mox.Conf.Dynamic
+	nc := c
+	nc.Accounts = map[string]config.Account{}
+	for name, a := range c.Accounts {
+		if name != account {
+			nc.Accounts[name] = a
+		}
+	}
+	if err := mox.WriteDynamicLocked(ctx, log, nc); err != nil {
+		return fmt.Errorf("writing domains.conf: %w", err)
+	}
+	if acc != nil {
+		if err := acc.Remove(context.Background()); err != nil {
+			return fmt.Errorf("account removed from configuration file, but scheduling account directory for removal failed: %v", err)
+		}
+	}
+	log.Info("new account removed", slog.String("account", account))
+	return nil
+}
+
 // AccountRemove removes an account and reloads the configuration.
 func AccountRemove(ctx context.Context, account string) (rerr error) {
+	return accountRemove(ctx, account, false)
+}
+
+// AccountRemoveKeepData removes an account from the configuration like
+// AccountRemove, but leaves the account data directory, with its messages, on
+// disk, e.g. for later inspection. An account with the same name cannot be added
+// again while the directory exists.
+func AccountRemoveKeepData(ctx context.Context, account string) (rerr error) {
+	return accountRemove(ctx, account, true)
+}
+
+func accountRemove(ctx context.Context, account string, keepData bool) (rerr error) {
 	log := pkglog.WithContext(ctx)
 	defer func() {
 		if rerr != nil {
-			log.Errorx("adding account", rerr, slog.String("account", account))
+			log.Errorx("removing account", rerr, slog.String("account", account), slog.Bool("keepdata", keepData))
 		}
 	}()
 
@@ -690,7 +2138,7 @@ func AccountRemove(ctx context.Context, account string) (rerr error) {
 	// because during tests the consistency checker takes the same lock.
 	acc, err := store.OpenAccount(log, account, false)
 	if err != nil {
-		return fmt.Errorf("%w: open account: %v", ErrRequest, err)
+		return fmt.Errorf("open account: %v", err)
 	}
 	defer func() {
 		err := acc.SessionsClear(context.Background(), log)
@@ -753,6 +2201,11 @@ func AccountRemove(ctx context.Context, account string) (rerr error) {
 		return fmt.Errorf("writing domains.conf: %w", err)
 	}
 
+	if keepData {
+		log.Info("account removed, keeping data directory", slog.String("account", account), slog.String("dir", acc.Dir))
+		return nil
+	}
+
 	// Mark files for account for removal as soon as all references have gone.
 	if err := acc.Remove(context.Background()); err != nil {
 		return fmt.Errorf("account removed from configuration file, but scheduling account directory for removal failed: %v", err)
@@ -762,72 +2215,195 @@ func AccountRemove(ctx context.Context, account string) (rerr error) {
 	return nil
 }
 
-// checkAddressAvailable checks that the address after canonicalization is not
-// already configured, and that its localpart does not contain a catchall
-// localpart separator.
-//
-// Must be called with config lock held.
-func checkAddressAvailable(addr smtp.Address) error {
-	dc, ok := mox.Conf.Dynamic.Domains[addr.Domain.Name()]
-	if !ok {
-		return fmt.Errorf("domain does not exist")
-	}
-	lp := mox.CanonicalLocalpart(addr.Localpart, dc)
-	if _, ok := mox.Conf.AccountDestinationsLocked[smtp.NewAddress(lp, addr.Domain).String()]; ok {
-		return fmt.Errorf("canonicalized address %s already configured", smtp.NewAddress(lp, addr.Domain))
-	}
-	for _, sep := range dc.LocalpartCatchallSeparatorsEffective {
-		if strings.Contains(string(addr.Localpart), sep) {
-			return fmt.Errorf("localpart cannot include domain catchall separator %s", sep)
-		}
-	}
-	if _, ok := dc.Aliases[lp.String()]; ok {
-		return fmt.Errorf("address in use as alias")
-	}
-	return nil
-}
-
-// AddressAdd adds an email address to an account and reloads the configuration. If
-// address starts with an @ it is treated as a catchall address for the domain.
-func AddressAdd(ctx context.Context, address, account string) (rerr error) {
+// AccountRename renames an account, moving its data directory and updating
+// references to the account in the domains configuration. The account must not
+// be in use, e.g. by IMAP sessions, and must not have queued messages, webhooks
+// or suppressed addresses. Accounts referenced in mox.conf cannot be renamed.
+func AccountRename(ctx context.Context, oldName, newName string) (rerr error) {
 	log := pkglog.WithContext(ctx)
 	defer func() {
 		if rerr != nil {
-			log.Errorx("adding address", rerr, slog.String("address", address), slog.String("account", account))
+			log.Errorx("renaming account", rerr, slog.String("account", oldName), slog.String("newname", newName))
 		}
 	}()
 
+	if newName == "" || newName == "." || newName == ".." || strings.ContainsAny(newName, `/\`) {
+		return requestErrorf(ErrCodeInvalid, "invalid new account name")
+	}
+	if oldName == newName {
+		return requestErrorf(ErrCodeInvalid, "new account name is the same as the current name")
+	}
+	if _, ok := mox.Conf.Account(oldName); !ok {
+		return requestErrorf(ErrCodeAccountNotFound, "account does not exist")
+	}
+
+	if msgs, err := queue.List(ctx, queue.Filter{Account: oldName}, queue.Sort{}); err != nil {
+		return fmt.Errorf("listing queued messages for account: %v", err)
+	} else if len(msgs) > 0 {
+		return requestErrorf(ErrCodeConflict, "account has %d queued message(s)", len(msgs))
+	}
+	if hooks, err := queue.HookList(ctx, queue.HookFilter{Account: oldName}, queue.HookSort{}); err != nil {
+		return fmt.Errorf("listing queued webhooks for account: %v", err)
+	} else if len(hooks) > 0 {
+		return requestErrorf(ErrCodeConflict, "account has %d queued webhook(s)", len(hooks))
+	}
+	if suppressions, err := queue.SuppressionList(ctx, oldName); err != nil {
+		return fmt.Errorf("listing suppressed addresses for account: %v", err)
+	} else if len(suppressions) > 0 {
+		return requestErrorf(ErrCodeConflict, "account has %d suppressed address(es)", len(suppressions))
+	}
+
+	// Clear login sessions, they are stored with the account name. The account is
+	// closed again before renaming.
+	acc, err := store.OpenAccount(log, oldName, false)
+	if err != nil {
+		return fmt.Errorf("open account: %v", err)
+	}
+	err = acc.SessionsClear(ctx, log)
+	xerr := acc.Close()
+	log.Check(xerr, "closing account")
+	if err != nil {
+		return fmt.Errorf("clearing login sessions: %v", err)
+	}
+
 	defer mox.Conf.DynamicLockUnlock()()
 
 	c := mox.Conf.Dynamic
-	a, ok := c.Accounts[account]
-	if !ok {
-		return fmt.Errorf("%w: account does not exist", ErrRequest)
+	if _, ok := c.Accounts[oldName]; !ok {
+		return requestErrorf(ErrCodeAccountNotFound, "account does not exist")
 	}
-
-	var destAddr string
-	if strings.HasPrefix(address, "@") {
-		d, err := dns.ParseDomain(address[1:])
-		if err != nil {
-			return fmt.Errorf("%w: parsing domain: %v", ErrRequest, err)
+	if _, ok := c.Accounts[newName]; ok {
+		return requestErrorf(ErrCodeAccountExists, "account with new name already present")
+	}
+	if mox.Conf.Static.Postmaster.Account == oldName {
+		return requestErrorf(ErrCodeConflict, "account is configured as postmaster account in mox.conf")
+	}
+	if mox.Conf.Static.HostTLSRPT.Account == oldName {
+		return requestErrorf(ErrCodeConflict, "account is configured for host tls reports in mox.conf")
+	}
+
+	// Compose new config without modifying existing data structures. If we fail, we
+	// leave no trace.
+	nc := c
+	nc.Domains = map[string]config.Domain{}
+	for name, d := range c.Domains {
+		if d.DMARC != nil && d.DMARC.Account == oldName {
+			dmarc := *d.DMARC
+			dmarc.Account = newName
+			d.DMARC = &dmarc
 		}
-		dname := d.Name()
-		destAddr = "@" + dname
-		if _, ok := mox.Conf.Dynamic.Domains[dname]; !ok {
-			return fmt.Errorf("%w: domain does not exist", ErrRequest)
-		} else if _, ok := mox.Conf.AccountDestinationsLocked[destAddr]; ok {
-			return fmt.Errorf("%w: catchall address already configured for domain", ErrRequest)
+		if d.TLSRPT != nil && d.TLSRPT.Account == oldName {
+			tlsrpt := *d.TLSRPT
+			tlsrpt.Account = newName
+			d.TLSRPT = &tlsrpt
 		}
-	} else {
+		nc.Domains[name] = d
+	}
+	nc.Accounts = map[string]config.Account{}
+	for name, a := range c.Accounts {
+		if name == oldName {
+			name = newName
+		}
+		nc.Accounts[name] = a
+	}
+
+	// With the dynamic config lock held, the account cannot be opened by its old or
+	// new name until the new config is written.
+	if err := store.RenameAccount(ctx, log, oldName, newName); err != nil {
+		return fmt.Errorf("renaming account data: %v", err)
+	}
+
+	if err := mox.WriteDynamicLocked(ctx, log, nc); err != nil {
+		xerr := store.RenameAccount(context.Background(), log, newName, oldName)
+		log.Check(xerr, "renaming account data back after error")
+		return fmt.Errorf("writing domains.conf: %w", err)
+	}
+
+	log.Info("account renamed", slog.String("account", oldName), slog.String("newname", newName))
+	return nil
+}
+
+// checkAddressAvailable checks that the address after canonicalization is not
+// already configured, and that its localpart does not contain a catchall
+// localpart separator.
+//
+// Must be called with config lock held.
+func checkAddressAvailable(addr smtp.Address) error {
+	dc, ok := mox.Conf.Dynamic.Domains[addr.Domain.Name()]
+	if !ok {
+		return fmt.Errorf("domain does not exist")
+	}
+	lp := mox.CanonicalLocalpart(addr.Localpart, dc)
+	if _, ok := mox.Conf.AccountDestinationsLocked[smtp.NewAddress(lp, addr.Domain).String()]; ok {
+		return fmt.Errorf("canonicalized address %s already configured", smtp.NewAddress(lp, addr.Domain))
+	}
+	for _, sep := range dc.LocalpartCatchallSeparatorsEffective {
+		if strings.Contains(string(addr.Localpart), sep) {
+			return fmt.Errorf("localpart cannot include domain catchall separator %s", sep)
+		}
+	}
+	if _, ok := dc.Aliases[lp.String()]; ok {
+		return fmt.Errorf("address in use as alias")
+	}
+	return nil
+}
+
+// addressDestination validates address for adding as destination to an account,
+// possibly a catchall address as for AddressAdd, and returns the address as used
+// in the config. Must be called with the dynamic config lock held.
+func addressDestination(address string) (string, error) {
+	if !strings.HasPrefix(address, "@") {
 		addr, err := smtp.ParseAddress(address)
 		if err != nil {
-			return fmt.Errorf("%w: parsing email address: %v", ErrRequest, err)
+			return "", requestErrorf(ErrCodeInvalid, "parsing email address: %v", err)
 		}
-
 		if err := checkAddressAvailable(addr); err != nil {
-			return fmt.Errorf("%w: address not available: %v", ErrRequest, err)
+			return "", requestErrorf(ErrCodeAddressInUse, "address not available: %v", err)
+		}
+		return addr.String(), nil
+	}
+
+	prefix := "@"
+	if strings.HasPrefix(address, "@.") {
+		prefix = "@."
+	}
+	d, err := dns.ParseDomain(address[len(prefix):])
+	if err != nil {
+		return "", requestErrorf(ErrCodeInvalid, "parsing domain: %v", err)
+	}
+	dname := d.Name()
+	destAddr := prefix + dname
+	if _, ok := mox.Conf.Dynamic.Domains[dname]; !ok {
+		return "", requestErrorf(ErrCodeDomainNotFound, "domain does not exist")
+	} else if _, ok := mox.Conf.AccountDestinationsLocked[destAddr]; ok {
+		return "", requestErrorf(ErrCodeAddressInUse, "catchall address already configured for domain")
+	}
+	return destAddr, nil
+}
+
+// AddressAdd adds an email address to an account and reloads the configuration. If
+// address starts with an @ it is treated as a catchall address for the domain. If
+// it starts with "@.", it is a catchall for the domain and its subdomains. A
+// catchall "@<domain>" takes precedence over "@.<domain>".
+func AddressAdd(ctx context.Context, address, account string) (rerr error) {
+	log := pkglog.WithContext(ctx)
+	defer func() {
+		if rerr != nil {
+			log.Errorx("adding address", rerr, slog.String("address", address), slog.String("account", account))
 		}
-		destAddr = addr.String()
+	}()
+
+	defer mox.Conf.DynamicLockUnlock()()
+
+	c := mox.Conf.Dynamic
+	a, ok := c.Accounts[account]
+	if !ok {
+		return requestErrorf(ErrCodeAccountNotFound, "account does not exist")
+	}
+
+	destAddr, err := addressDestination(address)
+	if err != nil {
+		return err
 	}
 
 	// Compose new config without modifying existing data structures. If we fail, we
@@ -848,33 +2424,78 @@ func AddressAdd(ctx context.Context, address, account string) (rerr error) {
 	return nil
 }
 
-// AddressRemove removes an email address and reloads the configuration.
-// Address can be a catchall address for the domain of the form "@<domain>".
-//
-// If the address is member of an alias, remove it from from the alias, unless it
-// is the last member.
-func AddressRemove(ctx context.Context, address string) (rerr error) {
+// AddressAddBulk adds multiple email addresses, possibly catchall addresses as
+// for AddressAdd, to an account, with a single rewrite of domains.conf. All
+// addresses are validated first. If any address cannot be added, none are added.
+func AddressAddBulk(ctx context.Context, account string, addresses []string) (rerr error) {
 	log := pkglog.WithContext(ctx)
 	defer func() {
 		if rerr != nil {
-			log.Errorx("removing address", rerr, slog.String("address", address))
+			log.Errorx("adding addresses", rerr, slog.Any("addresses", addresses), slog.String("account", account))
 		}
 	}()
 
+	if len(addresses) == 0 {
+		return requestErrorf(ErrCodeInvalid, "no addresses to add")
+	}
+
 	defer mox.Conf.DynamicLockUnlock()()
 
-	ad, ok := mox.Conf.AccountDestinationsLocked[address]
+	c := mox.Conf.Dynamic
+	a, ok := c.Accounts[account]
+	if !ok {
+		return requestErrorf(ErrCodeAccountNotFound, "account does not exist")
+	}
+
+	nd := maps.Clone(a.Destinations)
+	if nd == nil {
+		nd = map[string]config.Destination{}
+	}
+	for _, address := range addresses {
+		destAddr, err := addressDestination(address)
+		if err != nil {
+			return fmt.Errorf("address %q: %w", address, err)
+		}
+		if _, ok := nd[destAddr]; ok {
+			return requestErrorf(ErrCodeAddressInUse, "address %q specified multiple times", address)
+		}
+		nd[destAddr] = config.Destination{}
+	}
+
+	// Compose new config without modifying existing data structures. If we fail, we
+	// leave no trace.
+	nc := c
+	nc.Accounts = maps.Clone(c.Accounts)
+	a.Destinations = nd
+	nc.Accounts[account] = a
+
+	if err := mox.WriteDynamicLocked(ctx, log, nc); err != nil {
+		return fmt.Errorf("writing domains.conf: %w", err)
+	}
+	log.Info("addresses added", slog.Any("addresses", addresses), slog.String("account", account))
+	return nil
+}
+
+// addressRemovePrepare checks if address can be removed from its account, and
+// returns the account destination, the new config for the account without the
+// address, and the domains, with the address removed as member from aliases if
+// removeAliases is set. If force is set, DMARC and TLS reporting configurations of
+// domains using the address are removed, and queued messages sent from the address
+// are not checked. Must be called with the dynamic config lock held.
+func addressRemovePrepare(ctx context.Context, address string, removeAliases, force bool) (ad mox.AccountDestination, na config.Account, domains map[string]config.Domain, rerr error) {
+	var ok bool
+	ad, ok = mox.Conf.AccountDestinationsLocked[address]
 	if !ok {
-		return fmt.Errorf("%w: address does not exists", ErrRequest)
+		return mox.AccountDestination{}, config.Account{}, nil, requestErrorf(ErrCodeAddressNotFound, "address does not exists")
 	}
 
 	// Compose new config without modifying existing data structures. If we fail, we
 	// leave no trace.
 	a, ok := mox.Conf.Dynamic.Accounts[ad.Account]
 	if !ok {
-		return fmt.Errorf("internal error: cannot find account")
+		return mox.AccountDestination{}, config.Account{}, nil, fmt.Errorf("internal error: cannot find account")
 	}
-	na := a
+	na = a
 	na.Destinations = map[string]config.Destination{}
 	var dropped bool
 	for destAddr, d := range a.Destinations {
@@ -884,8 +2505,23 @@ func AddressRemove(ctx context.Context, address string) (rerr error) {
 			dropped = true
 		}
 	}
+	domains = maps.Clone(mox.Conf.Dynamic.Domains)
+	if !dropped && force {
+		// Remove reporting configurations that use the address.
+		for name, d := range domains {
+			if d.DMARC != nil && smtp.NewAddress(d.DMARC.ParsedLocalpart, d.DMARC.DNSDomain).String() == address {
+				d.DMARC = nil
+				dropped = true
+			}
+			if d.TLSRPT != nil && smtp.NewAddress(d.TLSRPT.ParsedLocalpart, d.TLSRPT.DNSDomain).String() == address {
+				d.TLSRPT = nil
+				dropped = true
+			}
+			domains[name] = d
+		}
+	}
 	if !dropped {
-		return fmt.Errorf("%w: address not removed, likely a postmaster/reporting address", ErrRequest)
+		return mox.AccountDestination{}, config.Account{}, nil, requestErrorf(ErrCodeConflict, "address not removed, likely a postmaster/reporting address")
 	}
 
 	// Also remove matching address from FromIDLoginAddresses, composing a new slice.
@@ -894,20 +2530,20 @@ func AddressRemove(ctx context.Context, address string) (rerr error) {
 	var pa smtp.Address // For non-catchall addresses (most).
 	var err error
 	if strings.HasPrefix(address, "@") {
-		dom, err = dns.ParseDomain(address[1:])
+		dom, err = dns.ParseDomain(strings.TrimPrefix(address[1:], "."))
 		if err != nil {
-			return fmt.Errorf("%w: parsing domain for catchall address: %v", ErrRequest, err)
+			return mox.AccountDestination{}, config.Account{}, nil, requestErrorf(ErrCodeInvalid, "parsing domain for catchall address: %v", err)
 		}
 	} else {
 		pa, err = smtp.ParseAddress(address)
 		if err != nil {
-			return fmt.Errorf("%w: parsing address: %v", ErrRequest, err)
+			return mox.AccountDestination{}, config.Account{}, nil, requestErrorf(ErrCodeInvalid, "parsing address: %v", err)
 		}
 		dom = pa.Domain
 	}
 	dc, ok := mox.Conf.Dynamic.Domains[dom.Name()]
 	if !ok {
-		return fmt.Errorf("%w: unknown domain in address %q", ErrRequest, address)
+		return mox.AccountDestination{}, config.Account{}, nil, requestErrorf(ErrCodeDomainNotFound, "unknown domain in address %q", address)
 	}
 
 	var fromIDLoginAddresses []string
@@ -932,41 +2568,40 @@ func AddressRemove(ctx context.Context, address string) (rerr error) {
 	// Refuse if there is still a TLS public key that references this address.
 	tlspubkeys, err := store.TLSPublicKeyList(ctx, ad.Account)
 	if err != nil {
-		return fmt.Errorf("%w: listing tls public keys for account: %v", ErrRequest, err)
+		return mox.AccountDestination{}, config.Account{}, nil, fmt.Errorf("listing tls public keys for account: %v", err)
 	}
 	for _, tpk := range tlspubkeys {
 		a, err := smtp.ParseAddress(tpk.LoginAddress)
 		if err != nil {
-			return fmt.Errorf("%w: parsing address from tls public key: %v", ErrRequest, err)
+			return mox.AccountDestination{}, config.Account{}, nil, requestErrorf(ErrCodeInvalid, "parsing address from tls public key: %v", err)
 		}
 		lp := mox.CanonicalLocalpart(a.Localpart, dc)
 		ca := smtp.NewAddress(lp, a.Domain)
 		if xad, ok := mox.Conf.AccountDestinationsLocked[ca.String()]; ok && xad.Localpart == ad.Localpart {
-			return fmt.Errorf("%w: tls public key %q references this address as login address %q, remove the tls public key before removing the address", ErrRequest, tpk.Fingerprint, tpk.LoginAddress)
+			return mox.AccountDestination{}, config.Account{}, nil, requestErrorf(ErrCodeConflict, "tls public key %q references this address as login address %q, remove the tls public key before removing the address", tpk.Fingerprint, tpk.LoginAddress)
 		}
 	}
 
 	// And remove as member from aliases configured in domains.
-	domains := maps.Clone(mox.Conf.Dynamic.Domains)
 	for _, aa := range na.Aliases {
-		if aa.SubscriptionAddress != address {
+		if !removeAliases || aa.SubscriptionAddress != address {
 			continue
 		}
 
 		aliasAddr := fmt.Sprintf("%s@%s", aa.Alias.LocalpartStr, aa.Alias.Domain.Name())
 
-		dom, ok := mox.Conf.Dynamic.Domains[aa.Alias.Domain.Name()]
+		dom, ok := domains[aa.Alias.Domain.Name()]
 		if !ok {
-			return fmt.Errorf("cannot find domain for alias %s", aliasAddr)
+			return mox.AccountDestination{}, config.Account{}, nil, fmt.Errorf("cannot find domain for alias %s", aliasAddr)
 		}
 		a, ok := dom.Aliases[aa.Alias.LocalpartStr]
 		if !ok {
-			return fmt.Errorf("cannot find alias %s", aliasAddr)
+			return mox.AccountDestination{}, config.Account{}, nil, fmt.Errorf("cannot find alias %s", aliasAddr)
 		}
 		a.Addresses = slices.Clone(a.Addresses)
 		a.Addresses = slices.DeleteFunc(a.Addresses, func(v string) bool { return v == address })
 		if len(a.Addresses) == 0 {
-			return fmt.Errorf("address is last member of alias %s, add new members or remove alias first", aliasAddr)
+			return mox.AccountDestination{}, config.Account{}, nil, fmt.Errorf("address is last member of alias %s, add new members or remove alias first", aliasAddr)
 		}
 		a.ParsedAddresses = nil // Filled when parsing config.
 		dom.Aliases = maps.Clone(dom.Aliases)
@@ -975,16 +2610,20 @@ func AddressRemove(ctx context.Context, address string) (rerr error) {
 	}
 	na.Aliases = nil // Filled when parsing config.
 
+	if force {
+		return ad, na, domains, nil
+	}
+
 	// Check that no message in the queue is for this address. The new account config
 	// must still match this address.
 	msgs, err := queue.List(ctx, queue.Filter{Account: ad.Account}, queue.Sort{})
 	if err != nil {
-		return fmt.Errorf("listing messages in queue for account: %v", err)
+		return mox.AccountDestination{}, config.Account{}, nil, fmt.Errorf("listing messages in queue for account: %v", err)
 	}
 	for _, m := range msgs {
 		dc, ok := mox.Conf.Dynamic.Domains[m.SenderDomainStr]
 		if !ok {
-			return fmt.Errorf("%w: unknown sender domain %q in queued message", ErrRequest, m.SenderDomainStr)
+			return mox.AccountDestination{}, config.Account{}, nil, requestErrorf(ErrCodeConflict, "unknown sender domain %q in queued message", m.SenderDomainStr)
 		}
 		lp := mox.CanonicalLocalpart(m.SenderLocalpart, dc)
 		sa := smtp.NewAddress(lp, m.SenderDomain.Domain).String()
@@ -992,16 +2631,57 @@ func AddressRemove(ctx context.Context, address string) (rerr error) {
 			// We are removing the catchall address. The queued message sender address must be
 			// configured explicitly to still belong to the account.
 			if xad, ok := mox.Conf.AccountDestinationsLocked[sa]; !ok || xad.Account != ad.Account {
-				return fmt.Errorf("%w: message delivery queue contains message with sender address %q that depends on the catchall address, drop message from queue first", ErrRequest, sa)
+				return mox.AccountDestination{}, config.Account{}, nil, requestErrorf(ErrCodeConflict, "message delivery queue contains message with sender address %q that depends on the catchall address, drop message from queue first", sa)
 			}
 		} else {
 			// We are removing a regular address. If the queued message matches the address,
 			// the catchall address must be configured for this account.
-			if xad, ok := mox.Conf.AccountDestinationsLocked["@"+m.SenderDomainStr]; (!ok || xad.Account != ad.Account) && sa == address {
-				return fmt.Errorf("%w: message delivery queue contains message with sender address %q and no catchall address is configured, drop message from queue first", ErrRequest, sa)
+			xad, ok := mox.Conf.AccountDestinationsLocked["@"+m.SenderDomainStr]
+			if !ok {
+				xad, ok = mox.Conf.AccountDestinationsLocked["@."+m.SenderDomainStr]
+			}
+			if (!ok || xad.Account != ad.Account) && sa == address {
+				return mox.AccountDestination{}, config.Account{}, nil, requestErrorf(ErrCodeConflict, "message delivery queue contains message with sender address %q and no catchall address is configured, drop message from queue first", sa)
 			}
 		}
 	}
+	return ad, na, domains, nil
+}
+
+// AddressRemove removes an email address and reloads the configuration.
+// Address can be a catchall address for the domain of the form "@<domain>", or
+// "@.<domain>" for the domain and its subdomains.
+//
+// If the address is member of an alias, remove it from from the alias, unless it
+// is the last member.
+func AddressRemove(ctx context.Context, address string) (rerr error) {
+	return addressRemove(ctx, address, false)
+}
+
+// AddressRemoveForce is like AddressRemove, but also removes addresses that are
+// in use for DMARC or TLS reporting of domains, removing the reporting
+// configuration for those domains, and removes addresses that queued messages are
+// sent from. Incoming reports for the domains will no longer be accepted, and
+// DSNs for the queued messages may not be deliverable. An address referenced by a
+// TLS public key as login address is still not removed.
+func AddressRemoveForce(ctx context.Context, address string) (rerr error) {
+	return addressRemove(ctx, address, true)
+}
+
+func addressRemove(ctx context.Context, address string, force bool) (rerr error) {
+	log := pkglog.WithContext(ctx)
+	defer func() {
+		if rerr != nil {
+			log.Errorx("removing address", rerr, slog.String("address", address), slog.Bool("force", force))
+		}
+	}()
+
+	defer mox.Conf.DynamicLockUnlock()()
+
+	ad, na, domains, err := addressRemovePrepare(ctx, address, true, force)
+	if err != nil {
+		return err
+	}
 
 	nc := mox.Conf.Dynamic
 	nc.Accounts = map[string]config.Account{}
@@ -1012,125 +2692,1369 @@ func AddressRemove(ctx context.Context, address string) (rerr error) {
 	if err := mox.WriteDynamicLocked(ctx, log, nc); err != nil {
 		return fmt.Errorf("writing domains.conf: %w", err)
 	}
-	log.Info("address removed", slog.String("address", address), slog.String("account", ad.Account))
+	log.Info("address removed", slog.String("address", address), slog.String("account", ad.Account), slog.Bool("force", force))
 	return nil
 }
 
-func AliasAdd(ctx context.Context, addr smtp.Address, alias config.Alias) error {
-	return DomainSave(ctx, addr.Domain.Name(), func(d *config.Domain) error {
-		if _, ok := d.Aliases[addr.Localpart.String()]; ok {
-			return fmt.Errorf("%w: alias already present", ErrRequest)
-		}
-		if d.Aliases == nil {
-			d.Aliases = map[string]config.Alias{}
+// AddressMove moves an address, possibly a catchall address, from account
+// fromAccount to toAccount, keeping its destination settings, such as rulesets.
+// The address remains a member of aliases it is a member of. The same checks as
+// for AddressRemove apply for the source account.
+func AddressMove(ctx context.Context, address, fromAccount, toAccount string) (rerr error) {
+	log := pkglog.WithContext(ctx)
+	defer func() {
+		if rerr != nil {
+			log.Errorx("moving address", rerr, slog.String("address", address), slog.String("from", fromAccount), slog.String("to", toAccount))
 		}
-		d.Aliases = maps.Clone(d.Aliases)
-		d.Aliases[addr.Localpart.String()] = alias
-		return nil
-	})
-}
+	}()
 
-func AliasUpdate(ctx context.Context, addr smtp.Address, alias config.Alias) error {
-	return DomainSave(ctx, addr.Domain.Name(), func(d *config.Domain) error {
-		a, ok := d.Aliases[addr.Localpart.String()]
-		if !ok {
-			return fmt.Errorf("%w: alias does not exist", ErrRequest)
-		}
-		a.PostPublic = alias.PostPublic
-		a.ListMembers = alias.ListMembers
-		a.AllowMsgFrom = alias.AllowMsgFrom
-		d.Aliases = maps.Clone(d.Aliases)
-		d.Aliases[addr.Localpart.String()] = a
-		return nil
-	})
-}
+	if fromAccount == toAccount {
+		return requestErrorf(ErrCodeInvalid, "source and destination account are the same")
+	}
 
-func AliasRemove(ctx context.Context, addr smtp.Address) error {
-	return DomainSave(ctx, addr.Domain.Name(), func(d *config.Domain) error {
-		_, ok := d.Aliases[addr.Localpart.String()]
-		if !ok {
-			return fmt.Errorf("%w: alias does not exist", ErrRequest)
-		}
-		d.Aliases = maps.Clone(d.Aliases)
-		delete(d.Aliases, addr.Localpart.String())
-		return nil
-	})
-}
+	defer mox.Conf.DynamicLockUnlock()()
 
-func AliasAddressesAdd(ctx context.Context, addr smtp.Address, addresses []string) error {
-	if len(addresses) == 0 {
-		return fmt.Errorf("%w: at least one address required", ErrRequest)
+	acc, ok := mox.Conf.Dynamic.Accounts[toAccount]
+	if !ok {
+		return requestErrorf(ErrCodeAccountNotFound, "account %q does not exist", toAccount)
 	}
-	return DomainSave(ctx, addr.Domain.Name(), func(d *config.Domain) error {
-		alias, ok := d.Aliases[addr.Localpart.String()]
-		if !ok {
-			return fmt.Errorf("%w: no such alias", ErrRequest)
-		}
-		alias.Addresses = append(slices.Clone(alias.Addresses), addresses...)
-		alias.ParsedAddresses = nil
-		d.Aliases = maps.Clone(d.Aliases)
-		d.Aliases[addr.Localpart.String()] = alias
-		return nil
-	})
-}
 
-func AliasAddressesRemove(ctx context.Context, addr smtp.Address, addresses []string) error {
-	if len(addresses) == 0 {
-		return fmt.Errorf("%w: need at least one address", ErrRequest)
+	ad, na, domains, err := addressRemovePrepare(ctx, address, false, false)
+	if err != nil {
+		return err
 	}
-	return DomainSave(ctx, addr.Domain.Name(), func(d *config.Domain) error {
-		alias, ok := d.Aliases[addr.Localpart.String()]
-		if !ok {
-			return fmt.Errorf("%w: no such alias", ErrRequest)
-		}
-		alias.Addresses = slices.DeleteFunc(slices.Clone(alias.Addresses), func(addr string) bool {
-			n := len(addresses)
-			addresses = slices.DeleteFunc(addresses, func(a string) bool { return a == addr })
-			return n > len(addresses)
-		})
-		if len(addresses) > 0 {
-			return fmt.Errorf("%w: address not found: %s", ErrRequest, strings.Join(addresses, ", "))
-		}
-		alias.ParsedAddresses = nil
-		d.Aliases = maps.Clone(d.Aliases)
-		d.Aliases[addr.Localpart.String()] = alias
-		return nil
-	})
+	if ad.Account != fromAccount {
+		return requestErrorf(ErrCodeAddressNotFound, "address does not belong to account %q", fromAccount)
+	}
+
+	nacc := acc
+	nacc.Destinations = maps.Clone(acc.Destinations)
+	if nacc.Destinations == nil {
+		nacc.Destinations = map[string]config.Destination{}
+	}
+	nacc.Destinations[address] = mox.Conf.Dynamic.Accounts[fromAccount].Destinations[address]
+
+	nc := mox.Conf.Dynamic
+	nc.Accounts = map[string]config.Account{}
+	maps.Copy(nc.Accounts, mox.Conf.Dynamic.Accounts)
+	nc.Accounts[fromAccount] = na
+	nc.Accounts[toAccount] = nacc
+	nc.Domains = domains
+
+	if err := mox.WriteDynamicLocked(ctx, log, nc); err != nil {
+		return fmt.Errorf("writing domains.conf: %w", err)
+	}
+	log.Info("address moved", slog.String("address", address), slog.String("from", fromAccount), slog.String("to", toAccount))
+	return nil
 }
 
-// AccountSave updates the configuration of an account. Function xmodify is called
-// with a shallow copy of the current configuration of the account. It must not
-// change referencing fields (e.g. existing slice/map/pointer), they may still be
-// in use, and the change may be rolled back. Referencing values must be copied and
-// replaced by the modify. The function may raise a panic for error handling.
-func AccountSave(ctx context.Context, account string, xmodify func(acc *config.Account)) (rerr error) {
+// AccountPrimaryAddressChange replaces the primary address of an account with
+// newAddress, e.g. after a name change, with a single rewrite of domains.conf.
+// The new address gets the destination settings, such as rulesets, of the old
+// address, replaces the old address in FromIDLoginAddresses, and, if the old
+// address is removed, replaces it as member of aliases. If keepOld is set, the
+// old address remains configured for the account, so email to the old address is
+// still delivered. Otherwise the old address is removed with the same checks as
+// for AddressRemove.
+//
+// The primary address is derived: it is the address of the account in its
+// default domain that is in FromIDLoginAddresses, or otherwise the address with
+// the account name as localpart, or otherwise the only address in the default
+// domain. The new address must be in the default domain of the account.
+func AccountPrimaryAddressChange(ctx context.Context, account, newAddress string, keepOld bool) (rerr error) {
 	log := pkglog.WithContext(ctx)
 	defer func() {
 		if rerr != nil {
-			log.Errorx("saving account fields", rerr, slog.String("account", account))
+			log.Errorx("changing primary address of account", rerr, slog.String("account", account), slog.String("newaddress", newAddress), slog.Bool("keepold", keepOld))
 		}
 	}()
 
+	if strings.HasPrefix(newAddress, "@") {
+		return requestErrorf(ErrCodeInvalid, "new primary address cannot be a catchall address")
+	}
+
 	defer mox.Conf.DynamicLockUnlock()()
 
-	c := mox.Conf.Dynamic
-	acc, ok := c.Accounts[account]
+	acc, ok := mox.Conf.Dynamic.Accounts[account]
 	if !ok {
-		return fmt.Errorf("%w: account not present", ErrRequest)
+		return requestErrorf(ErrCodeAccountNotFound, "account does not exist")
 	}
+	destAddr, err := addressDestination(newAddress)
+	if err != nil {
+		return err
+	}
+	addr, err := smtp.ParseAddress(destAddr)
+	if err != nil {
+		return requestErrorf(ErrCodeInvalid, "parsing address: %v", err)
+	}
+	if addr.Domain != acc.DNSDomain {
+		return requestErrorf(ErrCodeInvalid, "new primary address must be in default domain %s of account", acc.DNSDomain.Name())
+	}
+	dc := mox.Conf.Dynamic.Domains[acc.DNSDomain.Name()]
 
-	xmodify(&acc)
-
-	// Compose new config without modifying existing data structures. If we fail, we
-	// leave no trace.
-	nc := c
-	nc.Accounts = map[string]config.Account{}
-	maps.Copy(nc.Accounts, c.Accounts)
-	nc.Accounts[account] = acc
+	oldAddr, err := accountPrimaryAddress(account, acc, dc)
+	if err != nil {
+		return err
+	}
 
-	if err := mox.WriteDynamicLocked(ctx, log, nc); err != nil {
-		return fmt.Errorf("writing domains.conf: %w", err)
+	// Replace the old address in FromIDLoginAddresses.
+	var fromIDLoginAddresses []string
+	var fromID bool
+	for i, fa := range acc.ParsedFromIDLoginAddresses {
+		if smtp.NewAddress(mox.CanonicalLocalpart(fa.Localpart, dc), fa.Domain).String() == oldAddr {
+			fromID = true
+		} else {
+			fromIDLoginAddresses = append(fromIDLoginAddresses, acc.FromIDLoginAddresses[i])
+		}
+	}
+	if fromID {
+		fromIDLoginAddresses = append(fromIDLoginAddresses, destAddr)
+	}
+
+	na := acc
+	domains := mox.Conf.Dynamic.Domains
+	if keepOld {
+		na.Destinations = maps.Clone(acc.Destinations)
+	} else {
+		_, na, domains, err = addressRemovePrepare(ctx, oldAddr, false, false)
+		if err != nil {
+			return err
+		}
+
+		// Replace the old address as alias member.
+		for _, aa := range acc.Aliases {
+			if aa.SubscriptionAddress != oldAddr {
+				continue
+			}
+			dom := domains[aa.Alias.Domain.Name()]
+			a, ok := dom.Aliases[aa.Alias.LocalpartStr]
+			if !ok {
+				return fmt.Errorf("cannot find alias %s@%s", aa.Alias.LocalpartStr, aa.Alias.Domain.Name())
+			}
+			a.Addresses = slices.Clone(a.Addresses)
+			for i, v := range a.Addresses {
+				if v == oldAddr {
+					a.Addresses[i] = destAddr
+				}
+			}
+			a.ParsedAddresses = nil // Filled when parsing config.
+			dom.Aliases = maps.Clone(dom.Aliases)
+			dom.Aliases[aa.Alias.LocalpartStr] = a
+			domains[aa.Alias.Domain.Name()] = dom
+		}
+	}
+	na.Destinations[destAddr] = acc.Destinations[oldAddr]
+	na.FromIDLoginAddresses = fromIDLoginAddresses
+
+	nc := mox.Conf.Dynamic
+	nc.Accounts = maps.Clone(mox.Conf.Dynamic.Accounts)
+	nc.Accounts[account] = na
+	nc.Domains = domains
+
+	if err := mox.WriteDynamicLocked(ctx, log, nc); err != nil {
+		return fmt.Errorf("writing domains.conf: %w", err)
+	}
+	log.Info("primary address of account changed", slog.String("account", account), slog.String("oldaddress", oldAddr), slog.String("newaddress", destAddr), slog.Bool("keepold", keepOld))
+	return nil
+}
+
+// accountPrimaryAddress returns the primary address of an account, see
+// AccountPrimaryAddressChange. Must be called with the dynamic config lock held.
+func accountPrimaryAddress(account string, acc config.Account, dc config.Domain) (string, error) {
+	var addrs, fromIDAddrs []string
+	var nameAddr string
+	for destAddr := range acc.Destinations {
+		if strings.HasPrefix(destAddr, "@") {
+			continue
+		}
+		a, err := smtp.ParseAddress(destAddr)
+		if err != nil || a.Domain != acc.DNSDomain {
+			continue
+		}
+		addrs = append(addrs, destAddr)
+		if a.Localpart.String() == account {
+			nameAddr = destAddr
+		}
+		for _, fa := range acc.ParsedFromIDLoginAddresses {
+			if smtp.NewAddress(mox.CanonicalLocalpart(fa.Localpart, dc), fa.Domain).String() == destAddr {
+				fromIDAddrs = append(fromIDAddrs, destAddr)
+				break
+			}
+		}
+	}
+	switch {
+	case len(fromIDAddrs) == 1:
+		return fromIDAddrs[0], nil
+	case len(fromIDAddrs) == 0 && nameAddr != "":
+		return nameAddr, nil
+	case len(fromIDAddrs) == 0 && len(addrs) == 1:
+		return addrs[0], nil
+	case len(addrs) == 0:
+		return "", requestErrorf(ErrCodeConflict, "account has no address in its default domain")
+	}
+	return "", requestErrorf(ErrCodeConflict, "primary address of account is ambiguous")
+}
+
+// AliasList returns the aliases of domain, sorted by localpart, with their parsed
+// addresses.
+func AliasList(ctx context.Context, domain dns.Domain) ([]config.Alias, error) {
+	dc, ok := mox.Conf.Domain(domain)
+	if !ok {
+		return nil, requestErrorf(ErrCodeDomainNotFound, "domain does not exist")
+	}
+	l := slices.Collect(maps.Values(dc.Aliases))
+	slices.SortFunc(l, func(a, b config.Alias) int {
+		return strings.Compare(a.LocalpartStr, b.LocalpartStr)
+	})
+	return l, nil
+}
+
+// AliasGet returns the alias for addr, with its parsed addresses.
+func AliasGet(ctx context.Context, addr smtp.Address) (config.Alias, error) {
+	dc, ok := mox.Conf.Domain(addr.Domain)
+	if !ok {
+		return config.Alias{}, requestErrorf(ErrCodeDomainNotFound, "domain does not exist")
+	}
+	a, ok := dc.Aliases[addr.Localpart.String()]
+	if !ok {
+		return config.Alias{}, requestErrorf(ErrCodeAliasNotFound, "alias does not exist")
+	}
+	return a, nil
+}
+
+// AliasMember is a member address of an alias, with the account it resolves to.
+type AliasMember struct {
+	Address    string
+	Account    string // Account the address is configured for, or "unknown".
+	Configured bool   // Whether the address is currently a destination of an account.
+}
+
+// AliasMembersList returns the member addresses of an alias, with the account
+// each address currently resolves to.
+func AliasMembersList(ctx context.Context, addr smtp.Address) ([]AliasMember, error) {
+	defer mox.Conf.DynamicLockUnlock()()
+
+	dc, ok := mox.Conf.Dynamic.Domains[addr.Domain.Name()]
+	if !ok {
+		return nil, requestErrorf(ErrCodeDomainNotFound, "domain does not exist")
+	}
+	a, ok := dc.Aliases[addr.Localpart.String()]
+	if !ok {
+		return nil, requestErrorf(ErrCodeAliasNotFound, "alias does not exist")
+	}
+	l := make([]AliasMember, len(a.Addresses))
+	for i, s := range a.Addresses {
+		m := AliasMember{Address: s, Account: "unknown"}
+		if pa, err := smtp.ParseAddress(s); err == nil {
+			if ad, ok := mox.Conf.AccountDestinationsLocked[pa.Pack(true)]; ok {
+				m.Account = ad.Account
+				m.Configured = true
+			}
+		}
+		l[i] = m
+	}
+	return l, nil
+}
+
+// aliasCheckAddresses checks that each address is configured as a destination
+// of an account, returning an error naming the first unknown address. With
+// allowMissing, unknown addresses are returned in skipped instead, and the
+// configured addresses in addresses. Must be called with the dynamic config lock
+// held.
+func aliasCheckAddresses(l []string, allowMissing bool) (addresses, skipped []string, rerr error) {
+	for _, s := range l {
+		a, err := smtp.ParseAddress(s)
+		if err != nil {
+			return nil, nil, requestErrorf(ErrCodeInvalid, "parsing address %q: %v", s, err)
+		}
+		if _, ok := mox.Conf.AccountDestinationsLocked[a.Pack(true)]; ok {
+			addresses = append(addresses, s)
+		} else if allowMissing {
+			skipped = append(skipped, s)
+		} else {
+			return nil, nil, requestErrorf(ErrCodeAddressNotFound, "address %q is not configured for an account", s)
+		}
+	}
+	if len(addresses) == 0 && len(skipped) > 0 {
+		return nil, nil, requestErrorf(ErrCodeAddressNotFound, "none of the addresses are configured for an account")
+	}
+	return addresses, skipped, nil
+}
+
+// AliasAdd adds an alias. All addresses of the alias must be configured as
+// destinations for accounts. With allowMissing, addresses that are not
+// configured, e.g. external or future addresses, are left out of the alias and
+// returned instead of causing an error, but at least one address must remain.
+func AliasAdd(ctx context.Context, addr smtp.Address, alias config.Alias, allowMissing bool) (skipped []string, rerr error) {
+	err := DomainSave(ctx, addr.Domain.Name(), func(d *config.Domain) error {
+		if _, ok := d.Aliases[addr.Localpart.String()]; ok {
+			return requestErrorf(ErrCodeAliasExists, "alias already present")
+		}
+		addresses, xskipped, err := aliasCheckAddresses(alias.Addresses, allowMissing)
+		if err != nil {
+			return err
+		}
+		skipped = xskipped
+		alias.Addresses = addresses
+		if d.Aliases == nil {
+			d.Aliases = map[string]config.Alias{}
+		}
+		d.Aliases = maps.Clone(d.Aliases)
+		d.Aliases[addr.Localpart.String()] = alias
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return skipped, nil
+}
+
+// AliasAddWithMembers adds an alias with members in a single config change. The
+// Addresses of alias are replaced by members, of which at least one is required.
+// Members must be configured as destinations for accounts. With allowMissing,
+// members that are not configured are skipped and returned instead of causing an
+// error, but at least one member must remain.
+func AliasAddWithMembers(ctx context.Context, addr smtp.Address, alias config.Alias, members []string, allowMissing bool) (skipped []string, rerr error) {
+	if len(members) == 0 {
+		return nil, requestErrorf(ErrCodeInvalid, "at least one member required")
+	}
+	seen := map[string]bool{}
+	for _, s := range members {
+		a, err := smtp.ParseAddress(s)
+		if err != nil {
+			return nil, requestErrorf(ErrCodeInvalid, "parsing address %q: %v", s, err)
+		}
+		if seen[a.Pack(true)] {
+			return nil, requestErrorf(ErrCodeInvalid, "duplicate member %q", s)
+		}
+		seen[a.Pack(true)] = true
+	}
+
+	err := DomainSave(ctx, addr.Domain.Name(), func(d *config.Domain) error {
+		if _, ok := d.Aliases[addr.Localpart.String()]; ok {
+			return requestErrorf(ErrCodeAliasExists, "alias already present")
+		}
+		addresses, xskipped, err := aliasCheckAddresses(members, allowMissing)
+		if err != nil {
+			return err
+		}
+		skipped = xskipped
+		alias.Addresses = addresses
+		alias.ParsedAddresses = nil
+		if d.Aliases == nil {
+			d.Aliases = map[string]config.Alias{}
+		}
+		d.Aliases = maps.Clone(d.Aliases)
+		d.Aliases[addr.Localpart.String()] = alias
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return skipped, nil
+}
+
+func AliasUpdate(ctx context.Context, addr smtp.Address, alias config.Alias) error {
+	return DomainSave(ctx, addr.Domain.Name(), func(d *config.Domain) error {
+		a, ok := d.Aliases[addr.Localpart.String()]
+		if !ok {
+			return requestErrorf(ErrCodeAliasNotFound, "alias does not exist")
+		}
+		a.PostPublic = alias.PostPublic
+		a.ListMembers = alias.ListMembers
+		a.AllowMsgFrom = alias.AllowMsgFrom
+		d.Aliases = maps.Clone(d.Aliases)
+		d.Aliases[addr.Localpart.String()] = a
+		return nil
+	})
+}
+
+// AliasAllowMsgFromAddressesSave sets the addresses that are allowed to send
+// messages to the alias, based on the message From header. If addresses is
+// non-empty, only those senders can send to the alias, instead of members or
+// anyone for aliases with PostPublic. An empty list removes the restriction.
+func AliasAllowMsgFromAddressesSave(ctx context.Context, addr smtp.Address, addresses []string) error {
+	seen := map[smtp.Address]bool{}
+	for _, s := range addresses {
+		a, err := smtp.ParseAddress(s)
+		if err != nil {
+			return requestErrorf(ErrCodeInvalid, "parsing address %q: %v", s, err)
+		}
+		if seen[a] {
+			return requestErrorf(ErrCodeInvalid, "duplicate address %q", s)
+		}
+		seen[a] = true
+	}
+
+	return DomainSave(ctx, addr.Domain.Name(), func(d *config.Domain) error {
+		a, ok := d.Aliases[addr.Localpart.String()]
+		if !ok {
+			return requestErrorf(ErrCodeAliasNotFound, "alias does not exist")
+		}
+		if len(addresses) == 0 {
+			a.AllowMsgFromAddresses = nil
+		} else {
+			a.AllowMsgFromAddresses = slices.Clone(addresses)
+		}
+		d.Aliases = maps.Clone(d.Aliases)
+		d.Aliases[addr.Localpart.String()] = a
+		return nil
+	})
+}
+
+func AliasRemove(ctx context.Context, addr smtp.Address) error {
+	return DomainSave(ctx, addr.Domain.Name(), func(d *config.Domain) error {
+		_, ok := d.Aliases[addr.Localpart.String()]
+		if !ok {
+			return requestErrorf(ErrCodeAliasNotFound, "alias does not exist")
+		}
+		d.Aliases = maps.Clone(d.Aliases)
+		delete(d.Aliases, addr.Localpart.String())
+		return nil
+	})
+}
+
+// AliasAddressesAdd adds member addresses to an alias. The addresses must be
+// configured as destinations for accounts. With allowMissing, addresses that are
+// not configured are not added and returned instead of causing an error, but at
+// least one address must be added.
+func AliasAddressesAdd(ctx context.Context, addr smtp.Address, addresses []string, allowMissing bool) (skipped []string, rerr error) {
+	if len(addresses) == 0 {
+		return nil, requestErrorf(ErrCodeInvalid, "at least one address required")
+	}
+	err := DomainSave(ctx, addr.Domain.Name(), func(d *config.Domain) error {
+		alias, ok := d.Aliases[addr.Localpart.String()]
+		if !ok {
+			return requestErrorf(ErrCodeAliasNotFound, "no such alias")
+		}
+		added, xskipped, err := aliasCheckAddresses(addresses, allowMissing)
+		if err != nil {
+			return err
+		}
+		skipped = xskipped
+		alias.Addresses = append(slices.Clone(alias.Addresses), added...)
+		alias.ParsedAddresses = nil
+		d.Aliases = maps.Clone(d.Aliases)
+		d.Aliases[addr.Localpart.String()] = alias
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return skipped, nil
+}
+
+func AliasAddressesRemove(ctx context.Context, addr smtp.Address, addresses []string) error {
+	if len(addresses) == 0 {
+		return requestErrorf(ErrCodeInvalid, "need at least one address")
+	}
+	return DomainSave(ctx, addr.Domain.Name(), func(d *config.Domain) error {
+		alias, ok := d.Aliases[addr.Localpart.String()]
+		if !ok {
+			return requestErrorf(ErrCodeAliasNotFound, "no such alias")
+		}
+		alias.Addresses = slices.DeleteFunc(slices.Clone(alias.Addresses), func(addr string) bool {
+			n := len(addresses)
+			addresses = slices.DeleteFunc(addresses, func(a string) bool { return a == addr })
+			return n > len(addresses)
+		})
+		if len(addresses) > 0 {
+			return requestErrorf(ErrCodeAddressNotFound, "address not found: %s", strings.Join(addresses, ", "))
+		}
+		alias.ParsedAddresses = nil
+		d.Aliases = maps.Clone(d.Aliases)
+		d.Aliases[addr.Localpart.String()] = alias
+		return nil
+	})
+}
+
+// AccountSave updates the configuration of an account. Function xmodify is called
+// with a shallow copy of the current configuration of the account. It must not
+// change referencing fields (e.g. existing slice/map/pointer), they may still be
+// in use, and the change may be rolled back. Referencing values must be copied and
+// replaced by the modify. The function may raise a panic for error handling.
+func AccountSave(ctx context.Context, account string, xmodify func(acc *config.Account)) (rerr error) {
+	log := pkglog.WithContext(ctx)
+	defer func() {
+		if rerr != nil {
+			log.Errorx("saving account fields", rerr, slog.String("account", account))
+		}
+	}()
+
+	defer mox.Conf.DynamicLockUnlock()()
+
+	c := mox.Conf.Dynamic
+	acc, ok := c.Accounts[account]
+	if !ok {
+		return requestErrorf(ErrCodeAccountNotFound, "account not present")
+	}
+
+	xmodify(&acc)
+
+	// Compose new config without modifying existing data structures. If we fail, we
+	// leave no trace.
+	nc := c
+	nc.Accounts = map[string]config.Account{}
+	maps.Copy(nc.Accounts, c.Accounts)
+	nc.Accounts[account] = acc
+
+	if err := mox.WriteDynamicLocked(ctx, log, nc); err != nil {
+		return fmt.Errorf("writing domains.conf: %w", err)
 	}
 	log.Info("account fields saved", slog.String("account", account))
 	return nil
 }
+
+// AccountInfo is a summary of an account, as returned by AccountList.
+type AccountInfo struct {
+	Name          string
+	Addresses     int      // Number of configured destination addresses, including catchall addresses.
+	Password      bool     // Whether a password is set.
+	JunkFilter    bool     // Whether the junk filter is enabled.
+	LoginDisabled bool     // Whether logins are disabled.
+	Domains       []string // Sorted names of domains of the account and its addresses.
+}
+
+// AccountList returns a summary of all configured accounts, sorted by name.
+func AccountList(ctx context.Context) ([]AccountInfo, error) {
+	log := pkglog.WithContext(ctx)
+
+	// Accounts are opened without holding the dynamic config lock, the consistency
+	// checker run when closing accounts during tests takes the same lock.
+	c := mox.Conf.DynamicConfig()
+	l := make([]AccountInfo, 0, len(c.Accounts))
+	for name, acc := range c.Accounts {
+		domains := map[string]struct{}{acc.Domain: {}}
+		for addr := range acc.Destinations {
+			if strings.HasPrefix(addr, "@") {
+				domains[strings.TrimPrefix(addr[1:], ".")] = struct{}{}
+			} else if a, err := smtp.ParseAddress(addr); err == nil {
+				domains[a.Domain.Name()] = struct{}{}
+			}
+		}
+		password, err := accountHasPassword(ctx, log, name)
+		if err != nil {
+			return nil, fmt.Errorf("checking password for account %q: %v", name, err)
+		}
+		l = append(l, AccountInfo{
+			Name:          name,
+			Addresses:     len(acc.Destinations),
+			Password:      password,
+			JunkFilter:    acc.JunkFilter != nil,
+			LoginDisabled: acc.LoginDisabled != "",
+			Domains:       slices.Sorted(maps.Keys(domains)),
+		})
+	}
+	slices.SortFunc(l, func(a, b AccountInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return l, nil
+}
+
+// AccountSettingsGet returns the effective configuration of account, including
+// fields derived when loading the configuration, such as
+// ParsedFromIDLoginAddresses. The returned value is a deep copy, changing it does
+// not change the configuration, see AccountSave for that. Compiled regular
+// expressions are shared, they are immutable.
+func AccountSettingsGet(ctx context.Context, account string) (config.Account, error) {
+	acc, ok := mox.Conf.Account(account)
+	if !ok {
+		return config.Account{}, requestErrorf(ErrCodeAccountNotFound, "account does not exist")
+	}
+	return configCopy(reflect.ValueOf(acc)).Interface().(config.Account), nil
+}
+
+// accountHasPassword returns whether a password is set for the account. An
+// account that has never been opened, and has no database yet, has no password.
+func accountHasPassword(ctx context.Context, log mlog.Log, name string) (bool, error) {
+	dbpath := filepath.Join(mox.DataDirPath("accounts"), name, "index.db")
+	if _, err := os.Stat(dbpath); err != nil && errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	acc, err := store.OpenAccount(log, name, false)
+	if err != nil {
+		return false, fmt.Errorf("open account: %v", err)
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+	var exists bool
+	err = acc.DB.Read(ctx, func(tx *bstore.Tx) error {
+		exists, err = bstore.QueryTx[store.Password](tx).Exists()
+		return err
+	})
+	return exists, err
+}
+
+// AccountLoginDisable disables logins for account on all protocols, with message
+// as the error shown to the user. An empty message enables logins again.
+// Incoming deliveries for the account are still accepted while logins are
+// disabled. When disabling, existing web login sessions are removed.
+func AccountLoginDisable(ctx context.Context, account, message string) error {
+	log := pkglog.WithContext(ctx)
+
+	if _, ok := mox.Conf.Account(account); !ok {
+		return requestErrorf(ErrCodeAccountNotFound, "account does not exist")
+	}
+	acc, err := store.OpenAccount(log, account, false)
+	if err != nil {
+		return fmt.Errorf("open account: %v", err)
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	err = AccountSave(ctx, account, func(acc *config.Account) {
+		acc.LoginDisabled = message
+	})
+	if err != nil {
+		return err
+	}
+	if message == "" {
+		return nil
+	}
+	if err := acc.SessionsClear(ctx, log); err != nil {
+		return fmt.Errorf("clearing login sessions: %v", err)
+	}
+	return nil
+}
+
+// AccountOutgoingLimitsSave sets the maximum number of outgoing messages and of
+// first-time recipients in a 24 hour window for an account. Submissions over the
+// limits are rejected with a temporary error. Zero means unlimited, stored as a
+// negative value in the account config, where zero means the default limits of
+// 1000 messages and 200 first-time recipients.
+func AccountOutgoingLimitsSave(ctx context.Context, account string, perDay, firstTimeRecipients int) error {
+	if perDay < 0 || firstTimeRecipients < 0 {
+		return requestErrorf(ErrCodeInvalid, "limits cannot be negative, use 0 for unlimited")
+	}
+	if perDay == 0 {
+		perDay = -1
+	}
+	if firstTimeRecipients == 0 {
+		firstTimeRecipients = -1
+	}
+	return AccountSave(ctx, account, func(acc *config.Account) {
+		acc.MaxOutgoingMessagesPerDay = perDay
+		acc.MaxFirstTimeRecipientsPerDay = firstTimeRecipients
+	})
+}
+
+// AccountAddressList returns the sorted destination addresses of an account,
+// including catchall addresses of the form "@domain" (or "@.domain" for
+// subdomains).
+func AccountAddressList(ctx context.Context, account string) ([]string, error) {
+	acc, ok := mox.Conf.Account(account)
+	if !ok {
+		return nil, requestErrorf(ErrCodeAccountNotFound, "account does not exist")
+	}
+	l := slices.Collect(maps.Keys(acc.Destinations))
+	slices.Sort(l)
+	return l, nil
+}
+
+// SuppressionAdd adds a manual suppression for address to the suppression list
+// of account, preventing messages from being sent to the address. Suppressions
+// are stored for the base address, with the localpart simplified, so similar
+// addresses are suppressed as well. ErrRequest is returned if the (base) address
+// is already suppressed.
+func SuppressionAdd(ctx context.Context, account, address, reason string) error {
+	if _, ok := mox.Conf.Account(account); !ok {
+		return requestErrorf(ErrCodeAccountNotFound, "account does not exist")
+	}
+	addr, err := smtp.ParseAddress(address)
+	if err != nil {
+		return requestErrorf(ErrCodeInvalid, "parsing address: %v", err)
+	}
+	sup := webapi.Suppression{
+		Account: account,
+		Manual:  true,
+		Reason:  reason,
+	}
+	if err := queue.SuppressionAdd(ctx, addr.Path(), &sup); err != nil && errors.Is(err, bstore.ErrUnique) {
+		return requestErrorf(ErrCodeExists, "address already suppressed")
+	} else if err != nil {
+		return fmt.Errorf("adding suppression: %v", err)
+	}
+	return nil
+}
+
+// TLSPublicKeyAdd adds a public key for TLS client authentication for account,
+// from the certificate in certPEM. Connections authenticated with the key log in
+// with loginAddress, which must be an address of the account. The name of the key
+// is taken from the certificate. ErrRequest is returned if the key already exists.
+func TLSPublicKeyAdd(ctx context.Context, account, loginAddress string, certPEM []byte) (store.TLSPublicKey, error) {
+	log := pkglog.WithContext(ctx)
+
+	if _, ok := mox.Conf.Account(account); !ok {
+		return store.TLSPublicKey{}, requestErrorf(ErrCodeAccountNotFound, "account does not exist")
+	}
+	addr, err := smtp.ParseAddress(loginAddress)
+	if err != nil {
+		return store.TLSPublicKey{}, requestErrorf(ErrCodeInvalid, "parsing login address: %v", err)
+	}
+	if accDest, _, ok := mox.Conf.AccountDestination(addr.String()); !ok || accDest.Account != account {
+		return store.TLSPublicKey{}, requestErrorf(ErrCodeInvalid, "login address is not an address of the account")
+	}
+
+	block, rest := pem.Decode(certPEM)
+	if block == nil {
+		return store.TLSPublicKey{}, requestErrorf(ErrCodeInvalid, "no pem data found")
+	} else if block.Type != "CERTIFICATE" {
+		return store.TLSPublicKey{}, requestErrorf(ErrCodeInvalid, "unexpected pem type %q, need CERTIFICATE", block.Type)
+	} else if len(rest) != 0 {
+		return store.TLSPublicKey{}, requestErrorf(ErrCodeInvalid, "only single pem block allowed")
+	}
+	tpk, err := store.ParseTLSPublicKeyCert(block.Bytes)
+	if err != nil {
+		return store.TLSPublicKey{}, requestErrorf(ErrCodeInvalid, "%v", err)
+	}
+	tpk.Account = account
+	tpk.LoginAddress = addr.String()
+	if err := store.TLSPublicKeyAdd(ctx, &tpk); err != nil && errors.Is(err, bstore.ErrUnique) {
+		return store.TLSPublicKey{}, requestErrorf(ErrCodeExists, "tls public key already exists")
+	} else if err != nil {
+		return store.TLSPublicKey{}, fmt.Errorf("adding tls public key: %v", err)
+	}
+	log.Info("tls public key added", slog.String("account", account), slog.String("fingerprint", tpk.Fingerprint))
+	return tpk, nil
+}
+
+// TLSPublicKeyRemove removes the TLS public key with fingerprint. ErrRequest is
+// returned if the key does not exist.
+func TLSPublicKeyRemove(ctx context.Context, fingerprint string) error {
+	log := pkglog.WithContext(ctx)
+
+	if err := store.TLSPublicKeyRemove(ctx, fingerprint); err != nil && errors.Is(err, bstore.ErrAbsent) {
+		return requestErrorf(ErrCodeNotFound, "tls public key does not exist")
+	} else if err != nil {
+		return fmt.Errorf("removing tls public key: %v", err)
+	}
+	log.Info("tls public key removed", slog.String("fingerprint", fingerprint))
+	return nil
+}
+
+// Match types returned by ResolveAddress.
+const (
+	MatchDestination       = "destination"        // Address configured for an account.
+	MatchCatchall          = "catchall"           // Catchall destination "@<domain>".
+	MatchSubdomainCatchall = "subdomain-catchall" // Catchall destination "@.<domain>", for the domain and its subdomains.
+	MatchAlias             = "alias"              // Alias, delivered to its members.
+	MatchPostmaster        = "postmaster"         // Postmaster address, delivered to the configured postmaster account.
+)
+
+// ResolveAddress returns the account that incoming messages for address are
+// delivered to, and how address matched. It uses the same lookup as incoming
+// deliveries: an address configured for an account, then an alias, then a
+// catchall destination for the domain, then one for a parent domain. Localpart
+// catchall separators and case are handled as for deliveries.
+//
+// For a destination or catchall match, detail is the matching configured
+// destination. For an alias, account is empty and detail is the alias address
+// followed by the member addresses and their accounts. Disabled domains are
+// resolved as if enabled. ErrRequest is returned if no account would receive
+// messages for address.
+func ResolveAddress(ctx context.Context, address string) (account, matchType, detail string, rerr error) {
+	addr, err := smtp.ParseAddress(address)
+	if err != nil {
+		return "", "", "", requestErrorf(ErrCodeInvalid, "parsing address: %v", err)
+	}
+
+	accName, alias, canonical, _, err := mox.LookupAddress(addr.Localpart, addr.Domain, true, true, false)
+	if err != nil && (errors.Is(err, mox.ErrAddressNotFound) || errors.Is(err, mox.ErrDomainNotFound)) {
+		return "", "", "", requestErrorf(ErrCodeAddressNotFound, "no account for address: %v", err)
+	} else if err != nil {
+		return "", "", "", fmt.Errorf("looking up address: %v", err)
+	}
+
+	switch {
+	case alias != nil:
+		members := make([]string, len(alias.ParsedAddresses))
+		for i, aa := range alias.ParsedAddresses {
+			members[i] = fmt.Sprintf("%s (account %s)", aa.Address.Pack(true), aa.AccountName)
+		}
+		return "", MatchAlias, fmt.Sprintf("%s: %s", canonical, strings.Join(members, ", ")), nil
+	case canonical == "postmaster":
+		return accName, MatchPostmaster, "postmaster", nil
+	case strings.HasPrefix(canonical, "@."):
+		return accName, MatchSubdomainCatchall, canonical, nil
+	case strings.HasPrefix(canonical, "@"):
+		return accName, MatchCatchall, canonical, nil
+	}
+	return accName, MatchDestination, canonical, nil
+}
+
+// DestinationForwardSave sets the addresses that incoming messages for a
+// destination address of an account are forwarded to, after delivery to the
+// account. An empty forwardTo stops forwarding.
+func DestinationForwardSave(ctx context.Context, address string, forwardTo []string) error {
+	accDest, _, ok := mox.Conf.AccountDestination(address)
+	if !ok {
+		return requestErrorf(ErrCodeAddressNotFound, "destination address does not exist")
+	}
+	for _, s := range forwardTo {
+		fa, err := smtp.ParseAddress(s)
+		if err != nil {
+			return requestErrorf(ErrCodeInvalid, "parsing forward address %q: %v", s, err)
+		}
+		if fa.String() == address {
+			return requestErrorf(ErrCodeInvalid, "cannot forward to the destination address itself")
+		}
+	}
+	if len(forwardTo) == 0 {
+		forwardTo = nil
+	}
+
+	var xerr error
+	err := AccountSave(ctx, accDest.Account, func(acc *config.Account) {
+		// Check again, config may have changed before we got the lock.
+		dest, ok := acc.Destinations[address]
+		if !ok {
+			xerr = requestErrorf(ErrCodeAddressNotFound, "destination address does not exist for account")
+			return
+		}
+		dest.Forward = slices.Clone(forwardTo)
+		acc.Destinations = maps.Clone(acc.Destinations)
+		acc.Destinations[address] = dest
+	})
+	if xerr != nil {
+		return xerr
+	}
+	return err
+}
+
+// QueueDrop removes messages matching filter from the queue without delivering
+// them and without sending DSNs, e.g. to flush messages for a recipient domain
+// that no longer accepts email. An empty filter is rejected, to prevent
+// accidentally dropping all messages. The number of dropped messages is returned.
+func QueueDrop(ctx context.Context, f queue.Filter) (int, error) {
+	log := pkglog.WithContext(ctx)
+
+	if reflect.ValueOf(f).IsZero() {
+		return 0, requestErrorf(ErrCodeInvalid, "filter required")
+	}
+	n, err := queue.Drop(ctx, log, f)
+	if err != nil {
+		return 0, fmt.Errorf("dropping messages from queue: %v", err)
+	}
+	log.Info("dropped messages from queue", slog.Int("count", n))
+	return n, nil
+}
+
+// QueueRequeue schedules messages matching filter for immediate delivery, e.g.
+// after fixing a problem at a receiving server. The next attempt is set to now
+// and the number of attempts is reset, so the backoff for later attempts starts
+// over. The delivery loop is woken up. Messages on hold stay on hold. The number
+// of requeued messages is returned.
+func QueueRequeue(ctx context.Context, f queue.Filter) (int, error) {
+	log := pkglog.WithContext(ctx)
+
+	n, err := queue.Requeue(ctx, f)
+	if err != nil {
+		return 0, fmt.Errorf("requeueing messages: %v", err)
+	}
+	log.Info("requeued messages", slog.Int("count", n))
+	return n, nil
+}
+
+// RulesetDisable sets whether ruleset with index (zero-based) of destination
+// address of account is disabled. Disabled rulesets are skipped when evaluating
+// rulesets for incoming messages.
+func RulesetDisable(ctx context.Context, account, address string, index int, disabled bool) error {
+	acc, ok := mox.Conf.Account(account)
+	if !ok {
+		return requestErrorf(ErrCodeAccountNotFound, "account does not exist")
+	}
+	dest, ok := acc.Destinations[address]
+	if !ok {
+		return requestErrorf(ErrCodeAddressNotFound, "destination address does not exist for account")
+	}
+	if index < 0 || index >= len(dest.Rulesets) {
+		return requestErrorf(ErrCodeNotFound, "ruleset does not exist for destination")
+	}
+
+	var xerr error
+	err := AccountSave(ctx, account, func(acc *config.Account) {
+		// Check again, config may have changed before we got the lock.
+		dest, ok := acc.Destinations[address]
+		if !ok || index >= len(dest.Rulesets) {
+			xerr = requestErrorf(ErrCodeNotFound, "ruleset does not exist for destination")
+			return
+		}
+		dest.Rulesets = slices.Clone(dest.Rulesets)
+		dest.Rulesets[index].Disabled = disabled
+		acc.Destinations = maps.Clone(acc.Destinations)
+		acc.Destinations[address] = dest
+	})
+	if xerr != nil {
+		return xerr
+	}
+	return err
+}
+
+// AccountSetMaxMailboxes sets the maximum number of mailboxes for an account. Zero
+// means the global default maximum applies, a negative value means no maximum.
+// Existing mailboxes are kept if the account has more than the new maximum.
+func AccountSetMaxMailboxes(ctx context.Context, account string, n int) error {
+	return AccountSave(ctx, account, func(acc *config.Account) {
+		acc.MaxMailboxes = n
+	})
+}
+
+// AccountQuotaSave sets the maximum total size of messages in bytes and the
+// maximum number of messages for an account. Zero means no limit, also for
+// maxBytes when a global default maximum size is configured: it is stored as a
+// negative QuotaMessageSize in the account config. Deliveries that would exceed
+// the quota are refused, existing messages are kept.
+func AccountQuotaSave(ctx context.Context, account string, maxBytes int64, maxMessages int) error {
+	if maxBytes < 0 || maxMessages < 0 {
+		return requestErrorf(ErrCodeInvalid, "quota cannot be negative, use 0 for unlimited")
+	}
+	if maxBytes == 0 {
+		maxBytes = -1
+	}
+	return AccountSave(ctx, account, func(acc *config.Account) {
+		acc.QuotaMessageSize = maxBytes
+		acc.QuotaMessageCount = maxMessages
+	})
+}
+
+// AccountRejectsMailboxSave sets the mailbox in which copies of rejected
+// messages are stored for an account. The mailbox is created on first delivery
+// to it. Messages already in the previous rejects mailbox are kept there.
+func AccountRejectsMailboxSave(ctx context.Context, account, mailbox string) error {
+	if mailbox == "" {
+		return requestErrorf(ErrCodeInvalid, "rejects mailbox cannot be empty")
+	}
+	name, _, err := store.CheckMailboxName(mailbox, false)
+	if err != nil {
+		return requestErrorf(ErrCodeInvalid, "invalid rejects mailbox name: %v", err)
+	}
+	return AccountSave(ctx, account, func(acc *config.Account) {
+		acc.RejectsMailbox = name
+	})
+}
+
+// AccountJunkFilterSave sets the junk filter parameters for an account. If jf is
+// nil, the junk filter is disabled for the account. The junk filter database is
+// kept, it is used again when the junk filter is enabled again.
+func AccountJunkFilterSave(ctx context.Context, account string, jf *config.JunkFilter) error {
+	if jf != nil {
+		if jf.Threshold <= 0 || jf.Threshold >= 1 {
+			return requestErrorf(ErrCodeInvalid, "threshold must be between 0 and 1")
+		}
+		if jf.MaxPower <= 0 || jf.MaxPower > .5 {
+			return requestErrorf(ErrCodeInvalid, "max power must be larger than 0 and at most 0.5")
+		}
+		if jf.TopWords <= 0 {
+			return requestErrorf(ErrCodeInvalid, "top words must be larger than 0")
+		}
+		if jf.IgnoreWords < 0 || jf.IgnoreWords >= .5 {
+			return requestErrorf(ErrCodeInvalid, "ignore words must be at least 0 and smaller than 0.5")
+		}
+		if jf.RareWords < 0 {
+			return requestErrorf(ErrCodeInvalid, "rare words cannot be negative")
+		}
+		if !jf.Onegrams && !jf.Twograms && !jf.Threegrams {
+			return requestErrorf(ErrCodeInvalid, "at least one of onegrams, twograms and threegrams must be enabled")
+		}
+		njf := *jf
+		jf = &njf
+	}
+	return AccountSave(ctx, account, func(acc *config.Account) {
+		acc.JunkFilter = jf
+	})
+}
+
+// DomainInfo summarizes the configuration of a domain.
+type DomainInfo struct {
+	Domain               dns.Domain
+	Disabled             bool
+	Description          string
+	ClientSettingsDomain string // Empty if the hostname of the mail server is used.
+	DKIMSelectors        int    // Number of configured DKIM selectors.
+	DKIMSign             int    // Number of DKIM selectors used for signing.
+	DMARC                bool   // Whether DMARC reports are accepted for the domain.
+	MTASTS               bool   // Whether an MTA-STS policy is configured.
+	TLSRPT               bool   // Whether TLS reports are accepted for the domain.
+	Aliases              int    // Number of aliases.
+}
+
+// DomainList returns a summary of all configured domains, sorted by name.
+func DomainList(ctx context.Context) []DomainInfo {
+	c := mox.Conf.DynamicConfig()
+	l := make([]DomainInfo, 0, len(c.Domains))
+	for _, d := range c.Domains {
+		l = append(l, DomainInfo{
+			Domain:               d.Domain,
+			Disabled:             d.Disabled,
+			Description:          d.Description,
+			ClientSettingsDomain: d.ClientSettingsDomain,
+			DKIMSelectors:        len(d.DKIM.Selectors),
+			DKIMSign:             len(d.DKIM.Sign),
+			DMARC:                d.DMARC != nil,
+			MTASTS:               d.MTASTS != nil,
+			TLSRPT:               d.TLSRPT != nil,
+			Aliases:              len(d.Aliases),
+		})
+	}
+	slices.SortFunc(l, func(a, b DomainInfo) int {
+		return strings.Compare(a.Domain.Name(), b.Domain.Name())
+	})
+	return l
+}
+
+// DomainDisable sets whether a domain is disabled. A disabled domain rejects
+// incoming and outgoing messages involving the domain with a temporary error, and
+// does not request new TLS certificates with ACME. Accounts with addresses at the
+// domain can still log in.
+func DomainDisable(ctx context.Context, domain dns.Domain, disabled bool) error {
+	return DomainSave(ctx, domain.Name(), func(d *config.Domain) error {
+		d.Disabled = disabled
+		return nil
+	})
+}
+
+// DomainClientSettingsDomainSave sets the host name used in client settings for
+// a domain, e.g. in autoconfig/autodiscover responses, instead of the hostname of
+// the mail server. A zero clientSettings clears the setting.
+func DomainClientSettingsDomainSave(ctx context.Context, domain, clientSettings dns.Domain) error {
+	var name string
+	if !clientSettings.IsZero() {
+		d, err := dns.ParseDomain(clientSettings.Name())
+		if err != nil {
+			return requestErrorf(ErrCodeInvalid, "invalid client settings domain: %v", err)
+		}
+		name = d.Name()
+	}
+	return DomainSave(ctx, domain.Name(), func(d *config.Domain) error {
+		d.ClientSettingsDomain = name
+		return nil
+	})
+}
+
+// DomainLocalpartConfigSave saves the localpart catchall separators and
+// case-sensitivity for a domain. Each separator must be a single
+// non-alphanumeric character. Separators that are not yet configured cannot be
+// added if they occur in a configured localpart of the domain, e.g. of an account
+// destination, alias, or DMARC/TLS reporting address.
+func DomainLocalpartConfigSave(ctx context.Context, domain dns.Domain, separators []string, caseSensitive bool) error {
+	seen := map[string]bool{}
+	for _, sep := range separators {
+		if utf8.RuneCountInString(sep) != 1 {
+			return requestErrorf(ErrCodeInvalid, "separator %q must be a single character", sep)
+		}
+		c, _ := utf8.DecodeRuneInString(sep)
+		if unicode.IsLetter(c) || unicode.IsDigit(c) || unicode.IsSpace(c) || unicode.IsControl(c) {
+			return requestErrorf(ErrCodeInvalid, "separator %q must not be alphanumeric, whitespace or a control character", sep)
+		}
+		if seen[sep] {
+			return requestErrorf(ErrCodeInvalid, "duplicate separator %q", sep)
+		}
+		seen[sep] = true
+	}
+
+	return DomainSave(ctx, domain.Name(), func(d *config.Domain) error {
+		// Localparts configured for the domain.
+		var localparts []string
+		for _, acc := range mox.Conf.Dynamic.Accounts {
+			for addr := range acc.Destinations {
+				if strings.HasPrefix(addr, "@") {
+					continue
+				}
+				a, err := smtp.ParseAddress(addr)
+				if err == nil && a.Domain == domain {
+					localparts = append(localparts, string(a.Localpart))
+				}
+			}
+		}
+		for lp := range d.Aliases {
+			localparts = append(localparts, lp)
+		}
+		if d.DMARC != nil {
+			localparts = append(localparts, d.DMARC.Localpart)
+		}
+		if d.TLSRPT != nil {
+			localparts = append(localparts, d.TLSRPT.Localpart)
+		}
+
+		// Existing separators may be in use by reporting addresses for backwards
+		// compatibility, we only check new separators.
+		for _, sep := range separators {
+			if slices.Contains(d.LocalpartCatchallSeparatorsEffective, sep) {
+				continue
+			}
+			for _, lp := range localparts {
+				if strings.Contains(lp, sep) {
+					return requestErrorf(ErrCodeConflict, "separator %q is used in configured localpart %q", sep, lp)
+				}
+			}
+		}
+
+		d.LocalpartCatchallSeparatorsEffective = separators
+		// If there is a single separator, we prefer the non-list form, it's easier to
+		// read/edit and should suffice for most setups.
+		d.LocalpartCatchallSeparator = ""
+		d.LocalpartCatchallSeparators = nil
+		if len(separators) == 1 {
+			d.LocalpartCatchallSeparator = separators[0]
+		} else {
+			d.LocalpartCatchallSeparators = separators
+		}
+		d.LocalpartCaseSensitive = caseSensitive
+		return nil
+	})
+}
+
+// MTASTSModeSave changes the mode of the MTA-STS policy of a domain, e.g. from
+// testing to enforce. When the mode changes, the policy ID is changed so remote
+// senders fetch the new policy.
+func MTASTSModeSave(ctx context.Context, domain dns.Domain, mode mtasts.Mode) error {
+	switch mode {
+	case mtasts.ModeEnforce, mtasts.ModeTesting, mtasts.ModeNone:
+	default:
+		return requestErrorf(ErrCodeInvalid, "invalid mta-sts mode %q", mode)
+	}
+	return DomainSave(ctx, domain.Name(), func(d *config.Domain) error {
+		if d.MTASTS == nil {
+			return requestErrorf(ErrCodeNotFound, "domain has no mta-sts policy")
+		}
+		if d.MTASTS.Mode == mode {
+			return nil
+		}
+		sts := *d.MTASTS
+		sts.Mode = mode
+		sts.PolicyID = mtastsPolicyID(sts.PolicyID)
+		d.MTASTS = &sts
+		return nil
+	})
+}
+
+// Bounds for the MaxAge of MTA-STS policies.
+const (
+	MTASTSMaxAgeMin = time.Hour
+	MTASTSMaxAgeMax = 31557600 * time.Second // Maximum allowed by RFC 8461.
+)
+
+// MTASTSMaxAgeSave changes how long remote mail servers may cache the MTA-STS
+// policy of a domain. MaxAge must be between MTASTSMaxAgeMin and MTASTSMaxAgeMax.
+// When the max age changes, the policy ID is changed so remote senders fetch the
+// new policy, the DNS TXT record for MTA-STS must be updated with the new ID.
+func MTASTSMaxAgeSave(ctx context.Context, domain dns.Domain, maxAge time.Duration) error {
+	if maxAge < MTASTSMaxAgeMin || maxAge > MTASTSMaxAgeMax {
+		return requestErrorf(ErrCodeInvalid, "mta-sts max age must be between %v and %v", MTASTSMaxAgeMin, MTASTSMaxAgeMax)
+	}
+	return DomainSave(ctx, domain.Name(), func(d *config.Domain) error {
+		if d.MTASTS == nil {
+			return requestErrorf(ErrCodeNotFound, "domain has no mta-sts policy")
+		}
+		if d.MTASTS.MaxAge == maxAge {
+			return nil
+		}
+		sts := *d.MTASTS
+		sts.MaxAge = maxAge
+		sts.PolicyID = mtastsPolicyID(sts.PolicyID)
+		d.MTASTS = &sts
+		return nil
+	})
+}
+
+// DomainMTASTSMXSave sets the mail server host names listed in the MTA-STS
+// policy of a domain. Host names can start with a wildcard label, "*.", matching a
+// single label. The list cannot be empty. When the list changes, the policy ID
+// is changed so remote senders fetch the new policy, the DNS TXT record for
+// MTA-STS must be updated with the new ID.
+func DomainMTASTSMXSave(ctx context.Context, domain dns.Domain, mx []string) error {
+	if len(mx) == 0 {
+		return requestErrorf(ErrCodeInvalid, "mta-sts policy must have at least one mx host")
+	}
+	var l []string
+	for _, s := range mx {
+		// ../rfc/8461:469
+		var wildcard string
+		if rest, ok := strings.CutPrefix(s, "*."); ok {
+			wildcard = "*."
+			s = rest
+		}
+		d, err := dns.ParseDomain(s)
+		if err != nil {
+			return requestErrorf(ErrCodeInvalid, "parsing mx host %q: %v", wildcard+s, err)
+		}
+		name := wildcard + d.Name()
+		if slices.Contains(l, name) {
+			return requestErrorf(ErrCodeInvalid, "duplicate mx host %q", name)
+		}
+		l = append(l, name)
+	}
+
+	return DomainSave(ctx, domain.Name(), func(d *config.Domain) error {
+		if d.MTASTS == nil {
+			return requestErrorf(ErrCodeNotFound, "domain has no mta-sts policy")
+		}
+		if slices.Equal(d.MTASTS.MX, l) {
+			return nil
+		}
+		sts := *d.MTASTS
+		sts.MX = l
+		sts.PolicyID = mtastsPolicyID(sts.PolicyID)
+		d.MTASTS = &sts
+		return nil
+	})
+}
+
+// mtastsPolicyID returns a new policy ID based on the current time, different
+// from the previous ID prevID.
+func mtastsPolicyID(prevID string) string {
+	base := time.Now().UTC().Format("20060102T150405")
+	id := base
+	for i := 2; id == prevID; i++ {
+		id = fmt.Sprintf("%s%d", base, i)
+	}
+	return id
+}
+
+// DomainSetFooter sets the footer, e.g. a legal disclaimer, added to outgoing
+// messages from the domain. If both textFooter and htmlFooter are empty, the
+// footer is removed.
+func DomainSetFooter(ctx context.Context, domain dns.Domain, textFooter, htmlFooter string) error {
+	var footer *config.Footer
+	if textFooter != "" || htmlFooter != "" {
+		if err := mox.CheckFooter(textFooter, htmlFooter); err != nil {
+			return requestErrorf(ErrCodeInvalid, "%v", err)
+		}
+		footer = &config.Footer{Text: textFooter, HTML: htmlFooter}
+	}
+	return DomainSave(ctx, domain.Name(), func(d *config.Domain) error {
+		d.Footer = footer
+		return nil
+	})
+}
+
+// DomainDNSBLsSave sets the DNS block lists used for incoming deliveries to
+// recipients in the domain, instead of those of the SMTP listener. An empty list
+// makes the listener DNSBLs apply again.
+func DomainDNSBLsSave(ctx context.Context, domain dns.Domain, dnsbls []string) error {
+	for _, s := range dnsbls {
+		if _, err := dns.ParseDomain(s); err != nil {
+			return requestErrorf(ErrCodeInvalid, "parsing dnsbl zone %q: %v", s, err)
+		}
+	}
+	return DomainSave(ctx, domain.Name(), func(d *config.Domain) error {
+		d.DNSBLs = slices.Clone(dnsbls)
+		return nil
+	})
+}
+
+// DomainTLSRPTSave sets the address and account for incoming TLS reports
+// (TLSRPT) about deliveries to the domain. With a nil tlsrpt, no TLS reports are
+// requested for the domain anymore, and DomainRecords no longer suggests a TLSRPT
+// DNS record.
+func DomainTLSRPTSave(ctx context.Context, domain dns.Domain, tlsrpt *config.TLSRPT) error {
+	var ntlsrpt *config.TLSRPT
+	if tlsrpt != nil {
+		if _, ok := mox.Conf.Account(tlsrpt.Account); !ok {
+			return requestErrorf(ErrCodeAccountNotFound, "account %q does not exist", tlsrpt.Account)
+		}
+		if _, err := smtp.ParseLocalpart(tlsrpt.Localpart); err != nil {
+			return requestErrorf(ErrCodeInvalid, "parsing localpart %q: %v", tlsrpt.Localpart, err)
+		}
+		if tlsrpt.Domain != "" {
+			if _, err := dns.ParseDomain(tlsrpt.Domain); err != nil {
+				return requestErrorf(ErrCodeInvalid, "parsing domain %q: %v", tlsrpt.Domain, err)
+			}
+		}
+		if _, _, err := store.CheckMailboxName(tlsrpt.Mailbox, true); err != nil {
+			return requestErrorf(ErrCodeInvalid, "invalid mailbox %q: %v", tlsrpt.Mailbox, err)
+		}
+		ntlsrpt = &config.TLSRPT{
+			Localpart: tlsrpt.Localpart,
+			Domain:    tlsrpt.Domain,
+			Account:   tlsrpt.Account,
+			Mailbox:   tlsrpt.Mailbox,
+		}
+	}
+	return DomainSave(ctx, domain.Name(), func(d *config.Domain) error {
+		d.TLSRPT = ntlsrpt
+		return nil
+	})
+}
+
+// DomainDMARCSave sets the address and account for incoming DMARC aggregate
+// reports about messages from the domain, and the policy, percentage and
+// additional report addresses for the DMARC DNS record suggested by
+// DomainRecords. With a nil dmarc, no DMARC reports are processed for the domain
+// anymore, and the suggested DNS record has the default policy without report
+// addresses.
+func DomainDMARCSave(ctx context.Context, domain dns.Domain, dmarc *config.DMARC) error {
+	var ndmarc *config.DMARC
+	if dmarc != nil {
+		if _, ok := mox.Conf.Account(dmarc.Account); !ok {
+			return requestErrorf(ErrCodeAccountNotFound, "account %q does not exist", dmarc.Account)
+		}
+		if lp, err := smtp.ParseLocalpart(dmarc.Localpart); err != nil {
+			return requestErrorf(ErrCodeInvalid, "parsing localpart %q: %v", dmarc.Localpart, err)
+		} else if lp.IsInternational() {
+			return requestErrorf(ErrCodeInvalid, "localpart %q is internationalized, only ascii localparts are allowed for dmarc", dmarc.Localpart)
+		}
+		if dmarc.Domain != "" {
+			if _, err := dns.ParseDomain(dmarc.Domain); err != nil {
+				return requestErrorf(ErrCodeInvalid, "parsing domain %q: %v", dmarc.Domain, err)
+			}
+		}
+		if _, _, err := store.CheckMailboxName(dmarc.Mailbox, true); err != nil {
+			return requestErrorf(ErrCodeInvalid, "invalid mailbox %q: %v", dmarc.Mailbox, err)
+		}
+		switch dmarc.Policy {
+		case "", "none", "quarantine", "reject":
+		default:
+			return requestErrorf(ErrCodeInvalid, "unknown policy %q, must be none, quarantine or reject", dmarc.Policy)
+		}
+		if dmarc.Percentage < 0 || dmarc.Percentage > 100 {
+			return requestErrorf(ErrCodeInvalid, "percentage %d must be between 0 and 100, 0 for the default of 100", dmarc.Percentage)
+		}
+		for _, s := range append(slices.Clone(dmarc.ExtraAggregateReportAddresses), dmarc.FailureReportAddresses...) {
+			if _, err := smtp.ParseAddress(s); err != nil {
+				return requestErrorf(ErrCodeInvalid, "parsing report address %q: %v", s, err)
+			}
+		}
+		ndmarc = &config.DMARC{
+			Localpart:                     dmarc.Localpart,
+			Domain:                        dmarc.Domain,
+			Account:                       dmarc.Account,
+			Mailbox:                       dmarc.Mailbox,
+			Policy:                        dmarc.Policy,
+			Percentage:                    dmarc.Percentage,
+			ExtraAggregateReportAddresses: slices.Clone(dmarc.ExtraAggregateReportAddresses),
+			FailureReportAddresses:        slices.Clone(dmarc.FailureReportAddresses),
+		}
+	}
+	return DomainSave(ctx, domain.Name(), func(d *config.Domain) error {
+		d.DMARC = ndmarc
+		return nil
+	})
+}
+
+// ConfigReload checks the configuration files on disk, and if they are valid,
+// applies the domains.conf from disk to the running config, e.g. after manual
+// edits. Changes to mox.conf are only checked, they require a restart to take
+// effect. If the configuration is not valid, an error with all problems found is
+// returned, and the running config is not changed.
+func ConfigReload(ctx context.Context) (rerr error) {
+	log := pkglog.WithContext(ctx)
+	defer func() {
+		if rerr != nil {
+			log.Errorx("reloading config", rerr)
+		}
+	}()
+
+	if errs := mox.Conf.Reload(ctx, log); len(errs) > 0 {
+		return requestErrorf(ErrCodeInvalid, "invalid config: %v", errors.Join(errs...))
+	}
+	log.Info("config reloaded")
+	return nil
+}
@@ -0,0 +1,3856 @@
+package admin
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mjl-/bstore"
+	"github.com/mjl-/sconf"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dkim"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/junk"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/mtasts"
+	"github.com/mjl-/mox/queue"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/store"
+
+	// Register listener reloaders for ListenerReload.
+	_ "github.com/mjl-/mox/imapserver"
+	_ "github.com/mjl-/mox/smtpserver"
+)
+
+var ctxbg = context.Background()
+
+func tcheck(t *testing.T, err error, msg string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %s", msg, err)
+	}
+}
+
+// setupConfig loads a copy of the webadmin test config from a temporary
+// directory, so tests can make changes.
+func setupConfig(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"mox.conf", "domains.conf"} {
+		buf, err := os.ReadFile(filepath.Join("../testdata/webadmin", name))
+		tcheck(t, err, "read config")
+		err = os.WriteFile(filepath.Join(dir, name), buf, 0660)
+		tcheck(t, err, "write config")
+	}
+	mox.Context = ctxbg
+	mox.ConfigStaticPath = filepath.Join(dir, "mox.conf")
+	mox.ConfigDynamicPath = filepath.Join(dir, "domains.conf")
+	mox.MustLoadConfig(true, false)
+}
+
+func TestCheckDKIMSelector(t *testing.T) {
+	domain := dns.Domain{ASCII: "mox.example"}
+
+	test := func(selector string, expErr bool) {
+		t.Helper()
+		err := checkDKIMSelector(dns.Domain{ASCII: selector}, domain)
+		if (err != nil) != expErr {
+			t.Fatalf("selector %q: got err %v, expected error %v", selector, err, expErr)
+		}
+	}
+
+	test("2024a", false)
+	test("sel-1.sub", false)
+	test(strings.Repeat("a", 63), false)
+
+	test("", true)
+	test(strings.Repeat("a", 64), true)
+	test("a..b", true)
+	test("-sel", true)
+	test("sel-", true)
+	test("sel_1", true)
+	test("sel 1", true)
+	test("sél", true)
+	test(strings.Repeat(strings.Repeat("a", 63)+".", 3)+strings.Repeat("a", 63), true) // Record name too long.
+}
+
+func TestCheckDKIMHeaders(t *testing.T) {
+	test := func(headers []string, expErr bool) {
+		t.Helper()
+		err := checkDKIMHeaders(headers)
+		if (err != nil) != expErr {
+			t.Fatalf("headers %q: got err %v, expected error %v", headers, err, expErr)
+		}
+	}
+
+	test(nil, false)
+	test([]string{"From"}, false)
+	test([]string{"from", "To", "Subject", "List-Id"}, false)
+	test([]string{"To", "Subject"}, true)    // Missing From.
+	test([]string{"From", "To", "to"}, true) // Duplicate.
+	test([]string{"From", "Sub ject"}, true) // Space.
+	test([]string{"From", "Subject:"}, true) // Colon.
+	test([]string{"From", "X;Y"}, true)      // Semicolon.
+	test([]string{"From", "Süb"}, true)      // Non-ASCII.
+	test([]string{"From", ""}, true)         // Empty.
+}
+
+func TestDKIMAddHeaders(t *testing.T) {
+	setupConfig(t)
+
+	domain := dns.Domain{ASCII: "mox.example"}
+	selector := dns.Domain{ASCII: "sel1"}
+	for _, headers := range [][]string{{"To", "Subject"}, {"From", "Bogus Header"}} {
+		err := DKIMAdd(ctxbg, domain, selector, "ed25519", 0, "sha256", true, true, false, headers, 0)
+		if !errors.Is(err, ErrRequest) {
+			t.Fatalf("headers %q: got err %v, expected ErrRequest", headers, err)
+		}
+	}
+	files, err := os.ReadDir(mox.ConfigDynamicDirPath("dkim"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("reading dkim dir: %v", err)
+	}
+	if len(files) != 0 {
+		t.Fatalf("key files written for invalid headers: %v", files)
+	}
+	if dc, _ := mox.Conf.Domain(domain); len(dc.DKIM.Selectors) != 0 {
+		t.Fatalf("selector added for invalid headers")
+	}
+}
+
+func TestDKIMAddExistingNoKey(t *testing.T) {
+	setupConfig(t)
+
+	var generated int
+	defer func(orig func(selector, domain dns.Domain, algorithm string, bits int) ([]byte, string, error)) {
+		dkimMakeKey = orig
+	}(dkimMakeKey)
+	dkimMakeKey = func(selector, domain dns.Domain, algorithm string, bits int) ([]byte, string, error) {
+		generated++
+		return makeDKIMKey(selector, domain, algorithm, bits)
+	}
+
+	domain := dns.Domain{ASCII: "mox.example"}
+	selector := dns.Domain{ASCII: "sel1"}
+	err := DKIMAdd(ctxbg, domain, selector, "ed25519", 0, "sha256", true, true, true, nil, 0)
+	tcheck(t, err, "dkim add")
+	if generated != 1 {
+		t.Fatalf("generated %d keys, expected 1", generated)
+	}
+
+	// Existing selector and unknown domain are rejected before generating a key.
+	err = DKIMAdd(ctxbg, domain, selector, "rsa", 0, "sha256", true, true, true, nil, 0)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("add existing selector: got err %v, expected ErrRequest", err)
+	}
+	err = DKIMAdd(ctxbg, dns.Domain{ASCII: "missing.example"}, selector, "rsa", 0, "sha256", true, true, true, nil, 0)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("add selector for unknown domain: got err %v, expected ErrRequest", err)
+	}
+	if generated != 1 {
+		t.Fatalf("generated %d keys, expected no keys for conflicts", generated)
+	}
+}
+
+func TestDKIMAddLifetime(t *testing.T) {
+	setupConfig(t)
+
+	domain := dns.Domain{ASCII: "mox.example"}
+	testLifetime := func(selector string, lifetime time.Duration, expErr bool, expExpiration string) {
+		t.Helper()
+		err := DKIMAdd(ctxbg, domain, dns.Domain{ASCII: selector}, "ed25519", 0, "sha256", true, true, true, nil, lifetime)
+		if expErr {
+			if !errors.Is(err, ErrRequest) {
+				t.Fatalf("lifetime %v: got err %v, expected ErrRequest", lifetime, err)
+			}
+			return
+		}
+		tcheck(t, err, "dkim add")
+		dc, _ := mox.Conf.Domain(domain)
+		if sel := dc.DKIM.Selectors[selector]; sel.Expiration != expExpiration {
+			t.Fatalf("lifetime %v: got expiration %q, expected %q", lifetime, sel.Expiration, expExpiration)
+		}
+	}
+
+	testLifetime("none", 0, false, "")
+	testLifetime("min", DKIMLifetimeMin, false, "5m")
+	testLifetime("max", DKIMLifetimeMax, false, "8760h")
+	testLifetime("default", DKIMLifetimeDefault, false, "72h")
+	testLifetime("short", DKIMLifetimeMin-time.Second, true, "")
+	testLifetime("long", DKIMLifetimeMax+time.Second, true, "")
+	testLifetime("negative", -time.Hour, true, "")
+}
+
+func TestDKIMSelectorUpdate(t *testing.T) {
+	setupConfig(t)
+
+	domain := dns.Domain{ASCII: "mox.example"}
+	selector := dns.Domain{ASCII: "sel1"}
+	err := DKIMAdd(ctxbg, domain, selector, "ed25519", 0, "sha256", true, true, true, nil, 0)
+	tcheck(t, err, "dkim add")
+	dc, _ := mox.Conf.Domain(domain)
+	keyFile := dc.DKIM.Selectors["sel1"].PrivateKeyFile
+
+	params := DKIMParams{Hash: "sha256", HeaderRelaxed: true, Headers: []string{"From", "To", "Subject"}, Lifetime: 72 * time.Hour}
+	err = DKIMSelectorUpdate(ctxbg, domain, selector, params)
+	tcheck(t, err, "update selector")
+	dc, _ = mox.Conf.Domain(domain)
+	sel := dc.DKIM.Selectors["sel1"]
+	if sel.Expiration != "72h" || sel.ExpirationSeconds != 72*3600 {
+		t.Fatalf("got expiration %q (%d seconds), expected 72h", sel.Expiration, sel.ExpirationSeconds)
+	}
+	if sel.PrivateKeyFile != keyFile {
+		t.Fatalf("private key file changed from %q to %q", keyFile, sel.PrivateKeyFile)
+	}
+	if sel.Canonicalization.BodyRelaxed || !sel.DontSealHeaders || !slices.Equal(sel.Headers, params.Headers) {
+		t.Fatalf("selector not updated: %#v", sel)
+	}
+
+	err = DKIMSelectorUpdate(ctxbg, domain, dns.Domain{ASCII: "bogus"}, params)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("update unknown selector: got err %v, expected ErrRequest", err)
+	}
+	bad := params
+	bad.Hash = "md5"
+	err = DKIMSelectorUpdate(ctxbg, domain, selector, bad)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("update with bad hash: got err %v, expected ErrRequest", err)
+	}
+	bad = params
+	bad.Headers = []string{"To", "Subject"}
+	err = DKIMSelectorUpdate(ctxbg, domain, selector, bad)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("update with headers without From: got err %v, expected ErrRequest", err)
+	}
+}
+
+func TestDKIMSelectorList(t *testing.T) {
+	setupConfig(t)
+
+	domain := dns.Domain{ASCII: "mox.example"}
+	err := DKIMAdd(ctxbg, domain, dns.Domain{ASCII: "ed1"}, "ed25519", 0, "sha256", true, true, true, nil, 72*time.Hour)
+	tcheck(t, err, "dkim add ed25519")
+	err = DKIMAdd(ctxbg, domain, dns.Domain{ASCII: "ec1"}, "ecdsa", 0, "sha256", true, true, true, nil, 0)
+	tcheck(t, err, "dkim add ecdsa")
+	other := dns.Domain{ASCII: "other.example"}
+	err = DomainAdd(ctxbg, false, other, "mjl", "")
+	tcheck(t, err, "add domain")
+
+	l, err := DKIMSelectorList(ctxbg)
+	tcheck(t, err, "list selectors")
+	year := time.Now().Format("2006")
+	type sel struct {
+		domain, selector, algorithm string
+		sign                        bool
+	}
+	var got []sel
+	for _, info := range l {
+		got = append(got, sel{info.Domain.Name(), info.Selector.Name(), info.Algorithm, info.Sign})
+		if info.Created.IsZero() || time.Since(info.Created) > time.Minute {
+			t.Fatalf("selector %s: unexpected created time %v", info.Selector, info.Created)
+		}
+		if !strings.Contains(info.Note, "dkim private key for "+info.Selector.ASCII+"._domainkey."+info.Domain.ASCII) {
+			t.Fatalf("selector %s: unexpected note %q", info.Selector, info.Note)
+		}
+	}
+	exp := []sel{
+		{"mox.example", "ec1", "ecdsa-p256", false},
+		{"mox.example", "ed1", "ed25519", false},
+		{"other.example", year + "a", "rsa-2048", true},
+		{"other.example", year + "b", "rsa-2048", false},
+	}
+	if !slices.Equal(got, exp) {
+		t.Fatalf("got selectors %v, expected %v", got, exp)
+	}
+	if l[1].Expiration != "72h" {
+		t.Fatalf("got expiration %q, expected 72h", l[1].Expiration)
+	}
+}
+
+func TestDKIMAddECDSA(t *testing.T) {
+	setupConfig(t)
+
+	domain := dns.Domain{ASCII: "mox.example"}
+	selector := dns.Domain{ASCII: "ec1"}
+
+	buf, err := MakeDKIMECDSAKey(selector, domain)
+	tcheck(t, err, "make ecdsa key")
+	b, _ := pem.Decode(buf)
+	if b == nil {
+		t.Fatalf("no pem block")
+	}
+	if !strings.HasPrefix(b.Headers["Note"], "ecdsa-p256 dkim private key for ec1._domainkey.mox.example") {
+		t.Fatalf("unexpected note %q", b.Headers["Note"])
+	}
+	key, err := x509.ParsePKCS8PrivateKey(b.Bytes)
+	tcheck(t, err, "parse key")
+	if k, ok := key.(*ecdsa.PrivateKey); !ok || k.Curve != elliptic.P256() {
+		t.Fatalf("got key %T, expected ecdsa p256", key)
+	}
+
+	err = DKIMAdd(ctxbg, domain, selector, "ecdsa", 0, "sha256", true, true, false, nil, 0)
+	tcheck(t, err, "add ecdsa dkim selector")
+
+	dc, ok := mox.Conf.Domain(domain)
+	if !ok {
+		t.Fatalf("domain not found")
+	}
+	sel, ok := dc.DKIM.Selectors["ec1"]
+	if !ok {
+		t.Fatalf("selector not added")
+	}
+	if sel.Algorithm != "ecdsa-p256" {
+		t.Fatalf("got algorithm %q, expected ecdsa-p256", sel.Algorithm)
+	}
+
+	records, err := DomainRecords(dc, domain, false, "", "")
+	tcheck(t, err, "domain records")
+	var txt string
+	for _, r := range records {
+		if strings.HasPrefix(r, "ec1._domainkey.mox.example. ") {
+			// Combine the possibly split strings.
+			for _, m := range regexp.MustCompile(`"([^"]*)"`).FindAllStringSubmatch(r, -1) {
+				txt += m[1]
+			}
+		}
+	}
+	if txt == "" {
+		t.Fatalf("no dkim record in %v", records)
+	}
+	r, _, err := dkim.ParseRecord(txt)
+	tcheck(t, err, "parse dkim record")
+	if r.Key != "ecdsa" {
+		t.Fatalf("got key type %q, expected ecdsa", r.Key)
+	}
+	if !sel.Key.Public().(*ecdsa.PublicKey).Equal(r.PublicKey) {
+		t.Fatalf("public key in record does not match private key")
+	}
+
+	// Sign a message with the new key and verify it against the record.
+	msg := strings.ReplaceAll("From: <mjl@mox.example>\nSubject: test\n\ntest\n", "\n", "\r\n")
+	dkimsel := dkim.Selector{
+		Hash:          "sha256",
+		HeaderRelaxed: true,
+		BodyRelaxed:   true,
+		Headers:       []string{"From", "Subject"},
+		PrivateKey:    sel.Key,
+		Domain:        selector,
+	}
+	headers, err := dkim.Sign(ctxbg, pkglog.Logger, "mjl", domain, []dkim.Selector{dkimsel}, false, strings.NewReader(msg))
+	tcheck(t, err, "sign message")
+	resolver := dns.MockResolver{
+		TXT: map[string][]string{"ec1._domainkey.mox.example.": {txt}},
+	}
+	results, err := dkim.Verify(ctxbg, pkglog.Logger, resolver, false, dkim.DefaultPolicy, strings.NewReader(headers+msg), false)
+	tcheck(t, err, "verify message")
+	if len(results) != 1 || results[0].Status != dkim.StatusPass {
+		t.Fatalf("got results %#v, expected single pass", results)
+	}
+}
+
+func TestMakeDKIMRSAKeyBits(t *testing.T) {
+	domain := dns.Domain{ASCII: "mox.example"}
+	selector := dns.Domain{ASCII: "sel"}
+
+	for _, bits := range []int{1024, 3072} {
+		buf, err := MakeDKIMRSAKeyBits(selector, domain, bits)
+		tcheck(t, err, "make rsa key")
+		b, _ := pem.Decode(buf)
+		if b == nil {
+			t.Fatalf("no pem block")
+		}
+		if exp := fmt.Sprintf("rsa-%d dkim private key", bits); !strings.HasPrefix(b.Headers["Note"], exp) {
+			t.Fatalf("got note %q, expected prefix %q", b.Headers["Note"], exp)
+		}
+		key, err := x509.ParsePKCS8PrivateKey(b.Bytes)
+		tcheck(t, err, "parse key")
+		k, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			t.Fatalf("got key %T, expected rsa", key)
+		}
+		if k.N.BitLen() != bits {
+			t.Fatalf("got %d bits, expected %d", k.N.BitLen(), bits)
+		}
+	}
+
+	_, err := MakeDKIMRSAKeyBits(selector, domain, 512)
+	if err == nil {
+		t.Fatalf("expected error for 512 bit key")
+	}
+}
+
+func TestParseDKIMKeyNote(t *testing.T) {
+	domain := dns.Domain{ASCII: "mox.example"}
+	selector := dns.Domain{ASCII: "sel"}
+
+	start := time.Now().Truncate(time.Second)
+	buf, err := MakeDKIMEd25519Key(selector, domain)
+	tcheck(t, err, "make key")
+	kind, generatedAt, sel, dom, err := ParseDKIMKeyNote(buf)
+	tcheck(t, err, "parse note")
+	if kind != "ed25519" || sel != "sel" || dom != "mox.example" || generatedAt.Before(start) || generatedAt.After(time.Now()) {
+		t.Fatalf("got kind %q, generated %v, selector %q, domain %q", kind, generatedAt, sel, dom)
+	}
+
+	// Without selector and domain.
+	buf, err = MakeDKIMRSAKeyBits(dns.Domain{}, dns.Domain{}, 1024)
+	tcheck(t, err, "make key")
+	kind, _, sel, dom, err = ParseDKIMKeyNote(buf)
+	tcheck(t, err, "parse note")
+	if kind != "rsa-1024" || sel != "" || dom != "" {
+		t.Fatalf("got kind %q, selector %q, domain %q", kind, sel, dom)
+	}
+
+	for _, note := range []string{"", "some key", "ed25519 dkim private key, generated by mox on bogus", "ed25519 dkim private key for sel, generated by mox on 2024-01-01T00:00:00Z"} {
+		h := map[string]string{}
+		if note != "" {
+			h["Note"] = note
+		}
+		buf := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Headers: h, Bytes: []byte("x")})
+		if _, _, _, _, err := ParseDKIMKeyNote(buf); !errors.Is(err, ErrRequest) {
+			t.Fatalf("note %q: got err %v, expected ErrRequest", note, err)
+		}
+	}
+}
+
+func TestDomainAddMulti(t *testing.T) {
+	setupConfig(t)
+
+	domainsConf, err := os.ReadFile(mox.ConfigDynamicPath)
+	tcheck(t, err, "read domains.conf")
+
+	spec := func(domain, localpart string) DomainAddSpec {
+		return DomainAddSpec{Domain: dns.Domain{ASCII: domain}, AccountName: "mjl", Localpart: smtp.Localpart(localpart)}
+	}
+
+	checkUnchanged := func() {
+		t.Helper()
+		buf, err := os.ReadFile(mox.ConfigDynamicPath)
+		tcheck(t, err, "read domains.conf")
+		if !bytes.Equal(buf, domainsConf) {
+			t.Fatalf("domains.conf changed")
+		}
+		for _, name := range []string{"a.example", "b.example"} {
+			if _, ok := mox.Conf.Domain(dns.Domain{ASCII: name}); ok {
+				t.Fatalf("domain %s was added", name)
+			}
+		}
+		files, err := filepath.Glob(filepath.Join(filepath.Dir(mox.ConfigDynamicPath), "dkim", "*"))
+		tcheck(t, err, "list dkim files")
+		if len(files) != 0 {
+			t.Fatalf("dkim key files remain: %v", files)
+		}
+	}
+
+	// Third domain already exists, caught during validation.
+	err = DomainAddMulti(ctxbg, []DomainAddSpec{spec("a.example", ""), spec("b.example", ""), spec("mox.example", "")})
+	if err == nil {
+		t.Fatalf("expected error for existing domain")
+	}
+	checkUnchanged()
+
+	// Third domain is not in canonical form, only caught when checking the new
+	// config, after keys were generated.
+	err = DomainAddMulti(ctxbg, []DomainAddSpec{spec("a.example", ""), spec("b.example", ""), spec("C.example", "")})
+	if err == nil {
+		t.Fatalf("expected error for invalid domain")
+	}
+	checkUnchanged()
+
+	err = DomainAddMulti(ctxbg, []DomainAddSpec{spec("a.example", ""), spec("b.example", "")})
+	tcheck(t, err, "add domains")
+	for _, name := range []string{"a.example", "b.example"} {
+		if _, ok := mox.Conf.Domain(dns.Domain{ASCII: name}); !ok {
+			t.Fatalf("domain %s not added", name)
+		}
+	}
+}
+
+func TestDomainAddWithRecords(t *testing.T) {
+	setupConfig(t)
+
+	domain := dns.Domain{ASCII: "new.example"}
+	records, err := DomainAddWithRecords(ctxbg, false, domain, "mjl", "", false, "", "")
+	tcheck(t, err, "add domain with records")
+
+	dc, ok := mox.Conf.Domain(domain)
+	if !ok {
+		t.Fatalf("domain not added")
+	}
+	if len(dc.DKIM.Selectors) == 0 {
+		t.Fatalf("no dkim selectors for new domain")
+	}
+	for name := range dc.DKIM.Selectors {
+		prefix := name + "._domainkey.new.example. "
+		if !slices.ContainsFunc(records, func(r string) bool { return strings.HasPrefix(r, prefix) }) {
+			t.Fatalf("missing dkim record for selector %q in %v", name, records)
+		}
+	}
+
+	_, err = DomainAddWithRecords(ctxbg, false, domain, "mjl", "", false, "", "")
+	if err == nil {
+		t.Fatalf("expected error for existing domain")
+	}
+}
+
+func TestDomainAddDiskSpace(t *testing.T) {
+	setupConfig(t)
+
+	defer func(orig func(dir string) (uint64, bool, error)) {
+		diskAvailable = orig
+	}(diskAvailable)
+	diskAvailable = func(dir string) (uint64, bool, error) {
+		return 1024, true, nil
+	}
+
+	domain := dns.Domain{ASCII: "new.example"}
+	err := DomainAdd(ctxbg, false, domain, "mjl", "")
+	if !errors.Is(err, ErrRequest) || !strings.Contains(err.Error(), "insufficient disk space") {
+		t.Fatalf("got err %v, expected ErrRequest for insufficient disk space", err)
+	}
+	if _, ok := mox.Conf.Domain(domain); ok {
+		t.Fatalf("domain was added")
+	}
+
+	// No keys are written without dkim.
+	_, _, err = MakeDomainConfig(ctxbg, domain, domain, "mjl", false, false, MakeDomainConfigOpts{NoDKIM: true})
+	tcheck(t, err, "make domain config without dkim")
+
+	// Unknown available space does not prevent adding.
+	diskAvailable = func(dir string) (uint64, bool, error) {
+		return 0, false, nil
+	}
+	err = DomainAdd(ctxbg, false, domain, "mjl", "")
+	tcheck(t, err, "add domain")
+}
+
+func TestUndoLast(t *testing.T) {
+	setupConfig(t)
+
+	// Nothing changed yet.
+	err := UndoLast(ctxbg)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v without previous config, expected ErrRequest", err)
+	}
+
+	domainsConf, err := os.ReadFile(mox.ConfigDynamicPath)
+	tcheck(t, err, "read domains.conf")
+
+	err = AddressAdd(ctxbg, "mjl3@mox.example", "mjl")
+	tcheck(t, err, "add address")
+	if _, _, ok := mox.Conf.AccountDestination("mjl3@mox.example"); !ok {
+		t.Fatalf("address not added")
+	}
+
+	err = UndoLast(ctxbg)
+	tcheck(t, err, "undo last")
+	if _, _, ok := mox.Conf.AccountDestination("mjl3@mox.example"); ok {
+		t.Fatalf("address still present after undo")
+	}
+	buf, err := os.ReadFile(mox.ConfigDynamicPath)
+	tcheck(t, err, "read domains.conf")
+	if !bytes.Equal(buf, domainsConf) {
+		t.Fatalf("domains.conf after undo differs from original")
+	}
+
+	// The undo itself cannot be undone.
+	err = UndoLast(ctxbg)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v for second undo, expected ErrRequest", err)
+	}
+
+	// An added account that already has its directory cannot be undone.
+	err = AccountAdd(ctxbg, "other", "other@mox.example")
+	tcheck(t, err, "add account")
+	err = os.MkdirAll(filepath.Join(mox.DataDirPath("accounts"), "other"), 0770)
+	tcheck(t, err, "create account directory")
+	err = UndoLast(ctxbg)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v for undo with account directory, expected ErrRequest", err)
+	}
+	if _, ok := mox.Conf.Account("other"); !ok {
+		t.Fatalf("account removed by refused undo")
+	}
+}
+
+func TestAccountRename(t *testing.T) {
+	setupConfig(t)
+	log := pkglog.WithContext(ctxbg)
+
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	err = queue.Init()
+	tcheck(t, err, "queue init")
+	defer queue.Shutdown()
+
+	err = AccountAdd(ctxbg, "other", "other@mox.example")
+	tcheck(t, err, "add account")
+	err = DomainSave(ctxbg, "mox.example", func(d *config.Domain) error {
+		d.DMARC = &config.DMARC{Localpart: "dmarcreports", Account: "other", Mailbox: "DMARC"}
+		return nil
+	})
+	tcheck(t, err, "save domain")
+
+	// Create the account directory.
+	acc, err := store.OpenAccount(log, "other", false)
+	tcheck(t, err, "open account")
+
+	// Account in use cannot be renamed.
+	err = AccountRename(ctxbg, "other", "renamed")
+	if err == nil {
+		t.Fatalf("renamed account that is in use")
+	}
+	err = acc.Close()
+	tcheck(t, err, "close account")
+	acc.WaitClosed()
+
+	// Postmaster account from mox.conf, existing and invalid names.
+	for _, names := range [][2]string{{"mjl", "renamed"}, {"other", "mjl"}, {"other", "../x"}, {"missing", "renamed"}} {
+		err := AccountRename(ctxbg, names[0], names[1])
+		if err == nil {
+			t.Fatalf("renamed %q to %q, expected error", names[0], names[1])
+		}
+	}
+
+	err = AccountRename(ctxbg, "other", "renamed")
+	tcheck(t, err, "rename account")
+
+	if _, ok := mox.Conf.Account("other"); ok {
+		t.Fatalf("old account still present")
+	}
+	accName, _, _, _, err := mox.LookupAddress("other", dns.Domain{ASCII: "mox.example"}, false, false, false)
+	tcheck(t, err, "lookup address")
+	if accName != "renamed" {
+		t.Fatalf("address delivers to account %q, expected renamed", accName)
+	}
+	if dc, _ := mox.Conf.Domain(dns.Domain{ASCII: "mox.example"}); dc.DMARC.Account != "renamed" {
+		t.Fatalf("dmarc account is %q, expected renamed", dc.DMARC.Account)
+	}
+	if _, err := os.Stat(filepath.Join(mox.DataDirPath("accounts"), "other")); err == nil {
+		t.Fatalf("old account directory still exists")
+	}
+
+	acc, err = store.OpenAccount(log, "renamed", false)
+	tcheck(t, err, "open renamed account")
+	err = acc.DB.Read(ctxbg, func(tx *bstore.Tx) error {
+		mb, err := acc.MailboxFind(tx, "Inbox")
+		if err == nil && mb == nil {
+			err = fmt.Errorf("inbox not found")
+		}
+		return err
+	})
+	tcheck(t, err, "find inbox in renamed account")
+	err = acc.Close()
+	tcheck(t, err, "close account")
+	acc.WaitClosed()
+}
+
+func TestAccountExport(t *testing.T) {
+	setupConfig(t)
+	log := pkglog.WithContext(ctxbg)
+
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	defer store.Switchboard()()
+
+	acc, err := store.OpenAccount(log, "mjl", false)
+	tcheck(t, err, "open account")
+	msgFile, err := store.CreateMessageTemp(log, "admin-test-export")
+	tcheck(t, err, "create temp message")
+	defer os.Remove(msgFile.Name())
+	defer msgFile.Close()
+	const msg = "Subject: test\r\n\r\ntest\r\n"
+	_, err = msgFile.Write([]byte(msg))
+	tcheck(t, err, "write message")
+	acc.WithWLock(func() {
+		m := store.Message{Received: time.Now(), Size: int64(len(msg))}
+		err = acc.DeliverMailbox(log, "Inbox", &m, msgFile)
+	})
+	tcheck(t, err, "deliver message")
+	err = acc.Close()
+	tcheck(t, err, "close account")
+	acc.WaitClosed()
+
+	var buf bytes.Buffer
+	err = AccountExport(ctxbg, "mjl", &buf)
+	tcheck(t, err, "export account")
+
+	gzr, err := gzip.NewReader(&buf)
+	tcheck(t, err, "gzip reader")
+	tr := tar.NewReader(gzr)
+	var haveConfig bool
+	var messages int
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		tcheck(t, err, "next tar file")
+		data, err := io.ReadAll(tr)
+		tcheck(t, err, "read tar file")
+		if h.Name == "account.json" {
+			var accConf config.Account
+			err := json.Unmarshal(data, &accConf)
+			tcheck(t, err, "parse account config")
+			if _, ok := accConf.Destinations["mjl@mox.example"]; !ok {
+				t.Fatalf("account config misses destination mjl@mox.example: %v", accConf.Destinations)
+			}
+			haveConfig = true
+		} else if strings.HasPrefix(h.Name, "messages/Inbox/new/") || strings.HasPrefix(h.Name, "messages/Inbox/cur/") {
+			if !strings.HasSuffix(h.Name, "/") && strings.Contains(string(data), "Subject: test") {
+				messages++
+			}
+		} else if !strings.HasPrefix(h.Name, "messages/") {
+			t.Fatalf("unexpected file %q in archive", h.Name)
+		}
+	}
+	if !haveConfig || messages != 1 {
+		t.Fatalf("got config %v, messages %d, expected config and 1 message", haveConfig, messages)
+	}
+
+	err = AccountExport(ctxbg, "missing", io.Discard)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v for missing account, expected ErrRequest", err)
+	}
+}
+
+func TestConfigBackup(t *testing.T) {
+	setupConfig(t)
+
+	domain := dns.Domain{ASCII: "new.example"}
+	err := DomainAdd(ctxbg, false, domain, "mjl", "")
+	tcheck(t, err, "add domain")
+	err = os.WriteFile(mox.ConfigDirPath("adminpassword"), []byte("hash\n"), 0660)
+	tcheck(t, err, "write admin password file")
+
+	var buf bytes.Buffer
+	err = ConfigBackup(ctxbg, &buf)
+	tcheck(t, err, "config backup")
+
+	gzr, err := gzip.NewReader(&buf)
+	tcheck(t, err, "gzip reader")
+	tr := tar.NewReader(gzr)
+	files := map[string][]byte{}
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		tcheck(t, err, "next tar file")
+		data, err := io.ReadAll(tr)
+		tcheck(t, err, "read tar file")
+		files[h.Name] = data
+	}
+
+	domainsConf, err := os.ReadFile(mox.ConfigDynamicPath)
+	tcheck(t, err, "read domains.conf")
+	if !bytes.Equal(files["domains.conf"], domainsConf) {
+		t.Fatalf("archive domains.conf differs from config")
+	}
+	if _, ok := files["mox.conf"]; !ok {
+		t.Fatalf("archive misses mox.conf")
+	}
+	if string(files["adminpassword"]) != "hash\n" {
+		t.Fatalf("archive misses admin password file")
+	}
+	var keys int
+	for _, dc := range mox.Conf.DynamicConfig().Domains {
+		for name, sel := range dc.DKIM.Selectors {
+			keyBuf, err := os.ReadFile(mox.ConfigDynamicDirPath(sel.PrivateKeyFile))
+			tcheck(t, err, "read key file")
+			if !bytes.Equal(files[sel.PrivateKeyFile], keyBuf) {
+				t.Fatalf("archive misses key file %q for selector %q", sel.PrivateKeyFile, name)
+			}
+			keys++
+		}
+	}
+	if keys == 0 || len(files) != 3+keys {
+		t.Fatalf("got %d files in archive, expected config files and %d keys", len(files), keys)
+	}
+}
+
+func TestDomainRemoveCheck(t *testing.T) {
+	setupConfig(t)
+
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+
+	domain := dns.Domain{ASCII: "new.example"}
+	err = DomainAdd(ctxbg, false, domain, "mjl", "")
+	tcheck(t, err, "add domain")
+
+	keyFiles, err := DomainRemoveCheck(ctxbg, domain)
+	tcheck(t, err, "check domain removal")
+	if len(keyFiles) != 2 {
+		t.Fatalf("got key files %v, expected 2", keyFiles)
+	}
+	for _, p := range keyFiles {
+		if _, err := os.Stat(mox.ConfigDirPath(p)); err != nil {
+			t.Fatalf("key file %q: %v", p, err)
+		}
+	}
+	if _, ok := mox.Conf.Domain(domain); !ok {
+		t.Fatalf("domain removed by check")
+	}
+
+	// Account mjl is in domain mox.example, so it cannot be removed.
+	_, err = DomainRemoveCheck(ctxbg, dns.Domain{ASCII: "mox.example"})
+	if err == nil {
+		t.Fatalf("check passed for domain referenced by account")
+	}
+	_, err = DomainRemoveCheck(ctxbg, dns.Domain{ASCII: "missing.example"})
+	if err == nil {
+		t.Fatalf("check passed for unknown domain")
+	}
+
+	err = DomainRemove(ctxbg, domain)
+	tcheck(t, err, "remove domain")
+	for _, p := range keyFiles {
+		if _, err := os.Stat(mox.ConfigDirPath(filepath.Join(filepath.Dir(p), "old", filepath.Base(p)))); err != nil {
+			t.Fatalf("key file %q not moved away: %v", p, err)
+		}
+	}
+}
+
+func TestAccountAddWithPassword(t *testing.T) {
+	setupConfig(t)
+	log := pkglog.WithContext(ctxbg)
+
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+
+	err = AccountAddWithPassword(ctxbg, "other", "other@mox.example", "test1234")
+	tcheck(t, err, "add account with password")
+	acc, _, err := store.OpenEmailAuth(log, "other@mox.example", "test1234", false)
+	tcheck(t, err, "login with new account")
+	err = acc.Close()
+	tcheck(t, err, "close account")
+	acc.WaitClosed()
+
+	// Password too short, account must not be added.
+	err = AccountAddWithPassword(ctxbg, "short", "short@mox.example", "short")
+	if err == nil {
+		t.Fatalf("account added with too short password")
+	}
+	if _, ok := mox.Conf.Account("short"); ok {
+		t.Fatalf("account present after failing to set password")
+	}
+	if _, err := os.Stat(filepath.Join(mox.DataDirPath("accounts"), "short")); err == nil {
+		t.Fatalf("account directory present after failing to set password")
+	}
+
+	// Without password, the account is added without being able to log in.
+	err = AccountAddWithPassword(ctxbg, "nopass", "nopass@mox.example", "")
+	tcheck(t, err, "add account without password")
+	if _, ok := mox.Conf.Account("nopass"); !ok {
+		t.Fatalf("account without password not present")
+	}
+}
+
+func TestAliasListGet(t *testing.T) {
+	setupConfig(t)
+
+	domain := dns.Domain{ASCII: "mox.example"}
+	for _, lp := range []string{"team", "all"} {
+		alias := config.Alias{Addresses: []string{"mjl@mox.example", "mjl2@mox.example"}, PostPublic: lp == "all"}
+		_, err := AliasAdd(ctxbg, smtp.NewAddress(smtp.Localpart(lp), domain), alias, false)
+		tcheck(t, err, "add alias")
+	}
+
+	l, err := AliasList(ctxbg, domain)
+	tcheck(t, err, "list aliases")
+	if len(l) != 2 || l[0].LocalpartStr != "all" || l[1].LocalpartStr != "team" {
+		t.Fatalf("got aliases %v, expected all and team", l)
+	}
+	for _, a := range l {
+		if len(a.ParsedAddresses) != 2 || a.ParsedAddresses[0].AccountName != "mjl" {
+			t.Fatalf("alias %q: got parsed addresses %v, expected 2 for account mjl", a.LocalpartStr, a.ParsedAddresses)
+		}
+	}
+
+	a, err := AliasGet(ctxbg, smtp.NewAddress("all", domain))
+	tcheck(t, err, "get alias")
+	if !a.PostPublic || a.Domain != domain {
+		t.Fatalf("got alias %v, expected public alias in mox.example", a)
+	}
+
+	_, err = AliasGet(ctxbg, smtp.NewAddress("missing", domain))
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v for missing alias, expected ErrRequest", err)
+	}
+	_, err = AliasList(ctxbg, dns.Domain{ASCII: "missing.example"})
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v for missing domain, expected ErrRequest", err)
+	}
+}
+
+func TestAliasMembersList(t *testing.T) {
+	setupConfig(t)
+
+	domain := dns.Domain{ASCII: "mox.example"}
+	addr := smtp.NewAddress("team", domain)
+	_, err := AliasAdd(ctxbg, addr, config.Alias{Addresses: []string{"mjl@mox.example", "mjl2@mox.example"}}, false)
+	tcheck(t, err, "add alias")
+
+	// Make mjl2@ dangling, as if removed from its account.
+	func() {
+		defer mox.Conf.DynamicLockUnlock()()
+		accDests := maps.Clone(mox.Conf.AccountDestinationsLocked)
+		delete(accDests, "mjl2@mox.example")
+		mox.Conf.AccountDestinationsLocked = accDests
+	}()
+
+	l, err := AliasMembersList(ctxbg, addr)
+	tcheck(t, err, "list alias members")
+	expect := []AliasMember{
+		{Address: "mjl@mox.example", Account: "mjl", Configured: true},
+		{Address: "mjl2@mox.example", Account: "unknown"},
+	}
+	if !reflect.DeepEqual(l, expect) {
+		t.Fatalf("got members %v, expected %v", l, expect)
+	}
+
+	_, err = AliasMembersList(ctxbg, smtp.NewAddress("missing", domain))
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v for missing alias, expected ErrRequest", err)
+	}
+}
+
+func TestAliasAddCheckAddresses(t *testing.T) {
+	setupConfig(t)
+
+	domain := dns.Domain{ASCII: "mox.example"}
+	addr := smtp.NewAddress("team", domain)
+
+	// Unknown member is rejected, naming the address.
+	_, err := AliasAdd(ctxbg, addr, config.Alias{Addresses: []string{"mjl@mox.example", "unknown@mox.example"}}, false)
+	if !errors.Is(err, ErrRequest) || !strings.Contains(err.Error(), "unknown@mox.example") {
+		t.Fatalf("got err %v, expected ErrRequest for unknown address", err)
+	}
+	_, err = AliasAdd(ctxbg, addr, config.Alias{Addresses: []string{"bogus"}}, false)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v, expected ErrRequest for invalid address", err)
+	}
+	if _, err := AliasGet(ctxbg, addr); err == nil {
+		t.Fatalf("alias was added")
+	}
+
+	// Configured members are accepted.
+	_, err = AliasAdd(ctxbg, addr, config.Alias{Addresses: []string{"mjl@mox.example"}}, false)
+	tcheck(t, err, "add alias")
+
+	_, err = AliasAddressesAdd(ctxbg, addr, []string{"unknown@mox.example"}, false)
+	if !errors.Is(err, ErrRequest) || !strings.Contains(err.Error(), "unknown@mox.example") {
+		t.Fatalf("got err %v, expected ErrRequest for unknown address", err)
+	}
+	_, err = AliasAddressesAdd(ctxbg, addr, []string{"mjl2@mox.example"}, false)
+	tcheck(t, err, "add alias address")
+
+	a, err := AliasGet(ctxbg, addr)
+	tcheck(t, err, "get alias")
+	if !reflect.DeepEqual(a.Addresses, []string{"mjl@mox.example", "mjl2@mox.example"}) {
+		t.Fatalf("got addresses %v, expected mjl and mjl2", a.Addresses)
+	}
+
+	// With allowMissing, unknown addresses are skipped and returned, but at least one
+	// configured address is needed.
+	addr2 := smtp.NewAddress("team2", domain)
+	_, err = AliasAdd(ctxbg, addr2, config.Alias{Addresses: []string{"unknown@mox.example"}}, true)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v, expected ErrRequest for only unknown addresses", err)
+	}
+	skipped, err := AliasAdd(ctxbg, addr2, config.Alias{Addresses: []string{"unknown@mox.example", "mjl@mox.example"}}, true)
+	tcheck(t, err, "add alias with missing address")
+	if !slices.Equal(skipped, []string{"unknown@mox.example"}) {
+		t.Fatalf("got skipped %v, expected unknown address", skipped)
+	}
+	a, err = AliasGet(ctxbg, addr2)
+	tcheck(t, err, "get alias")
+	if !slices.Equal(a.Addresses, []string{"mjl@mox.example"}) {
+		t.Fatalf("got addresses %v, expected only mjl", a.Addresses)
+	}
+
+	_, err = AliasAddressesAdd(ctxbg, addr2, []string{"unknown@mox.example"}, true)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v, expected ErrRequest for only unknown addresses", err)
+	}
+	skipped, err = AliasAddressesAdd(ctxbg, addr2, []string{"mjl2@mox.example", "unknown@mox.example"}, true)
+	tcheck(t, err, "add alias addresses with missing address")
+	if !slices.Equal(skipped, []string{"unknown@mox.example"}) {
+		t.Fatalf("got skipped %v, expected unknown address", skipped)
+	}
+	a, err = AliasGet(ctxbg, addr2)
+	tcheck(t, err, "get alias")
+	if !slices.Equal(a.Addresses, []string{"mjl@mox.example", "mjl2@mox.example"}) {
+		t.Fatalf("got addresses %v, expected mjl and mjl2", a.Addresses)
+	}
+}
+
+func TestAliasAddWithMembers(t *testing.T) {
+	setupConfig(t)
+
+	err := AddressAdd(ctxbg, "mjl3@mox.example", "mjl")
+	tcheck(t, err, "add address")
+
+	domain := dns.Domain{ASCII: "mox.example"}
+	addr := smtp.NewAddress("team", domain)
+	members := []string{"mjl@mox.example", "mjl2@mox.example", "mjl3@mox.example"}
+
+	// No members, duplicate and unknown members are rejected.
+	_, err = AliasAddWithMembers(ctxbg, addr, config.Alias{}, nil, false)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v without members, expected ErrRequest", err)
+	}
+	_, err = AliasAddWithMembers(ctxbg, addr, config.Alias{}, []string{"mjl@mox.example", "mjl@mox.example"}, false)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v for duplicate member, expected ErrRequest", err)
+	}
+	_, err = AliasAddWithMembers(ctxbg, addr, config.Alias{}, append(slices.Clone(members), "unknown@mox.example"), false)
+	if !errors.Is(err, ErrRequest) || !strings.Contains(err.Error(), "unknown@mox.example") {
+		t.Fatalf("got err %v for unknown member, expected ErrRequest", err)
+	}
+	_, err = AliasAddWithMembers(ctxbg, addr, config.Alias{}, []string{"unknown@mox.example"}, true)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v for only unknown members, expected ErrRequest", err)
+	}
+	if _, err := AliasGet(ctxbg, addr); err == nil {
+		t.Fatalf("alias was added")
+	}
+
+	skipped, err := AliasAddWithMembers(ctxbg, addr, config.Alias{PostPublic: true}, members, false)
+	tcheck(t, err, "add alias with members")
+	if len(skipped) != 0 {
+		t.Fatalf("got skipped members %v, expected none", skipped)
+	}
+	a, err := AliasGet(ctxbg, addr)
+	tcheck(t, err, "get alias")
+	if !reflect.DeepEqual(a.Addresses, members) || len(a.ParsedAddresses) != 3 || !a.PostPublic {
+		t.Fatalf("got alias %v, expected public alias with members %v", a, members)
+	}
+
+	_, err = AliasAddWithMembers(ctxbg, addr, config.Alias{}, members, false)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v for existing alias, expected ErrRequest", err)
+	}
+
+	// Unknown members are skipped with allowMissing.
+	addr2 := smtp.NewAddress("team2", domain)
+	skipped, err = AliasAddWithMembers(ctxbg, addr2, config.Alias{}, []string{"mjl@mox.example", "unknown@mox.example"}, true)
+	tcheck(t, err, "add alias with missing member")
+	if !reflect.DeepEqual(skipped, []string{"unknown@mox.example"}) {
+		t.Fatalf("got skipped members %v, expected unknown@mox.example", skipped)
+	}
+	a, err = AliasGet(ctxbg, addr2)
+	tcheck(t, err, "get alias")
+	if !reflect.DeepEqual(a.Addresses, []string{"mjl@mox.example"}) {
+		t.Fatalf("got addresses %v, expected mjl@mox.example", a.Addresses)
+	}
+}
+
+func TestSubdomainCatchall(t *testing.T) {
+	setupConfig(t)
+
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	err = queue.Init()
+	tcheck(t, err, "queue init")
+	defer queue.Shutdown()
+
+	lookup := func(address, expAccount, expCanonical string, expErr error) {
+		t.Helper()
+		addr, err := smtp.ParseAddress(address)
+		tcheck(t, err, "parse address")
+		accName, _, canonical, _, err := mox.LookupAddress(addr.Localpart, addr.Domain, false, false, true)
+		if expErr != nil || err != nil {
+			if !errors.Is(err, expErr) {
+				t.Fatalf("lookup %s: got err %v, expected %v", address, err, expErr)
+			}
+			return
+		}
+		if accName != expAccount || canonical != expCanonical {
+			t.Fatalf("lookup %s: got account %q, canonical %q, expected %q, %q", address, accName, canonical, expAccount, expCanonical)
+		}
+	}
+
+	lookup("x@sub.mox.example", "", "", mox.ErrDomainNotFound)
+
+	err = AddressAdd(ctxbg, "@.mox.example", "mjl")
+	tcheck(t, err, "add subdomain catchall")
+	err = AddressAdd(ctxbg, "@.mox.example", "mjl")
+	if err == nil {
+		t.Fatalf("added duplicate subdomain catchall")
+	}
+
+	lookup("mjl@mox.example", "mjl", "mjl@mox.example", nil)
+	lookup("x@mox.example", "mjl", "@.mox.example", nil)
+	lookup("x@sub.mox.example", "mjl", "@.mox.example", nil)
+	lookup("x@a.b.mox.example", "mjl", "@.mox.example", nil)
+	lookup("x@other.example", "", "", mox.ErrDomainNotFound)
+	lookup("x@sub.other.example", "", "", mox.ErrDomainNotFound)
+
+	// Exact catchall takes precedence.
+	err = AccountAdd(ctxbg, "other", "other@mox.example")
+	tcheck(t, err, "add account")
+	err = AddressAdd(ctxbg, "@mox.example", "other")
+	tcheck(t, err, "add catchall")
+	lookup("x@mox.example", "other", "@mox.example", nil)
+	lookup("x@sub.mox.example", "mjl", "@.mox.example", nil)
+
+	// Nearest configured parent domain without subdomain catchall stops the search.
+	err = DomainAdd(ctxbg, false, dns.Domain{ASCII: "sub.mox.example"}, "other", "")
+	tcheck(t, err, "add subdomain")
+	lookup("x@a.sub.mox.example", "", "", mox.ErrDomainNotFound)
+	lookup("x@a.b.mox.example", "mjl", "@.mox.example", nil)
+
+	err = AddressRemove(ctxbg, "@.mox.example")
+	tcheck(t, err, "remove subdomain catchall")
+	lookup("x@a.b.mox.example", "", "", mox.ErrDomainNotFound)
+	lookup("x@mox.example", "other", "@mox.example", nil)
+}
+
+func TestMoveAwayKeysCollision(t *testing.T) {
+	setupConfig(t)
+	log := pkglog.WithContext(ctxbg)
+
+	keyPath := filepath.Join("dkim", "sel1._domainkey.mox.example.privatekey.pkcs8.pem")
+	sels := map[string]config.Selector{"sel1": {PrivateKeyFile: keyPath}}
+
+	// Remove a selector with the same key file name three times.
+	for i := range 3 {
+		p := mox.ConfigDirPath(keyPath)
+		err := os.MkdirAll(filepath.Dir(p), 0770)
+		tcheck(t, err, "mkdir")
+		err = os.WriteFile(p, fmt.Appendf(nil, "key %d", i), 0660)
+		tcheck(t, err, "write key file")
+
+		moveAwayKeys(log, sels, map[string]bool{})
+
+		if _, err := os.Stat(p); err == nil {
+			t.Fatalf("key file not moved away, attempt %d", i)
+		}
+	}
+
+	files, err := os.ReadDir(mox.ConfigDirPath(filepath.Join("dkim", "old")))
+	tcheck(t, err, "read old dir")
+	if len(files) != 3 {
+		t.Fatalf("got %d files in old dir, expected 3", len(files))
+	}
+	seen := map[string]bool{}
+	for _, f := range files {
+		buf, err := os.ReadFile(mox.ConfigDirPath(filepath.Join("dkim", "old", f.Name())))
+		tcheck(t, err, "read moved key file")
+		seen[string(buf)] = true
+	}
+	if len(seen) != 3 {
+		t.Fatalf("moved key files overwritten, got %v", seen)
+	}
+}
+
+func TestDomainDisable(t *testing.T) {
+	setupConfig(t)
+
+	domain := dns.Domain{ASCII: "mox.example"}
+	before, _ := mox.Conf.Domain(domain)
+
+	err := DomainDisable(ctxbg, domain, true)
+	tcheck(t, err, "disable domain")
+
+	// Incoming delivery is refused, login lookups still work.
+	_, _, _, _, err = mox.LookupAddress("mjl", domain, false, false, true)
+	if !errors.Is(err, mox.ErrDomainDisabled) {
+		t.Fatalf("got err %v for delivery to disabled domain, expected ErrDomainDisabled", err)
+	}
+	accName, _, _, _, err := mox.LookupAddress("mjl", domain, false, false, false)
+	tcheck(t, err, "lookup address without checking disabled domain")
+	if accName != "mjl" {
+		t.Fatalf("got account %q, expected mjl", accName)
+	}
+
+	// Config is otherwise unchanged.
+	after, _ := mox.Conf.Domain(domain)
+	if !after.Disabled {
+		t.Fatalf("domain not disabled in config")
+	}
+	after.Disabled = false
+	if !reflect.DeepEqual(before, after) {
+		t.Fatalf("domain config changed other than disabled field:\n%#v\n%#v", before, after)
+	}
+
+	err = DomainDisable(ctxbg, domain, false)
+	tcheck(t, err, "enable domain")
+	_, _, _, _, err = mox.LookupAddress("mjl", domain, false, false, true)
+	tcheck(t, err, "lookup address after enabling domain")
+
+	err = DomainDisable(ctxbg, dns.Domain{ASCII: "missing.example"}, true)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v for unknown domain, expected ErrRequest", err)
+	}
+}
+
+func TestDomainAddDKIMDualSign(t *testing.T) {
+	setupConfig(t)
+
+	domain := dns.Domain{ASCII: "new.example"}
+	err := DomainAddMulti(ctxbg, []DomainAddSpec{{Domain: domain, AccountName: "mjl", DKIMDualSign: true}})
+	tcheck(t, err, "add domain")
+
+	dc, _ := mox.Conf.Domain(domain)
+	if len(dc.DKIM.Selectors) != 2 || len(dc.DKIM.Sign) != 2 {
+		t.Fatalf("got selectors %v, sign %v, expected two selectors used for signing", dc.DKIM.Selectors, dc.DKIM.Sign)
+	}
+	algorithms := map[string]bool{}
+	for _, name := range dc.DKIM.Sign {
+		sel, ok := dc.DKIM.Selectors[name]
+		if !ok {
+			t.Fatalf("unknown selector %q for signing", name)
+		}
+		algorithms[sel.Algorithm] = true
+	}
+	if !algorithms["ed25519"] || !algorithms["rsa-2048"] {
+		t.Fatalf("got algorithms %v, expected ed25519 and rsa-2048", algorithms)
+	}
+
+	records, err := DomainRecords(dc, domain, false, "", "")
+	tcheck(t, err, "domain records")
+	for _, name := range dc.DKIM.Sign {
+		prefix := name + "._domainkey.new.example. "
+		if !slices.ContainsFunc(records, func(r string) bool { return strings.HasPrefix(r, prefix) }) {
+			t.Fatalf("missing dkim record for selector %q", name)
+		}
+	}
+	if !slices.ContainsFunc(records, func(r string) bool { return strings.Contains(r, "k=ed25519") }) {
+		t.Fatalf("missing ed25519 dkim record")
+	}
+}
+
+func TestDomainAddReportAddresses(t *testing.T) {
+	setupConfig(t)
+
+	domain := dns.Domain{ASCII: "new.example"}
+	opts := MakeDomainConfigOpts{
+		DMARCLocalpart:  "dmarc-rua",
+		DMARCMailbox:    "Reports/DMARC",
+		TLSRPTLocalpart: "tls-rua",
+	}
+	err := DomainAddMulti(ctxbg, []DomainAddSpec{{Domain: domain, AccountName: "mjl", Opts: opts}})
+	tcheck(t, err, "add domain")
+
+	dc, _ := mox.Conf.Domain(domain)
+	if dc.DMARC.Localpart != "dmarc-rua" || dc.DMARC.Mailbox != "Reports/DMARC" {
+		t.Fatalf("got dmarc %v, expected custom localpart and mailbox", dc.DMARC)
+	}
+	if dc.TLSRPT.Localpart != "tls-rua" || dc.TLSRPT.Mailbox != "TLSRPT" {
+		t.Fatalf("got tlsrpt %v, expected custom localpart and default mailbox", dc.TLSRPT)
+	}
+	accName, _, _, _, err := mox.LookupAddress("dmarc-rua", domain, false, false, false)
+	tcheck(t, err, "lookup dmarc address")
+	if accName != "mjl" {
+		t.Fatalf("dmarc address delivers to %q, expected mjl", accName)
+	}
+
+	// Defaults.
+	err = DomainAdd(ctxbg, false, dns.Domain{ASCII: "default.example"}, "mjl", "")
+	tcheck(t, err, "add domain")
+	dc, _ = mox.Conf.Domain(dns.Domain{ASCII: "default.example"})
+	if dc.DMARC.Localpart != "dmarcreports" || dc.DMARC.Mailbox != "DMARC" || dc.TLSRPT.Localpart != "tlsreports" || dc.TLSRPT.Mailbox != "TLSRPT" {
+		t.Fatalf("got dmarc %v, tlsrpt %v, expected defaults", dc.DMARC, dc.TLSRPT)
+	}
+
+	// Invalid localpart and mailbox.
+	for _, opts := range []MakeDomainConfigOpts{{DMARCLocalpart: "a b"}, {TLSRPTLocalpart: "x..y"}, {DMARCMailbox: "a\nb"}} {
+		err = DomainAddMulti(ctxbg, []DomainAddSpec{{Domain: dns.Domain{ASCII: "invalid.example"}, AccountName: "mjl", Opts: opts}})
+		if !errors.Is(err, ErrRequest) {
+			t.Fatalf("got err %v for opts %v, expected ErrRequest", err, opts)
+		}
+	}
+}
+
+func TestMTASTSMaxAgeSave(t *testing.T) {
+	setupConfig(t)
+
+	l := mox.Conf.Static.Listeners["local"]
+	l.MTASTSHTTPS.Enabled = true
+	mox.Conf.Static.Listeners["local"] = l
+
+	domain := dns.Domain{ASCII: "new.example"}
+	err := DomainAdd(ctxbg, false, domain, "mjl", "")
+	tcheck(t, err, "add domain")
+	dc, _ := mox.Conf.Domain(domain)
+	if dc.MTASTS == nil {
+		t.Fatalf("new domain has no mta-sts policy")
+	}
+	oldID := dc.MTASTS.PolicyID
+
+	for _, d := range []time.Duration{MTASTSMaxAgeMin - time.Second, MTASTSMaxAgeMax + time.Second} {
+		err := MTASTSMaxAgeSave(ctxbg, domain, d)
+		if !errors.Is(err, ErrRequest) {
+			t.Fatalf("max age %v: got err %v, expected ErrRequest", d, err)
+		}
+	}
+	err = MTASTSMaxAgeSave(ctxbg, dns.Domain{ASCII: "mox.example"}, MTASTSMaxAgeMin)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v for domain without mta-sts, expected ErrRequest", err)
+	}
+
+	err = MTASTSMaxAgeSave(ctxbg, domain, MTASTSMaxAgeMax)
+	tcheck(t, err, "save max age")
+	dc, _ = mox.Conf.Domain(domain)
+	if dc.MTASTS.MaxAge != MTASTSMaxAgeMax || dc.MTASTS.PolicyID == oldID {
+		t.Fatalf("got max age %v, policy id %q, expected %v and new policy id (old %q)", dc.MTASTS.MaxAge, dc.MTASTS.PolicyID, MTASTSMaxAgeMax, oldID)
+	}
+
+	// DNS record has the new policy ID.
+	records, err := DomainRecordsStructured(ctxbg, domain)
+	tcheck(t, err, "dns records")
+	exp := Record{Type: "TXT", Name: "_mta-sts.new.example.", Value: "v=STSv1; id=" + dc.MTASTS.PolicyID, TTL: 300}
+	if !slices.Contains(records, exp) {
+		t.Fatalf("missing record %v in %v", exp, records)
+	}
+
+	// Same max age does not change the policy ID.
+	newID := dc.MTASTS.PolicyID
+	err = MTASTSMaxAgeSave(ctxbg, domain, MTASTSMaxAgeMax)
+	tcheck(t, err, "save same max age")
+	if dc, _ := mox.Conf.Domain(domain); dc.MTASTS.PolicyID != newID {
+		t.Fatalf("policy id changed for same max age")
+	}
+}
+
+func TestMTASTSModeSave(t *testing.T) {
+	setupConfig(t)
+
+	domain := dns.Domain{ASCII: "mox.example"}
+
+	// New domains start out in testing mode.
+	dc, _, err := MakeDomainConfig(ctxbg, dns.Domain{ASCII: "new.example"}, domain, "mjl", true, false, MakeDomainConfigOpts{})
+	tcheck(t, err, "make domain config")
+	if dc.MTASTS == nil || dc.MTASTS.Mode != mtasts.ModeTesting {
+		t.Fatalf("got mtasts %v, expected testing mode", dc.MTASTS)
+	}
+
+	// Domain without policy.
+	err = MTASTSModeSave(ctxbg, domain, mtasts.ModeEnforce)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v for domain without mta-sts, expected ErrRequest", err)
+	}
+
+	// Serving mta-sts policies requires a listener with MTASTSHTTPS.
+	l := mox.Conf.Static.Listeners["local"]
+	l.MTASTSHTTPS.Enabled = true
+	mox.Conf.Static.Listeners["local"] = l
+
+	err = DomainSave(ctxbg, domain.Name(), func(d *config.Domain) error {
+		d.MTASTS = dc.MTASTS
+		return nil
+	})
+	tcheck(t, err, "save mta-sts policy")
+
+	err = MTASTSModeSave(ctxbg, domain, "bogus")
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v for invalid mode, expected ErrRequest", err)
+	}
+
+	err = MTASTSModeSave(ctxbg, domain, mtasts.ModeEnforce)
+	tcheck(t, err, "save mode")
+	ndc, _ := mox.Conf.Domain(domain)
+	if ndc.MTASTS.Mode != mtasts.ModeEnforce || ndc.MTASTS.PolicyID == dc.MTASTS.PolicyID {
+		t.Fatalf("got mode %q, policy id %q, expected enforce and new policy id (old %q)", ndc.MTASTS.Mode, ndc.MTASTS.PolicyID, dc.MTASTS.PolicyID)
+	}
+
+	// Saving the same mode keeps the policy id.
+	err = MTASTSModeSave(ctxbg, domain, mtasts.ModeEnforce)
+	tcheck(t, err, "save mode")
+	if xdc, _ := mox.Conf.Domain(domain); xdc.MTASTS.PolicyID != ndc.MTASTS.PolicyID {
+		t.Fatalf("policy id changed without mode change")
+	}
+}
+
+func TestDomainLocalpartConfigSave(t *testing.T) {
+	setupConfig(t)
+
+	domain := dns.Domain{ASCII: "mox.example"}
+
+	err := AddressAdd(ctxbg, "first-last@mox.example", "mjl")
+	tcheck(t, err, "add address")
+
+	// Invalid separators.
+	for _, seps := range [][]string{{"ab"}, {"a"}, {"1"}, {" "}, {""}, {"+", "+"}} {
+		err := DomainLocalpartConfigSave(ctxbg, domain, seps, false)
+		if !errors.Is(err, ErrRequest) {
+			t.Fatalf("got err %v for separators %q, expected ErrRequest", err, seps)
+		}
+	}
+
+	// "-" is used in an existing address.
+	err = DomainLocalpartConfigSave(ctxbg, domain, []string{"+", "-"}, false)
+	if !errors.Is(err, ErrRequest) || !strings.Contains(err.Error(), "first-last") {
+		t.Fatalf("got err %v for colliding separator, expected ErrRequest", err)
+	}
+
+	err = DomainLocalpartConfigSave(ctxbg, domain, []string{"+", "_"}, true)
+	tcheck(t, err, "save localpart config")
+	dc, _ := mox.Conf.Domain(domain)
+	if !reflect.DeepEqual(dc.LocalpartCatchallSeparators, []string{"+", "_"}) || dc.LocalpartCatchallSeparator != "" || !dc.LocalpartCaseSensitive {
+		t.Fatalf("got separators %q and %q, case sensitive %v", dc.LocalpartCatchallSeparators, dc.LocalpartCatchallSeparator, dc.LocalpartCaseSensitive)
+	}
+	accName, _, _, _, err := mox.LookupAddress("mjl_test", domain, false, false, false)
+	tcheck(t, err, "lookup address with separator")
+	if accName != "mjl" {
+		t.Fatalf("got account %q, expected mjl", accName)
+	}
+
+	// Single separator is stored in the non-list form.
+	err = DomainLocalpartConfigSave(ctxbg, domain, []string{"+"}, false)
+	tcheck(t, err, "save localpart config")
+	dc, _ = mox.Conf.Domain(domain)
+	if dc.LocalpartCatchallSeparator != "+" || dc.LocalpartCatchallSeparators != nil {
+		t.Fatalf("got separator %q and %q, expected single +", dc.LocalpartCatchallSeparator, dc.LocalpartCatchallSeparators)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	log := pkglog.WithContext(ctxbg)
+	dir := t.TempDir()
+
+	p := filepath.Join(dir, "sub", "key.pem")
+	data := bytes.Repeat([]byte("test\n"), 10000)
+	err := writeFile(log, p, data)
+	tcheck(t, err, "write file")
+	buf, err := os.ReadFile(p)
+	tcheck(t, err, "read file")
+	if !bytes.Equal(buf, data) {
+		t.Fatalf("file has %d bytes, expected %d", len(buf), len(data))
+	}
+
+	// Existing files are not overwritten.
+	err = writeFile(log, p, []byte("other"))
+	if err == nil {
+		t.Fatalf("overwrote existing file")
+	}
+	buf, err = os.ReadFile(p)
+	tcheck(t, err, "read file")
+	if !bytes.Equal(buf, data) {
+		t.Fatalf("existing file was modified")
+	}
+}
+
+func TestAddressRemoveForce(t *testing.T) {
+	setupConfig(t)
+
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	err = queue.Init()
+	tcheck(t, err, "queue init")
+	defer queue.Shutdown()
+
+	domain := dns.Domain{ASCII: "mox.example"}
+	err = DomainSave(ctxbg, domain.Name(), func(d *config.Domain) error {
+		d.DMARC = &config.DMARC{Localpart: "dmarcreports", Account: "mjl", Mailbox: "DMARC"}
+		return nil
+	})
+	tcheck(t, err, "save domain")
+
+	// Reporting address is only removed when forced, along with the DMARC config.
+	err = AddressRemove(ctxbg, "dmarcreports@mox.example")
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("remove reporting address: got err %v, expected ErrRequest", err)
+	}
+	err = AddressRemoveForce(ctxbg, "dmarcreports@mox.example")
+	tcheck(t, err, "force remove reporting address")
+	if dc, _ := mox.Conf.Domain(domain); dc.DMARC != nil {
+		t.Fatalf("dmarc config still present after forced removal")
+	}
+	if _, _, ok := mox.Conf.AccountDestination("dmarcreports@mox.example"); ok {
+		t.Fatalf("reporting address still present after forced removal")
+	}
+
+	// Address with a queued message is only removed when forced.
+	msgFile, err := store.CreateMessageTemp(pkglog, "addressremove")
+	tcheck(t, err, "create temp message")
+	defer os.Remove(msgFile.Name())
+	defer msgFile.Close()
+	const msg = "Subject: test\r\n\r\ntest\r\n"
+	_, err = msgFile.WriteString(msg)
+	tcheck(t, err, "write message")
+	from := smtp.Path{Localpart: "mjl", IPDomain: dns.IPDomain{Domain: domain}}
+	to := smtp.Path{Localpart: "remote", IPDomain: dns.IPDomain{Domain: dns.Domain{ASCII: "remote.example"}}}
+	qm := queue.MakeMsg(from, to, false, false, int64(len(msg)), "<test@localhost>", nil, nil, time.Now(), "test")
+	qm.Hold = true
+	err = queue.Add(ctxbg, pkglog, "mjl", msgFile, qm)
+	tcheck(t, err, "add message to queue")
+
+	err = AddressRemove(ctxbg, "mjl@mox.example")
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("remove address with queued message: got err %v, expected ErrRequest", err)
+	}
+	err = AddressRemoveForce(ctxbg, "mjl@mox.example")
+	tcheck(t, err, "force remove address with queued message")
+
+	// Address referenced by a TLS public key is refused, also when forced.
+	tpk := store.TLSPublicKey{Fingerprint: "test", Name: "test", Type: "ed25519", CertDER: []byte{1}, Account: "mjl", LoginAddress: "mjl2@mox.example"}
+	err = store.TLSPublicKeyAdd(ctxbg, &tpk)
+	tcheck(t, err, "add tls public key")
+	err = AddressRemoveForce(ctxbg, "mjl2@mox.example")
+	if !errors.Is(err, ErrRequest) || !strings.Contains(err.Error(), "tls public key") {
+		t.Fatalf("force remove address referenced by tls public key: got err %v, expected ErrRequest", err)
+	}
+	if _, _, ok := mox.Conf.AccountDestination("mjl2@mox.example"); !ok {
+		t.Fatalf("address referenced by tls public key was removed")
+	}
+}
+
+func TestAddressMove(t *testing.T) {
+	setupConfig(t)
+
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	err = queue.Init()
+	tcheck(t, err, "queue init")
+	defer queue.Shutdown()
+
+	err = AccountAdd(ctxbg, "other", "other@mox.example")
+	tcheck(t, err, "add account")
+
+	// Give mjl2@mox.example a ruleset, and make it an alias member.
+	ruleset := config.Ruleset{HeadersRegexp: map[string]string{"subject": "test"}, Mailbox: "Test"}
+	err = AccountSave(ctxbg, "mjl", func(acc *config.Account) {
+		acc.Destinations = maps.Clone(acc.Destinations)
+		acc.Destinations["mjl2@mox.example"] = config.Destination{Mailbox: "Other", Rulesets: []config.Ruleset{ruleset}}
+	})
+	tcheck(t, err, "save account")
+	_, err = AliasAdd(ctxbg, smtp.NewAddress("team", dns.Domain{ASCII: "mox.example"}), config.Alias{Addresses: []string{"mjl2@mox.example"}}, false)
+	tcheck(t, err, "add alias")
+
+	// Bad requests.
+	for _, args := range [][3]string{
+		{"mjl2@mox.example", "mjl", "mjl"},
+		{"mjl2@mox.example", "other", "mjl"},
+		{"mjl2@mox.example", "mjl", "missing"},
+		{"missing@mox.example", "mjl", "other"},
+	} {
+		err := AddressMove(ctxbg, args[0], args[1], args[2])
+		if !errors.Is(err, ErrRequest) {
+			t.Fatalf("got err %v for move %v, expected ErrRequest", err, args)
+		}
+	}
+
+	err = AddressMove(ctxbg, "mjl2@mox.example", "mjl", "other")
+	tcheck(t, err, "move address")
+
+	acc, _ := mox.Conf.Account("mjl")
+	if _, ok := acc.Destinations["mjl2@mox.example"]; ok {
+		t.Fatalf("address still present in source account")
+	}
+	acc, _ = mox.Conf.Account("other")
+	dest, ok := acc.Destinations["mjl2@mox.example"]
+	if !ok || dest.Mailbox != "Other" || len(dest.Rulesets) != 1 || !reflect.DeepEqual(dest.Rulesets[0].HeadersRegexp, ruleset.HeadersRegexp) || dest.Rulesets[0].Mailbox != "Test" {
+		t.Fatalf("got destination %#v, expected destination with ruleset", dest)
+	}
+	accName, _, _, _, err := mox.LookupAddress("mjl2", dns.Domain{ASCII: "mox.example"}, false, false, false)
+	tcheck(t, err, "lookup address")
+	if accName != "other" {
+		t.Fatalf("address delivers to %q, expected other", accName)
+	}
+	a, err := AliasGet(ctxbg, smtp.NewAddress("team", dns.Domain{ASCII: "mox.example"}))
+	tcheck(t, err, "get alias")
+	if len(a.ParsedAddresses) != 1 || a.ParsedAddresses[0].AccountName != "other" {
+		t.Fatalf("got alias addresses %v, expected member in account other", a.ParsedAddresses)
+	}
+}
+
+func TestAccountRejectsMailboxSave(t *testing.T) {
+	setupConfig(t)
+
+	for _, mailbox := range []string{"", "Inbox", "a//b", "../Rejects"} {
+		err := AccountRejectsMailboxSave(ctxbg, "mjl", mailbox)
+		if !errors.Is(err, ErrRequest) {
+			t.Fatalf("mailbox %q: got err %v, expected ErrRequest", mailbox, err)
+		}
+	}
+	err := AccountRejectsMailboxSave(ctxbg, "missing", "Rejects")
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("missing account: got err %v, expected ErrRequest", err)
+	}
+
+	err = AccountRejectsMailboxSave(ctxbg, "mjl", "Junk/Rejected")
+	tcheck(t, err, "save rejects mailbox")
+	accConf, _ := mox.Conf.Account("mjl")
+	if accConf.RejectsMailbox != "Junk/Rejected" {
+		t.Fatalf("got rejects mailbox %q, expected Junk/Rejected", accConf.RejectsMailbox)
+	}
+
+	// Change must be persisted in domains.conf.
+	c, _, _, _, errs := mox.ParseDynamicConfig(ctxbg, pkglog, mox.ConfigDynamicPath, mox.Conf.Static)
+	if len(errs) > 0 {
+		t.Fatalf("parsing domains.conf: %v", errs)
+	}
+	if mb := c.Accounts["mjl"].RejectsMailbox; mb != "Junk/Rejected" {
+		t.Fatalf("got rejects mailbox %q from domains.conf, expected Junk/Rejected", mb)
+	}
+}
+
+func TestAccountJunkFilterSave(t *testing.T) {
+	setupConfig(t)
+
+	valid := config.JunkFilter{
+		Threshold: 0.9,
+		Params: junk.Params{
+			Onegrams:    true,
+			Twograms:    true,
+			MaxPower:    .05,
+			TopWords:    15,
+			IgnoreWords: .2,
+			RareWords:   1,
+		},
+	}
+	bad := []func(jf *config.JunkFilter){
+		func(jf *config.JunkFilter) { jf.Threshold = 0 },
+		func(jf *config.JunkFilter) { jf.Threshold = 1 },
+		func(jf *config.JunkFilter) { jf.MaxPower = 0 },
+		func(jf *config.JunkFilter) { jf.MaxPower = .6 },
+		func(jf *config.JunkFilter) { jf.TopWords = 0 },
+		func(jf *config.JunkFilter) { jf.IgnoreWords = .5 },
+		func(jf *config.JunkFilter) { jf.RareWords = -1 },
+		func(jf *config.JunkFilter) { jf.Onegrams, jf.Twograms = false, false },
+	}
+	for i, fn := range bad {
+		jf := valid
+		fn(&jf)
+		err := AccountJunkFilterSave(ctxbg, "mjl", &jf)
+		if !errors.Is(err, ErrRequest) {
+			t.Fatalf("bad params %d: got err %v, expected ErrRequest", i, err)
+		}
+	}
+
+	err := AccountJunkFilterSave(ctxbg, "mjl", &valid)
+	tcheck(t, err, "save junk filter")
+	accConf, _ := mox.Conf.Account("mjl")
+	if accConf.JunkFilter == nil || *accConf.JunkFilter != valid {
+		t.Fatalf("got junk filter %#v, expected %#v", accConf.JunkFilter, valid)
+	}
+
+	err = AccountJunkFilterSave(ctxbg, "mjl", nil)
+	tcheck(t, err, "disable junk filter")
+	accConf, _ = mox.Conf.Account("mjl")
+	if accConf.JunkFilter != nil {
+		t.Fatalf("junk filter still enabled: %#v", accConf.JunkFilter)
+	}
+}
+
+func TestAccountQuotaSave(t *testing.T) {
+	setupConfig(t)
+
+	err := AccountQuotaSave(ctxbg, "mjl", 1024, -1)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("negative message count: got err %v, expected ErrRequest", err)
+	}
+	err = AccountQuotaSave(ctxbg, "mjl", -1, 1)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("negative size: got err %v, expected ErrRequest", err)
+	}
+	err = AccountQuotaSave(ctxbg, "missing", 1024, 1)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("missing account: got err %v, expected ErrRequest", err)
+	}
+
+	err = AccountQuotaSave(ctxbg, "mjl", 1024*1024, 2)
+	tcheck(t, err, "save quota")
+	accConf, _ := mox.Conf.Account("mjl")
+	if accConf.QuotaMessageSize != 1024*1024 || accConf.QuotaMessageCount != 2 {
+		t.Fatalf("got quota size %d, count %d, expected 1048576 and 2", accConf.QuotaMessageSize, accConf.QuotaMessageCount)
+	}
+
+	// Deliveries beyond the quota are refused.
+	log := pkglog.WithContext(ctxbg)
+	err = store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	defer store.Switchboard()()
+
+	acc, err := store.OpenAccount(log, "mjl", false)
+	tcheck(t, err, "open account")
+	defer func() {
+		err := acc.Close()
+		tcheck(t, err, "close account")
+		acc.WaitClosed()
+	}()
+
+	deliver := func() error {
+		msgFile, err := store.CreateMessageTemp(log, "admin-test-quota")
+		tcheck(t, err, "create temp message")
+		defer os.Remove(msgFile.Name())
+		defer msgFile.Close()
+		const msg = "Subject: test\r\n\r\ntest\r\n"
+		_, err = msgFile.Write([]byte(msg))
+		tcheck(t, err, "write message")
+		acc.WithWLock(func() {
+			m := store.Message{Received: time.Now(), Size: int64(len(msg))}
+			err = acc.DeliverMailbox(log, "Inbox", &m, msgFile)
+		})
+		return err
+	}
+	tcheck(t, deliver(), "deliver first message")
+	tcheck(t, deliver(), "deliver second message")
+	if err := deliver(); !errors.Is(err, store.ErrOverQuota) {
+		t.Fatalf("third delivery: got err %v, expected ErrOverQuota", err)
+	}
+
+	// Size quota, smaller than the next message.
+	err = AccountQuotaSave(ctxbg, "mjl", 64, 0)
+	tcheck(t, err, "save size quota")
+	if err := deliver(); !errors.Is(err, store.ErrOverQuota) {
+		t.Fatalf("delivery over size quota: got err %v, expected ErrOverQuota", err)
+	}
+
+	// Zero means unlimited, also with a global default maximum size.
+	mox.Conf.Static.QuotaMessageSize = 64
+	defer func() {
+		mox.Conf.Static.QuotaMessageSize = 0
+	}()
+	err = AccountQuotaSave(ctxbg, "mjl", 0, 0)
+	tcheck(t, err, "remove quota")
+	accConf, _ = mox.Conf.Account("mjl")
+	if accConf.QuotaMessageSize >= 0 || accConf.QuotaMessageCount != 0 {
+		t.Fatalf("got quota size %d, count %d, expected negative size and 0 for unlimited", accConf.QuotaMessageSize, accConf.QuotaMessageCount)
+	}
+	tcheck(t, deliver(), "deliver after removing quota")
+}
+
+// keyFileCancelContext is canceled once a DKIM key file has been written.
+type keyFileCancelContext struct {
+	context.Context
+}
+
+func (ctx keyFileCancelContext) Err() error {
+	if files, _ := os.ReadDir(mox.ConfigDynamicDirPath("dkim")); len(files) > 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestDomainAddCanceled(t *testing.T) {
+	setupConfig(t)
+
+	// Context is canceled after the first key is written, before the second key is generated.
+	domain := dns.Domain{ASCII: "other.example"}
+	err := DomainAdd(keyFileCancelContext{ctxbg}, false, domain, "mjl", "")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got err %v, expected context.Canceled", err)
+	}
+	if _, ok := mox.Conf.Domain(domain); ok {
+		t.Fatalf("domain added after cancel")
+	}
+	files, err := os.ReadDir(mox.ConfigDynamicDirPath("dkim"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("reading dkim dir: %v", err)
+	}
+	if len(files) != 0 {
+		t.Fatalf("key files remain after cancel: %v", files)
+	}
+}
+
+func TestDomainList(t *testing.T) {
+	setupConfig(t)
+
+	// Enable MTA-STS for new domains.
+	l := mox.Conf.Static.Listeners["local"]
+	l.MTASTSHTTPS.Enabled = true
+	mox.Conf.Static.Listeners["local"] = l
+
+	err := DomainAdd(ctxbg, true, dns.Domain{ASCII: "other.example"}, "mjl", "")
+	tcheck(t, err, "add domain")
+	err = DomainSave(ctxbg, "other.example", func(d *config.Domain) error {
+		d.TLSRPT = nil
+		return nil
+	})
+	tcheck(t, err, "save domain")
+
+	list := DomainList(ctxbg)
+	exp := []DomainInfo{
+		{
+			Domain: dns.Domain{ASCII: "mox.example"},
+		},
+		{
+			Domain:               dns.Domain{ASCII: "other.example"},
+			Disabled:             true,
+			ClientSettingsDomain: "mail.other.example",
+			DKIMSelectors:        2,
+			DKIMSign:             1,
+			DMARC:                true,
+			MTASTS:               true,
+		},
+	}
+	if !reflect.DeepEqual(list, exp) {
+		t.Fatalf("got domains %#v, expected %#v", list, exp)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	setupConfig(t)
+
+	buf, err := os.ReadFile(mox.ConfigDynamicPath)
+	tcheck(t, err, "read domains.conf")
+
+	err = ConfigValidate(ctxbg, func(c *config.Dynamic) {
+		c.Accounts = maps.Clone(c.Accounts)
+		c.Accounts["other"] = MakeAccountConfig(smtp.NewAddress("other", dns.Domain{ASCII: "missing.example"}))
+	})
+	if !errors.Is(err, mox.ErrConfig) || !strings.Contains(err.Error(), "missing.example") {
+		t.Fatalf("got err %v, expected ErrConfig mentioning unknown domain", err)
+	}
+	if _, ok := mox.Conf.Account("other"); ok {
+		t.Fatalf("invalid account was added")
+	}
+
+	err = ConfigValidate(ctxbg, func(c *config.Dynamic) {
+		c.Accounts = maps.Clone(c.Accounts)
+		c.Accounts["other"] = MakeAccountConfig(smtp.NewAddress("other", dns.Domain{ASCII: "mox.example"}))
+	})
+	tcheck(t, err, "validate config")
+	if _, ok := mox.Conf.Account("other"); ok {
+		t.Fatalf("account added by validation")
+	}
+
+	nbuf, err := os.ReadFile(mox.ConfigDynamicPath)
+	tcheck(t, err, "read domains.conf")
+	if !bytes.Equal(buf, nbuf) {
+		t.Fatalf("domains.conf changed by validation")
+	}
+}
+
+func TestAccountList(t *testing.T) {
+	setupConfig(t)
+
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	defer store.Switchboard()()
+
+	other := dns.Domain{ASCII: "other.example"}
+	err = DomainAdd(ctxbg, false, other, "mjl", "")
+	tcheck(t, err, "add domain")
+	err = AddressAdd(ctxbg, "mjl@other.example", "mjl")
+	tcheck(t, err, "add address")
+	err = AccountAdd(ctxbg, "nopass", "nopass@mox.example")
+	tcheck(t, err, "add account")
+
+	acc, err := store.OpenAccount(pkglog, "mjl", false)
+	tcheck(t, err, "open account")
+	err = acc.SetPassword(pkglog, "test1234")
+	tcheck(t, err, "set password")
+	err = acc.Close()
+	tcheck(t, err, "close account")
+
+	l, err := AccountList(ctxbg)
+	tcheck(t, err, "list accounts")
+	exp := []AccountInfo{
+		{Name: "mjl", Addresses: 3, Password: true, Domains: []string{"mox.example", "other.example"}},
+		{Name: "nopass", Addresses: 1, JunkFilter: true, Domains: []string{"mox.example"}},
+	}
+	if !reflect.DeepEqual(l, exp) {
+		t.Fatalf("got accounts %#v, expected %#v", l, exp)
+	}
+}
+
+func TestAccountRemoveKeepData(t *testing.T) {
+	setupConfig(t)
+
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	defer store.Switchboard()()
+	err = queue.Init()
+	tcheck(t, err, "queue init")
+	defer queue.Shutdown()
+
+	err = AccountAdd(ctxbg, "other", "other@mox.example")
+	tcheck(t, err, "add account")
+	accountDir := filepath.Join(mox.DataDirPath("accounts"), "other")
+
+	err = AccountRemoveKeepData(ctxbg, "other")
+	tcheck(t, err, "remove account keeping data")
+	if _, ok := mox.Conf.Account("other"); ok {
+		t.Fatalf("account still present in config")
+	}
+	if _, err := os.Stat(filepath.Join(accountDir, "index.db")); err != nil {
+		t.Fatalf("account data removed: %v", err)
+	}
+
+	// Cannot add account with same name while directory exists.
+	err = AccountAdd(ctxbg, "other", "other@mox.example")
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("add account with existing directory: got err %v, expected ErrRequest", err)
+	}
+}
+
+func TestAccountLoginDisable(t *testing.T) {
+	setupConfig(t)
+
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	defer store.Switchboard()()
+
+	acc, err := store.OpenAccount(pkglog, "mjl", false)
+	tcheck(t, err, "open account")
+	err = acc.SetPassword(pkglog, "test1234")
+	tcheck(t, err, "set password")
+	err = acc.Close()
+	tcheck(t, err, "close account")
+
+	sessionToken, _, err := store.SessionAdd(ctxbg, pkglog, "mjl", "mjl@mox.example")
+	tcheck(t, err, "add session")
+
+	err = AccountLoginDisable(ctxbg, "missing", "disabled")
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("disable unknown account: got err %v, expected ErrRequest", err)
+	}
+
+	err = AccountLoginDisable(ctxbg, "mjl", "compromised, contact admin")
+	tcheck(t, err, "disable login")
+	_, _, err = store.OpenEmailAuth(pkglog, "mjl@mox.example", "test1234", true)
+	if !errors.Is(err, store.ErrLoginDisabled) || !strings.Contains(err.Error(), "compromised") {
+		t.Fatalf("login for disabled account: got err %v, expected ErrLoginDisabled with message", err)
+	}
+	if _, err := store.SessionUse(ctxbg, pkglog, "mjl", sessionToken, ""); err == nil {
+		t.Fatalf("session still valid after disabling login")
+	}
+
+	// Delivery still resolves to the account.
+	accName, _, _, _, err := mox.LookupAddress("mjl", dns.Domain{ASCII: "mox.example"}, false, false, false)
+	tcheck(t, err, "lookup address")
+	if accName != "mjl" {
+		t.Fatalf("address delivers to %q, expected mjl", accName)
+	}
+
+	err = AccountLoginDisable(ctxbg, "mjl", "")
+	tcheck(t, err, "enable login")
+	acc, _, err = store.OpenEmailAuth(pkglog, "mjl@mox.example", "test1234", true)
+	tcheck(t, err, "login after enabling")
+	err = acc.Close()
+	tcheck(t, err, "close account")
+}
+
+func TestAccountAddressList(t *testing.T) {
+	setupConfig(t)
+
+	_, err := AccountAddressList(ctxbg, "missing")
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("missing account: got err %v, expected ErrRequest", err)
+	}
+
+	err = AddressAdd(ctxbg, "@mox.example", "mjl")
+	tcheck(t, err, "add catchall address")
+
+	l, err := AccountAddressList(ctxbg, "mjl")
+	tcheck(t, err, "list addresses")
+	exp := []string{"@mox.example", "mjl2@mox.example", "mjl@mox.example"}
+	if !slices.Equal(l, exp) {
+		t.Fatalf("got addresses %v, expected %v", l, exp)
+	}
+}
+
+func TestDomainAddNoDKIM(t *testing.T) {
+	setupConfig(t)
+
+	domain := dns.Domain{ASCII: "nodkim.example"}
+	err := DomainAddMulti(ctxbg, []DomainAddSpec{{Domain: domain, AccountName: "mjl", DKIMDualSign: true, Opts: MakeDomainConfigOpts{NoDKIM: true}}})
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("no dkim with dual sign: got err %v, expected ErrRequest", err)
+	}
+
+	err = DomainAddMulti(ctxbg, []DomainAddSpec{{Domain: domain, AccountName: "mjl", Opts: MakeDomainConfigOpts{NoDKIM: true}}})
+	tcheck(t, err, "add domain without dkim")
+	dc, ok := mox.Conf.Domain(domain)
+	if !ok {
+		t.Fatalf("domain not added")
+	}
+	if len(dc.DKIM.Selectors) != 0 || len(dc.DKIM.Sign) != 0 {
+		t.Fatalf("got dkim config %#v, expected none", dc.DKIM)
+	}
+	files, err := os.ReadDir(mox.ConfigDynamicDirPath("dkim"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("reading dkim dir: %v", err)
+	}
+	if len(files) != 0 {
+		t.Fatalf("key files written for domain without dkim: %v", files)
+	}
+
+	// Domain can receive.
+	err = AddressAdd(ctxbg, "mjl@nodkim.example", "mjl")
+	tcheck(t, err, "add address")
+	accName, _, _, _, err := mox.LookupAddress("mjl", domain, false, false, false)
+	tcheck(t, err, "lookup address")
+	if accName != "mjl" {
+		t.Fatalf("address delivers to %q, expected mjl", accName)
+	}
+}
+
+func TestSuppressionAdd(t *testing.T) {
+	setupConfig(t)
+
+	err := queue.Init()
+	tcheck(t, err, "queue init")
+	defer queue.Shutdown()
+
+	for _, args := range [][2]string{{"missing", "bad@remote.example"}, {"mjl", "bogus"}} {
+		err := SuppressionAdd(ctxbg, args[0], args[1], "test")
+		if !errors.Is(err, ErrRequest) {
+			t.Fatalf("got err %v for %v, expected ErrRequest", err, args)
+		}
+	}
+
+	err = SuppressionAdd(ctxbg, "mjl", "Bad.User+tag@remote.example", "known bad")
+	tcheck(t, err, "add suppression")
+
+	// Same base address.
+	err = SuppressionAdd(ctxbg, "mjl", "baduser@remote.example", "again")
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("duplicate suppression: got err %v, expected ErrRequest", err)
+	}
+
+	l, err := queue.SuppressionList(ctxbg, "mjl")
+	tcheck(t, err, "list suppressions")
+	if len(l) != 1 {
+		t.Fatalf("got %d suppressions, expected 1", len(l))
+	}
+	sup := l[0]
+	if sup.BaseAddress != "baduser@remote.example" || sup.OriginalAddress != "Bad.User+tag@remote.example" || !sup.Manual || sup.Reason != "known bad" {
+		t.Fatalf("unexpected suppression %#v", sup)
+	}
+}
+
+func TestDestinationForwardSave(t *testing.T) {
+	setupConfig(t)
+
+	for _, args := range []struct {
+		address string
+		forward []string
+	}{
+		{"missing@mox.example", []string{"remote@example.org"}},
+		{"mjl@mox.example", []string{"bogus"}},
+		{"mjl@mox.example", []string{"mjl@mox.example"}},
+	} {
+		err := DestinationForwardSave(ctxbg, args.address, args.forward)
+		if !errors.Is(err, ErrRequest) {
+			t.Fatalf("got err %v for %v, expected ErrRequest", err, args)
+		}
+	}
+
+	err := DestinationForwardSave(ctxbg, "mjl@mox.example", []string{"remote@example.org", "mjl2@mox.example"})
+	tcheck(t, err, "save forward")
+	acc, _ := mox.Conf.Account("mjl")
+	if fwd := acc.Destinations["mjl@mox.example"].Forward; !slices.Equal(fwd, []string{"remote@example.org", "mjl2@mox.example"}) {
+		t.Fatalf("got forward %v, expected remote and mjl2", fwd)
+	}
+
+	err = DestinationForwardSave(ctxbg, "mjl@mox.example", nil)
+	tcheck(t, err, "clear forward")
+	acc, _ = mox.Conf.Account("mjl")
+	if fwd := acc.Destinations["mjl@mox.example"].Forward; fwd != nil {
+		t.Fatalf("got forward %v, expected none", fwd)
+	}
+}
+
+func TestResolveAddress(t *testing.T) {
+	setupConfig(t)
+
+	domain := dns.Domain{ASCII: "mox.example"}
+	_, err := AliasAdd(ctxbg, smtp.NewAddress("team", domain), config.Alias{Addresses: []string{"mjl@mox.example", "mjl2@mox.example"}}, false)
+	tcheck(t, err, "add alias")
+
+	test := func(address, expAccount, expMatch, expDetail string) {
+		t.Helper()
+		account, match, detail, err := ResolveAddress(ctxbg, address)
+		tcheck(t, err, "resolve address")
+		if account != expAccount || match != expMatch || detail != expDetail {
+			t.Fatalf("resolve %q: got %q, %q, %q, expected %q, %q, %q", address, account, match, detail, expAccount, expMatch, expDetail)
+		}
+	}
+	testErr := func(address string) {
+		t.Helper()
+		_, _, _, err := ResolveAddress(ctxbg, address)
+		if !errors.Is(err, ErrRequest) {
+			t.Fatalf("resolve %q: got err %v, expected ErrRequest", address, err)
+		}
+	}
+
+	test("mjl@mox.example", "mjl", MatchDestination, "mjl@mox.example")
+	test("MJL2@mox.example", "mjl", MatchDestination, "mjl2@mox.example")
+	test("team@mox.example", "", MatchAlias, "team@mox.example: mjl@mox.example (account mjl), mjl2@mox.example (account mjl)")
+	test("postmaster@mox.example", "mjl", MatchPostmaster, "postmaster")
+	testErr("other@mox.example")
+	testErr("mjl@sub.mox.example")
+	testErr("mjl@unknown.example")
+	testErr("bogus")
+
+	err = AddressAdd(ctxbg, "@mox.example", "mjl")
+	tcheck(t, err, "add catchall")
+	test("other@mox.example", "mjl", MatchCatchall, "@mox.example")
+	test("mjl@mox.example", "mjl", MatchDestination, "mjl@mox.example")
+	test("team@mox.example", "", MatchAlias, "team@mox.example: mjl@mox.example (account mjl), mjl2@mox.example (account mjl)")
+
+	err = AddressAdd(ctxbg, "@.mox.example", "mjl")
+	tcheck(t, err, "add subdomain catchall")
+	test("mjl@sub.mox.example", "mjl", MatchSubdomainCatchall, "@.mox.example")
+}
+
+func TestQueueDrop(t *testing.T) {
+	setupConfig(t)
+
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	err = queue.Init()
+	tcheck(t, err, "queue init")
+	defer queue.Shutdown()
+
+	msgFile, err := store.CreateMessageTemp(pkglog, "queuedrop")
+	tcheck(t, err, "create temp message")
+	defer os.Remove(msgFile.Name())
+	defer msgFile.Close()
+	const msg = "Subject: test\r\n\r\ntest\r\n"
+	_, err = msgFile.WriteString(msg)
+	tcheck(t, err, "write message")
+
+	from := smtp.Path{Localpart: "mjl", IPDomain: dns.IPDomain{Domain: dns.Domain{ASCII: "mox.example"}}}
+	var qml []queue.Msg
+	for _, dom := range []string{"dead.example", "dead.example", "alive.example"} {
+		to := smtp.Path{Localpart: "remote", IPDomain: dns.IPDomain{Domain: dns.Domain{ASCII: dom}}}
+		qm := queue.MakeMsg(from, to, false, false, int64(len(msg)), "<test@localhost>", nil, nil, time.Now(), "test")
+		qm.Hold = true // Prevent delivery attempts.
+		qml = append(qml, qm)
+	}
+	err = queue.Add(ctxbg, pkglog, "mjl", msgFile, qml...)
+	tcheck(t, err, "add messages to queue")
+
+	_, err = QueueDrop(ctxbg, queue.Filter{})
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("drop with empty filter: got err %v, expected ErrRequest", err)
+	}
+
+	n, err := QueueDrop(ctxbg, queue.Filter{RecipientDomain: "dead.example"})
+	tcheck(t, err, "drop messages")
+	if n != 2 {
+		t.Fatalf("dropped %d messages, expected 2", n)
+	}
+	l, err := queue.List(ctxbg, queue.Filter{}, queue.Sort{})
+	tcheck(t, err, "list queue")
+	if len(l) != 1 || l[0].RecipientDomainStr != "alive.example" {
+		t.Fatalf("got queue %v, expected single message to alive.example", l)
+	}
+
+	n, err = QueueDrop(ctxbg, queue.Filter{SenderDomain: "other.example"})
+	tcheck(t, err, "drop messages")
+	if n != 0 {
+		t.Fatalf("dropped %d messages for other sender domain, expected 0", n)
+	}
+}
+
+func TestRulesetDisable(t *testing.T) {
+	setupConfig(t)
+
+	rulesets := []config.Ruleset{
+		{HeadersRegexp: map[string]string{"subject": "test"}, Mailbox: "Test"},
+		{HeadersRegexp: map[string]string{"subject": "other"}, Mailbox: "Other"},
+	}
+	err := AccountSave(ctxbg, "mjl", func(acc *config.Account) {
+		acc.Destinations = maps.Clone(acc.Destinations)
+		acc.Destinations["mjl@mox.example"] = config.Destination{Rulesets: rulesets}
+	})
+	tcheck(t, err, "save account")
+
+	for _, args := range []struct {
+		account, address string
+		index            int
+	}{
+		{"missing", "mjl@mox.example", 0},
+		{"mjl", "missing@mox.example", 0},
+		{"mjl", "mjl@mox.example", 2},
+		{"mjl", "mjl@mox.example", -1},
+	} {
+		err := RulesetDisable(ctxbg, args.account, args.address, args.index, true)
+		if !errors.Is(err, ErrRequest) {
+			t.Fatalf("got err %v for %v, expected ErrRequest", err, args)
+		}
+	}
+
+	err = RulesetDisable(ctxbg, "mjl", "mjl@mox.example", 1, true)
+	tcheck(t, err, "disable ruleset")
+	accConf, _ := mox.Conf.Account("mjl")
+	l := accConf.Destinations["mjl@mox.example"].Rulesets
+	if len(l) != 2 || l[0].Disabled || !l[1].Disabled {
+		t.Fatalf("got rulesets %#v, expected second disabled", l)
+	}
+
+	err = RulesetDisable(ctxbg, "mjl", "mjl@mox.example", 1, false)
+	tcheck(t, err, "enable ruleset")
+	accConf, _ = mox.Conf.Account("mjl")
+	if accConf.Destinations["mjl@mox.example"].Rulesets[1].Disabled {
+		t.Fatalf("ruleset still disabled")
+	}
+}
+
+func TestDomainClientSettingsDomainSave(t *testing.T) {
+	setupConfig(t)
+
+	domain := dns.Domain{ASCII: "mox.example"}
+	err := DomainClientSettingsDomainSave(ctxbg, dns.Domain{ASCII: "missing.example"}, dns.Domain{ASCII: "mail.missing.example"})
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("unknown domain: got err %v, expected ErrRequest", err)
+	}
+	err = DomainClientSettingsDomainSave(ctxbg, domain, dns.Domain{ASCII: "bogus domain"})
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("invalid client settings domain: got err %v, expected ErrRequest", err)
+	}
+
+	csd := dns.Domain{ASCII: "mail.mox.example"}
+	err = DomainClientSettingsDomainSave(ctxbg, domain, csd)
+	tcheck(t, err, "save client settings domain")
+	dc, _ := mox.Conf.Domain(domain)
+	if dc.ClientSettingsDomain != "mail.mox.example" || dc.ClientSettingsDNSDomain != csd {
+		t.Fatalf("got client settings domain %q (%v), expected mail.mox.example", dc.ClientSettingsDomain, dc.ClientSettingsDNSDomain)
+	}
+
+	// Client configuration, as used for autoconfig/autodiscover, uses the new host.
+	l := mox.Conf.Static.Listeners["local"]
+	l.IMAPS.Enabled = true
+	l.Submissions.Enabled = true
+	mox.Conf.Static.Listeners["local"] = l
+	cc, err := ClientConfigDomain(domain)
+	tcheck(t, err, "client config")
+	if cc.IMAP.Host != csd || cc.Submission.Host != csd {
+		t.Fatalf("got client config %#v, expected hosts %s", cc, csd)
+	}
+
+	err = DomainClientSettingsDomainSave(ctxbg, domain, dns.Domain{})
+	tcheck(t, err, "clear client settings domain")
+	dc, _ = mox.Conf.Domain(domain)
+	if dc.ClientSettingsDomain != "" {
+		t.Fatalf("got client settings domain %q, expected empty", dc.ClientSettingsDomain)
+	}
+}
+
+func TestAccountTemplate(t *testing.T) {
+	setupConfig(t)
+
+	rejects := ""
+	noCustomPassword := false
+	NewAccountTemplate = AccountTemplate{
+		RejectsMailbox:     &rejects,
+		JunkFilter:         &config.JunkFilter{Threshold: 0.9},
+		AutomaticJunkFlags: &config.AutomaticJunkFlags{Enabled: true, JunkMailboxRegexp: "^spam"},
+		SubjectPass:        &config.SubjectPass{Period: time.Hour},
+		NoCustomPassword:   &noCustomPassword,
+	}
+	defer func() {
+		NewAccountTemplate = AccountTemplate{}
+	}()
+
+	err := AccountAdd(ctxbg, "other", "other@mox.example")
+	tcheck(t, err, "add account")
+	acc, _ := mox.Conf.Account("other")
+	if acc.RejectsMailbox != "" || acc.JunkFilter == nil || acc.JunkFilter.Threshold != 0.9 || acc.AutomaticJunkFlags.JunkMailboxRegexp != "^spam" || acc.AutomaticJunkFlags.NeutralMailboxRegexp != "" || acc.SubjectPass.Period != time.Hour || acc.NoCustomPassword {
+		t.Fatalf("account template not applied: %#v", acc)
+	}
+	if acc.JunkFilter == NewAccountTemplate.JunkFilter {
+		t.Fatalf("junk filter config shared with template")
+	}
+
+	// Fields not in the template get the defaults.
+	acc = MakeAccountConfigWith(smtp.NewAddress("x", dns.Domain{ASCII: "mox.example"}), AccountTemplate{SubjectPass: &config.SubjectPass{Period: time.Hour}})
+	if acc.RejectsMailbox != "Rejects" || acc.JunkFilter == nil || acc.JunkFilter.Threshold != 0.95 || !acc.NoCustomPassword || acc.SubjectPass.Period != time.Hour {
+		t.Fatalf("unexpected defaults: %#v", acc)
+	}
+}
+
+func TestConfigReload(t *testing.T) {
+	setupConfig(t)
+
+	other := dns.Domain{ASCII: "other.example"}
+	valid := "Domains:\n\tmox.example: nil\n\tother.example: nil\nAccounts:\n\tmjl:\n\t\tDomain: mox.example\n\t\tDestinations:\n\t\t\tmjl@mox.example: nil\n\t\t\tmjl@other.example: nil\n"
+	err := os.WriteFile(mox.ConfigDynamicPath, []byte(valid), 0660)
+	tcheck(t, err, "write config")
+	err = ConfigReload(ctxbg)
+	tcheck(t, err, "reload valid config")
+	if _, ok := mox.Conf.Domain(other); !ok {
+		t.Fatalf("domain from reloaded config not present")
+	}
+	if _, _, ok := mox.Conf.AccountDestination("mjl2@mox.example"); ok {
+		t.Fatalf("destination removed in reloaded config still present")
+	}
+
+	invalid := "Domains:\n\tmox.example: nil\nAccounts:\n\tmjl:\n\t\tDomain: mox.example\n\t\tDestinations:\n\t\t\tmjl@mox.example: nil\n\t\t\tmjl@missing.example: nil\n"
+	err = os.WriteFile(mox.ConfigDynamicPath, []byte(invalid), 0660)
+	tcheck(t, err, "write config")
+	err = ConfigReload(ctxbg)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v for invalid config, expected ErrRequest", err)
+	}
+	if _, ok := mox.Conf.Domain(other); !ok {
+		t.Fatalf("running config changed after invalid config")
+	}
+	if _, _, ok := mox.Conf.AccountDestination("mjl@other.example"); !ok {
+		t.Fatalf("destination missing after invalid config")
+	}
+}
+
+func TestAccountOutgoingLimitsSave(t *testing.T) {
+	setupConfig(t)
+
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	defer store.Switchboard()()
+
+	err = AccountOutgoingLimitsSave(ctxbg, "mjl", -1, 0)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("negative limit: got err %v, expected ErrRequest", err)
+	}
+	err = AccountOutgoingLimitsSave(ctxbg, "missing", 1, 1)
+	if err == nil {
+		t.Fatalf("limits for unknown account: got nil error")
+	}
+
+	err = AccountOutgoingLimitsSave(ctxbg, "mjl", 2, 10)
+	tcheck(t, err, "save limits")
+	accConf, _ := mox.Conf.Account("mjl")
+	if accConf.MaxOutgoingMessagesPerDay != 2 || accConf.MaxFirstTimeRecipientsPerDay != 10 {
+		t.Fatalf("limits not saved: %d %d", accConf.MaxOutgoingMessagesPerDay, accConf.MaxFirstTimeRecipientsPerDay)
+	}
+
+	acc, err := store.OpenAccount(pkglog, "mjl", false)
+	tcheck(t, err, "open account")
+	defer func() {
+		err := acc.Close()
+		tcheck(t, err, "close account")
+	}()
+	for range 2 {
+		err := acc.DB.Insert(ctxbg, &store.Outgoing{Recipient: "a@other.example", Submitted: time.Now()})
+		tcheck(t, err, "insert outgoing")
+	}
+	rcpt, err := smtp.ParseAddress("b@other.example")
+	tcheck(t, err, "parse address")
+	err = acc.DB.Read(ctxbg, func(tx *bstore.Tx) error {
+		msglimit, _, err := acc.SendLimitReached(tx, []smtp.Path{rcpt.Path()})
+		tcheck(t, err, "checking send limit")
+		if msglimit != 2 {
+			t.Fatalf("got msglimit %d, expected per-day limit 2 to be reached", msglimit)
+		}
+		return nil
+	})
+	tcheck(t, err, "read")
+
+	// Zero means unlimited.
+	err = AccountOutgoingLimitsSave(ctxbg, "mjl", 0, 0)
+	tcheck(t, err, "save unlimited")
+	accConf, _ = mox.Conf.Account("mjl")
+	if accConf.MaxOutgoingMessagesPerDay >= 0 || accConf.MaxFirstTimeRecipientsPerDay >= 0 {
+		t.Fatalf("got limits %d %d, expected negative values for unlimited", accConf.MaxOutgoingMessagesPerDay, accConf.MaxFirstTimeRecipientsPerDay)
+	}
+	err = acc.DB.Read(ctxbg, func(tx *bstore.Tx) error {
+		msglimit, rcptlimit, err := acc.SendLimitReached(tx, []smtp.Path{rcpt.Path()})
+		tcheck(t, err, "checking send limit")
+		if msglimit >= 0 || rcptlimit >= 0 {
+			t.Fatalf("got msglimit %d, rcptlimit %d, expected no limit reached", msglimit, rcptlimit)
+		}
+		return nil
+	})
+	tcheck(t, err, "read")
+}
+
+func TestDomainMTASTSMXSave(t *testing.T) {
+	setupConfig(t)
+
+	l := mox.Conf.Static.Listeners["local"]
+	l.MTASTSHTTPS.Enabled = true
+	mox.Conf.Static.Listeners["local"] = l
+
+	domain := dns.Domain{ASCII: "new.example"}
+	err := DomainAdd(ctxbg, false, domain, "mjl", "")
+	tcheck(t, err, "add domain")
+	dc, _ := mox.Conf.Domain(domain)
+	oldID := dc.MTASTS.PolicyID
+
+	for _, mx := range [][]string{nil, {"bad example"}, {"mox.example", "mox.example"}, {"a.*.example"}} {
+		err := DomainMTASTSMXSave(ctxbg, domain, mx)
+		if !errors.Is(err, ErrRequest) {
+			t.Fatalf("mx %v: got err %v, expected ErrRequest", mx, err)
+		}
+	}
+	err = DomainMTASTSMXSave(ctxbg, dns.Domain{ASCII: "mox.example"}, []string{"mox.example"})
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v for domain without mta-sts, expected ErrRequest", err)
+	}
+
+	err = DomainMTASTSMXSave(ctxbg, domain, []string{"mox.example", "*.backup.example"})
+	tcheck(t, err, "save mx")
+	dc, _ = mox.Conf.Domain(domain)
+	if !slices.Equal(dc.MTASTS.MX, []string{"mox.example", "*.backup.example"}) || dc.MTASTS.PolicyID == oldID {
+		t.Fatalf("got mx %v, policy id %q, expected new mx and new policy id (old %q)", dc.MTASTS.MX, dc.MTASTS.PolicyID, oldID)
+	}
+
+	// Saving the same list doesn't change the policy ID.
+	id := dc.MTASTS.PolicyID
+	err = DomainMTASTSMXSave(ctxbg, domain, []string{"mox.example", "*.backup.example"})
+	tcheck(t, err, "save same mx")
+	dc, _ = mox.Conf.Domain(domain)
+	if dc.MTASTS.PolicyID != id {
+		t.Fatalf("policy id changed without change to mx list")
+	}
+}
+
+func TestRequestError(t *testing.T) {
+	setupConfig(t)
+
+	testCode := func(err error, expCode string) {
+		t.Helper()
+		var rerr RequestError
+		if !errors.As(err, &rerr) || rerr.Code != expCode {
+			t.Fatalf("got err %v, expected RequestError with code %q", err, expCode)
+		}
+		if !errors.Is(err, ErrRequest) {
+			t.Fatalf("request error %v does not match ErrRequest", err)
+		}
+	}
+
+	domain := dns.Domain{ASCII: "mox.example"}
+	testCode(DomainAdd(ctxbg, false, domain, "mjl", ""), ErrCodeDomainExists)
+	testCode(DomainRemove(ctxbg, dns.Domain{ASCII: "missing.example"}), ErrCodeDomainNotFound)
+	testCode(AccountAdd(ctxbg, "mjl", "other@mox.example"), ErrCodeAccountExists)
+	testCode(AccountAdd(ctxbg, "other", "mjl@mox.example"), ErrCodeAddressInUse)
+	testCode(AccountAdd(ctxbg, "other", "bad address"), ErrCodeInvalid)
+	testCode(AddressAdd(ctxbg, "mjl@mox.example", "mjl"), ErrCodeAddressInUse)
+	testCode(AddressAdd(ctxbg, "other@mox.example", "missing"), ErrCodeAccountNotFound)
+	testCode(AddressRemove(ctxbg, "missing@mox.example"), ErrCodeAddressNotFound)
+
+	selector := dns.Domain{ASCII: "test"}
+	err := DKIMAdd(ctxbg, domain, selector, "ed25519", 0, "sha256", true, true, false, nil, 0)
+	tcheck(t, err, "add dkim selector")
+	testCode(DKIMAdd(ctxbg, domain, selector, "ed25519", 0, "sha256", true, true, false, nil, 0), ErrCodeSelectorExists)
+	testCode(DKIMAdd(ctxbg, dns.Domain{ASCII: "missing.example"}, selector, "ed25519", 0, "sha256", true, true, false, nil, 0), ErrCodeDomainNotFound)
+	testCode(DKIMRemove(ctxbg, domain, dns.Domain{ASCII: "missing"}), ErrCodeSelectorNotFound)
+	testCode(DKIMAdd(ctxbg, domain, dns.Domain{ASCII: "test2"}, "ed25519", 0, "sha256", true, true, false, []string{"bad header"}, 0), ErrCodeInvalid)
+	testCode(DomainAddMulti(ctxbg, []DomainAddSpec{{AccountName: "mjl"}}), ErrCodeInvalid)
+	testCode(DomainAddMulti(ctxbg, []DomainAddSpec{{Domain: dns.Domain{ASCII: "new.example"}, Localpart: "x"}}), ErrCodeInvalid)
+
+	aliasAddr := smtp.NewAddress("alias", domain)
+	_, err = AliasAdd(ctxbg, aliasAddr, config.Alias{Addresses: []string{"mjl@mox.example"}}, false)
+	tcheck(t, err, "add alias")
+	_, err = AliasAdd(ctxbg, aliasAddr, config.Alias{Addresses: []string{"mjl@mox.example"}}, false)
+	testCode(err, ErrCodeAliasExists)
+	testCode(AliasRemove(ctxbg, smtp.NewAddress("missing", domain)), ErrCodeAliasNotFound)
+	testCode(ListenerSetProxyProtocol(ctxbg, "missing", false, nil), ErrCodeNotFound)
+
+	// Account directory left behind, e.g. of a removed account.
+	accountDir := filepath.Join(mox.DataDirPath("accounts"), "leftover")
+	err = os.MkdirAll(accountDir, 0770)
+	tcheck(t, err, "mkdir")
+	err = os.WriteFile(filepath.Join(accountDir, "index.db"), nil, 0660)
+	tcheck(t, err, "write file")
+	testCode(AccountAdd(ctxbg, "leftover", "leftover@mox.example"), ErrCodeAccountDataExists)
+	err = os.RemoveAll(accountDir)
+	tcheck(t, err, "remove account dir")
+
+	// Message is as for other ErrRequest errors.
+	err = AccountAdd(ctxbg, "mjl", "other@mox.example")
+	if err.Error() != "bad request: account already present" {
+		t.Fatalf("unexpected error message %q", err.Error())
+	}
+}
+
+func TestDomainRemoveCleanupReferences(t *testing.T) {
+	setupConfig(t)
+
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	defer store.Switchboard()()
+
+	err = queue.Init()
+	tcheck(t, err, "queue init")
+	defer queue.Shutdown()
+
+	other := dns.Domain{ASCII: "other.example"}
+	err = DomainAdd(ctxbg, false, other, "mjl", "")
+	tcheck(t, err, "add domain")
+	err = AddressAdd(ctxbg, "mjl@other.example", "mjl")
+	tcheck(t, err, "add address")
+	err = AccountAdd(ctxbg, "only", "only@other.example")
+	tcheck(t, err, "add account")
+	_, err = AliasAdd(ctxbg, smtp.NewAddress("list", dns.Domain{ASCII: "mox.example"}), config.Alias{Addresses: []string{"mjl@mox.example", "mjl@other.example"}}, false)
+	tcheck(t, err, "add alias")
+	_, err = AliasAdd(ctxbg, smtp.NewAddress("otherlist", dns.Domain{ASCII: "mox.example"}), config.Alias{Addresses: []string{"mjl@other.example"}}, false)
+	tcheck(t, err, "add alias")
+	err = DomainSave(ctxbg, "mox.example", func(d *config.Domain) error {
+		d.DMARC = &config.DMARC{Localpart: "dmarcreports", Domain: "other.example", Account: "mjl", Mailbox: "DMARC"}
+		return nil
+	})
+	tcheck(t, err, "save dmarc")
+
+	// Account with only addresses in the domain prevents removal.
+	err = DomainRemoveCleanupReferences(ctxbg, other)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v, expected ErrRequest for account without remaining addresses", err)
+	}
+	err = AccountRemove(ctxbg, "only")
+	tcheck(t, err, "remove account")
+
+	// Account with the domain as default domain prevents removal, changing it could
+	// change the meaning of localpart-only destinations.
+	err = AccountAdd(ctxbg, "defdom", "defdom@other.example")
+	tcheck(t, err, "add account")
+	err = AddressAdd(ctxbg, "defdom@mox.example", "defdom")
+	tcheck(t, err, "add address")
+	err = DomainRemoveCleanupReferences(ctxbg, other)
+	var rerr RequestError
+	if !errors.As(err, &rerr) || rerr.Code != ErrCodeConflict {
+		t.Fatalf("got err %v, expected conflict for account with domain as default domain", err)
+	}
+	if acc, _ := mox.Conf.Account("defdom"); acc.Domain != "other.example" {
+		t.Fatalf("default domain of account changed to %q", acc.Domain)
+	}
+	err = AccountRemove(ctxbg, "defdom")
+	tcheck(t, err, "remove account")
+
+	// Without cleanup, the references remain, making removal fail.
+	err = DomainRemove(ctxbg, other)
+	if err == nil {
+		t.Fatalf("domain removed while still referenced")
+	}
+	if _, _, ok := mox.Conf.AccountDestination("mjl@other.example"); !ok {
+		t.Fatalf("reference removed without cleanup")
+	}
+
+	err = DomainRemoveCleanupReferences(ctxbg, other)
+	tcheck(t, err, "remove domain with cleanup")
+	if _, ok := mox.Conf.Domain(other); ok {
+		t.Fatalf("domain still present")
+	}
+	acc, _ := mox.Conf.Account("mjl")
+	for addr := range acc.Destinations {
+		if strings.HasSuffix(addr, "@other.example") {
+			t.Fatalf("destination %q for removed domain still present", addr)
+		}
+	}
+	dc, _ := mox.Conf.Domain(dns.Domain{ASCII: "mox.example"})
+	if dc.DMARC != nil {
+		t.Fatalf("dmarc config with address in removed domain still present")
+	}
+	if a, ok := dc.Aliases["list"]; !ok || !slices.Equal(a.Addresses, []string{"mjl@mox.example"}) {
+		t.Fatalf("unexpected alias after cleanup: %v", a)
+	}
+	if _, ok := dc.Aliases["otherlist"]; ok {
+		t.Fatalf("alias without remaining members still present")
+	}
+}
+
+func TestDomainRename(t *testing.T) {
+	setupConfig(t)
+
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	defer store.Switchboard()()
+
+	err = queue.Init()
+	tcheck(t, err, "queue init")
+	defer queue.Shutdown()
+
+	old := dns.Domain{ASCII: "old.example"}
+	renamed := dns.Domain{ASCII: "new.example"}
+	err = DomainAdd(ctxbg, false, old, "mjl", "")
+	tcheck(t, err, "add domain")
+	err = AccountAdd(ctxbg, "other", "other@old.example")
+	tcheck(t, err, "add account")
+	err = AddressAdd(ctxbg, "@old.example", "mjl")
+	tcheck(t, err, "add catchall")
+	_, err = AliasAdd(ctxbg, smtp.NewAddress("list", dns.Domain{ASCII: "mox.example"}), config.Alias{Addresses: []string{"mjl@mox.example", "other@old.example"}}, false)
+	tcheck(t, err, "add alias")
+	_, err = AliasAdd(ctxbg, smtp.NewAddress("team", old), config.Alias{Addresses: []string{"other@old.example"}}, false)
+	tcheck(t, err, "add alias in domain")
+	oldConf, _ := mox.Conf.Domain(old)
+
+	for _, args := range [][2]dns.Domain{
+		{{ASCII: "missing.example"}, renamed},
+		{old, {ASCII: "mox.example"}},
+		{old, old},
+		{old, {}},
+		{{ASCII: "mox.example"}, renamed},
+	} {
+		err := DomainRename(ctxbg, args[0], args[1])
+		if !errors.Is(err, ErrRequest) {
+			t.Fatalf("rename %v: got err %v, expected ErrRequest", args, err)
+		}
+	}
+
+	err = DomainRename(ctxbg, old, renamed)
+	tcheck(t, err, "rename domain")
+
+	if _, ok := mox.Conf.Domain(old); ok {
+		t.Fatalf("old domain still present")
+	}
+	dc, ok := mox.Conf.Domain(renamed)
+	if !ok {
+		t.Fatalf("renamed domain not present")
+	}
+	if len(dc.DKIM.Selectors) != len(oldConf.DKIM.Selectors) || !slices.Equal(dc.DKIM.Sign, oldConf.DKIM.Sign) {
+		t.Fatalf("dkim config changed, got %v, expected %v", dc.DKIM, oldConf.DKIM)
+	}
+	for name, sel := range oldConf.DKIM.Selectors {
+		if dc.DKIM.Selectors[name].PrivateKeyFile != sel.PrivateKeyFile {
+			t.Fatalf("dkim selector %q: got key file %q, expected %q", name, dc.DKIM.Selectors[name].PrivateKeyFile, sel.PrivateKeyFile)
+		}
+	}
+	if dc.ClientSettingsDomain != "mail.new.example" {
+		t.Fatalf("got client settings domain %q, expected mail.new.example", dc.ClientSettingsDomain)
+	}
+
+	acc, _ := mox.Conf.Account("other")
+	if acc.Domain != "new.example" || !slices.Equal(slices.Collect(maps.Keys(acc.Destinations)), []string{"other@new.example"}) {
+		t.Fatalf("got account domain %q, destinations %v, expected new.example", acc.Domain, acc.Destinations)
+	}
+	for _, addr := range []string{"other@new.example", "@new.example", "mjl@mox.example"} {
+		if _, _, ok := mox.Conf.AccountDestination(addr); !ok {
+			t.Fatalf("missing destination %q", addr)
+		}
+	}
+	for _, addr := range []string{"other@old.example", "@old.example"} {
+		if _, _, ok := mox.Conf.AccountDestination(addr); ok {
+			t.Fatalf("destination %q still present", addr)
+		}
+	}
+	a, err := AliasGet(ctxbg, smtp.NewAddress("list", dns.Domain{ASCII: "mox.example"}))
+	tcheck(t, err, "get alias")
+	if !slices.Equal(a.Addresses, []string{"mjl@mox.example", "other@new.example"}) {
+		t.Fatalf("got alias members %v, expected renamed member", a.Addresses)
+	}
+	a, err = AliasGet(ctxbg, smtp.NewAddress("team", renamed))
+	tcheck(t, err, "get alias in renamed domain")
+	if !slices.Equal(a.Addresses, []string{"other@new.example"}) {
+		t.Fatalf("got alias members %v, expected renamed member", a.Addresses)
+	}
+}
+
+func TestAddressAddBulk(t *testing.T) {
+	setupConfig(t)
+
+	// One address collides, none are added.
+	addrs := []string{"a@mox.example", "b@mox.example", "mjl2@mox.example", "@mox.example", "c@mox.example"}
+	err := AddressAddBulk(ctxbg, "mjl", addrs)
+	var rerr RequestError
+	if !errors.As(err, &rerr) || rerr.Code != ErrCodeAddressInUse {
+		t.Fatalf("got err %v, expected address in use", err)
+	}
+	for _, a := range addrs {
+		if _, _, ok := mox.Conf.AccountDestination(a); ok && a != "mjl2@mox.example" {
+			t.Fatalf("address %q added after failed bulk add", a)
+		}
+	}
+
+	err = AddressAddBulk(ctxbg, "mjl", []string{"a@mox.example", "a@mox.example"})
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v for duplicate address, expected ErrRequest", err)
+	}
+	err = AddressAddBulk(ctxbg, "missing", []string{"a@mox.example"})
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v for unknown account, expected ErrRequest", err)
+	}
+
+	addrs = []string{"a@mox.example", "b@mox.example", "@mox.example", "c@mox.example"}
+	err = AddressAddBulk(ctxbg, "mjl", addrs)
+	tcheck(t, err, "bulk add")
+	for _, a := range addrs {
+		accDest, _, ok := mox.Conf.AccountDestination(a)
+		if !ok || accDest.Account != "mjl" {
+			t.Fatalf("address %q not added", a)
+		}
+	}
+}
+
+func TestDynamicConfigChangeHandler(t *testing.T) {
+	setupConfig(t)
+
+	type change struct {
+		old, new config.Dynamic
+	}
+	var active atomic.Bool
+	var changes []change
+	active.Store(true)
+	mox.OnDynamicConfigChange(func(old, new config.Dynamic) {
+		if !active.Load() {
+			return
+		}
+		// Called without lock held, so config can be read.
+		mox.Conf.DynamicConfig()
+		changes = append(changes, change{old, new})
+	})
+	defer active.Store(false)
+
+	domain := dns.Domain{ASCII: "new.example"}
+	err := DomainAdd(ctxbg, false, domain, "mjl", "")
+	tcheck(t, err, "add domain")
+	if len(changes) != 1 {
+		t.Fatalf("got %d changes, expected 1", len(changes))
+	}
+	if _, ok := changes[0].old.Domains["new.example"]; ok {
+		t.Fatalf("new domain in old config")
+	}
+	if _, ok := changes[0].new.Domains["new.example"]; !ok {
+		t.Fatalf("new domain not in new config")
+	}
+
+	// Failed changes don't call the handler.
+	err = DomainAdd(ctxbg, false, domain, "mjl", "")
+	if err == nil {
+		t.Fatalf("adding domain again succeeded")
+	}
+	if len(changes) != 1 {
+		t.Fatalf("got %d changes after failed change, expected 1", len(changes))
+	}
+}
+
+func TestDomainAddLike(t *testing.T) {
+	setupConfig(t)
+
+	l := mox.Conf.Static.Listeners["local"]
+	l.MTASTSHTTPS.Enabled = true
+	mox.Conf.Static.Listeners["local"] = l
+
+	tmpl := dns.Domain{ASCII: "template.example"}
+	err := DomainAdd(ctxbg, false, tmpl, "mjl", "")
+	tcheck(t, err, "add template domain")
+	err = MTASTSMaxAgeSave(ctxbg, tmpl, 14*24*time.Hour)
+	tcheck(t, err, "save mta-sts max age")
+	tc, _ := mox.Conf.Domain(tmpl)
+
+	err = DomainAddLike(ctxbg, dns.Domain{ASCII: "other.example"}, dns.Domain{ASCII: "absent.example"}, "mjl", "")
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v for absent template domain, expected ErrRequest", err)
+	}
+
+	domain := dns.Domain{ASCII: "new.example"}
+	err = DomainAddLike(ctxbg, domain, tmpl, "mjl", "")
+	tcheck(t, err, "add domain like template")
+	dc, ok := mox.Conf.Domain(domain)
+	if !ok {
+		t.Fatalf("new domain not present")
+	}
+	if dc.MTASTS == nil || dc.MTASTS.MaxAge != 14*24*time.Hour {
+		t.Fatalf("got mta-sts %v, expected max age copied from template", dc.MTASTS)
+	}
+	if dc.MTASTS.PolicyID == tc.MTASTS.PolicyID {
+		t.Fatalf("mta-sts policy id not changed for new domain")
+	}
+	if !slices.Equal(dc.DKIM.Sign, tc.DKIM.Sign) || len(dc.DKIM.Selectors) != len(tc.DKIM.Selectors) {
+		t.Fatalf("got dkim %v, expected same selectors as template %v", dc.DKIM, tc.DKIM)
+	}
+	for name, sel := range dc.DKIM.Selectors {
+		tsel, ok := tc.DKIM.Selectors[name]
+		if !ok {
+			t.Fatalf("selector %q not in template", name)
+		}
+		if sel.Algorithm != tsel.Algorithm || sel.PrivateKeyFile == tsel.PrivateKeyFile || !strings.Contains(sel.PrivateKeyFile, "._domainkey.new.example.") {
+			t.Fatalf("got selector %q with algorithm %q and key file %q, expected new key like template", name, sel.Algorithm, sel.PrivateKeyFile)
+		}
+		if sel.Key.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(tsel.Key.Public()) {
+			t.Fatalf("selector %q has same key as template", name)
+		}
+	}
+}
+
+func TestAliasAllowMsgFromAddressesSave(t *testing.T) {
+	setupConfig(t)
+
+	addr := smtp.NewAddress("list", dns.Domain{ASCII: "mox.example"})
+	_, err := AliasAdd(ctxbg, addr, config.Alias{Addresses: []string{"mjl@mox.example", "mjl2@mox.example"}}, false)
+	tcheck(t, err, "add alias")
+
+	for _, l := range [][]string{{"bogus"}, {"a@example.org", "a@EXAMPLE.org"}} {
+		err := AliasAllowMsgFromAddressesSave(ctxbg, addr, l)
+		if !errors.Is(err, ErrRequest) {
+			t.Fatalf("addresses %v: got err %v, expected ErrRequest", l, err)
+		}
+	}
+	err = AliasAllowMsgFromAddressesSave(ctxbg, smtp.NewAddress("absent", addr.Domain), []string{"a@example.org"})
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v for absent alias, expected ErrRequest", err)
+	}
+
+	err = AliasAllowMsgFromAddressesSave(ctxbg, addr, []string{"a@example.org", "mjl@mox.example"})
+	tcheck(t, err, "save allowed addresses")
+	_, alias, _ := mox.Conf.AccountDestination(addr.String())
+	if alias == nil || !slices.Equal(alias.ParsedAllowMsgFromAddresses, []smtp.Address{smtp.NewAddress("a", dns.Domain{ASCII: "example.org"}), smtp.NewAddress("mjl", addr.Domain)}) {
+		t.Fatalf("got alias %v, expected parsed allowed addresses", alias)
+	}
+
+	err = AliasAllowMsgFromAddressesSave(ctxbg, addr, nil)
+	tcheck(t, err, "clear allowed addresses")
+	_, alias, _ = mox.Conf.AccountDestination(addr.String())
+	if alias == nil || alias.AllowMsgFromAddresses != nil || alias.ParsedAllowMsgFromAddresses != nil {
+		t.Fatalf("got alias %v, expected no allowed addresses", alias)
+	}
+}
+
+func TestQueueRequeue(t *testing.T) {
+	setupConfig(t)
+
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	err = queue.Init()
+	tcheck(t, err, "queue init")
+	defer queue.Shutdown()
+
+	msgFile, err := store.CreateMessageTemp(pkglog, "queuerequeue")
+	tcheck(t, err, "create temp message")
+	defer os.Remove(msgFile.Name())
+	defer msgFile.Close()
+	const msg = "Subject: test\r\n\r\ntest\r\n"
+	_, err = msgFile.WriteString(msg)
+	tcheck(t, err, "write message")
+
+	// Message that had failed attempts, with a next attempt in the future. The queue
+	// is not started, so no delivery is attempted.
+	from := smtp.Path{Localpart: "mjl", IPDomain: dns.IPDomain{Domain: dns.Domain{ASCII: "mox.example"}}}
+	to := smtp.Path{Localpart: "remote", IPDomain: dns.IPDomain{Domain: dns.Domain{ASCII: "remote.example"}}}
+	qm := queue.MakeMsg(from, to, false, false, int64(len(msg)), "<test@localhost>", nil, nil, time.Now().Add(time.Hour), "test")
+	qm.Attempts = 3
+	err = queue.Add(ctxbg, pkglog, "mjl", msgFile, qm)
+	tcheck(t, err, "add message to queue")
+
+	due := func() []queue.Msg {
+		t.Helper()
+		l, err := queue.List(ctxbg, queue.Filter{NextAttempt: "<now"}, queue.Sort{})
+		tcheck(t, err, "list due messages")
+		return l
+	}
+	if l := due(); len(l) != 0 {
+		t.Fatalf("got %d due messages before requeue, expected 0", len(l))
+	}
+
+	n, err := QueueRequeue(ctxbg, queue.Filter{RecipientDomain: "other.example"})
+	tcheck(t, err, "requeue without matches")
+	if n != 0 {
+		t.Fatalf("requeued %d messages, expected 0", n)
+	}
+
+	n, err = QueueRequeue(ctxbg, queue.Filter{RecipientDomain: "remote.example"})
+	tcheck(t, err, "requeue")
+	if n != 1 {
+		t.Fatalf("requeued %d messages, expected 1", n)
+	}
+	l := due()
+	if len(l) != 1 || l[0].Attempts != 0 {
+		t.Fatalf("got due messages %v, expected requeued message with attempts reset", l)
+	}
+}
+
+func TestTLSPublicKeyAdd(t *testing.T) {
+	setupConfig(t)
+
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	err = queue.Init()
+	tcheck(t, err, "queue init")
+	defer queue.Shutdown()
+
+	domain := dns.Domain{ASCII: "new.example"}
+	err = DomainAdd(ctxbg, false, domain, "mjl", "")
+	tcheck(t, err, "add domain")
+	err = AddressAdd(ctxbg, "mjl@new.example", "mjl")
+	tcheck(t, err, "add address")
+
+	privKey := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)) // Fake key, don't use this for real!
+	template := &x509.Certificate{SerialNumber: big.NewInt(1)}
+	certDER, err := x509.CreateCertificate(cryptorand.Reader, template, template, privKey.Public(), privKey)
+	tcheck(t, err, "make certificate")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+
+	for _, tc := range []struct {
+		account, loginAddress string
+		certPEM               []byte
+	}{
+		{"absent", "mjl@new.example", certPEM},
+		{"mjl", "other@new.example", certPEM},
+		{"mjl", "mjl@new.example", []byte("bogus")},
+	} {
+		_, err := TLSPublicKeyAdd(ctxbg, tc.account, tc.loginAddress, tc.certPEM)
+		if !errors.Is(err, ErrRequest) {
+			t.Fatalf("add tls public key for %q %q: got err %v, expected ErrRequest", tc.account, tc.loginAddress, err)
+		}
+	}
+
+	tpk, err := TLSPublicKeyAdd(ctxbg, "mjl", "mjl@new.example", certPEM)
+	tcheck(t, err, "add tls public key")
+	_, err = TLSPublicKeyAdd(ctxbg, "mjl", "mjl@new.example", certPEM)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("adding duplicate tls public key: got err %v, expected ErrRequest", err)
+	}
+
+	// Domain of login address cannot be removed while the key references it.
+	err = DomainRemoveCleanupReferences(ctxbg, domain)
+	if !errors.Is(err, ErrRequest) || !strings.Contains(err.Error(), "tls public key") {
+		t.Fatalf("removing domain referenced by tls public key: got err %v, expected ErrRequest", err)
+	}
+
+	err = TLSPublicKeyRemove(ctxbg, tpk.Fingerprint)
+	tcheck(t, err, "remove tls public key")
+	err = TLSPublicKeyRemove(ctxbg, tpk.Fingerprint)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("removing absent tls public key: got err %v, expected ErrRequest", err)
+	}
+	err = DomainRemoveCleanupReferences(ctxbg, domain)
+	tcheck(t, err, "remove domain")
+}
+
+func TestAccountSettingsGet(t *testing.T) {
+	setupConfig(t)
+
+	_, err := AccountSettingsGet(ctxbg, "absent")
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v for absent account, expected ErrRequest", err)
+	}
+
+	// FromID login addresses require a catchall separator.
+	err = DomainSave(ctxbg, "mox.example", func(d *config.Domain) error {
+		d.LocalpartCatchallSeparator = "+"
+		return nil
+	})
+	tcheck(t, err, "save domain")
+	err = AccountSave(ctxbg, "mjl", func(acc *config.Account) {
+		acc.FromIDLoginAddresses = []string{"mjl@mox.example"}
+		acc.JunkFilter = &config.JunkFilter{Threshold: 0.95}
+	})
+	tcheck(t, err, "save account")
+
+	acc, err := AccountSettingsGet(ctxbg, "mjl")
+	tcheck(t, err, "get account settings")
+	if len(acc.ParsedFromIDLoginAddresses) != 1 || acc.ParsedFromIDLoginAddresses[0].String() != "mjl@mox.example" {
+		t.Fatalf("got parsed fromid login addresses %v, expected derived field to be set", acc.ParsedFromIDLoginAddresses)
+	}
+
+	// Changing the copy must not change the live config.
+	acc.FromIDLoginAddresses[0] = "changed@mox.example"
+	acc.ParsedFromIDLoginAddresses[0].Localpart = "changed"
+	acc.JunkFilter.Threshold = 0.5
+	delete(acc.Destinations, "mjl@mox.example")
+	dest := acc.Destinations["mjl2@mox.example"]
+	dest.Mailbox = "Changed"
+	acc.Destinations["mjl2@mox.example"] = dest
+
+	live, _ := mox.Conf.Account("mjl")
+	if live.FromIDLoginAddresses[0] != "mjl@mox.example" || live.ParsedFromIDLoginAddresses[0].Localpart != "mjl" {
+		t.Fatalf("live fromid login addresses changed through copy")
+	}
+	if live.JunkFilter.Threshold != 0.95 {
+		t.Fatalf("live junk filter changed through copy")
+	}
+	if _, ok := live.Destinations["mjl@mox.example"]; !ok || live.Destinations["mjl2@mox.example"].Mailbox == "Changed" {
+		t.Fatalf("live destinations changed through copy")
+	}
+}
+
+func TestDKIMSignSelectorsSave(t *testing.T) {
+	setupConfig(t)
+
+	domain := dns.Domain{ASCII: "new.example"}
+	err := DomainAdd(ctxbg, false, domain, "mjl", "")
+	tcheck(t, err, "add domain")
+	dc, _ := mox.Conf.Domain(domain)
+	selectors := slices.Sorted(maps.Keys(dc.DKIM.Selectors))
+	if len(selectors) != 2 || len(dc.DKIM.Sign) != 1 {
+		t.Fatalf("got selectors %v, sign %v, expected 2 selectors, signing with 1", selectors, dc.DKIM.Sign)
+	}
+
+	for _, l := range [][]string{{"absent"}, {selectors[0], "absent"}, {selectors[0], selectors[0]}} {
+		err := DKIMSignSelectorsSave(ctxbg, domain, l)
+		if !errors.Is(err, ErrRequest) {
+			t.Fatalf("sign selectors %v: got err %v, expected ErrRequest", l, err)
+		}
+	}
+
+	err = DKIMSignSelectorsSave(ctxbg, domain, selectors)
+	tcheck(t, err, "set sign selectors")
+	dc, _ = mox.Conf.Domain(domain)
+	if !slices.Equal(dc.DKIM.Sign, selectors) {
+		t.Fatalf("got sign %v, expected %v", dc.DKIM.Sign, selectors)
+	}
+
+	err = DKIMSignSelectorsSave(ctxbg, domain, nil)
+	tcheck(t, err, "clear sign selectors")
+	dc, _ = mox.Conf.Domain(domain)
+	if len(dc.DKIM.Sign) != 0 || len(dc.DKIM.Selectors) != 2 {
+		t.Fatalf("got sign %v with %d selectors, expected no signing selectors and selectors kept", dc.DKIM.Sign, len(dc.DKIM.Selectors))
+	}
+}
+
+func TestDKIMSelectorDisable(t *testing.T) {
+	setupConfig(t)
+	log := pkglog.WithContext(ctxbg)
+
+	domain := dns.Domain{ASCII: "new.example"}
+	err := DomainAdd(ctxbg, false, domain, "mjl", "")
+	tcheck(t, err, "add domain")
+	dc, _ := mox.Conf.Domain(domain)
+	selectors := slices.Sorted(maps.Keys(dc.DKIM.Selectors))
+	err = DKIMSignSelectorsSave(ctxbg, domain, selectors)
+	tcheck(t, err, "set sign selectors")
+
+	from := smtp.Path{Localpart: "mjl", IPDomain: dns.IPDomain{Domain: domain}}
+	msg := []byte("From: <mjl@new.example>\r\nSubject: test\r\n\r\ntest\r\n")
+	checkSigned := func(exp ...string) {
+		t.Helper()
+		headers, err := mox.DKIMSign(ctxbg, log, from, false, msg)
+		tcheck(t, err, "dkim sign")
+		for _, sel := range selectors {
+			signed := strings.Contains(headers, "s="+sel+";")
+			if signed != slices.Contains(exp, sel) {
+				t.Fatalf("selector %q: got signed %v, expected signatures for %v, headers %q", sel, signed, exp, headers)
+			}
+		}
+	}
+	checkSigned(selectors...)
+
+	err = DKIMSelectorDisable(ctxbg, domain, dns.Domain{ASCII: selectors[0]}, true)
+	tcheck(t, err, "disable selector")
+	dc, _ = mox.Conf.Domain(domain)
+	if !dc.DKIM.Selectors[selectors[0]].Disabled || !slices.Equal(dc.DKIM.Sign, selectors) {
+		t.Fatalf("got selector %v, sign %v, expected disabled selector still listed for signing", dc.DKIM.Selectors[selectors[0]], dc.DKIM.Sign)
+	}
+	checkSigned(selectors[1])
+
+	err = DKIMSelectorDisable(ctxbg, domain, dns.Domain{ASCII: selectors[0]}, false)
+	tcheck(t, err, "enable selector")
+	checkSigned(selectors...)
+
+	err = DKIMSelectorDisable(ctxbg, domain, dns.Domain{ASCII: "absent"}, true)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v for absent selector, expected ErrRequest", err)
+	}
+}
+
+func TestDomainTLSRPTSave(t *testing.T) {
+	setupConfig(t)
+
+	domain := dns.Domain{ASCII: "new.example"}
+	err := DomainAdd(ctxbg, false, domain, "mjl", "")
+	tcheck(t, err, "add domain")
+
+	hasTLSRPTRecord := func() bool {
+		t.Helper()
+		dc, _ := mox.Conf.Domain(domain)
+		records, err := DomainRecords(dc, domain, false, "", "")
+		tcheck(t, err, "dns records")
+		return slices.ContainsFunc(records, func(r string) bool { return strings.HasPrefix(r, "_smtp._tls.new.example.") })
+	}
+	if !hasTLSRPTRecord() {
+		t.Fatalf("missing tlsrpt record for new domain")
+	}
+
+	for _, tlsrpt := range []config.TLSRPT{
+		{Localpart: "tlsreports", Account: "absent", Mailbox: "TLSRPT"},
+		{Localpart: "bad localpart", Account: "mjl", Mailbox: "TLSRPT"},
+		{Localpart: "tlsreports", Account: "mjl", Mailbox: ""},
+	} {
+		err := DomainTLSRPTSave(ctxbg, domain, &tlsrpt)
+		if !errors.Is(err, ErrRequest) {
+			t.Fatalf("tlsrpt %v: got err %v, expected ErrRequest", tlsrpt, err)
+		}
+	}
+
+	err = DomainTLSRPTSave(ctxbg, domain, nil)
+	tcheck(t, err, "clear tlsrpt")
+	dc, _ := mox.Conf.Domain(domain)
+	if dc.TLSRPT != nil || hasTLSRPTRecord() {
+		t.Fatalf("tlsrpt still configured or in dns records after clearing")
+	}
+
+	err = DomainTLSRPTSave(ctxbg, domain, &config.TLSRPT{Localpart: "tls", Account: "mjl", Mailbox: "Reports"})
+	tcheck(t, err, "set tlsrpt")
+	dc, _ = mox.Conf.Domain(domain)
+	if dc.TLSRPT == nil || dc.TLSRPT.ParsedLocalpart != "tls" || !hasTLSRPTRecord() {
+		t.Fatalf("got tlsrpt %v, expected tlsrpt config and dns record", dc.TLSRPT)
+	}
+}
+
+func TestAccountAddStaleDir(t *testing.T) {
+	setupConfig(t)
+
+	// Directory without files, e.g. from an interrupted earlier attempt, is removed.
+	staleDir := filepath.Join(mox.DataDirPath("accounts"), "stale")
+	err := os.MkdirAll(filepath.Join(staleDir, "msg"), 0770)
+	tcheck(t, err, "create stale account directory")
+	err = AccountAdd(ctxbg, "stale", "stale@mox.example")
+	tcheck(t, err, "add account with empty stale directory")
+	if _, err := os.Stat(staleDir); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("stat stale account directory: got err %v, expected fs.ErrNotExist", err)
+	}
+
+	// Directory with data is kept, and the account not added.
+	dataDir := filepath.Join(mox.DataDirPath("accounts"), "data")
+	err = os.MkdirAll(dataDir, 0770)
+	tcheck(t, err, "create account directory")
+	err = os.WriteFile(filepath.Join(dataDir, "index.db"), []byte("dummy"), 0660)
+	tcheck(t, err, "write file in account directory")
+	err = AccountAdd(ctxbg, "data", "data@mox.example")
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("add account with existing data: got err %v, expected ErrRequest", err)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "index.db")); err != nil {
+		t.Fatalf("file in account directory removed: %v", err)
+	}
+	if _, ok := mox.Conf.Account("data"); ok {
+		t.Fatalf("account added despite existing account directory")
+	}
+}
+
+func TestListenerSetProxyProtocol(t *testing.T) {
+	setupConfig(t)
+
+	readStatic := func() config.Static {
+		t.Helper()
+		f, err := os.Open(mox.ConfigStaticPath)
+		tcheck(t, err, "open mox.conf")
+		defer f.Close()
+		var c config.Static
+		err = sconf.Parse(f, &c)
+		tcheck(t, err, "parse mox.conf")
+		return c
+	}
+	orig := readStatic()
+
+	for _, args := range []struct {
+		listener string
+		enabled  bool
+		nets     []string
+	}{
+		{"local", true, nil},
+		{"local", false, []string{"10.0.0.0/8"}},
+		{"local", true, []string{"bogus"}},
+		{"missing", true, []string{"10.0.0.0/8"}},
+	} {
+		err := ListenerSetProxyProtocol(ctxbg, args.listener, args.enabled, args.nets)
+		if !errors.Is(err, ErrRequest) {
+			t.Fatalf("got err %v for %v, expected ErrRequest", err, args)
+		}
+	}
+	if c := readStatic(); !reflect.DeepEqual(c, orig) {
+		t.Fatalf("mox.conf changed after failed calls")
+	}
+
+	nets := []string{"10.0.0.0/8", "2001:db8::/32"}
+	err := ListenerSetProxyProtocol(ctxbg, "local", true, nets)
+	tcheck(t, err, "enable proxy protocol")
+	c := readStatic()
+	pp := c.Listeners["local"].ProxyProtocol
+	if pp == nil || !slices.Equal(pp.TrustedNetworks, nets) {
+		t.Fatalf("got proxy protocol %v, expected trusted networks %v", pp, nets)
+	}
+
+	// The written file is still a valid config.
+	_, errs := mox.ParseConfig(ctxbg, pkglog, mox.ConfigStaticPath, true, false, false)
+	if len(errs) > 0 {
+		t.Fatalf("parsing written mox.conf: %v", errs)
+	}
+
+	// Disabling restores the original settings, the file round-trips.
+	err = ListenerSetProxyProtocol(ctxbg, "local", false, nil)
+	tcheck(t, err, "disable proxy protocol")
+	if c := readStatic(); !reflect.DeepEqual(c, orig) {
+		t.Fatalf("got config %#v after round-trip, expected %#v", c, orig)
+	}
+}
+
+func TestListenerReload(t *testing.T) {
+	setupConfig(t)
+	log := pkglog.WithContext(ctxbg)
+
+	// Find a free port for the new listener.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	tcheck(t, err, "listen")
+	addr := ln.Addr().String()
+	port := ln.Addr().(*net.TCPAddr).Port
+	err = ln.Close()
+	tcheck(t, err, "close listener")
+
+	// Unchanged configuration, nothing to do.
+	result, err := ListenerReload(ctxbg)
+	tcheck(t, err, "reload listeners")
+	if !reflect.DeepEqual(result, ListenerReloadResult{}) {
+		t.Fatalf("got %#v, expected %#v", result, ListenerReloadResult{})
+	}
+
+	err = staticConfigSave(log, func(c *config.Static) error {
+		var l config.Listener
+		l.IPs = []string{"127.0.0.1"}
+		l.SMTP.Enabled = true
+		l.SMTP.Port = port
+		l.SMTP.NoSTARTTLS = true
+		c.Listeners["extra"] = l
+		return nil
+	})
+	tcheck(t, err, "add listener")
+
+	result, err = ListenerReload(ctxbg)
+	tcheck(t, err, "reload listeners")
+	if !reflect.DeepEqual(result, ListenerReloadResult{Opened: []string{"smtp extra " + addr}}) {
+		t.Fatalf("got %#v, expected %#v", result, ListenerReloadResult{Opened: []string{"smtp extra " + addr}})
+	}
+
+	conn, err := net.Dial("tcp", addr)
+	tcheck(t, err, "dial new listener")
+	err = conn.Close()
+	tcheck(t, err, "close connection")
+
+	// Reloading again doesn't open the socket again.
+	result, err = ListenerReload(ctxbg)
+	tcheck(t, err, "reload listeners")
+	if !reflect.DeepEqual(result, ListenerReloadResult{}) {
+		t.Fatalf("got %#v, expected %#v", result, ListenerReloadResult{})
+	}
+
+	// If a new socket cannot be opened, nothing changes: the removed socket stays
+	// open and the other new socket is closed again.
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	tcheck(t, err, "listen")
+	defer busy.Close()
+	ln, err = net.Listen("tcp", "127.0.0.1:0")
+	tcheck(t, err, "listen")
+	addr2 := ln.Addr().String()
+	port2 := ln.Addr().(*net.TCPAddr).Port
+	err = ln.Close()
+	tcheck(t, err, "close listener")
+	err = staticConfigSave(log, func(c *config.Static) error {
+		l := c.Listeners["extra"]
+		delete(c.Listeners, "extra")
+		l.SMTP.Port = port2
+		l.IMAP.Enabled = true
+		l.IMAP.Port = busy.Addr().(*net.TCPAddr).Port
+		c.Listeners["extra2"] = l
+		return nil
+	})
+	tcheck(t, err, "replace listener")
+	_, err = ListenerReload(ctxbg)
+	if err == nil {
+		t.Fatalf("reload succeeded, expected error for address in use")
+	}
+	conn, err = net.Dial("tcp", addr)
+	tcheck(t, err, "dial remaining listener")
+	err = conn.Close()
+	tcheck(t, err, "close connection")
+	if conn, err := net.Dial("tcp", addr2); err == nil {
+		conn.Close()
+		t.Fatalf("dial to listener of failed reload succeeded")
+	}
+
+	// A new socket cannot take over the address of a removed socket.
+	err = staticConfigSave(log, func(c *config.Static) error {
+		l := c.Listeners["extra2"]
+		delete(c.Listeners, "extra2")
+		l.SMTP.Port = port
+		l.IMAP.Enabled = false
+		l.IMAP.Port = 0
+		c.Listeners["moved"] = l
+		return nil
+	})
+	tcheck(t, err, "move listener")
+	_, err = ListenerReload(ctxbg)
+	var rerr RequestError
+	if !errors.As(err, &rerr) || rerr.Code != ErrCodeConflict {
+		t.Fatalf("got err %v, expected request error with code %q for moved address", err, ErrCodeConflict)
+	}
+
+	// The unprivileged process cannot open privileged ports.
+	if os.Getuid() != 0 {
+		err = staticConfigSave(log, func(c *config.Static) error {
+			l := c.Listeners["moved"]
+			delete(c.Listeners, "moved")
+			l.SMTP.Port = 25
+			c.Listeners["extra"] = l
+			return nil
+		})
+		tcheck(t, err, "listener with privileged port")
+		_, err = ListenerReload(ctxbg)
+		if !errors.As(err, &rerr) || rerr.Code != ErrCodeConflict {
+			t.Fatalf("got err %v, expected request error with code %q for privileged port", err, ErrCodeConflict)
+		}
+	}
+
+	err = staticConfigSave(log, func(c *config.Static) error {
+		delete(c.Listeners, "moved")
+		var l config.Listener
+		l.IPs = []string{"127.0.0.1"}
+		l.SMTP.Enabled = true
+		l.SMTP.Port = port
+		l.SMTP.NoSTARTTLS = true
+		c.Listeners["extra"] = l
+		return nil
+	})
+	tcheck(t, err, "restore listener")
+
+	// Adding a listener with TLS requires a restart.
+	err = staticConfigSave(log, func(c *config.Static) error {
+		l := c.Listeners["extra"]
+		l.TLS = &config.TLS{KeyCerts: []config.KeyCert{{CertFile: "cert.pem", KeyFile: "key.pem"}}}
+		c.Listeners["extra"] = l
+		return nil
+	})
+	tcheck(t, err, "add tls to listener")
+	_, err = ListenerReload(ctxbg)
+	if err == nil || !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v, expected ErrRequest for new tls config", err)
+	}
+
+	err = staticConfigSave(log, func(c *config.Static) error {
+		delete(c.Listeners, "extra")
+		return nil
+	})
+	tcheck(t, err, "remove listener")
+
+	result, err = ListenerReload(ctxbg)
+	tcheck(t, err, "reload listeners")
+	if !reflect.DeepEqual(result, ListenerReloadResult{Closed: []string{"smtp extra " + addr}}) {
+		t.Fatalf("got %#v, expected %#v", result, ListenerReloadResult{Closed: []string{"smtp extra " + addr}})
+	}
+
+	_, err = net.Dial("tcp", addr)
+	if err == nil {
+		t.Fatalf("dial to closed listener succeeded")
+	}
+}
+
+func TestAccountPrimaryAddressChange(t *testing.T) {
+	setupConfig(t)
+
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	err = queue.Init()
+	tcheck(t, err, "queue init")
+	defer queue.Shutdown()
+
+	err = AccountSave(ctxbg, "mjl", func(acc *config.Account) {
+		acc.Destinations = maps.Clone(acc.Destinations)
+		acc.Destinations["mjl@mox.example"] = config.Destination{Mailbox: "Primary"}
+	})
+	tcheck(t, err, "save account")
+	_, err = AliasAdd(ctxbg, smtp.NewAddress("team", dns.Domain{ASCII: "mox.example"}), config.Alias{Addresses: []string{"mjl@mox.example"}}, false)
+	tcheck(t, err, "add alias")
+
+	// Bad requests.
+	for _, args := range [][2]string{
+		{"missing", "jane@mox.example"},
+		{"mjl", "mjl2@mox.example"},
+		{"mjl", "@mox.example"},
+		{"mjl", "jane@other.example"},
+	} {
+		err := AccountPrimaryAddressChange(ctxbg, args[0], args[1], false)
+		if !errors.Is(err, ErrRequest) {
+			t.Fatalf("got err %v for %v, expected ErrRequest", err, args)
+		}
+	}
+
+	// Old address is removed, and replaced as alias member.
+	err = AccountPrimaryAddressChange(ctxbg, "mjl", "jane@mox.example", false)
+	tcheck(t, err, "change primary address")
+	acc, _ := mox.Conf.Account("mjl")
+	if _, ok := acc.Destinations["mjl@mox.example"]; ok {
+		t.Fatalf("old address still present")
+	}
+	if dest, ok := acc.Destinations["jane@mox.example"]; !ok || dest.Mailbox != "Primary" {
+		t.Fatalf("got destination %#v, expected new address with settings of old address", dest)
+	}
+	alias, err := AliasGet(ctxbg, smtp.NewAddress("team", dns.Domain{ASCII: "mox.example"}))
+	tcheck(t, err, "get alias")
+	if !reflect.DeepEqual(alias.Addresses, []string{"jane@mox.example"}) {
+		t.Fatalf("got alias members %v, expected new address", alias.Addresses)
+	}
+
+	// With jane@ and mjl2@, and without FromID login address, the primary address is
+	// ambiguous.
+	err = AccountPrimaryAddressChange(ctxbg, "mjl", "janet@mox.example", true)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v for ambiguous primary address, expected ErrRequest", err)
+	}
+
+	// FromID login addresses require a catchall separator.
+	err = DomainSave(ctxbg, "mox.example", func(d *config.Domain) error {
+		d.LocalpartCatchallSeparator = "+"
+		return nil
+	})
+	tcheck(t, err, "save domain")
+	err = AccountSave(ctxbg, "mjl", func(acc *config.Account) {
+		acc.FromIDLoginAddresses = []string{"jane@mox.example"}
+	})
+	tcheck(t, err, "save account")
+
+	// Old address is kept, new address becomes the FromID login address.
+	err = AccountPrimaryAddressChange(ctxbg, "mjl", "janet@mox.example", true)
+	tcheck(t, err, "change primary address keeping old")
+	acc, _ = mox.Conf.Account("mjl")
+	if dest, ok := acc.Destinations["jane@mox.example"]; !ok || dest.Mailbox != "Primary" {
+		t.Fatalf("got destination %#v for old address, expected it kept", dest)
+	}
+	if dest, ok := acc.Destinations["janet@mox.example"]; !ok || dest.Mailbox != "Primary" {
+		t.Fatalf("got destination %#v, expected new address with settings of old address", dest)
+	}
+	if !reflect.DeepEqual(acc.FromIDLoginAddresses, []string{"janet@mox.example"}) {
+		t.Fatalf("got fromid login addresses %v, expected new address", acc.FromIDLoginAddresses)
+	}
+	alias, err = AliasGet(ctxbg, smtp.NewAddress("team", dns.Domain{ASCII: "mox.example"}))
+	tcheck(t, err, "get alias")
+	if !reflect.DeepEqual(alias.Addresses, []string{"jane@mox.example"}) {
+		t.Fatalf("got alias members %v, expected old address kept as member", alias.Addresses)
+	}
+}
+
+func TestFetchAutoconfig(t *testing.T) {
+	autodiscoverIMAPPort := 993
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /mail/config-v1.1.xml", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("emailaddress") != "mjl@mox.example" {
+			http.Error(w, "bad emailaddress", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>
+<clientConfig version="1.1">
+  <emailProvider id="mox.example">
+    <incomingServer type="imap"><hostname>mail.mox.example</hostname><port>993</port><socketType>SSL</socketType></incomingServer>
+    <incomingServer type="imap"><hostname>mail.mox.example</hostname><port>443</port><socketType>SSL</socketType></incomingServer>
+    <outgoingServer type="smtp"><hostname>mail.mox.example</hostname><port>587</port><socketType>STARTTLS</socketType></outgoingServer>
+  </emailProvider>
+</clientConfig>`)
+	})
+	mux.HandleFunc("POST /autodiscover/autodiscover.xml", func(w http.ResponseWriter, r *http.Request) {
+		buf, _ := io.ReadAll(r.Body)
+		if !bytes.Contains(buf, []byte("<EMailAddress>mjl@mox.example</EMailAddress>")) {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<Autodiscover><Response><Account>
+  <Protocol><Type>IMAP</Type><Server>mail.mox.example</Server><Port>%d</Port><SSL>on</SSL><Encryption>TLS</Encryption></Protocol>
+  <Protocol><Type>SMTP</Type><Server>mail.mox.example</Server><Port>587</Port><SSL>on</SSL></Protocol>
+</Account></Response></Autodiscover>`, autodiscoverIMAPPort)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	_, _, err := FetchAutoconfig(ctxbg, server.Client(), server.URL, "bogus")
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v, expected ErrRequest", err)
+	}
+
+	raw, parsed, err := FetchAutoconfig(ctxbg, server.Client(), server.URL+"/", "mjl@mox.example")
+	tcheck(t, err, "fetch autoconfig")
+	host := dns.Domain{ASCII: "mail.mox.example"}
+	expConfig := ClientConfig{
+		IMAP:       ProtocolConfig{Host: host, Port: 993, TLSMode: TLSModeImmediate, EnabledOnHTTPS: true},
+		Submission: ProtocolConfig{Host: host, Port: 587, TLSMode: TLSModeSTARTTLS},
+	}
+	if parsed != expConfig {
+		t.Fatalf("got config %#v, expected %#v", parsed, expConfig)
+	}
+	for _, s := range []string{
+		"# GET " + server.URL + "/mail/config-v1.1.xml?emailaddress=mjl%40mox.example\n",
+		"<clientConfig",
+		"# POST " + server.URL + "/autodiscover/autodiscover.xml\n",
+		"<Autodiscover>",
+	} {
+		if !bytes.Contains(raw, []byte(s)) {
+			t.Fatalf("raw response does not contain %q:\n%s", s, raw)
+		}
+	}
+
+	// Autodiscover returning a different config than autoconfig is an error, with the
+	// raw responses still returned.
+	autodiscoverIMAPPort = 143
+	raw, _, err = FetchAutoconfig(ctxbg, server.Client(), server.URL, "mjl@mox.example")
+	if err == nil || !strings.Contains(err.Error(), "differ") {
+		t.Fatalf("got err %v, expected error about differing config", err)
+	}
+	if !bytes.Contains(raw, []byte("<Port>143</Port>")) {
+		t.Fatalf("raw response missing autodiscover response:\n%s", raw)
+	}
+
+	// Error response from the server.
+	raw, _, err = FetchAutoconfig(ctxbg, server.Client(), server.URL, "other@mox.example")
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatalf("got err %v, expected http status error", err)
+	}
+	if !bytes.Contains(raw, []byte("bad emailaddress")) {
+		t.Fatalf("raw response missing error response:\n%s", raw)
+	}
+}
+
+func TestDomainSetFooter(t *testing.T) {
+	setupConfig(t)
+
+	domain := dns.Domain{ASCII: "mox.example"}
+
+	err := DomainSetFooter(ctxbg, domain, strings.Repeat("x", 4097), "")
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v, expected ErrRequest for too long footer", err)
+	}
+	err = DomainSetFooter(ctxbg, dns.Domain{ASCII: "absent.example"}, "disclaimer", "")
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v, expected ErrRequest for unknown domain", err)
+	}
+
+	err = DomainSetFooter(ctxbg, domain, "disclaimer", "<p>disclaimer</p>")
+	tcheck(t, err, "set footer")
+	dc, _ := mox.Conf.Domain(domain)
+	if dc.Footer == nil || *dc.Footer != (config.Footer{Text: "disclaimer", HTML: "<p>disclaimer</p>"}) {
+		t.Fatalf("got footer %v, expected text and html footer", dc.Footer)
+	}
+
+	err = DomainSetFooter(ctxbg, domain, "", "")
+	tcheck(t, err, "clear footer")
+	dc, _ = mox.Conf.Domain(domain)
+	if dc.Footer != nil {
+		t.Fatalf("got footer %v, expected none after clearing", dc.Footer)
+	}
+}
+
+func TestDKIMSharedKeys(t *testing.T) {
+	setupConfig(t)
+
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	err = queue.Init()
+	tcheck(t, err, "queue init")
+	defer queue.Shutdown()
+
+	shared, err := DKIMSharedKeys(ctxbg)
+	tcheck(t, err, "shared keys")
+	if len(shared) != 0 {
+		t.Fatalf("got shared keys %v, expected none", shared)
+	}
+
+	domA := dns.Domain{ASCII: "a.example"}
+	domB := dns.Domain{ASCII: "b.example"}
+	err = DomainAdd(ctxbg, false, domA, "mjl", "")
+	tcheck(t, err, "add domain a")
+	err = DomainAdd(ctxbg, false, domB, "mjl", "")
+	tcheck(t, err, "add domain b")
+
+	// Make b.example use the keys of a.example.
+	dcA, _ := mox.Conf.Domain(domA)
+	if len(dcA.DKIM.Selectors) == 0 {
+		t.Fatalf("no dkim selectors for new domain")
+	}
+	err = DomainSave(ctxbg, domB.Name(), func(d *config.Domain) error {
+		d.DKIM = config.DKIM{
+			Selectors: maps.Clone(dcA.DKIM.Selectors),
+			Sign:      slices.Clone(dcA.DKIM.Sign),
+		}
+		return nil
+	})
+	tcheck(t, err, "make domain b use keys of domain a")
+
+	shared, err = DKIMSharedKeys(ctxbg)
+	tcheck(t, err, "shared keys")
+	exp := map[string][]string{}
+	for selName, sel := range dcA.DKIM.Selectors {
+		exp[filepath.Clean(sel.PrivateKeyFile)] = []string{
+			selName + "._domainkey.a.example",
+			selName + "._domainkey.b.example",
+		}
+	}
+	if !reflect.DeepEqual(shared, exp) {
+		t.Fatalf("got shared keys %v, expected %v", shared, exp)
+	}
+
+	// Removing b.example keeps the shared keys in place for a.example, and they are no
+	// longer shared.
+	err = DomainRemove(ctxbg, domB)
+	tcheck(t, err, "remove domain b")
+	for p := range exp {
+		if _, err := os.Stat(mox.ConfigDynamicDirPath(p)); err != nil {
+			t.Fatalf("shared key file %s moved away after removing domain: %v", p, err)
+		}
+	}
+	shared, err = DKIMSharedKeys(ctxbg)
+	tcheck(t, err, "shared keys")
+	if len(shared) != 0 {
+		t.Fatalf("got shared keys %v after removing domain, expected none", shared)
+	}
+}
+
+func TestSimulateDelivery(t *testing.T) {
+	setupConfig(t)
+	log := pkglog.WithContext(ctxbg)
+
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	defer store.Switchboard()()
+
+	err = AccountAdd(ctxbg, "other", "other@mox.example")
+	tcheck(t, err, "add account")
+	_, err = AliasAdd(ctxbg, smtp.NewAddress("team", dns.Domain{ASCII: "mox.example"}), config.Alias{
+		Addresses: []string{"mjl@mox.example", "other@mox.example"},
+	}, false)
+	tcheck(t, err, "add alias")
+	err = AccountSave(ctxbg, "mjl", func(acc *config.Account) {
+		acc.Destinations = maps.Clone(acc.Destinations)
+		acc.Destinations["mjl@mox.example"] = config.Destination{
+			Rulesets: []config.Ruleset{
+				{HeadersRegexp: map[string]string{"subject": "newsletter"}, Mailbox: "Newsletters", AcceptRejectsToMailbox: "Rejects"},
+			},
+		}
+	})
+	tcheck(t, err, "save account")
+
+	makeMsg := func(from, subject, body string) []byte {
+		return []byte(strings.ReplaceAll(fmt.Sprintf("From: <%s>\nTo: <mjl@mox.example>\nSubject: %s\nContent-Type: text/plain\n\n%s\n", from, subject, body), "\n", "\r\n"))
+	}
+
+	_, err = SimulateDelivery(ctxbg, "remote@example.org", "mjl@mox.example", nil)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v, expected ErrRequest for empty message", err)
+	}
+
+	// Unknown address.
+	trace, err := SimulateDelivery(ctxbg, "remote@example.org", "absent@mox.example", makeMsg("remote@example.org", "hi", "hello"))
+	tcheck(t, err, "simulate delivery")
+	if !trace.Rejected || len(trace.Recipients) != 0 {
+		t.Fatalf("got trace %#v, expected rejection for unknown address", trace)
+	}
+
+	// Non-member is not allowed to send to the alias.
+	trace, err = SimulateDelivery(ctxbg, "remote@example.org", "team@mox.example", makeMsg("remote@example.org", "hi", "hello"))
+	tcheck(t, err, "simulate delivery")
+	if !trace.Rejected {
+		t.Fatalf("got trace %#v, expected rejection for non-member sending to alias", trace)
+	}
+
+	// Member sending to the alias is not delivered a copy. The account of the other
+	// member is not created.
+	trace, err = SimulateDelivery(ctxbg, "mjl@mox.example", "team@mox.example", makeMsg("mjl@mox.example", "hi", "hello"))
+	tcheck(t, err, "simulate delivery")
+	if trace.Rejected || len(trace.Recipients) != 1 || trace.Recipients[0].Address != "other@mox.example" || trace.Recipients[0].Alias != "team@mox.example" || trace.Recipients[0].Account != "other" || trace.Recipients[0].Mailbox != "Inbox" {
+		t.Fatalf("got trace %#v, expected delivery to other member through alias", trace)
+	}
+	if _, err := os.Stat(filepath.Join(mox.DataDirPath("accounts"), "other")); err == nil {
+		t.Fatalf("account directory created by simulated delivery")
+	}
+
+	// Ruleset match.
+	trace, err = SimulateDelivery(ctxbg, "remote@example.org", "mjl@mox.example", makeMsg("remote@example.org", "weekly newsletter", "hello"))
+	tcheck(t, err, "simulate delivery")
+	exp := DeliveryTraceRecipient{Address: "mjl@mox.example", Account: "mjl", Ruleset: 0, Mailbox: "Newsletters", RejectsMailbox: "Rejects"}
+	if trace.Rejected || len(trace.Recipients) != 1 || trace.Recipients[0] != exp {
+		t.Fatalf("got trace %#v, expected recipient %#v", trace, exp)
+	}
+	trace, err = SimulateDelivery(ctxbg, "remote@example.org", "mjl@mox.example", makeMsg("remote@example.org", "hi", "hello"))
+	tcheck(t, err, "simulate delivery")
+	if len(trace.Recipients) != 1 || trace.Recipients[0].Ruleset != -1 || trace.Recipients[0].Mailbox != "Inbox" {
+		t.Fatalf("got trace %#v, expected no ruleset match and delivery to inbox", trace)
+	}
+
+	// Junk filter, trained with enough ham for significant results.
+	err = AccountJunkFilterSave(ctxbg, "mjl", &config.JunkFilter{
+		Threshold: 0.9,
+		Params:    junk.Params{Onegrams: true, MaxPower: 0.01, TopWords: 10, IgnoreWords: 0.1, RareWords: 2},
+	})
+	tcheck(t, err, "save junk filter")
+	acc, err := store.OpenAccount(log, "mjl", false)
+	tcheck(t, err, "open account")
+	deliver := func(junk bool, msg []byte) {
+		t.Helper()
+		msgFile, err := store.CreateMessageTemp(log, "admin-test-simulate")
+		tcheck(t, err, "create temp message")
+		defer store.CloseRemoveTempFile(log, msgFile, "test message")
+		_, err = msgFile.Write(msg)
+		tcheck(t, err, "write message")
+		acc.WithWLock(func() {
+			m := store.Message{Received: time.Now(), Size: int64(len(msg)), Flags: store.Flags{Junk: junk, Notjunk: !junk}}
+			err = acc.DeliverMailbox(log, "Inbox", &m, msgFile)
+		})
+		tcheck(t, err, "deliver message")
+	}
+	for range 50 {
+		deliver(false, makeMsg("remote@example.org", "hi", "meeting project schedule"))
+		deliver(true, makeMsg("remote@example.org", "hi", "casino lottery prize"))
+	}
+	err = acc.Close()
+	tcheck(t, err, "close account")
+
+	trace, err = SimulateDelivery(ctxbg, "remote@example.org", "mjl@mox.example", makeMsg("remote@example.org", "weekly newsletter", "casino lottery prize"))
+	tcheck(t, err, "simulate delivery")
+	if r := trace.Recipients[0]; !r.JunkFilter || !r.JunkSignificant || !r.JunkReject || r.JunkScore <= r.JunkThreshold || r.RejectsMailbox != "Rejects" {
+		t.Fatalf("got recipient %#v, expected junk rejection to rejects mailbox", r)
+	}
+	trace, err = SimulateDelivery(ctxbg, "remote@example.org", "mjl@mox.example", makeMsg("remote@example.org", "hi", "meeting project schedule"))
+	tcheck(t, err, "simulate delivery")
+	if r := trace.Recipients[0]; !r.JunkFilter || !r.JunkSignificant || r.JunkReject || r.JunkScore >= r.JunkThreshold || !r.MailboxExists {
+		t.Fatalf("got recipient %#v, expected junk filter to accept message into existing mailbox", r)
+	}
+}
+
+func TestDKIMKeyRotate(t *testing.T) {
+	setupConfig(t)
+
+	domain := dns.Domain{ASCII: "new.example"}
+	err := DomainAdd(ctxbg, false, domain, "mjl", "")
+	tcheck(t, err, "add domain")
+
+	dc, _ := mox.Conf.Domain(domain)
+	var selName string
+	for name := range dc.DKIM.Selectors {
+		selName = name
+		break
+	}
+	if selName == "" {
+		t.Fatalf("no dkim selector for new domain")
+	}
+	selector := dns.Domain{ASCII: selName}
+
+	err = DKIMKeyRotate(ctxbg, dns.Domain{ASCII: "absent.example"}, selector)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v, expected ErrRequest for unknown domain", err)
+	}
+	err = DKIMKeyRotate(ctxbg, domain, dns.Domain{ASCII: "absent"})
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v, expected ErrRequest for unknown selector", err)
+	}
+
+	// Rotate twice in quick succession, typically within the same second, each
+	// rotation must get its own key file.
+	paths := []string{dc.DKIM.Selectors[selName].PrivateKeyFile}
+	type privateKey interface {
+		Equal(crypto.PrivateKey) bool
+	}
+	keys := []privateKey{dc.DKIM.Selectors[selName].Key.(privateKey)}
+	keyType := fmt.Sprintf("%T", keys[0])
+	for i := range 2 {
+		err := DKIMKeyRotate(ctxbg, domain, selector)
+		tcheck(t, err, fmt.Sprintf("rotate key %d", i))
+		dc, _ := mox.Conf.Domain(domain)
+		sel := dc.DKIM.Selectors[selName]
+		if s := fmt.Sprintf("%T", sel.Key); s != keyType {
+			t.Fatalf("rotated key has type %s, expected %s", s, keyType)
+		}
+		key := sel.Key.(privateKey)
+		if slices.Contains(paths, sel.PrivateKeyFile) || slices.ContainsFunc(keys, func(k privateKey) bool { return k.Equal(key) }) {
+			t.Fatalf("rotation %d did not result in new key file and key, path %s", i, sel.PrivateKeyFile)
+		}
+		if _, err := os.Stat(mox.ConfigDynamicDirPath(sel.PrivateKeyFile)); err != nil {
+			t.Fatalf("new key file: %v", err)
+		}
+		paths = append(paths, sel.PrivateKeyFile)
+		keys = append(keys, key)
+	}
+
+	// Previous key files have been moved away.
+	for _, p := range paths[:2] {
+		if _, err := os.Stat(mox.ConfigDynamicDirPath(p)); err == nil {
+			t.Fatalf("old key file %s still present", p)
+		}
+		if _, err := os.Stat(mox.ConfigDynamicDirPath(filepath.Join(filepath.Dir(p), "old", filepath.Base(p)))); err != nil {
+			t.Fatalf("old key file %s not moved away: %v", p, err)
+		}
+	}
+}
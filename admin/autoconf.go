@@ -0,0 +1,260 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/moxvar"
+	"github.com/mjl-/mox/smtp"
+)
+
+// Parts of the autoconfig and autodiscover XML responses we need to get the client
+// configuration from. See ../http/autoconf.go for the responses we serve.
+type autoconfigServer struct {
+	Type       string `xml:"type,attr"`
+	Hostname   string `xml:"hostname"`
+	Port       int    `xml:"port"`
+	SocketType string `xml:"socketType"`
+}
+
+type autoconfigResponse struct {
+	XMLName       xml.Name `xml:"clientConfig"`
+	EmailProvider struct {
+		IncomingServers []autoconfigServer `xml:"incomingServer"`
+		OutgoingServers []autoconfigServer `xml:"outgoingServer"`
+	} `xml:"emailProvider"`
+}
+
+type autodiscoverResponse struct {
+	XMLName  xml.Name `xml:"Autodiscover"`
+	Response struct {
+		Account struct {
+			Protocol []struct {
+				Type       string
+				Server     string
+				Port       int
+				SSL        string
+				Encryption string
+			}
+		}
+	}
+}
+
+// FetchAutoconfig fetches the client configuration for emailAddress like a mail
+// client would, from both the Thunderbird autoconfig endpoint
+// (/mail/config-v1.1.xml) and the Microsoft autodiscover endpoint
+// (/autodiscover/autodiscover.xml), and checks the IMAP and Submission settings
+// from both responses match.
+//
+// If baseURL is empty, requests go to https://autoconfig.<domain> and
+// https://autodiscover.<domain>, otherwise both endpoints are requested at baseURL,
+// e.g. "https://localhost:443" for a specific listener. If client is nil, a client
+// with a 30 second timeout is used.
+//
+// The raw responses are returned for inspection, each preceded by a line with the
+// request method and URL, also when an error is returned. The returned parsed
+// configuration is from the autoconfig response, which also indicates whether
+// IMAP and Submission are enabled on the HTTPS port.
+func FetchAutoconfig(ctx context.Context, client *http.Client, baseURL, emailAddress string) (raw []byte, parsed ClientConfig, rerr error) {
+	addr, err := smtp.ParseAddress(emailAddress)
+	if err != nil {
+		return nil, ClientConfig{}, requestErrorf(ErrCodeInvalid, "parsing email address: %v", err)
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	autoconfigBase := "https://autoconfig." + addr.Domain.ASCII
+	autodiscoverBase := "https://autodiscover." + addr.Domain.ASCII
+	if baseURL != "" {
+		baseURL = strings.TrimSuffix(baseURL, "/")
+		autoconfigBase = baseURL
+		autodiscoverBase = baseURL
+	}
+
+	var rawBuf bytes.Buffer
+	acRaw, acConfig, err := fetchAutoconfig(ctx, client, autoconfigBase, addr)
+	rawBuf.Write(acRaw)
+	if err != nil {
+		return rawBuf.Bytes(), ClientConfig{}, err
+	}
+	adRaw, adConfig, err := fetchAutodiscover(ctx, client, autodiscoverBase, addr)
+	rawBuf.Write(adRaw)
+	if err != nil {
+		return rawBuf.Bytes(), ClientConfig{}, err
+	}
+
+	same := func(a, b ProtocolConfig) bool {
+		return a.Host == b.Host && a.Port == b.Port && a.TLSMode == b.TLSMode
+	}
+	if !same(acConfig.IMAP, adConfig.IMAP) {
+		return rawBuf.Bytes(), ClientConfig{}, fmt.Errorf("imap config from autoconfig (%s:%d, tls mode %d) and autodiscover (%s:%d, tls mode %d) differ", acConfig.IMAP.Host, acConfig.IMAP.Port, acConfig.IMAP.TLSMode, adConfig.IMAP.Host, adConfig.IMAP.Port, adConfig.IMAP.TLSMode)
+	}
+	if !same(acConfig.Submission, adConfig.Submission) {
+		return rawBuf.Bytes(), ClientConfig{}, fmt.Errorf("submission config from autoconfig (%s:%d, tls mode %d) and autodiscover (%s:%d, tls mode %d) differ", acConfig.Submission.Host, acConfig.Submission.Port, acConfig.Submission.TLSMode, adConfig.Submission.Host, adConfig.Submission.Port, adConfig.Submission.TLSMode)
+	}
+	return rawBuf.Bytes(), acConfig, nil
+}
+
+// fetchAutoconfig fetches and parses the Thunderbird autoconfig XML for addr.
+func fetchAutoconfig(ctx context.Context, client *http.Client, baseURL string, addr smtp.Address) (raw []byte, parsed ClientConfig, rerr error) {
+	u := fmt.Sprintf("%s/mail/config-v1.1.xml?emailaddress=%s", baseURL, url.QueryEscape(addr.String()))
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, ClientConfig{}, fmt.Errorf("making http request: %v", err)
+	}
+	raw, err = fetchClientConfig(ctx, client, req)
+	if err != nil {
+		return raw, ClientConfig{}, fmt.Errorf("autoconfig: %w", err)
+	}
+	body := raw[bytes.IndexByte(raw, '\n')+1:]
+
+	var resp autoconfigResponse
+	if err := xml.Unmarshal(body, &resp); err != nil {
+		return raw, ClientConfig{}, fmt.Errorf("parsing autoconfig xml: %v", err)
+	}
+
+	tlsMode := func(socketType string) (TLSMode, error) {
+		switch socketType {
+		case "SSL":
+			return TLSModeImmediate, nil
+		case "STARTTLS":
+			return TLSModeSTARTTLS, nil
+		case "plain":
+			return TLSModeNone, nil
+		}
+		return 0, fmt.Errorf("unknown socket type %q", socketType)
+	}
+	gather := func(l []autoconfigServer, typ string, pc *ProtocolConfig) error {
+		var have bool
+		for _, s := range l {
+			if s.Type != typ {
+				continue
+			}
+			mode, err := tlsMode(s.SocketType)
+			if err != nil {
+				return err
+			}
+			host, err := dns.ParseDomain(s.Hostname)
+			if err != nil {
+				return fmt.Errorf("parsing hostname %q: %v", s.Hostname, err)
+			}
+			if !have {
+				*pc = ProtocolConfig{Host: host, Port: s.Port, TLSMode: mode}
+				have = true
+			} else if s.Port == 443 && mode == TLSModeImmediate {
+				pc.EnabledOnHTTPS = true
+			}
+		}
+		if !have {
+			return fmt.Errorf("missing %s server", typ)
+		}
+		return nil
+	}
+	if err := gather(resp.EmailProvider.IncomingServers, "imap", &parsed.IMAP); err != nil {
+		return raw, ClientConfig{}, fmt.Errorf("autoconfig incoming server: %v", err)
+	}
+	if err := gather(resp.EmailProvider.OutgoingServers, "smtp", &parsed.Submission); err != nil {
+		return raw, ClientConfig{}, fmt.Errorf("autoconfig outgoing server: %v", err)
+	}
+	return raw, parsed, nil
+}
+
+// fetchAutodiscover fetches and parses the Microsoft autodiscover XML for addr.
+func fetchAutodiscover(ctx context.Context, client *http.Client, baseURL string, addr smtp.Address) (raw []byte, parsed ClientConfig, rerr error) {
+	var reqBuf bytes.Buffer
+	fmt.Fprint(&reqBuf, xml.Header)
+	fmt.Fprint(&reqBuf, `<Autodiscover xmlns="http://schemas.microsoft.com/exchange/autodiscover/outlook/requestschema/2006"><Request><EMailAddress>`)
+	if err := xml.EscapeText(&reqBuf, []byte(addr.String())); err != nil {
+		return nil, ClientConfig{}, fmt.Errorf("writing request: %v", err)
+	}
+	fmt.Fprint(&reqBuf, `</EMailAddress><AcceptableResponseSchema>http://schemas.microsoft.com/exchange/autodiscover/outlook/responseschema/2006a</AcceptableResponseSchema></Request></Autodiscover>`)
+
+	u := baseURL + "/autodiscover/autodiscover.xml"
+	req, err := http.NewRequestWithContext(ctx, "POST", u, &reqBuf)
+	if err != nil {
+		return nil, ClientConfig{}, fmt.Errorf("making http request: %v", err)
+	}
+	req.Header.Set("Content-Type", "text/xml")
+	raw, err = fetchClientConfig(ctx, client, req)
+	if err != nil {
+		return raw, ClientConfig{}, fmt.Errorf("autodiscover: %w", err)
+	}
+	body := raw[bytes.IndexByte(raw, '\n')+1:]
+
+	var resp autodiscoverResponse
+	if err := xml.Unmarshal(body, &resp); err != nil {
+		return raw, ClientConfig{}, fmt.Errorf("parsing autodiscover xml: %v", err)
+	}
+
+	var haveIMAP, haveSubmission bool
+	for _, p := range resp.Response.Account.Protocol {
+		var pc *ProtocolConfig
+		switch {
+		case p.Type == "IMAP" && !haveIMAP:
+			pc = &parsed.IMAP
+			haveIMAP = true
+		case p.Type == "SMTP" && !haveSubmission:
+			pc = &parsed.Submission
+			haveSubmission = true
+		default:
+			continue
+		}
+		host, err := dns.ParseDomain(p.Server)
+		if err != nil {
+			return raw, ClientConfig{}, fmt.Errorf("autodiscover %s protocol: parsing server %q: %v", p.Type, p.Server, err)
+		}
+		pc.Host = host
+		pc.Port = p.Port
+		switch {
+		case p.SSL == "off":
+			pc.TLSMode = TLSModeNone
+		case p.Encryption == "TLS" || p.Encryption == "SSL":
+			pc.TLSMode = TLSModeImmediate
+		default:
+			pc.TLSMode = TLSModeSTARTTLS
+		}
+	}
+	if !haveIMAP {
+		return raw, ClientConfig{}, fmt.Errorf("autodiscover: missing imap protocol")
+	} else if !haveSubmission {
+		return raw, ClientConfig{}, fmt.Errorf("autodiscover: missing smtp protocol")
+	}
+	return raw, parsed, nil
+}
+
+// fetchClientConfig executes req and returns a line with the request method and
+// URL followed by the response body. The response must have status 200.
+func fetchClientConfig(ctx context.Context, client *http.Client, req *http.Request) ([]byte, error) {
+	log := pkglog.WithContext(ctx)
+
+	req.Header.Set("User-Agent", "mox/"+moxvar.Version)
+	t0 := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return []byte(fmt.Sprintf("# %s %s\n", req.Method, req.URL)), fmt.Errorf("http request: %v", err)
+	}
+	defer func() {
+		err := resp.Body.Close()
+		log.Check(err, "closing http response body")
+	}()
+	buf := []byte(fmt.Sprintf("# %s %s\n", req.Method, req.URL))
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	buf = append(buf, body...)
+	log.Debug("fetched client config", slog.String("url", req.URL.String()), slog.Int("status", resp.StatusCode), slog.Duration("duration", time.Since(t0)))
+	if err != nil {
+		return buf, fmt.Errorf("reading http response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return buf, fmt.Errorf("http response status %s", resp.Status)
+	}
+	return buf, nil
+}
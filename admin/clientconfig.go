@@ -1,7 +1,6 @@
 package admin
 
 import (
-	"fmt"
 	"maps"
 	"slices"
 
@@ -37,7 +36,7 @@ func ClientConfigDomain(d dns.Domain) (rconfig ClientConfig, rerr error) {
 
 	domConf, ok := mox.Conf.Domain(d)
 	if !ok {
-		return ClientConfig{}, fmt.Errorf("%w: unknown domain", ErrRequest)
+		return ClientConfig{}, requestErrorf(ErrCodeDomainNotFound, "unknown domain")
 	}
 
 	gather := func(l config.Listener) (done bool) {
@@ -96,7 +95,7 @@ func ClientConfigDomain(d dns.Domain) (rconfig ClientConfig, rerr error) {
 			return
 		}
 	}
-	return ClientConfig{}, fmt.Errorf("%w: no listeners found for imap and/or submission", ErrRequest)
+	return ClientConfig{}, requestErrorf(ErrCodeConflict, "no listeners found for imap and/or submission")
 }
 
 // ClientConfigs holds the client configuration for IMAP/Submission for a
@@ -118,7 +117,7 @@ type ClientConfigsEntry struct {
 func ClientConfigsDomain(d dns.Domain) (ClientConfigs, error) {
 	domConf, ok := mox.Conf.Domain(d)
 	if !ok {
-		return ClientConfigs{}, fmt.Errorf("%w: unknown domain", ErrRequest)
+		return ClientConfigs{}, requestErrorf(ErrCodeDomainNotFound, "unknown domain")
 	}
 
 	c := ClientConfigs{}
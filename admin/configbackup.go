@@ -0,0 +1,122 @@
+package admin
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/store"
+)
+
+// ConfigBackup writes a gzipped tar file to w with the files needed to restore
+// the configuration: mox.conf, domains.conf, the admin password file (if
+// present), and all DKIM private key files referenced by domains.conf. Account
+// data, including messages, is not included.
+//
+// Files are stored under their path relative to the config directory, as
+// referenced in the config files. Absolute paths are stored without leading
+// slash. The dynamic config lock is held while writing, so the archive is
+// consistent with domains.conf.
+func ConfigBackup(ctx context.Context, w io.Writer) (rerr error) {
+	log := pkglog.WithContext(ctx)
+	defer func() {
+		if rerr != nil {
+			log.Errorx("writing config backup", rerr)
+		}
+	}()
+
+	defer mox.Conf.DynamicLockUnlock()()
+
+	type file struct {
+		name string // In archive.
+		path string // On disk.
+	}
+	files := []file{
+		{filepath.Base(mox.ConfigStaticPath), mox.ConfigStaticPath},
+		{filepath.Base(mox.ConfigDynamicPath), mox.ConfigDynamicPath},
+	}
+	if p := mox.Conf.Static.AdminPasswordFile; p != "" {
+		path := mox.ConfigDirPath(p)
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, file{archiveName(p), path})
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("checking admin password file: %v", err)
+		}
+	}
+	var keyPaths []string
+	for p := range gatherUsedKeysPaths(mox.Conf.Dynamic) {
+		if p != "." {
+			keyPaths = append(keyPaths, p)
+		}
+	}
+	slices.Sort(keyPaths)
+	for _, p := range keyPaths {
+		files = append(files, file{archiveName(p), mox.ConfigDynamicDirPath(p)})
+	}
+
+	gzw := gzip.NewWriter(w)
+	archiver := store.TarArchiver{Writer: tar.NewWriter(gzw)}
+
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := backupFile(log, archiver, f.name, f.path); err != nil {
+			return err
+		}
+	}
+
+	if err := archiver.Close(); err != nil {
+		return fmt.Errorf("closing tar: %v", err)
+	}
+	if err := gzw.Close(); err != nil {
+		return fmt.Errorf("closing gzip: %v", err)
+	}
+
+	log.Info("config backup written", slog.Int("files", len(files)))
+	return nil
+}
+
+// archiveName returns the name in the archive for a path from the config.
+func archiveName(p string) string {
+	return strings.TrimPrefix(filepath.ToSlash(filepath.Clean(p)), "/")
+}
+
+// backupFile adds the file at path to the archive as name.
+func backupFile(log mlog.Log, archiver store.Archiver, name, path string) error {
+	sf, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open file for backup: %v", err)
+	}
+	defer func() {
+		err := sf.Close()
+		log.Check(err, "closing file after backup", slog.String("path", path))
+	}()
+	fi, err := sf.Stat()
+	if err != nil {
+		return fmt.Errorf("stat file for backup: %v", err)
+	}
+	df, err := archiver.Create(name, fi.Size(), fi.ModTime())
+	if err != nil {
+		return fmt.Errorf("adding %s to archive: %v", name, err)
+	}
+	if _, err := io.Copy(df, sf); err != nil {
+		df.Close()
+		return fmt.Errorf("writing %s to archive: %v", name, err)
+	}
+	if err := df.Close(); err != nil {
+		return fmt.Errorf("closing %s in archive: %v", name, err)
+	}
+	return nil
+}
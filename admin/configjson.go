@@ -0,0 +1,262 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/smtp"
+)
+
+// ConfigRedacted replaces secrets, such as Authorization headers for webhooks, in
+// the JSON export of the configuration.
+const ConfigRedacted = "(redacted)"
+
+// ConfigExportJSON returns the dynamic configuration, i.e. domains.conf, as
+// indented JSON, for backups and reviewing changes. Only fields stored in
+// domains.conf are exported, in a stable order. Secrets are replaced with
+// ConfigRedacted, they must be set again before a restored configuration is
+// used.
+func ConfigExportJSON(ctx context.Context) ([]byte, error) {
+	c := mox.Conf.DynamicConfig()
+	v, err := configJSONValue(reflect.ValueOf(c), "")
+	if err != nil {
+		return nil, err
+	}
+	buf, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return nil, fmt.Errorf("marshal config: %v", err)
+	}
+	return append(buf, '\n'), nil
+}
+
+// configJSONValue returns a value for marshaling to JSON, leaving out fields that
+// are not stored in the config file, and redacting secrets.
+func configJSONValue(v reflect.Value, fieldName string) (any, error) {
+	if fieldName == "Authorization" && v.Kind() == reflect.String && v.String() != "" {
+		return ConfigRedacted, nil
+	}
+
+	t := v.Type()
+	if t.Implements(reflect.TypeFor[json.Marshaler]()) || t.Implements(reflect.TypeFor[encoding.TextMarshaler]()) {
+		return v.Interface(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return configJSONValue(v.Elem(), fieldName)
+	case reflect.Struct:
+		m := map[string]any{}
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if !f.IsExported() || f.Tag.Get("sconf") == "-" {
+				continue
+			}
+			fv, err := configJSONValue(v.Field(i), f.Name)
+			if err != nil {
+				return nil, err
+			}
+			m[f.Name] = fv
+		}
+		return m, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %v in config", t.Key())
+		}
+		if v.IsNil() {
+			return nil, nil
+		}
+		m := map[string]any{}
+		iter := v.MapRange()
+		for iter.Next() {
+			mv, err := configJSONValue(iter.Value(), "")
+			if err != nil {
+				return nil, err
+			}
+			m[iter.Key().String()] = mv
+		}
+		return m, nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+		l := make([]any, v.Len())
+		for i := range v.Len() {
+			lv, err := configJSONValue(v.Index(i), fieldName)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = lv
+		}
+		return l, nil
+	case reflect.Interface, reflect.Func, reflect.Chan:
+		return nil, fmt.Errorf("unsupported type %v in config", t)
+	}
+	return v.Interface(), nil
+}
+
+// configCopy returns a deep copy of a configuration value, so changes to the copy
+// don't affect the shared configuration. Pointers to types with unexported fields,
+// such as compiled regular expressions, are not copied, they are treated as
+// immutable.
+func configCopy(v reflect.Value) reflect.Value {
+	t := v.Type()
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() || hasUnexportedFields(t.Elem()) {
+			return v
+		}
+		nv := reflect.New(t.Elem())
+		nv.Elem().Set(configCopy(v.Elem()))
+		return nv
+	case reflect.Struct:
+		nv := reflect.New(t).Elem()
+		nv.Set(v)
+		for i := range t.NumField() {
+			if t.Field(i).IsExported() {
+				nv.Field(i).Set(configCopy(v.Field(i)))
+			}
+		}
+		return nv
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		nv := reflect.MakeMapWithSize(t, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			nv.SetMapIndex(iter.Key(), configCopy(iter.Value()))
+		}
+		return nv
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		nv := reflect.MakeSlice(t, v.Len(), v.Len())
+		for i := range v.Len() {
+			nv.Index(i).Set(configCopy(v.Index(i)))
+		}
+		return nv
+	case reflect.Array:
+		nv := reflect.New(t).Elem()
+		for i := range v.Len() {
+			nv.Index(i).Set(configCopy(v.Index(i)))
+		}
+		return nv
+	}
+	return v
+}
+
+func hasUnexportedFields(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := range t.NumField() {
+		if !t.Field(i).IsExported() {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigImportJSONCheck parses a configuration exported with ConfigExportJSON,
+// and validates it like a domains.conf that is loaded, returning the parsed
+// configuration. The configuration is not applied. Secrets that were redacted in
+// the export are not detected.
+func ConfigImportJSONCheck(ctx context.Context, buf []byte) (config.Dynamic, error) {
+	log := pkglog.WithContext(ctx)
+
+	var c config.Dynamic
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return config.Dynamic{}, requestErrorf(ErrCodeInvalid, "parsing json: %v", err)
+	}
+	if dec.More() {
+		return config.Dynamic{}, requestErrorf(ErrCodeInvalid, "trailing data after json config")
+	}
+
+	defer mox.Conf.DynamicLockUnlock()()
+	if err := mox.CheckDynamicLocked(ctx, log, c); err != nil {
+		return config.Dynamic{}, requestErrorf(ErrCodeInvalid, "%v", err)
+	}
+	return c, nil
+}
+
+// AliasesExport returns the aliases of domain as indented JSON, an object with
+// the alias localparts as keys and the alias configurations, including member
+// addresses, as values. The result can be imported with AliasesImport.
+func AliasesExport(ctx context.Context, domain dns.Domain) ([]byte, error) {
+	dc, ok := mox.Conf.Domain(domain)
+	if !ok {
+		return nil, requestErrorf(ErrCodeDomainNotFound, "domain does not exist")
+	}
+	aliases := dc.Aliases
+	if aliases == nil {
+		aliases = map[string]config.Alias{}
+	}
+	v, err := configJSONValue(reflect.ValueOf(aliases), "")
+	if err != nil {
+		return nil, err
+	}
+	buf, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return nil, fmt.Errorf("marshal aliases: %v", err)
+	}
+	return append(buf, '\n'), nil
+}
+
+// AliasesImport adds the aliases in buf, as exported by AliasesExport, to domain.
+// All member addresses must be configured as destinations for accounts. If an
+// alias already exists, an error is returned, unless overwrite is set, in which
+// case the existing alias is replaced. Either all aliases are imported, or none.
+func AliasesImport(ctx context.Context, domain dns.Domain, buf []byte, overwrite bool) error {
+	var aliases map[string]config.Alias
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&aliases); err != nil {
+		return requestErrorf(ErrCodeInvalid, "parsing json: %v", err)
+	}
+	if dec.More() {
+		return requestErrorf(ErrCodeInvalid, "trailing data after json aliases")
+	}
+	for lpstr := range aliases {
+		if _, err := smtp.ParseLocalpart(lpstr); err != nil {
+			return requestErrorf(ErrCodeInvalid, "parsing alias localpart %q: %v", lpstr, err)
+		}
+	}
+
+	return DomainSave(ctx, domain.Name(), func(d *config.Domain) error {
+		d.Aliases = maps.Clone(d.Aliases)
+		if d.Aliases == nil {
+			d.Aliases = map[string]config.Alias{}
+		}
+		for lpstr, a := range aliases {
+			if _, ok := d.Aliases[lpstr]; ok && !overwrite {
+				return requestErrorf(ErrCodeAliasExists, "alias %q already exists", lpstr)
+			}
+			if _, _, err := aliasCheckAddresses(a.Addresses, false); err != nil {
+				return fmt.Errorf("alias %q: %w", lpstr, err)
+			}
+			d.Aliases[lpstr] = config.Alias{
+				Addresses:    a.Addresses,
+				PostPublic:   a.PostPublic,
+				ListMembers:  a.ListMembers,
+				AllowMsgFrom: a.AllowMsgFrom,
+
+				AllowMsgFromAddresses: a.AllowMsgFromAddresses,
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,102 @@
+package admin
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/smtp"
+)
+
+func TestConfigJSON(t *testing.T) {
+	setupConfig(t)
+
+	err := AccountSave(ctxbg, "mjl", func(acc *config.Account) {
+		acc.OutgoingWebhook = &config.OutgoingWebhook{URL: "http://localhost:1234/hook", Authorization: "Basic c2VjcmV0"}
+	})
+	tcheck(t, err, "save account")
+
+	buf, err := ConfigExportJSON(ctxbg)
+	tcheck(t, err, "export config")
+	if bytes.Contains(buf, []byte("c2VjcmV0")) || !bytes.Contains(buf, []byte(ConfigRedacted)) {
+		t.Fatalf("secret not redacted in exported config:\n%s", buf)
+	}
+	// Derived fields are not exported.
+	if bytes.Contains(buf, []byte("DNSDomain")) || bytes.Contains(buf, []byte("ParsedFromIDLoginAddresses")) {
+		t.Fatalf("derived fields in exported config:\n%s", buf)
+	}
+
+	// Export is stable.
+	buf2, err := ConfigExportJSON(ctxbg)
+	tcheck(t, err, "export config")
+	if !bytes.Equal(buf, buf2) {
+		t.Fatalf("exports differ")
+	}
+
+	c, err := ConfigImportJSONCheck(ctxbg, buf)
+	tcheck(t, err, "check exported config")
+	if acc, ok := c.Accounts["mjl"]; !ok || acc.Domain != "mox.example" || acc.OutgoingWebhook.Authorization != ConfigRedacted {
+		t.Fatalf("unexpected account in imported config: %#v", acc)
+	}
+
+	// Invalid config: destination address with unknown domain.
+	bad := bytes.Replace(buf, []byte(`"mjl@mox.example"`), []byte(`"mjl@unknown.example"`), 1)
+	if _, err := ConfigImportJSONCheck(ctxbg, bad); !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v for invalid config, expected ErrRequest", err)
+	}
+
+	// Unknown field.
+	if _, err := ConfigImportJSONCheck(ctxbg, []byte(`{"Bogus": 1}`)); !errors.Is(err, ErrRequest) {
+		t.Fatalf("got err %v for unknown field, expected ErrRequest", err)
+	}
+}
+
+func TestAliasesExportImport(t *testing.T) {
+	setupConfig(t)
+
+	domain := dns.Domain{ASCII: "mox.example"}
+	other := dns.Domain{ASCII: "other.example"}
+	err := DomainAdd(ctxbg, false, other, "mjl", "")
+	tcheck(t, err, "add domain")
+
+	_, err = AliasAdd(ctxbg, smtp.NewAddress("team", domain), config.Alias{Addresses: []string{"mjl@mox.example", "mjl2@mox.example"}, ListMembers: true}, false)
+	tcheck(t, err, "add alias")
+	_, err = AliasAdd(ctxbg, smtp.NewAddress("all", domain), config.Alias{Addresses: []string{"mjl@mox.example"}, PostPublic: true, AllowMsgFrom: true}, false)
+	tcheck(t, err, "add alias")
+
+	buf, err := AliasesExport(ctxbg, domain)
+	tcheck(t, err, "export aliases")
+
+	err = AliasesImport(ctxbg, other, buf, false)
+	tcheck(t, err, "import aliases")
+	l, err := AliasList(ctxbg, other)
+	tcheck(t, err, "list aliases")
+	if len(l) != 2 || l[0].LocalpartStr != "all" || !l[0].PostPublic || !l[0].AllowMsgFrom || l[0].ListMembers || len(l[0].Addresses) != 1 ||
+		l[1].LocalpartStr != "team" || !l[1].ListMembers || l[1].PostPublic || len(l[1].Addresses) != 2 {
+		t.Fatalf("unexpected imported aliases %#v", l)
+	}
+	buf2, err := AliasesExport(ctxbg, other)
+	tcheck(t, err, "export imported aliases")
+	if !bytes.Equal(buf, buf2) {
+		t.Fatalf("export after import differs:\n%s\n%s", buf, buf2)
+	}
+
+	// Existing aliases are only replaced with overwrite.
+	err = AliasesImport(ctxbg, other, buf, false)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("import existing aliases: got err %v, expected ErrRequest", err)
+	}
+	err = AliasesImport(ctxbg, other, buf, true)
+	tcheck(t, err, "import aliases with overwrite")
+
+	// Unknown members are rejected, and nothing is imported.
+	err = AliasesImport(ctxbg, other, []byte(`{"new": {"Addresses": ["mjl@mox.example"]}, "bad": {"Addresses": ["unknown@mox.example"]}}`), false)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("import with unknown member: got err %v, expected ErrRequest", err)
+	}
+	if _, err := AliasGet(ctxbg, smtp.NewAddress("new", other)); err == nil {
+		t.Fatalf("alias imported despite error")
+	}
+}
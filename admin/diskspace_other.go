@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd
+
+package admin
+
+// diskAvailableBytes does not know the available disk space on this platform.
+func diskAvailableBytes(dir string) (available uint64, known bool, rerr error) {
+	return 0, false, nil
+}
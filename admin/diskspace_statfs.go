@@ -0,0 +1,17 @@
+//go:build linux || darwin || freebsd
+
+package admin
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// diskAvailableBytes returns the number of bytes available to unprivileged users
+// on the file system holding dir.
+func diskAvailableBytes(dir string) (available uint64, known bool, rerr error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return 0, false, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), true, nil
+}
@@ -0,0 +1,236 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/mjl-/mox/dkim"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/mtasts"
+)
+
+// DNSCheckStatus is the result of checking a single DNS record.
+type DNSCheckStatus string
+
+const (
+	DNSCheckOK       DNSCheckStatus = "ok"       // Expected record is published.
+	DNSCheckMissing  DNSCheckStatus = "missing"  // No record of this kind is published.
+	DNSCheckMismatch DNSCheckStatus = "mismatch" // A record of this kind is published, but differs.
+	DNSCheckError    DNSCheckStatus = "error"    // Lookup failed, see Error.
+)
+
+// DNSCheckRecord is the status of a record required for a domain.
+type DNSCheckRecord struct {
+	Kind     string // "mx", "spf", "dkim", "dmarc", "mtasts", "mtasts-policy", "tlsrpt".
+	Name     string // Absolute DNS name with trailing dot. For "mtasts-policy", the URL of the policy.
+	Type     string // "MX", "TXT", or "HTTPS" for "mtasts-policy".
+	Expected string // Expected value, from DomainRecords.
+	Found    []string
+	Status   DNSCheckStatus
+	Error    string // For status DNSCheckError.
+}
+
+// DomainDNSCheck is the result of DomainCheckDNS.
+type DomainDNSCheck struct {
+	Domain  dns.Domain
+	Records []DNSCheckRecord
+}
+
+// OK returns whether all records are published as expected.
+func (c DomainDNSCheck) OK() bool {
+	return !slices.ContainsFunc(c.Records, func(r DNSCheckRecord) bool { return r.Status != DNSCheckOK })
+}
+
+// DomainCheckDNS looks up the MX and TXT records that DomainRecords lists for a
+// domain (SPF, DKIM, DMARC, MTA-STS, TLSRPT) and compares them against the records
+// as published in DNS. If the MTA-STS record is published, the MTA-STS policy is
+// fetched and checked to list the mail host.
+//
+// Records are looked up with a strict resolver. Unlike the checks in the admin web
+// interface, the result is structured, for use by monitoring.
+func DomainCheckDNS(ctx context.Context, domain dns.Domain) (DomainDNSCheck, error) {
+	log := pkglog.WithContext(ctx)
+	resolver := dns.StrictResolver{Pkg: "admin", Log: log.Logger}
+	return domainCheckDNS(ctx, resolver, domain)
+}
+
+func domainCheckDNS(ctx context.Context, resolver dns.Resolver, domain dns.Domain) (DomainDNSCheck, error) {
+	log := pkglog.WithContext(ctx)
+
+	domConf, ok := mox.Conf.Domain(domain)
+	if !ok {
+		return DomainDNSCheck{}, requestErrorf(ErrCodeDomainNotFound, "domain not present")
+	}
+	lines, err := DomainRecords(domConf, domain, false, "", "")
+	if err != nil {
+		return DomainDNSCheck{}, fmt.Errorf("making expected dns records: %v", err)
+	}
+	records, err := parseDomainRecords(lines)
+	if err != nil {
+		return DomainDNSCheck{}, fmt.Errorf("parsing expected dns records: %v", err)
+	}
+
+	result := DomainDNSCheck{Domain: domain}
+	for _, rec := range records {
+		r := DNSCheckRecord{Name: rec.Name, Type: rec.Type}
+		switch r.Type {
+		case "MX":
+			t := strings.Fields(rec.Value)
+			if len(t) != 2 {
+				continue
+			}
+			r.Kind = "mx"
+			r.Expected = t[1]
+			mxl, _, err := resolver.LookupMX(ctx, r.Name)
+			for _, mx := range mxl {
+				r.Found = append(r.Found, mx.Host)
+			}
+			r.Status, r.Error = dnsCheckStatus(err, r.Expected, r.Found, func(s string) bool { return true })
+		case "TXT":
+			r.Expected = rec.Value
+			r.Kind = txtKind(r.Name, r.Expected)
+			r.Found, _, err = resolver.LookupTXT(ctx, r.Name)
+			// Other TXT records with a different purpose can be present at the same name.
+			version, _, _ := strings.Cut(r.Expected, ";")
+			version, _, _ = strings.Cut(version, " ")
+			r.Status, r.Error = dnsCheckStatus(err, r.Expected, r.Found, func(s string) bool {
+				return strings.HasPrefix(s, version)
+			})
+		default:
+			continue
+		}
+		result.Records = append(result.Records, r)
+
+		if r.Kind == "mtasts" && r.Status == DNSCheckOK {
+			result.Records = append(result.Records, mtastsPolicyCheck(ctx, log.Logger, domain))
+		}
+	}
+	return result, nil
+}
+
+// DKIMSelectorVerifyDNS looks up the DKIM DNS record for selector of domain with
+// a strict resolver, and returns whether the public key in the record matches the
+// private key configured for the selector, e.g. to check the record is published
+// before signing with the selector, see DKIMSignSelectorsSave. An error is
+// returned if no DKIM record is published, or if the published record is invalid.
+func DKIMSelectorVerifyDNS(ctx context.Context, domain, selector dns.Domain) (bool, error) {
+	log := pkglog.WithContext(ctx)
+	resolver := dns.StrictResolver{Pkg: "admin", Log: log.Logger}
+	return dkimSelectorVerifyDNS(ctx, resolver, domain, selector)
+}
+
+func dkimSelectorVerifyDNS(ctx context.Context, resolver dns.Resolver, domain, selector dns.Domain) (bool, error) {
+	name, value, err := DKIMPublicKeyRecord(ctx, domain, selector)
+	if err != nil {
+		return false, err
+	}
+	expected, _, err := dkim.ParseRecord(value)
+	if err != nil {
+		return false, fmt.Errorf("parsing expected dkim record: %v", err)
+	}
+
+	txts, _, err := resolver.LookupTXT(ctx, name)
+	if err != nil && dns.IsNotFound(err) {
+		return false, fmt.Errorf("no dkim record published at %s", name)
+	} else if err != nil {
+		return false, fmt.Errorf("looking up dkim record at %s: %v", name, err)
+	}
+	var published *dkim.Record
+	for _, txt := range txts {
+		r, isdkim, err := dkim.ParseRecord(txt)
+		if !isdkim {
+			continue
+		} else if err != nil {
+			return false, fmt.Errorf("parsing dkim record published at %s: %v", name, err)
+		} else if published != nil {
+			return false, fmt.Errorf("multiple dkim records published at %s", name)
+		}
+		published = r
+	}
+	if published == nil {
+		return false, fmt.Errorf("no dkim record published at %s", name)
+	}
+	return published.Key == expected.Key && bytes.Equal(published.Pubkey, expected.Pubkey), nil
+}
+
+// dnsCheckStatus returns the status for a lookup of expected with a result of
+// found. Records in found for which same returns true are of the same kind.
+func dnsCheckStatus(err error, expected string, found []string, same func(s string) bool) (DNSCheckStatus, string) {
+	if err != nil && !dns.IsNotFound(err) {
+		return DNSCheckError, err.Error()
+	}
+	if slices.Contains(found, expected) {
+		return DNSCheckOK, ""
+	}
+	if slices.ContainsFunc(found, same) {
+		return DNSCheckMismatch, ""
+	}
+	return DNSCheckMissing, ""
+}
+
+// txtValue returns the concatenated strings of a TXT record line from
+// DomainRecords, which can span multiple lines.
+func txtValue(line string) string {
+	var s string
+	for {
+		_, rest, ok := strings.Cut(line, `"`)
+		if !ok {
+			return s
+		}
+		v, rest, ok := strings.Cut(rest, `"`)
+		if !ok {
+			return s
+		}
+		s += v
+		line = rest
+	}
+}
+
+func txtKind(name, value string) string {
+	switch {
+	case strings.Contains(name, "._domainkey."):
+		return "dkim"
+	case strings.HasPrefix(name, "_dmarc."):
+		return "dmarc"
+	case strings.HasPrefix(name, "_mta-sts."):
+		return "mtasts"
+	case strings.HasPrefix(name, "_smtp._tls."):
+		return "tlsrpt"
+	case strings.HasPrefix(value, "v=spf1"):
+		return "spf"
+	}
+	return "txt"
+}
+
+// mtastsPolicyCheck fetches the MTA-STS policy for domain, and checks it allows
+// the mail host.
+func mtastsPolicyCheck(ctx context.Context, elog *slog.Logger, domain dns.Domain) DNSCheckRecord {
+	host := mox.Conf.Static.HostnameDomain
+	r := DNSCheckRecord{
+		Kind:     "mtasts-policy",
+		Name:     fmt.Sprintf("https://mta-sts.%s/.well-known/mta-sts.txt", domain.ASCII),
+		Type:     "HTTPS",
+		Expected: "mx: " + host.ASCII,
+	}
+	policy, policyText, err := mtasts.FetchPolicy(ctx, elog, domain)
+	if err != nil {
+		r.Status = DNSCheckError
+		r.Error = err.Error()
+		if policyText != "" {
+			r.Found = []string{policyText}
+		}
+		return r
+	}
+	r.Found = []string{policyText}
+	if policy.Matches(host) {
+		r.Status = DNSCheckOK
+	} else {
+		r.Status = DNSCheckMismatch
+	}
+	return r
+}
@@ -1,13 +1,20 @@
 package admin
 
 import (
+	"context"
 	"crypto"
+	"crypto/ecdsa"
 	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
+	"encoding/pem"
 	"fmt"
+	"maps"
 	"net/url"
+	"os"
+	"strconv"
 	"strings"
 
 	"github.com/mjl-/adns"
@@ -32,10 +39,30 @@ import (
 // that caID will be suggested. If acmeAccountURI is also set, CAA records also
 // restricting issuance to that account ID will be suggested.
 func DomainRecords(domConf config.Domain, domain dns.Domain, hasDNSSEC bool, certIssuerDomainName, acmeAccountURI string) ([]string, error) {
+	return domainRecords(domConf, domain, mox.Conf.Static.HostnameDomain, hasDNSSEC, certIssuerDomainName, acmeAccountURI)
+}
+
+// DomainRecordsForHostname returns DNS records like DomainRecords, but with
+// hostname as mail host instead of the configured hostname, e.g. for a secondary
+// instance or when the public SMTP host has a different name. The MX, SPF,
+// MTA-STS, autoconfig and SRV records reference hostname. No CAA records are
+// returned.
+func DomainRecordsForHostname(ctx context.Context, domain, hostname dns.Domain) ([]string, error) {
+	domConf, ok := mox.Conf.Domain(domain)
+	if !ok {
+		return nil, requestErrorf(ErrCodeDomainNotFound, "domain not present")
+	}
+	if hostname.IsZero() {
+		return nil, requestErrorf(ErrCodeInvalid, "hostname required")
+	}
+	return domainRecords(domConf, domain, hostname, false, "", "")
+}
+
+func domainRecords(domConf config.Domain, domain, hostname dns.Domain, hasDNSSEC bool, certIssuerDomainName, acmeAccountURI string) ([]string, error) {
 	d := domain.ASCII
-	h := mox.Conf.Static.HostnameDomain.ASCII
+	h := hostname.ASCII
 	csd := h
-	if domConf.ClientSettingsDomain != "" && domConf.ClientSettingsDNSDomain != mox.Conf.Static.HostnameDomain {
+	if domConf.ClientSettingsDomain != "" && domConf.ClientSettingsDNSDomain != hostname {
 		csd = domConf.ClientSettingsDNSDomain.ASCII
 	}
 
@@ -142,19 +169,9 @@ func DomainRecords(domConf config.Domain, domain dns.Domain, hasDNSSEC bool, cer
 	slices.Sort(selectors)
 	for _, name := range selectors {
 		sel := domConf.DKIM.Selectors[name]
-		dkimr := dkim.Record{
-			Version:   "DKIM1",
-			Hashes:    []string{"sha256"},
-			PublicKey: sel.Key.Public(),
-		}
-		if _, ok := sel.Key.(ed25519.PrivateKey); ok {
-			dkimr.Key = "ed25519"
-		} else if _, ok := sel.Key.(*rsa.PrivateKey); !ok {
-			return nil, fmt.Errorf("unrecognized private key for DKIM selector %q: %T", name, sel.Key)
-		}
-		txt, err := dkimr.Record()
+		txt, err := dkimRecordTXT(sel.Key)
 		if err != nil {
-			return nil, fmt.Errorf("making DKIM DNS TXT record: %v", err)
+			return nil, fmt.Errorf("dkim selector %q: %v", name, err)
 		}
 
 		if len(txt) > 100 {
@@ -170,14 +187,40 @@ func DomainRecords(domConf config.Domain, domain dns.Domain, hasDNSSEC bool, cer
 	}
 	dmarcr := dmarc.DefaultRecord
 	dmarcr.Policy = "reject"
-	if domConf.DMARC != nil {
+	if dc := domConf.DMARC; dc != nil {
 		uri := url.URL{
 			Scheme: "mailto",
-			Opaque: smtp.NewAddress(domConf.DMARC.ParsedLocalpart, domConf.DMARC.DNSDomain).Pack(false),
+			Opaque: smtp.NewAddress(dc.ParsedLocalpart, dc.DNSDomain).Pack(false),
 		}
 		dmarcr.AggregateReportAddresses = []dmarc.URI{
 			{Address: uri.String(), MaxSize: 10, Unit: "m"},
 		}
+		if dc.Policy != "" {
+			dmarcr.Policy = dmarc.Policy(dc.Policy)
+		}
+		if dc.Percentage != 0 {
+			dmarcr.Percentage = dc.Percentage
+		}
+		mailtoURIs := func(l []string) ([]dmarc.URI, error) {
+			var uris []dmarc.URI
+			for _, s := range l {
+				addr, err := smtp.ParseAddress(s)
+				if err != nil {
+					return nil, fmt.Errorf("parsing dmarc report address %q: %v", s, err)
+				}
+				u := url.URL{Scheme: "mailto", Opaque: addr.Pack(false)}
+				uris = append(uris, dmarc.URI{Address: u.String()})
+			}
+			return uris, nil
+		}
+		extra, err := mailtoURIs(dc.ExtraAggregateReportAddresses)
+		if err != nil {
+			return nil, err
+		}
+		dmarcr.AggregateReportAddresses = append(dmarcr.AggregateReportAddresses, extra...)
+		if dmarcr.FailureReportAddresses, err = mailtoURIs(dc.FailureReportAddresses); err != nil {
+			return nil, err
+		}
 	}
 	dspfr := spf.Record{Version: "spf1"}
 	for _, ip := range mox.DomainSPFIPs() {
@@ -187,10 +230,21 @@ func DomainRecords(domConf config.Domain, domain dns.Domain, hasDNSSEC bool, cer
 		}
 		dspfr.Directives = append(dspfr.Directives, spf.Directive{Mechanism: mech, IP: ip})
 	}
-	dspfr.Directives = append(dspfr.Directives,
-		spf.Directive{Mechanism: "mx"},
-		spf.Directive{Qualifier: "~", Mechanism: "all"},
-	)
+	dspfr.Directives = append(dspfr.Directives, spf.Directive{Mechanism: "mx"})
+	// Third parties sending for the domain, each included once.
+	spfIncludes := map[string]bool{}
+	for _, s := range domConf.SPFIncludes {
+		id, err := dns.ParseDomainLax(s)
+		if err != nil {
+			return nil, fmt.Errorf("parsing spf include domain %q: %v", s, err)
+		}
+		if spfIncludes[id.ASCII] {
+			continue
+		}
+		spfIncludes[id.ASCII] = true
+		dspfr.Directives = append(dspfr.Directives, spf.Directive{Mechanism: "include", DomainSpec: id.ASCII})
+	}
+	dspfr.Directives = append(dspfr.Directives, spf.Directive{Qualifier: "~", Mechanism: "all"})
 	dspftxt, err := dspfr.Record()
 	if err != nil {
 		return nil, fmt.Errorf("making domain spf record: %v", err)
@@ -264,19 +318,34 @@ func DomainRecords(domConf config.Domain, domain dns.Domain, hasDNSSEC bool, cer
 		// https://github.com/mjl-/mox/pull/367#issuecomment-3486518824. Software isn't
 		// likely to actually update their configs to the targets of CNAMEs, and the
 		// additional lookups won't cause relevant delays or traffic.
-		"; For secure IMAP and submission autoconfig, point to mail host.",
-		fmt.Sprintf(`_imaps._tcp.%s.        SRV 0 1 993 %s.`, d, csd),
-		fmt.Sprintf(`_submissions._tcp.%s.  SRV 0 1 465 %s.`, d, csd),
-		"",
-		// ../rfc/6186:242
-		"; Next records specify POP3 and non-TLS ports are not to be used.",
-		"; These are optional and safe to leave out (e.g. if you have to click a lot in a",
-		"; DNS admin web interface).",
-		fmt.Sprintf(`_imap._tcp.%s.         SRV 0 0 0 .`, d),
-		fmt.Sprintf(`_submission._tcp.%s.   SRV 0 0 0 .`, d),
-		fmt.Sprintf(`_pop3._tcp.%s.         SRV 0 0 0 .`, d),
-		fmt.Sprintf(`_pop3s._tcp.%s.        SRV 0 0 0 .`, d),
 	)
+	imapsPort, submissionsPort := srvServicePorts()
+	var unavailable []string
+	if imapsPort > 0 || submissionsPort > 0 {
+		records = append(records, "; For secure IMAP and submission autoconfig, point to mail host.")
+	}
+	if imapsPort > 0 {
+		records = append(records, fmt.Sprintf(`_imaps._tcp.%s.        SRV 0 1 %d %s.`, d, imapsPort, csd))
+	} else {
+		unavailable = append(unavailable, "imaps")
+	}
+	if submissionsPort > 0 {
+		records = append(records, fmt.Sprintf(`_submissions._tcp.%s.  SRV 0 1 %d %s.`, d, submissionsPort, csd))
+	} else {
+		unavailable = append(unavailable, "submissions")
+	}
+	if imapsPort > 0 || submissionsPort > 0 {
+		records = append(records, "")
+	}
+	// ../rfc/6186:242
+	records = append(records,
+		"; Next records specify POP3 and non-TLS ports, and services not enabled, are not",
+		"; to be used. These are optional and safe to leave out (e.g. if you have to click",
+		"; a lot in a DNS admin web interface).",
+	)
+	for _, service := range append(unavailable, "imap", "submission", "pop3", "pop3s") {
+		records = append(records, fmt.Sprintf(`%-*s SRV 0 0 0 .`, 20+len(d), fmt.Sprintf("_%s._tcp.%s.", service, d)))
+	}
 
 	if certIssuerDomainName != "" {
 		// ../rfc/8659:18 for CAA records.
@@ -325,3 +394,181 @@ func DomainRecords(domConf config.Domain, domain dns.Domain, hasDNSSEC bool, cer
 	}
 	return records, nil
 }
+
+// srvServicePorts returns the ports for IMAPS and Submissions of the first
+// listeners with the service enabled, for SRV records. A zero port means the
+// service is not enabled on any listener.
+func srvServicePorts() (imaps, submissions int) {
+	names := slices.Sorted(maps.Keys(mox.Conf.Static.Listeners))
+	for _, name := range names {
+		l := mox.Conf.Static.Listeners[name]
+		if imaps == 0 && l.IMAPS.Enabled {
+			imaps = config.Port(l.IMAPS.Port, 993)
+		}
+		if submissions == 0 && l.Submissions.Enabled {
+			submissions = config.Port(l.Submissions.Port, 465)
+		}
+	}
+	return
+}
+
+// dkimRecordTXT returns the DKIM DNS TXT record value for the public key of key.
+func dkimRecordTXT(key crypto.Signer) (string, error) {
+	dkimr := dkim.Record{
+		Version:   "DKIM1",
+		Hashes:    []string{"sha256"},
+		PublicKey: key.Public(),
+	}
+	switch key.(type) {
+	case ed25519.PrivateKey:
+		dkimr.Key = "ed25519"
+	case *ecdsa.PrivateKey:
+		dkimr.Key = "ecdsa"
+	case *rsa.PrivateKey:
+	default:
+		return "", fmt.Errorf("unrecognized private key %T", key)
+	}
+	txt, err := dkimr.Record()
+	if err != nil {
+		return "", fmt.Errorf("making DKIM DNS TXT record: %v", err)
+	}
+	return txt, nil
+}
+
+// DKIMPublicKeyRecord returns the DNS name and TXT record value to publish for a
+// DKIM selector of a domain, the single-record counterpart to DomainRecords. The
+// private key file of the selector is read, and the public key derived from it.
+// The name is absolute, with trailing dot.
+func DKIMPublicKeyRecord(ctx context.Context, domain, selector dns.Domain) (name, value string, rerr error) {
+	domConf, ok := mox.Conf.Domain(domain)
+	if !ok {
+		return "", "", requestErrorf(ErrCodeDomainNotFound, "domain not present")
+	}
+	sel, ok := domConf.DKIM.Selectors[selector.Name()]
+	if !ok {
+		return "", "", requestErrorf(ErrCodeSelectorNotFound, "selector not present")
+	}
+
+	pemBuf, err := os.ReadFile(mox.ConfigDynamicDirPath(sel.PrivateKeyFile))
+	if err != nil {
+		return "", "", fmt.Errorf("reading private key: %v", err)
+	}
+	key, _, err := parseDKIMKey(pemBuf)
+	if err != nil {
+		return "", "", err
+	}
+	value, err = dkimRecordTXT(key)
+	if err != nil {
+		return "", "", err
+	}
+	name = fmt.Sprintf("%s._domainkey.%s.", selector.ASCII, domain.ASCII)
+	return name, value, nil
+}
+
+// DKIMKeyInspect parses a PEM-encoded PKCS#8 DKIM private key, e.g. generated
+// externally, and returns its algorithm, as in the DKIM selector configuration
+// (e.g. "rsa-2048", "ed25519", "ecdsa-p256"), and the value of the DNS TXT record
+// for its public key. The config and files on disk are not used or changed.
+func DKIMKeyInspect(pemData []byte) (algorithm string, publicRecord string, err error) {
+	key, algorithm, err := parseDKIMKey(pemData)
+	if err != nil {
+		return "", "", requestErrorf(ErrCodeInvalid, "%v", err)
+	}
+	publicRecord, err = dkimRecordTXT(key)
+	if err != nil {
+		return "", "", err
+	}
+	return algorithm, publicRecord, nil
+}
+
+// parseDKIMKey parses a PEM-encoded PKCS#8 private key, and checks it can be used
+// for DKIM like when loading the config.
+func parseDKIMKey(pemData []byte) (key crypto.Signer, algorithm string, err error) {
+	p, _ := pem.Decode(pemData)
+	if p == nil {
+		return nil, "", fmt.Errorf("private key has no PEM block")
+	}
+	if p.Type == "ENCRYPTED PRIVATE KEY" || strings.Contains(p.Headers["Proc-Type"], "ENCRYPTED") {
+		return nil, "", fmt.Errorf("private key is encrypted, only unencrypted keys are supported")
+	}
+	if p.Type != "PRIVATE KEY" {
+		return nil, "", fmt.Errorf("pem block has type %q, must be a pkcs#8 %q", p.Type, "PRIVATE KEY")
+	}
+	k, err := x509.ParsePKCS8PrivateKey(p.Bytes)
+	if err != nil {
+		return nil, "", fmt.Errorf("parsing private key: %v", err)
+	}
+	switch k := k.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 1024 {
+			// ../rfc/6376:757
+			return nil, "", fmt.Errorf("rsa keys must be >= 1024 bits, is %d bits", k.N.BitLen())
+		}
+		return k, fmt.Sprintf("rsa-%d", k.N.BitLen()), nil
+	case ed25519.PrivateKey:
+		return k, "ed25519", nil
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, "", fmt.Errorf("only ecdsa keys with curve p256 are supported")
+		}
+		return k, "ecdsa-p256", nil
+	}
+	return nil, "", fmt.Errorf("private key type %T not supported", k)
+}
+
+// Record is a DNS record from DomainRecords, for comparing against records in DNS
+// and generating DNS operator-specific formats.
+type Record struct {
+	Type  string // E.g. "MX", "TXT", "CNAME", "SRV", "TLSA", "CAA".
+	Name  string // Absolute name with trailing dot.
+	Value string // As in a zone file, e.g. "10 mail.example." for MX. For TXT, the unquoted concatenated strings.
+	TTL   int    // In seconds.
+}
+
+// DomainRecordsStructured returns the DNS records from DomainRecords for a
+// domain as structured records. Records that are commented out in the text form,
+// such as TLSA records without DNSSEC, are not included. No CAA records are
+// returned.
+func DomainRecordsStructured(ctx context.Context, domain dns.Domain) ([]Record, error) {
+	domConf, ok := mox.Conf.Domain(domain)
+	if !ok {
+		return nil, requestErrorf(ErrCodeDomainNotFound, "domain not present")
+	}
+	lines, err := DomainRecords(domConf, domain, false, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("making dns records: %v", err)
+	}
+	return parseDomainRecords(lines)
+}
+
+// parseDomainRecords parses text lines from DomainRecords into records.
+func parseDomainRecords(lines []string) ([]Record, error) {
+	var records []Record
+	var ttl int
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if s, ok := strings.CutPrefix(line, "$TTL "); ok {
+			v, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				return nil, fmt.Errorf("parsing ttl in line %q: %v", line, err)
+			}
+			ttl = v
+			continue
+		}
+		t := strings.Fields(line)
+		if len(t) < 3 {
+			return nil, fmt.Errorf("malformed dns record line %q", line)
+		}
+		r := Record{Type: t[1], Name: t[0], TTL: ttl}
+		if r.Type == "TXT" {
+			r.Value = txtValue(line)
+		} else {
+			r.Value = strings.Join(t[2:], " ")
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
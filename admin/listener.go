@@ -0,0 +1,254 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/mjl-/sconf"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/moxio"
+)
+
+// Serializes changes to mox.conf.
+var staticConfigMutex sync.Mutex
+
+// ListenerSetProxyProtocol enables or disables accepting PROXY protocol headers
+// on the SMTP/Submission(s)/IMAP(S) ports of a listener, honoring them only for
+// connections from trustedNets (IP networks in CIDR notation).
+//
+// The change is written to mox.conf, which is not reloaded while running: mox
+// must be restarted for the change to take effect. Comments in mox.conf are not
+// preserved.
+func ListenerSetProxyProtocol(ctx context.Context, listener string, enabled bool, trustedNets []string) (rerr error) {
+	log := pkglog.WithContext(ctx)
+	defer func() {
+		if rerr != nil {
+			log.Errorx("setting proxy protocol for listener", rerr, slog.String("listener", listener))
+		}
+	}()
+
+	if enabled && len(trustedNets) == 0 {
+		return requestErrorf(ErrCodeInvalid, "at least one trusted network required")
+	} else if !enabled && len(trustedNets) > 0 {
+		return requestErrorf(ErrCodeInvalid, "trusted networks not allowed when disabling")
+	}
+	for _, s := range trustedNets {
+		if _, err := mox.ParseIPNet(s); err != nil {
+			return requestErrorf(ErrCodeInvalid, "%v", err)
+		}
+	}
+
+	err := staticConfigSave(log, func(c *config.Static) error {
+		l, ok := c.Listeners[listener]
+		if !ok {
+			return requestErrorf(ErrCodeNotFound, "listener does not exist")
+		}
+		l.ProxyProtocol = nil
+		if enabled {
+			l.ProxyProtocol = &config.ProxyProtocol{TrustedNetworks: trustedNets}
+		}
+		c.Listeners[listener] = l
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Info("proxy protocol for listener saved, restart mox to apply", slog.String("listener", listener), slog.Bool("enabled", enabled), slog.Any("trustednets", trustedNets))
+	return nil
+}
+
+// staticConfigSave reads mox.conf from disk, calls xmodify with it, and writes
+// the modified config back. The running configuration is not changed.
+//
+// The file is written from the parsed config, so comments, empty optional fields
+// and the order of map keys in the original file are not preserved. The settings
+// themselves are: parsing the written file gives the same config.
+func staticConfigSave(log mlog.Log, xmodify func(c *config.Static) error) error {
+	staticConfigMutex.Lock()
+	defer staticConfigMutex.Unlock()
+
+	f, err := os.Open(mox.ConfigStaticPath)
+	if err != nil {
+		return fmt.Errorf("open mox.conf: %v", err)
+	}
+	var c config.Static
+	err = sconf.Parse(f, &c)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("parsing mox.conf: %v", err)
+	}
+	fi, err := os.Stat(mox.ConfigStaticPath)
+	if err != nil {
+		return fmt.Errorf("stat mox.conf: %v", err)
+	}
+
+	if err := xmodify(&c); err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	if err := sconf.Write(&b, c); err != nil {
+		return fmt.Errorf("writing mox.conf: %v", err)
+	}
+
+	// Write to temporary file and rename over the original, so mox.conf is never
+	// partially written.
+	p := mox.ConfigStaticPath + ".new"
+	nf, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("creating new mox.conf: %v", err)
+	}
+	defer func() {
+		if nf != nil {
+			err := nf.Close()
+			log.Check(err, "closing new mox.conf after error")
+			err = os.Remove(p)
+			log.Check(err, "removing new mox.conf after error")
+		}
+	}()
+	if _, err := nf.Write(b.Bytes()); err != nil {
+		return fmt.Errorf("write new mox.conf: %v", err)
+	}
+	if err := nf.Sync(); err != nil {
+		return fmt.Errorf("sync new mox.conf: %v", err)
+	}
+	if err := nf.Close(); err != nil {
+		return fmt.Errorf("close new mox.conf: %v", err)
+	}
+	nf = nil
+	if err := os.Rename(p, mox.ConfigStaticPath); err != nil {
+		return fmt.Errorf("replacing mox.conf: %v", err)
+	}
+	if err := moxio.SyncDir(log, filepath.Dir(mox.ConfigStaticPath)); err != nil {
+		return fmt.Errorf("sync dir of mox.conf: %v", err)
+	}
+	return nil
+}
+
+// ListenerReloadResult lists the sockets opened and closed by ListenerReload,
+// each of the form "<protocol> <listener> <address>".
+type ListenerReloadResult struct {
+	Opened []string
+	Closed []string
+}
+
+// ListenerReload reads mox.conf, compares its listeners with the running
+// listeners, and opens sockets for SMTP, submission(s) and IMAP(S) ports that were
+// added, and closes sockets for ports that were removed. Connections are not
+// dropped, neither on remaining sockets nor on closed sockets.
+//
+// Only opening and closing of sockets is applied. Changed settings of remaining
+// sockets, HTTP listeners (for web interfaces, autoconfig, MTA-STS, etc), and
+// other changes to mox.conf still require a restart. The running configuration
+// is not changed.
+//
+// New sockets are opened by the unprivileged mox process, the privileged process
+// that opens the sockets at startup is not involved. Adding ports below 1024, like
+// 25, therefore requires a restart and is rejected, as is reusing the address of
+// a removed socket for a new socket. Certificate files cannot be opened by the
+// unprivileged process either, so ports with TLS can only be added to a running
+// listener with the same TLS configuration, whose TLS configuration is reused.
+//
+// All new sockets are opened before any socket is closed. If a socket cannot be
+// opened, the sockets opened so far are closed again and no listener is changed.
+func ListenerReload(ctx context.Context) (result ListenerReloadResult, rerr error) {
+	log := pkglog.WithContext(ctx)
+	defer func() {
+		if rerr != nil {
+			log.Errorx("reloading listeners", rerr)
+		}
+	}()
+
+	staticConfigMutex.Lock()
+	defer staticConfigMutex.Unlock()
+
+	c, errs := mox.ParseConfig(ctx, log, mox.ConfigStaticPath, true, false, false)
+	if len(errs) > 0 {
+		return result, requestErrorf(ErrCodeConflict, "parsing mox.conf: %v", errors.Join(errs...))
+	}
+	for name, l := range c.Static.Listeners {
+		if l.TLS == nil {
+			continue
+		}
+		running, ok := mox.Conf.Static.Listeners[name]
+		if !ok || running.TLS == nil || running.TLS.ACME != l.TLS.ACME || !slices.Equal(running.TLS.KeyCerts, l.TLS.KeyCerts) || running.TLS.MinVersion != l.TLS.MinVersion || running.TLS.ClientAuthDisabled != l.TLS.ClientAuthDisabled {
+			return result, requestErrorf(ErrCodeConflict, "tls configuration for listener %q is new or changed, restart mox to apply", name)
+		}
+		l.TLS = running.TLS
+		c.Static.Listeners[name] = l
+	}
+
+	type reload struct {
+		add, remove []mox.ListenerSocket
+		apply       func(lns []net.Listener)
+	}
+	var reloads []reload
+	var add []mox.ListenerSocket
+	removed := map[string]string{} // Network and address to key of removed socket.
+	for _, proto := range slices.Sorted(maps.Keys(mox.ListenerReloaders)) {
+		r := reload{}
+		r.add, r.remove, r.apply = mox.ListenerReloaders[proto](c.Static)
+		reloads = append(reloads, r)
+		add = append(add, r.add...)
+		for _, s := range r.remove {
+			removed[s.Network+" "+s.Address] = s.Key
+		}
+	}
+
+	// Check all new sockets before opening or closing anything.
+	for _, s := range add {
+		if key, ok := removed[s.Network+" "+s.Address]; ok {
+			return result, requestErrorf(ErrCodeConflict, "new socket %q uses address of removed socket %q, restart mox to apply", s.Key, key)
+		}
+		_, portStr, err := net.SplitHostPort(s.Address)
+		if err != nil {
+			return result, fmt.Errorf("parsing address of socket %q: %v", s.Key, err)
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			return result, fmt.Errorf("parsing port of socket %q: %v", s.Key, err)
+		}
+		if port < 1024 && os.Getuid() != 0 {
+			return result, requestErrorf(ErrCodeConflict, "new socket %q uses privileged port %d, which the unprivileged mox process cannot open, restart mox to apply", s.Key, port)
+		}
+	}
+
+	var lns []net.Listener
+	for _, s := range add {
+		ln, err := net.Listen(s.Network, s.Address)
+		if err != nil {
+			for _, ln := range lns {
+				xerr := ln.Close()
+				log.Check(xerr, "closing new listener after error")
+			}
+			return result, fmt.Errorf("listen for %s: %v", s.Key, err)
+		}
+		lns = append(lns, ln)
+	}
+
+	for _, r := range reloads {
+		r.apply(lns[:len(r.add)])
+		lns = lns[len(r.add):]
+		for _, s := range r.add {
+			result.Opened = append(result.Opened, s.Key)
+		}
+		for _, s := range r.remove {
+			result.Closed = append(result.Closed, s.Key)
+		}
+	}
+	log.Info("listeners reloaded", slog.Any("opened", result.Opened), slog.Any("closed", result.Closed))
+	return result, nil
+}
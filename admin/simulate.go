@@ -0,0 +1,269 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/store"
+)
+
+// DeliveryTrace describes how an incoming message would be handled, as determined
+// by SimulateDelivery.
+type DeliveryTrace struct {
+	Steps      []string                 // Human-readable description of each step, in order.
+	Rejected   bool                     // Whether the recipient would be refused before analysis, e.g. unknown address.
+	Recipients []DeliveryTraceRecipient // Final recipients, after alias expansion.
+}
+
+// DeliveryTraceRecipient is a final recipient of a simulated delivery.
+type DeliveryTraceRecipient struct {
+	Address         string  // Address of the recipient, after alias expansion.
+	Alias           string  // Alias the address was expanded from, empty if not delivered through an alias.
+	Account         string  // Account the message would be delivered to.
+	Ruleset         int     // Index of first matching ruleset of the destination, -1 if none.
+	Mailbox         string  // Mailbox the message would be delivered to when accepted.
+	MailboxExists   bool    // If false, mailbox would be created on delivery.
+	RejectsMailbox  string  // Mailbox for a rejected message, from the ruleset, if any.
+	JunkFilter      bool    // Whether the account has a junk filter.
+	JunkScore       float64 // Spam probability according to the junk filter, between 0 and 1.
+	JunkSignificant bool    // Whether the junk filter had enough known words for a decision.
+	JunkThreshold   float64 // Threshold of the junk filter, above which messages are rejected.
+	JunkReject      bool    // Whether the junk filter would reject the message from a sender without reputation, at the configured threshold.
+}
+
+// SimulateDelivery evaluates delivery of rawMessage from envelopeFrom to
+// envelopeTo without delivering it: recipient lookup, alias expansion, ruleset
+// matching, destination mailbox and junk filter classification. The returned
+// trace describes each step. Checks that need an SMTP connection, i.e. SPF, DKIM,
+// DMARC, reputation based on the remote IP and rate limits, are not evaluated, so
+// rulesets requiring a verified domain do not match. An empty envelopeFrom is the
+// null sender.
+func SimulateDelivery(ctx context.Context, envelopeFrom, envelopeTo string, rawMessage []byte) (trace DeliveryTrace, rerr error) {
+	log := pkglog.WithContext(ctx)
+
+	addStep := func(format string, args ...any) {
+		trace.Steps = append(trace.Steps, fmt.Sprintf(format, args...))
+	}
+
+	var mailFrom smtp.Address
+	if envelopeFrom != "" {
+		var err error
+		mailFrom, err = smtp.ParseAddress(envelopeFrom)
+		if err != nil {
+			return DeliveryTrace{}, requestErrorf(ErrCodeInvalid, "parsing envelope from address: %v", err)
+		}
+	}
+	rcptTo, err := smtp.ParseAddress(envelopeTo)
+	if err != nil {
+		return DeliveryTrace{}, requestErrorf(ErrCodeInvalid, "parsing envelope to address: %v", err)
+	}
+	if len(rawMessage) == 0 {
+		return DeliveryTrace{}, requestErrorf(ErrCodeInvalid, "empty message")
+	}
+
+	// Rulesets and junk filter work on a message file.
+	f, err := store.CreateMessageTemp(log, "simulatedelivery")
+	if err != nil {
+		return DeliveryTrace{}, fmt.Errorf("creating temporary message file: %v", err)
+	}
+	defer store.CloseRemoveTempFile(log, f, "simulated delivery message")
+	if _, err := f.Write(rawMessage); err != nil {
+		return DeliveryTrace{}, fmt.Errorf("writing temporary message file: %v", err)
+	}
+	size := int64(len(rawMessage))
+
+	msgFrom, _, _, err := message.From(log.Logger, false, f, nil)
+	if err != nil {
+		addStep("message From header could not be parsed: %v", err)
+	} else {
+		addStep("message From address is %s", msgFrom)
+	}
+
+	accName, alias, canonical, dest, err := mox.LookupAddress(rcptTo.Localpart, rcptTo.Domain, true, true, true)
+	switch {
+	case errors.Is(err, mox.ErrDomainNotFound):
+		addStep("recipient domain %s is not a local domain, message would be rejected", rcptTo.Domain)
+		trace.Rejected = true
+		return trace, nil
+	case errors.Is(err, mox.ErrDomainDisabled):
+		addStep("recipient domain %s is disabled, message would be rejected with a temporary error", rcptTo.Domain)
+		trace.Rejected = true
+		return trace, nil
+	case errors.Is(err, mox.ErrAddressNotFound):
+		addStep("recipient address %s does not exist, message would be rejected", rcptTo)
+		trace.Rejected = true
+		return trace, nil
+	case err != nil:
+		return DeliveryTrace{}, fmt.Errorf("looking up recipient: %v", err)
+	}
+
+	// Gather final recipients, expanding an alias.
+	type recipient struct {
+		addr    smtp.Address
+		account string
+		dest    config.Destination
+		alias   string
+	}
+	var rcpts []recipient
+	if alias != nil {
+		addStep("recipient %s is an alias with %d member(s)", canonical, len(alias.ParsedAddresses))
+		// Same check as for incoming SMTP deliveries.
+		if !mox.AliasAllowedMsgFrom(*alias, msgFrom) {
+			addStep("message From address %s is not allowed to send to alias, message would be rejected", msgFrom)
+			trace.Rejected = true
+			return trace, nil
+		}
+		addStep("message From address %s is allowed to send to alias", msgFrom)
+		for _, aa := range alias.ParsedAddresses {
+			if aa.Address == msgFrom {
+				addStep("alias member %s is the sender, not delivering to it", aa.Address)
+				continue
+			}
+			addStep("alias expands to %s in account %s", aa.Address, aa.AccountName)
+			rcpts = append(rcpts, recipient{aa.Address, aa.AccountName, aa.Destination, canonical})
+		}
+	} else {
+		addStep("recipient %s is address %s in account %s", rcptTo, canonical, accName)
+		rcpts = []recipient{{rcptTo, accName, dest, ""}}
+	}
+
+	m := store.Message{
+		MailFrom:          mailFrom.String(),
+		MailFromLocalpart: mailFrom.Localpart,
+		MailFromDomain:    mailFrom.Domain.Name(),
+		MsgFromLocalpart:  msgFrom.Localpart,
+		MsgFromDomain:     msgFrom.Domain.Name(),
+		Size:              size,
+	}
+	if envelopeFrom == "" {
+		m.MailFrom = ""
+	}
+
+	for _, r := range rcpts {
+		tr, err := simulateRecipient(ctx, addStep, r.addr, r.account, r.dest, &m, f, size)
+		if err != nil {
+			return DeliveryTrace{}, fmt.Errorf("simulating delivery to %s: %v", r.addr, err)
+		}
+		tr.Alias = r.alias
+		trace.Recipients = append(trace.Recipients, tr)
+	}
+
+	addStep("not evaluated: spf, dkim, dmarc, reputation and rate limits, these require an smtp connection")
+	return trace, nil
+}
+
+// simulateRecipient evaluates the rulesets and junk filter for delivery of a
+// message to a single address of an account. The destination mailbox and junk
+// decision are made with the same functions as for incoming SMTP deliveries. The
+// account is only opened if it already exists, to prevent creating it.
+func simulateRecipient(ctx context.Context, addStep func(format string, args ...any), addr smtp.Address, accName string, dest config.Destination, m *store.Message, f *os.File, size int64) (DeliveryTraceRecipient, error) {
+	log := pkglog.WithContext(ctx)
+
+	tr := DeliveryTraceRecipient{
+		Address: addr.String(),
+		Account: accName,
+	}
+
+	tr.Ruleset = store.MessageRulesetIndex(log, dest, m, nil, f)
+	var rs *config.Ruleset
+	if tr.Ruleset >= 0 {
+		rs = &dest.Rulesets[tr.Ruleset]
+		tr.RejectsMailbox = rs.AcceptRejectsToMailbox
+	}
+	tr.Mailbox = store.DeliveryMailbox(dest, rs)
+	if rs == nil {
+		addStep("%s: no ruleset matches, mailbox %s", addr, tr.Mailbox)
+	} else {
+		addStep("%s: ruleset %d matches, mailbox %s", addr, tr.Ruleset, tr.Mailbox)
+		if rs.IsForward {
+			addStep("%s: ruleset marks message as forwarded, dmarc rejections are not applied", addr)
+		}
+		if !rs.ListAllowDNSDomain.IsZero() {
+			addStep("%s: ruleset allows mailing list domain %s, message accepted if spf or dkim for it passes", addr, rs.ListAllowDNSDomain)
+		}
+	}
+
+	// Opening an account that doesn't exist yet would create it.
+	accDir := filepath.Join(mox.DataDirPath("accounts"), accName)
+	if _, err := os.Stat(filepath.Join(accDir, "index.db")); err != nil && errors.Is(err, fs.ErrNotExist) {
+		addStep("%s: account %s has not been initialized yet, it would be created with its default mailboxes on delivery", addr, accName)
+		return tr, nil
+	} else if err != nil {
+		return tr, fmt.Errorf("checking account database: %v", err)
+	}
+
+	acc, err := store.OpenAccount(log, accName, false)
+	if err != nil {
+		return tr, fmt.Errorf("open account: %v", err)
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account after simulating delivery")
+	}()
+
+	err = acc.DB.Read(ctx, func(tx *bstore.Tx) error {
+		mb, err := acc.MailboxFind(tx, tr.Mailbox)
+		tr.MailboxExists = mb != nil
+		return err
+	})
+	if err != nil {
+		return tr, fmt.Errorf("looking up mailbox: %v", err)
+	}
+	if !tr.MailboxExists {
+		addStep("%s: mailbox %s does not exist and would be created", addr, tr.Mailbox)
+	}
+
+	conf, _ := acc.Conf()
+	if conf.JunkFilter == nil {
+		addStep("%s: account has no junk filter", addr)
+		return tr, nil
+	}
+	// Opening a junk filter that doesn't exist yet would create it.
+	if _, err := os.Stat(filepath.Join(acc.Dir, "junkfilter.db")); err != nil && errors.Is(err, fs.ErrNotExist) {
+		addStep("%s: junk filter of account has not been trained yet, decision based on reputation", addr)
+		return tr, nil
+	}
+	jf, jfconf, err := acc.OpenJunkFilter(ctx, log)
+	if err != nil {
+		return tr, fmt.Errorf("open junk filter: %v", err)
+	}
+	defer func() {
+		err := jf.CloseDiscard()
+		log.Check(err, "closing junk filter after simulating delivery")
+	}()
+	result, err := jf.ClassifyMessageReader(ctx, f, size)
+	if err != nil {
+		return tr, fmt.Errorf("classifying message: %v", err)
+	}
+	tr.JunkFilter = true
+	tr.JunkScore = result.Probability
+	tr.JunkSignificant = result.Significant
+	tr.JunkThreshold = jfconf.Threshold
+	tr.JunkReject = !store.JunkAccept(result, jfconf.Threshold, false)
+	if !result.Significant {
+		addStep("%s: junk filter score %.3f, not significant", addr, result.Probability)
+	} else {
+		addStep("%s: junk filter score %.3f, threshold %.3f", addr, result.Probability, jfconf.Threshold)
+	}
+	if tr.JunkReject {
+		addStep("%s: junk filter would reject message for senders without reputation", addr)
+	} else if strict := min(jfconf.Threshold, store.JunkThresholdStrict); !store.JunkAccept(result, strict, true) {
+		addStep("%s: junk filter would reject message for senders without reputation at stricter threshold %.3f, used for failing reverse ip, no tls, or recipient not in to/cc header", addr, strict)
+	} else {
+		addStep("%s: junk filter would accept message", addr)
+	}
+	if tr.JunkReject && tr.RejectsMailbox != "" {
+		addStep("%s: rejected message would be delivered to mailbox %s due to ruleset", addr, tr.RejectsMailbox)
+	}
+	return tr, nil
+}
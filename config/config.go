@@ -44,6 +44,7 @@ type Static struct {
 	HostnameDomain   dns.Domain        `sconf:"-" json:"-"` // Parsed form of hostname.
 	CheckUpdates     bool              `sconf:"optional" sconf-doc:"If enabled, a single DNS TXT lookup of _updates.xmox.nl is done every 24h to check for a new release. Each time a new release is found, a changelog is fetched from https://updates.xmox.nl/changelog and delivered to the postmaster mailbox."`
 	Pedantic         bool              `sconf:"optional" sconf-doc:"In pedantic mode protocol violations (that happen in the wild) for SMTP/IMAP/etc result in errors instead of accepting such behaviour."`
+	DNSSECRequired   bool              `sconf:"optional" sconf-doc:"If enabled, mox checks at startup that the configured DNS resolvers verify DNSSEC, i.e. set the authentic data bit in responses for DNSSEC-signed zones, and refuses to start if they do not. Without DNSSEC-verifying resolvers, MX records are not protected and DANE cannot be used for outgoing deliveries."`
 	TLS              struct {
 		CA *struct {
 			AdditionalToSystem bool     `sconf:"optional"`
@@ -73,6 +74,9 @@ type Static struct {
 	NoOutgoingTLSReports            bool  `sconf:"optional" sconf-doc:"Do not send TLS reports. By default, reports about failed SMTP STARTTLS connections and related MTA-STS/DANE policies are sent to domains if their TLSRPT DNS record requests them. Reports covering a 24 hour UTC interval are sent daily. Reports are sent from the postmaster address of the configured domain the mailhostname is in. If there is no such domain, or it does not have DKIM configured, no reports are sent."`
 	OutgoingTLSReportsForAllSuccess bool  `sconf:"optional" sconf-doc:"Also send TLS reports if there were no SMTP STARTTLS connection failures. By default, reports are only sent when at least one failure occurred. If a report is sent, it does always include the successful connection counts as well."`
 	QuotaMessageSize                int64 `sconf:"optional" sconf-doc:"Default maximum total message size in bytes for each individual account, only applicable if greater than zero. Can be overridden per account. Attempting to add new messages to an account beyond its maximum total size will result in an error. Useful to prevent a single account from filling storage. The quota only applies to the email message files, not to any file system overhead and also not the message index database file (account for approximately 15% overhead)."`
+	MaxMailboxes                    int   `sconf:"optional" sconf-doc:"Default maximum number of mailboxes for each individual account, only applicable if greater than zero. Can be overridden per account. Prevents email clients from creating an excessive number of mailboxes."`
+
+	ConnectionReuse *ConnectionReuse `sconf:"optional" sconf-doc:"Reuse outgoing SMTP connections for direct delivery to the configured recipient domains. After delivering, the connection is kept open for a short while, and used for a next delivery to the same host for the same recipient domain. Useful for bulk delivery to a few large mail providers. Regardless of this setting, SMTP PIPELINING is used when announced by the remote server."`
 
 	// All IPs that were explicitly listened on for external SMTP. Only set when there
 	// are no unspecified external SMTP listeners and there is at most one for IPv4 and
@@ -88,6 +92,15 @@ type Static struct {
 	GID uint32 `sconf:"-" json:"-"`
 }
 
+// ConnectionReuse configures reuse of outgoing SMTP connections per recipient
+// domain.
+type ConnectionReuse struct {
+	Domains  []string      `sconf-doc:"Recipient domains for which connections are reused, ASCII or unicode."`
+	IdleTime time.Duration `sconf:"optional" sconf-doc:"How long an idle connection is kept open for reuse. Default 30s, maximum 5m."`
+
+	ParsedDomains map[dns.Domain]struct{} `sconf:"-" json:"-"`
+}
+
 // InitialMailboxes are mailboxes created for a new account.
 type InitialMailboxes struct {
 	SpecialUse SpecialUseMailboxes `sconf:"optional" sconf-doc:"Special-use roles to mailbox to create."`
@@ -143,6 +156,8 @@ type Listener struct {
 	Hostname       string     `sconf:"optional" sconf-doc:"If empty, the config global Hostname is used. The internal services webadmin, webaccount, webmail and webapi only match requests to IPs, this hostname, \"localhost\". All except webadmin also match for any client settings domain."`
 	HostnameDomain dns.Domain `sconf:"-" json:"-"` // Set when parsing config.
 
+	ProxyProtocol *ProxyProtocol `sconf:"optional" sconf-doc:"If set, connections for SMTP, Submission, Submissions, IMAP and IMAPS from the trusted networks must start with a PROXY protocol (version 1 or 2) header, as sent by load balancers such as HAProxy. The source IP address from the header is used as the remote IP address for logging, rate limiting, DNSBL and reputation checks. Connections from other networks are handled as regular connections, their PROXY protocol headers are not honored."`
+
 	TLS                *TLS  `sconf:"optional" sconf-doc:"For SMTP/IMAP STARTTLS, direct TLS and HTTPS connections."`
 	SMTPMaxMessageSize int64 `sconf:"optional" sconf-doc:"Maximum size in bytes for incoming and outgoing messages. Default is 100MB."`
 	SMTP               struct {
@@ -222,6 +237,14 @@ type Listener struct {
 	} `sconf:"optional" sconf-doc:"All configured WebHandlers will serve on an enabled listener. Either ACME must be configured, or for each WebHandler domain a TLS certificate must be configured."`
 }
 
+// ProxyProtocol holds the upstream networks that are trusted to send a PROXY
+// protocol header with the original source address of a connection.
+type ProxyProtocol struct {
+	TrustedNetworks []string `sconf-doc:"IP networks in CIDR notation, e.g. 192.0.2.0/24 or 2001:db8::/64, of load balancers that are trusted to send a PROXY protocol header. A single IP address is also accepted. Must be non-empty."`
+
+	TrustedIPNets []*net.IPNet `sconf:"-" json:"-"` // Parsed form of TrustedNetworks.
+}
+
 // WebService is an internal web interface: webmail, webaccount, webadmin, webapi.
 type WebService struct {
 	Enabled   bool
@@ -306,6 +329,9 @@ type Domain struct {
 	TLSRPT                      *TLSRPT          `sconf:"optional" sconf-doc:"With TLSRPT a domain specifies in DNS where reports about encountered SMTP TLS behaviour should be sent. Useful for monitoring. Incoming TLS reports are automatically parsed, validated, added to metrics and stored in the reporting database for later display in the admin web pages."`
 	Routes                      []Route          `sconf:"optional" sconf-doc:"Routes for delivering outgoing messages through the queue. Each delivery attempt evaluates account routes, these domain routes and finally global routes. The transport of the first matching route is used in the delivery attempt. If no routes match, which is the default with no configured routes, messages are delivered directly from the queue."`
 	Aliases                     map[string]Alias `sconf:"optional" sconf-doc:"Aliases that cause messages to be delivered to one or more locally configured addresses. Keys are localparts (encoded, as they appear in email addresses)."`
+	Footer                      *Footer          `sconf:"optional" sconf-doc:"Footer, e.g. a legal disclaimer, added to outgoing messages with a message From address of this domain, at submission (SMTP, webmail, webapi) and before DKIM signing. For multipart/alternative messages, the footer is added to both the text and HTML alternatives. Messages that already have a DKIM-Signature, are signed or encrypted, or are automatically generated (Auto-Submitted header) are not changed."`
+	DNSBLs                      []string         `sconf:"optional" sconf-doc:"Addresses of DNS block lists for incoming messages for this domain. If non-empty, these are used instead of the DNSBLs of the SMTP listener for deliveries to recipients in this domain. Example DNSBLs: sbl.spamhaus.org, bl.spamcop.net."`
+	SPFIncludes                 []string         `sconf:"optional" sconf-doc:"Domains to add as include mechanisms to the suggested SPF record for this domain, for third parties that send messages for this domain, e.g. _spf.crm.example. Mox does not use these itself, they are only added to the DNS records suggested for the domain."`
 
 	Domain                  dns.Domain `sconf:"-"`
 	ClientSettingsDNSDomain dns.Domain `sconf:"-" json:"-"`
@@ -315,6 +341,14 @@ type Domain struct {
 	// the domain. Disables some functionality related to hosting a domain.
 	ReportsOnly                          bool     `sconf:"-" json:"-"`
 	LocalpartCatchallSeparatorsEffective []string `sconf:"-"` // Either LocalpartCatchallSeparators, the value of LocalpartCatchallSeparator, or empty.
+
+	DNSBLZones        []dns.Domain `sconf:"-" json:"-"` // Parsed DNSBLs.
+	SPFIncludeDomains []dns.Domain `sconf:"-" json:"-"` // Parsed SPFIncludes.
+}
+
+type Footer struct {
+	Text string `sconf:"optional" sconf-doc:"Text added to the end of text/plain parts, after an empty line. At most 4096 bytes."`
+	HTML string `sconf:"optional" sconf-doc:"HTML added before the closing body tag of text/html parts, or to the end if there is no closing body tag. At most 4096 bytes."`
 }
 
 // todo: allow external addresses as members of aliases. we would add messages for them to the queue for outgoing delivery. we should require an admin addresses to which delivery failures will be delivered (locally, and to use in smtp mail from, so dsns go there). also take care to evaluate smtputf8 (if external address requires utf8 and incoming transaction didn't).
@@ -327,9 +361,12 @@ type Alias struct {
 	ListMembers  bool     `sconf:"optional" sconf-doc:"If true, members can see addresses of members."`
 	AllowMsgFrom bool     `sconf:"optional" sconf-doc:"If true, members are allowed to send messages with this alias address in the message From header."`
 
-	LocalpartStr    string         `sconf:"-"` // In encoded form.
-	Domain          dns.Domain     `sconf:"-"`
-	ParsedAddresses []AliasAddress `sconf:"-"` // Matches addresses.
+	AllowMsgFromAddresses []string `sconf:"optional" sconf-doc:"If non-empty, only messages with one of these addresses in the message From header can be sent to the alias, instead of members (and anyone with PostPublic). The addresses do not have to be members or local addresses. Takes precedence over AllowMsgFrom: the alias address itself can only be used as message From address if it is listed."`
+
+	LocalpartStr                string         `sconf:"-"` // In encoded form.
+	Domain                      dns.Domain     `sconf:"-"`
+	ParsedAddresses             []AliasAddress `sconf:"-"` // Matches addresses.
+	ParsedAllowMsgFromAddresses []smtp.Address `sconf:"-"` // Parsed AllowMsgFromAddresses.
 }
 
 type AliasAddress struct {
@@ -344,6 +381,11 @@ type DMARC struct {
 	Account   string `sconf-doc:"Account to deliver to."`
 	Mailbox   string `sconf-doc:"Mailbox to deliver to, e.g. DMARC."`
 
+	Policy                        string   `sconf:"optional" sconf-doc:"Policy for messages failing DMARC in the DMARC DNS record suggested for the domain: none, quarantine or reject. Default reject."`
+	Percentage                    int      `sconf:"optional" sconf-doc:"Percentage of messages failing DMARC that the policy applies to, in the suggested DNS record. Between 0 and 100, with 0 for the default of 100."`
+	ExtraAggregateReportAddresses []string `sconf:"optional" sconf-doc:"Additional addresses to request aggregate reports to be sent to in the suggested DNS record, e.g. of an external DMARC report processor. Domains of external addresses must publish a DNS record that authorizes receiving reports for this domain."`
+	FailureReportAddresses        []string `sconf:"optional" sconf-doc:"Addresses to request failure reports to be sent to in the suggested DNS record. Mox does not process failure reports. Domains of external addresses must publish a DNS record that authorizes receiving reports for this domain."`
+
 	ParsedLocalpart smtp.Localpart `sconf:"-"` // Lower-case if case-sensitivity is not configured for domain. Not "canonical" for catchall separators for backwards compatibility.
 	DNSDomain       dns.Domain     `sconf:"-"` // Effective domain, always set based on Domain field or Domain where this is configured.
 }
@@ -380,8 +422,9 @@ type Selector struct {
 	DontSealHeaders  bool             `sconf:"optional" sconf-doc:"If set, don't prevent duplicate headers from being added. Not recommended."`
 	Expiration       string           `sconf:"optional" sconf-doc:"Period a signature is valid after signing, as duration, e.g. 72h. The period should be enough for delivery at the final destination, potentially with several hops/relays. In the order of days at least."`
 	PrivateKeyFile   string           `sconf-doc:"Either an RSA or ed25519 private key file in PKCS8 PEM form."`
+	Disabled         bool             `sconf:"optional" sconf-doc:"If set, messages are not signed with this selector, even if it is listed in Sign. For quickly stopping use of a key, e.g. after it was misused, without removing it."`
 
-	Algorithm         string        `sconf:"-"`          // "ed25519", "rsa-*", based on private key.
+	Algorithm         string        `sconf:"-"`          // "ed25519", "rsa-*", "ecdsa-p256", based on private key.
 	ExpirationSeconds int           `sconf:"-" json:"-"` // Parsed from Expiration.
 	Key               crypto.Signer `sconf:"-" json:"-"` // As parsed with x509.ParsePKCS8PrivateKey.
 	Domain            dns.Domain    `sconf:"-" json:"-"` // Of selector only, not FQDN.
@@ -442,15 +485,17 @@ type Account struct {
 	Domain                       string                 `sconf-doc:"Default domain for account. Deprecated behaviour: If a destination is not a full address but only a localpart, this domain is added to form a full address."`
 	Description                  string                 `sconf:"optional" sconf-doc:"Free form description, e.g. full name or alternative contact info."`
 	FullName                     string                 `sconf:"optional" sconf-doc:"Full name, to use in message From header when composing messages in webmail. Can be overridden per destination."`
-	Destinations                 map[string]Destination `sconf:"optional" sconf-doc:"Destinations, keys are email addresses (with IDNA domains). All destinations are allowed for logging in with IMAP/SMTP/webmail. If no destinations are configured, the account can not login. If the address is of the form '@domain', i.e. with localpart missing, it serves as a catchall for the domain, matching all messages that are not explicitly configured. Deprecated behaviour: If the address is not a full address but a localpart, it is combined with Domain to form a full address."`
+	Destinations                 map[string]Destination `sconf:"optional" sconf-doc:"Destinations, keys are email addresses (with IDNA domains). All destinations are allowed for logging in with IMAP/SMTP/webmail. If no destinations are configured, the account can not login. If the address is of the form '@domain', i.e. with localpart missing, it serves as a catchall for the domain, matching all messages that are not explicitly configured. An address of the form '@.domain' is a catchall for the domain and all its subdomains that are not configured as domains themselves, with a catchall '@domain' taking precedence for the domain itself. Deprecated behaviour: If the address is not a full address but a localpart, it is combined with Domain to form a full address."`
 	SubjectPass                  SubjectPass            `sconf:"optional" sconf-doc:"If configured, messages classified as weakly spam are rejected with instructions to retry delivery, but this time with a signed token added to the subject. During the next delivery attempt, the signed token will bypass the spam filter. Messages with a clear spam signal, such as a known bad reputation, are rejected/delayed without a signed token."`
 	QuotaMessageSize             int64                  `sconf:"optional" sconf-doc:"Default maximum total message size in bytes for the account, overriding any globally configured default maximum size if non-zero. A negative value can be used to have no limit in case there is a limit by default. Attempting to add new messages to an account beyond its maximum total size will result in an error. Useful to prevent a single account from filling storage."`
+	QuotaMessageCount            int                    `sconf:"optional" sconf-doc:"Maximum number of messages for the account, only applicable if greater than zero. Attempting to add new messages to an account with this many messages will result in an error."`
+	MaxMailboxes                 int                    `sconf:"optional" sconf-doc:"Maximum number of mailboxes for the account, overriding any globally configured default maximum if non-zero. A negative value can be used to have no limit in case there is a limit by default. Creating mailboxes beyond the maximum, e.g. with IMAP CREATE, results in an error. Messages for mailboxes from delivery rulesets that cannot be created are delivered to the Inbox."`
 	RejectsMailbox               string                 `sconf:"optional" sconf-doc:"Mail that looks like spam will be rejected, but a copy can be stored temporarily in a mailbox, e.g. Rejects. If mail isn't coming in when you expect, you can look there. The mail still isn't accepted, so the remote mail server may retry (hopefully, if legitimate), or give up (hopefully, if indeed a spammer). Messages are automatically removed from this mailbox, so do not set it to a mailbox that has messages you want to keep."`
 	KeepRejects                  bool                   `sconf:"optional" sconf-doc:"Don't automatically delete mail in the RejectsMailbox listed above. This can be useful, e.g. for future spam training. It can also cause storage to fill up."`
 	AutomaticJunkFlags           AutomaticJunkFlags     `sconf:"optional" sconf-doc:"Automatically set $Junk and $NotJunk flags based on mailbox messages are delivered/moved/copied to. Email clients typically have too limited functionality to conveniently set these flags, especially $NonJunk, but they can all move messages to a different mailbox, so this helps them."`
 	JunkFilter                   *JunkFilter            `sconf:"optional" sconf-doc:"Content-based filtering, using the junk-status of individual messages to rank words in such messages as spam or ham. It is recommended you always set the applicable (non)-junk status on messages, and that you do not empty your Trash because those messages contain valuable ham/spam training information."` // todo: sane defaults for junkfilter
-	MaxOutgoingMessagesPerDay    int                    `sconf:"optional" sconf-doc:"Maximum number of outgoing messages for this account in a 24 hour window. This limits the damage to recipients and the reputation of this mail server in case of account compromise. Default 1000."`
-	MaxFirstTimeRecipientsPerDay int                    `sconf:"optional" sconf-doc:"Maximum number of first-time recipients in outgoing messages for this account in a 24 hour window. This limits the damage to recipients and the reputation of this mail server in case of account compromise. Default 200."`
+	MaxOutgoingMessagesPerDay    int                    `sconf:"optional" sconf-doc:"Maximum number of outgoing messages for this account in a 24 hour window. This limits the damage to recipients and the reputation of this mail server in case of account compromise. Default 1000. A negative value means no limit."`
+	MaxFirstTimeRecipientsPerDay int                    `sconf:"optional" sconf-doc:"Maximum number of first-time recipients in outgoing messages for this account in a 24 hour window. This limits the damage to recipients and the reputation of this mail server in case of account compromise. Default 200. A negative value means no limit."`
 	NoFirstTimeSenderDelay       bool                   `sconf:"optional" sconf-doc:"Do not apply a delay to SMTP connections before accepting an incoming message from a first-time sender. Can be useful for accounts that sends automated responses and want instant replies."`
 	NoCustomPassword             bool                   `sconf:"optional" sconf-doc:"If set, this account cannot set a password of their own choice, but can only set a new randomly generated password, preventing password reuse across services and use of weak passwords. Custom account passwords can be set by the admin."`
 	IMAPCapabilitiesDisabled     []string               `sconf:"optional" sconf-doc:"IMAP capabilities (upper-case) to disable on the connection after authentication. Useful if the account uses an email client with an incompatible implementation for a capability/extension."`
@@ -483,6 +528,7 @@ type Destination struct {
 	SMTPError                    string    `sconf:"optional" sconf-doc:"If non-empty, incoming delivery attempts to this destination will be rejected during SMTP RCPT TO with this error response line. Useful when a catchall address is configured for the domain and messages to some addresses should be rejected. The response line must start with an error code. Currently the following error resonse codes are allowed: 421 (temporary local error), 550 (user not found). If the line consists of only an error code, an appropriate error message is added. Rejecting messages with a 4xx code invites later retries by the remote, while 5xx codes should prevent further delivery attempts."`
 	MessageAuthRequiredSMTPError string    `sconf:"optional" sconf-doc:"If non-empty, an additional DMARC-like message authentication check is done for incoming messages, validating the domain in the From-header of the message. Messages without either an aligned SPF or aligned DKIM pass are rejected during the SMTP DATA command with a permanent error code followed by the message in this field. The domain in the message 'From' header is matched in relaxed or strict mode according to the domain's DMARC policy if present, or relaxed mode (organizational instead of exact domain match) otherwise. Useful for autoresponders that don't want to accept messages they don't want to send an automated reply to."`
 	FullName                     string    `sconf:"optional" sconf-doc:"Full name to use in message From header when composing messages coming from this address with webmail."`
+	Forward                      []string  `sconf:"optional" sconf-doc:"Addresses to forward a copy of incoming messages to, after they are delivered to the account. Forwarded messages are sent with this destination address as SMTP MAIL FROM, so SPF verification at the forward targets is done for this host. The message is not modified, so DKIM signatures of the original message remain valid and can still give a DMARC pass. Messages from senders without DKIM signatures may be rejected or marked as spam at the forward targets due to failing DMARC. Be careful to not create forwarding loops."`
 
 	DMARCReports     bool `sconf:"-" json:"-"`
 	HostTLSReports   bool `sconf:"-" json:"-"`
@@ -518,8 +564,9 @@ type Ruleset struct {
 	ListAllowDomain        string `sconf:"optional" sconf-doc:"Influences spam filtering only, this option does not change whether a message matches this ruleset. If this domain matches an SPF- and/or DKIM-verified (sub)domain, the message is accepted without further spam checks, such as a junk filter or DMARC reject evaluation. DMARC rejects should not apply for mailing lists that are not configured to rewrite the From-header of messages that don't have a passing DKIM signature of the From-domain. Otherwise, by rejecting messages, you may be automatically unsubscribed from the mailing list. The assumption is that mailing lists do their own spam filtering/moderation."`
 	AcceptRejectsToMailbox string `sconf:"optional" sconf-doc:"Influences spam filtering only, this option does not change whether a message matches this ruleset. If a message is classified as spam, it isn't rejected during the SMTP transaction (the normal behaviour), but accepted during the SMTP transaction and delivered to the specified mailbox. The specified mailbox is not automatically cleaned up like the account global Rejects mailbox, unless set to that Rejects mailbox."`
 
-	Mailbox string `sconf-doc:"Mailbox to deliver to if this ruleset matches."`
-	Comment string `sconf:"optional" sconf-doc:"Free-form comments."`
+	Mailbox  string `sconf-doc:"Mailbox to deliver to if this ruleset matches."`
+	Comment  string `sconf:"optional" sconf-doc:"Free-form comments."`
+	Disabled bool   `sconf:"optional" sconf-doc:"If set, the ruleset is skipped when evaluating rulesets for incoming messages, e.g. while debugging delivery to mailboxes."`
 
 	SMTPMailFromRegexpCompiled *regexp.Regexp      `sconf:"-" json:"-"`
 	MsgFromRegexpCompiled      *regexp.Regexp      `sconf:"-" json:"-"`
@@ -530,7 +577,7 @@ type Ruleset struct {
 
 // Equal returns whether r and o are equal, only looking at their user-changeable fields.
 func (r Ruleset) Equal(o Ruleset) bool {
-	if r.SMTPMailFromRegexp != o.SMTPMailFromRegexp || r.MsgFromRegexp != o.MsgFromRegexp || r.VerifiedDomain != o.VerifiedDomain || r.IsForward != o.IsForward || r.ListAllowDomain != o.ListAllowDomain || r.AcceptRejectsToMailbox != o.AcceptRejectsToMailbox || r.Mailbox != o.Mailbox || r.Comment != o.Comment {
+	if r.SMTPMailFromRegexp != o.SMTPMailFromRegexp || r.MsgFromRegexp != o.MsgFromRegexp || r.VerifiedDomain != o.VerifiedDomain || r.IsForward != o.IsForward || r.ListAllowDomain != o.ListAllowDomain || r.AcceptRejectsToMailbox != o.AcceptRejectsToMailbox || r.Mailbox != o.Mailbox || r.Comment != o.Comment || r.Disabled != o.Disabled {
 		return false
 	}
 	if !reflect.DeepEqual(r.HeadersRegexp, o.HeadersRegexp) {
@@ -79,6 +79,13 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 	# result in errors instead of accepting such behaviour. (optional)
 	Pedantic: false
 
+	# If enabled, mox checks at startup that the configured DNS resolvers verify
+	# DNSSEC, i.e. set the authentic data bit in responses for DNSSEC-signed zones,
+	# and refuses to start if they do not. Without DNSSEC-verifying resolvers, MX
+	# records are not protected and DANE cannot be used for outgoing deliveries.
+	# (optional)
+	DNSSECRequired: false
+
 	# Global TLS configuration, e.g. for additional Certificate Authorities. Used for
 	# outgoing SMTP connections, HTTPS requests. (optional)
 	TLS:
@@ -181,6 +188,20 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 			# (optional)
 			Hostname:
 
+			# If set, connections for SMTP, Submission, Submissions, IMAP and IMAPS from the
+			# trusted networks must start with a PROXY protocol (version 1 or 2) header, as
+			# sent by load balancers such as HAProxy. The source IP address from the header is
+			# used as the remote IP address for logging, rate limiting, DNSBL and reputation
+			# checks. Connections from other networks are handled as regular connections,
+			# their PROXY protocol headers are not honored. (optional)
+			ProxyProtocol:
+
+				# IP networks in CIDR notation, e.g. 192.0.2.0/24 or 2001:db8::/64, of load
+				# balancers that are trusted to send a PROXY protocol header. A single IP address
+				# is also accepted. Must be non-empty.
+				TrustedNetworks:
+					-
+
 			# For SMTP/IMAP STARTTLS, direct TLS and HTTPS connections. (optional)
 			TLS:
 
@@ -785,6 +806,26 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 	# (optional)
 	QuotaMessageSize: 0
 
+	# Default maximum number of mailboxes for each individual account, only applicable
+	# if greater than zero. Can be overridden per account. Prevents email clients from
+	# creating an excessive number of mailboxes. (optional)
+	MaxMailboxes: 0
+
+	# Reuse outgoing SMTP connections for direct delivery to the configured recipient
+	# domains. After delivering, the connection is kept open for a short while, and
+	# used for a next delivery to the same host for the same recipient domain. Useful
+	# for bulk delivery to a few large mail providers. Regardless of this setting,
+	# SMTP PIPELINING is used when announced by the remote server. (optional)
+	ConnectionReuse:
+
+		# Recipient domains for which connections are reused, ASCII or unicode.
+		Domains:
+			-
+
+		# How long an idle connection is kept open for reuse. Default 30s, maximum 5m.
+		# (optional)
+		IdleTime: 0s
+
 # domains.conf
 
 	# NOTE: This config file is in 'sconf' format. Indent with tabs. Comments must be
@@ -877,6 +918,11 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 						# Either an RSA or ed25519 private key file in PKCS8 PEM form.
 						PrivateKeyFile:
 
+						# If set, messages are not signed with this selector, even if it is listed in
+						# Sign. For quickly stopping use of a key, e.g. after it was misused, without
+						# removing it. (optional)
+						Disabled: false
+
 				# List of selectors that emails will be signed with. (optional)
 				Sign:
 					-
@@ -913,6 +959,28 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 				# Mailbox to deliver to, e.g. DMARC.
 				Mailbox:
 
+				# Policy for messages failing DMARC in the DMARC DNS record suggested for the
+				# domain: none, quarantine or reject. Default reject. (optional)
+				Policy:
+
+				# Percentage of messages failing DMARC that the policy applies to, in the
+				# suggested DNS record. Between 0 and 100, with 0 for the default of 100.
+				# (optional)
+				Percentage: 0
+
+				# Additional addresses to request aggregate reports to be sent to in the suggested
+				# DNS record, e.g. of an external DMARC report processor. Domains of external
+				# addresses must publish a DNS record that authorizes receiving reports for this
+				# domain. (optional)
+				ExtraAggregateReportAddresses:
+					-
+
+				# Addresses to request failure reports to be sent to in the suggested DNS record.
+				# Mox does not process failure reports. Domains of external addresses must publish
+				# a DNS record that authorizes receiving reports for this domain. (optional)
+				FailureReportAddresses:
+					-
+
 			# MTA-STS is a mechanism that allows publishing a policy with requirements for
 			# WebPKI-verified SMTP STARTTLS connections for email delivered to a domain.
 			# Existence of a policy is announced in a DNS TXT record (often
@@ -1030,6 +1098,44 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 					# message From header. (optional)
 					AllowMsgFrom: false
 
+					# If non-empty, only messages with one of these addresses in the message From
+					# header can be sent to the alias, instead of members (and anyone with
+					# PostPublic). The addresses do not have to be members or local addresses. Takes
+					# precedence over AllowMsgFrom: the alias address itself can only be used as
+					# message From address if it is listed. (optional)
+					AllowMsgFromAddresses:
+						-
+
+			# Footer, e.g. a legal disclaimer, added to outgoing messages with a message From
+			# address of this domain, at submission (SMTP, webmail, webapi) and before DKIM
+			# signing. For multipart/alternative messages, the footer is added to both the
+			# text and HTML alternatives. Messages that already have a DKIM-Signature, are
+			# signed or encrypted, or are automatically generated (Auto-Submitted header) are
+			# not changed. (optional)
+			Footer:
+
+				# Text added to the end of text/plain parts, after an empty line. At most 4096
+				# bytes. (optional)
+				Text:
+
+				# HTML added before the closing body tag of text/html parts, or to the end if
+				# there is no closing body tag. At most 4096 bytes. (optional)
+				HTML:
+
+			# Addresses of DNS block lists for incoming messages for this domain. If
+			# non-empty, these are used instead of the DNSBLs of the SMTP listener for
+			# deliveries to recipients in this domain. Example DNSBLs: sbl.spamhaus.org,
+			# bl.spamcop.net. (optional)
+			DNSBLs:
+				-
+
+			# Domains to add as include mechanisms to the suggested SPF record for this
+			# domain, for third parties that send messages for this domain, e.g.
+			# _spf.crm.example. Mox does not use these itself, they are only added to the DNS
+			# records suggested for the domain. (optional)
+			SPFIncludes:
+				-
+
 	# Accounts represent mox users, each with a password and email address(es) to
 	# which email can be delivered (possibly at different domains). Each account has
 	# its own on-disk directory holding its messages and index database. An account
@@ -1107,9 +1213,12 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 			# allowed for logging in with IMAP/SMTP/webmail. If no destinations are
 			# configured, the account can not login. If the address is of the form '@domain',
 			# i.e. with localpart missing, it serves as a catchall for the domain, matching
-			# all messages that are not explicitly configured. Deprecated behaviour: If the
-			# address is not a full address but a localpart, it is combined with Domain to
-			# form a full address. (optional)
+			# all messages that are not explicitly configured. An address of the form
+			# '@.domain' is a catchall for the domain and all its subdomains that are not
+			# configured as domains themselves, with a catchall '@domain' taking precedence
+			# for the domain itself. Deprecated behaviour: If the address is not a full
+			# address but a localpart, it is combined with Domain to form a full address.
+			# (optional)
 			Destinations:
 				x:
 
@@ -1184,6 +1293,10 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 							# Free-form comments. (optional)
 							Comment:
 
+							# If set, the ruleset is skipped when evaluating rulesets for incoming messages,
+							# e.g. while debugging delivery to mailboxes. (optional)
+							Disabled: false
+
 					# If non-empty, incoming delivery attempts to this destination will be rejected
 					# during SMTP RCPT TO with this error response line. Useful when a catchall
 					# address is configured for the domain and messages to some addresses should be
@@ -1210,6 +1323,16 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 					# address with webmail. (optional)
 					FullName:
 
+					# Addresses to forward a copy of incoming messages to, after they are delivered to
+					# the account. Forwarded messages are sent with this destination address as SMTP
+					# MAIL FROM, so SPF verification at the forward targets is done for this host. The
+					# message is not modified, so DKIM signatures of the original message remain valid
+					# and can still give a DMARC pass. Messages from senders without DKIM signatures
+					# may be rejected or marked as spam at the forward targets due to failing DMARC.
+					# Be careful to not create forwarding loops. (optional)
+					Forward:
+						-
+
 			# If configured, messages classified as weakly spam are rejected with instructions
 			# to retry delivery, but this time with a signed token added to the subject.
 			# During the next delivery attempt, the signed token will bypass the spam filter.
@@ -1227,6 +1350,18 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 			# Useful to prevent a single account from filling storage. (optional)
 			QuotaMessageSize: 0
 
+			# Maximum number of messages for the account, only applicable if greater than
+			# zero. Attempting to add new messages to an account with this many messages will
+			# result in an error. (optional)
+			QuotaMessageCount: 0
+
+			# Maximum number of mailboxes for the account, overriding any globally configured
+			# default maximum if non-zero. A negative value can be used to have no limit in
+			# case there is a limit by default. Creating mailboxes beyond the maximum, e.g.
+			# with IMAP CREATE, results in an error. Messages for mailboxes from delivery
+			# rulesets that cannot be created are delivered to the Inbox. (optional)
+			MaxMailboxes: 0
+
 			# Mail that looks like spam will be rejected, but a copy can be stored temporarily
 			# in a mailbox, e.g. Rejects. If mail isn't coming in when you expect, you can
 			# look there. The mail still isn't accepted, so the remote mail server may retry
@@ -1303,12 +1438,13 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 
 			# Maximum number of outgoing messages for this account in a 24 hour window. This
 			# limits the damage to recipients and the reputation of this mail server in case
-			# of account compromise. Default 1000. (optional)
+			# of account compromise. Default 1000. A negative value means no limit. (optional)
 			MaxOutgoingMessagesPerDay: 0
 
 			# Maximum number of first-time recipients in outgoing messages for this account in
 			# a 24 hour window. This limits the damage to recipients and the reputation of
-			# this mail server in case of account compromise. Default 200. (optional)
+			# this mail server in case of account compromise. Default 200. A negative value
+			# means no limit. (optional)
 			MaxFirstTimeRecipientsPerDay: 0
 
 			# Do not apply a delay to SMTP connections before accepting an incoming message
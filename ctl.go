@@ -1085,21 +1085,8 @@ func servectlcmd(ctx context.Context, xctl *ctl, cid int64, shutdown func()) {
 		account := xctl.xread()
 		message := xctl.xread()
 
-		acc, err := store.OpenAccount(log, account, false)
-		xctl.xcheck(err, "open account")
-		defer func() {
-			err := acc.Close()
-			log.Check(err, "closing account")
-		}()
-
-		err = admin.AccountSave(ctx, account, func(acc *config.Account) {
-			acc.LoginDisabled = message
-		})
-		xctl.xcheck(err, "saving account")
-
-		err = acc.SessionsClear(ctx, xctl.log)
-		xctl.xcheck(err, "clearing active web sessions")
-
+		err := admin.AccountLoginDisable(ctx, account, message)
+		xctl.xcheck(err, "disabling account")
 		xctl.xwriteok()
 
 	case "accountenable":
@@ -1109,9 +1096,7 @@ func servectlcmd(ctx context.Context, xctl *ctl, cid int64, shutdown func()) {
 		< "ok" or error
 		*/
 		account := xctl.xread()
-		err := admin.AccountSave(ctx, account, func(acc *config.Account) {
-			acc.LoginDisabled = ""
-		})
+		err := admin.AccountLoginDisable(ctx, account, "")
 		xctl.xcheck(err, "enabling account")
 		xctl.xwriteok()
 
@@ -1269,6 +1254,9 @@ func servectlcmd(ctx context.Context, xctl *ctl, cid int64, shutdown func()) {
 		fmt.Fprintf(xw, "# postpublic %v\n", alias.PostPublic)
 		fmt.Fprintf(xw, "# listmembers %v\n", alias.ListMembers)
 		fmt.Fprintf(xw, "# allowmsgfrom %v\n", alias.AllowMsgFrom)
+		if len(alias.AllowMsgFromAddresses) > 0 {
+			fmt.Fprintf(xw, "# allowmsgfromaddresses %s\n", strings.Join(alias.AllowMsgFromAddresses, " "))
+		}
 		fmt.Fprintln(xw, "# members:")
 		for _, a := range alias.Addresses {
 			fmt.Fprintln(xw, a)
@@ -1288,7 +1276,7 @@ func servectlcmd(ctx context.Context, xctl *ctl, cid int64, shutdown func()) {
 		xctl.xcheck(err, "parsing address")
 		var alias config.Alias
 		xparseJSON(xctl, line, &alias)
-		err = admin.AliasAdd(ctx, addr, alias)
+		_, err = admin.AliasAdd(ctx, addr, alias, false)
 		xctl.xcheck(err, "adding alias")
 		xctl.xwriteok()
 
@@ -1365,7 +1353,7 @@ func servectlcmd(ctx context.Context, xctl *ctl, cid int64, shutdown func()) {
 		xctl.xcheck(err, "parsing address")
 		var addresses []string
 		xparseJSON(xctl, line, &addresses)
-		err = admin.AliasAddressesAdd(ctx, addr, addresses)
+		_, err = admin.AliasAddressesAdd(ctx, addr, addresses, false)
 		xctl.xcheck(err, "adding addresses to alias")
 		xctl.xwriteok()
 
@@ -14,6 +14,7 @@ import (
 	"bytes"
 	"context"
 	"crypto"
+	"crypto/ecdsa"
 	"crypto/ed25519"
 	cryptorand "crypto/rand"
 	"crypto/rsa"
@@ -120,7 +121,7 @@ type Selector struct {
 	// several hops/relays. In the order of days at least.
 	Expiration time.Duration
 
-	PrivateKey crypto.Signer // Either an *rsa.PrivateKey or ed25519.PrivateKey.
+	PrivateKey crypto.Signer // An *rsa.PrivateKey, ed25519.PrivateKey or *ecdsa.PrivateKey (P-256).
 	Domain     dns.Domain    // Of selector only, not FQDN.
 }
 
@@ -167,6 +168,10 @@ func Sign(ctx context.Context, elog *slog.Logger, localpart smtp.Localpart, doma
 		case ed25519.PrivateKey:
 			sig.AlgorithmSign = "ed25519"
 			MetricSign.IncLabels("ed25519")
+		case *ecdsa.PrivateKey:
+			// Not standardized, only for use between parties that agree on it.
+			sig.AlgorithmSign = "ecdsa"
+			MetricSign.IncLabels("ecdsa")
 		default:
 			return "", fmt.Errorf("internal error, unknown pivate key %T", sel.PrivateKey)
 		}
@@ -255,6 +260,12 @@ func Sign(ctx context.Context, elog *slog.Logger, localpart smtp.Localpart, doma
 			if err != nil {
 				return "", fmt.Errorf("signing data: %v", err)
 			}
+		case *ecdsa.PrivateKey:
+			// ASN.1 DER-encoded signature over the hash.
+			sig.Signature, err = key.Sign(cryptorand.Reader, dh, h)
+			if err != nil {
+				return "", fmt.Errorf("signing data: %v", err)
+			}
 		default:
 			return "", fmt.Errorf("unsupported private key type: %s", err)
 		}
@@ -593,6 +604,10 @@ func verifySignatureRecord(r *Record, sig *Sig, hash crypto.Hash, canonHeaderSim
 		if ok := ed25519.Verify(k, dh, sig.Signature); !ok {
 			return StatusFail, fmt.Errorf("%w: ed25519 verification", ErrSigVerify)
 		}
+	case *ecdsa.PublicKey:
+		if ok := ecdsa.VerifyASN1(k, dh, sig.Signature); !ok {
+			return StatusFail, fmt.Errorf("%w: ecdsa verification", ErrSigVerify)
+		}
 	default:
 		return StatusPermerror, fmt.Errorf("%w: unrecognized signature algorithm %q", ErrSigAlgorithmUnknown, r.Key)
 	}
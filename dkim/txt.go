@@ -1,7 +1,9 @@
 package dkim
 
 import (
+	"crypto/ecdsa"
 	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/base64"
@@ -22,13 +24,13 @@ import (
 type Record struct {
 	Version  string   // Version, fixed "DKIM1" (case sensitive). Field "v".
 	Hashes   []string // Acceptable hash algorithms, e.g. "sha1", "sha256". Optional, defaults to all algorithms. Field "h".
-	Key      string   // Key type, "rsa" or "ed25519". Optional, default "rsa". Field "k".
+	Key      string   // Key type, "rsa", "ed25519" or non-standard "ecdsa" (P-256). Optional, default "rsa". Field "k".
 	Notes    string   // Debug notes. Field "n".
 	Pubkey   []byte   // Public key, as base64 in record. If empty, the key has been revoked. Field "p".
 	Services []string // Service types. Optional, default "*" for all services. Other values: "email". Field "s".
 	Flags    []string // Flags, colon-separated. Optional, default is no flags. Other values: "y" for testing DKIM, "s" for "i=" must have same domain as "d" in signatures. Field "t".
 
-	PublicKey any `json:"-"` // Parsed form of public key, an *rsa.PublicKey or ed25519.PublicKey.
+	PublicKey any `json:"-"` // Parsed form of public key, an *rsa.PublicKey, ed25519.PublicKey or *ecdsa.PublicKey.
 }
 
 // ../rfc/6376:1438
@@ -90,6 +92,12 @@ func (r *Record) Record() (string, error) {
 			}
 		case ed25519.PublicKey:
 			pk = []byte(k)
+		case *ecdsa.PublicKey:
+			var err error
+			pk, err = x509.MarshalPKIXPublicKey(k)
+			if err != nil {
+				return "", fmt.Errorf("marshal ecdsa public key: %v", err)
+			}
 		default:
 			return "", fmt.Errorf("unknown public key type %T", r.PublicKey)
 		}
@@ -270,6 +278,17 @@ func ParseRecord(s string) (record *Record, isdkim bool, err error) {
 		} else {
 			record.PublicKey = ed25519.PublicKey(record.Pubkey)
 		}
+	case "ecdsa":
+		// Not standardized. P-256 public key in PKIX form, like for rsa.
+		if len(record.Pubkey) == 0 {
+			// Revoked key, nothing to do.
+		} else if pk, err := x509.ParsePKIXPublicKey(record.Pubkey); err != nil {
+			xerrorf("%w: %s", errRecordBadPublicKey, err)
+		} else if k, ok := pk.(*ecdsa.PublicKey); !ok || k.Curve != elliptic.P256() {
+			xerrorf("%w: got %T, need an ECDSA P-256 key", errRecordBadPublicKey, pk)
+		} else {
+			record.PublicKey = pk
+		}
 	default:
 		xerrorf("%w: %q", errRecordUnknownAlgorithm, record.Key)
 	}
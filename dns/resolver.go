@@ -75,6 +75,24 @@ var _ Resolver = StrictResolver{}
 
 var ErrRelativeDNSName = errors.New("dns: host to lookup must be absolute, ending with a dot")
 
+// ErrResolverNoDNSSEC is returned by CheckResolverDNSSEC for resolvers that do not
+// verify DNSSEC.
+var ErrResolverNoDNSSEC = errors.New("dns: resolver does not verify dnssec")
+
+// CheckResolverDNSSEC checks whether resolver verifies DNSSEC, by looking up a
+// record in a DNSSEC-signed zone and checking the response is authentic. Some
+// DNSSEC-verifying resolvers return unauthentic data for ".", so "com." is
+// looked up. ErrResolverNoDNSSEC is returned if the response is not authentic.
+func CheckResolverDNSSEC(ctx context.Context, resolver Resolver) error {
+	_, result, err := resolver.LookupNS(ctx, "com.")
+	if err != nil {
+		return fmt.Errorf("looking up ns records for com.: %w", err)
+	} else if !result.Authentic {
+		return ErrResolverNoDNSSEC
+	}
+	return nil
+}
+
 func metricLookupObserve(pkg, typ string, err error, start time.Time) {
 	var result string
 	var dnsErr *adns.DNSError
@@ -20,7 +20,7 @@ any parameters. Followed by the help and usage information for each command.
 
 	mox [-config config/mox.conf] [-pedantic] ...
 	mox serve
-	mox quickstart [-skipdial] [-existing-webserver] [-hostname host] user@domain [user | uid]
+	mox quickstart [-skipdial] [-existing-webserver] [-hostname host] [-no-dnsbl | -dnsbl zone ...] [-acme-directory url] [-acme-contact email] [-tls-cert certfile -tls-key keyfile] user@domain [user@domain ...] [user | uid]
 	mox stop
 	mox setaccountpassword account
 	mox setadminpassword
@@ -151,11 +151,18 @@ Only implemented on unix systems, not Windows.
 Quickstart generates configuration files and prints instructions to quickly set up a mox instance.
 
 Quickstart writes configuration files, prints initial admin and account
-passwords, DNS records you should create. If you run it on Linux it writes a
-systemd service file and prints commands to enable and start mox as service.
+passwords, DNS records you should create. It writes a service file and prints
+commands to enable and start mox as service: a systemd service file on Linux,
+an rc.d script on FreeBSD and a launchd plist on macOS.
 
 All output is written to quickstart.log for later reference.
 
+Multiple email addresses can be specified to configure multiple domains from the
+start. The first address is used for the primary domain, its account receives
+messages for postmaster. Each further address configures an additional domain,
+with its own DKIM keys, and an account named after the localpart of the address.
+Addresses with the same localpart are added to a single account.
+
 The user or uid is optional, defaults to "mox", and is the user or uid/gid mox
 will run as after initialization.
 
@@ -168,7 +175,9 @@ you specified. Use flag -hostname to explicitly specify the hostname mox will
 run on.
 
 Mox is by far easiest to operate if you let it listen on port 443 (HTTPS) and
-80 (HTTP). TLS will be fully automatic with ACME with Let's Encrypt.
+80 (HTTP). TLS will be fully automatic with ACME with Let's Encrypt. Use flag
+-acme-directory for a different ACME provider, or flags -tls-cert and -tls-key
+to use an existing TLS certificate instead of ACME.
 
 You can run mox along with an existing webserver, but because of MTA-STS and
 autoconfig, you'll need to forward HTTPS traffic for two domains to mox. Run
@@ -183,13 +192,27 @@ traffic to your existing backend applications. Look for "WebHandlers:" in the
 output of "mox config describe-domains" and see the output of
 "mox config example webhandlers".
 
-	usage: mox quickstart [-skipdial] [-existing-webserver] [-hostname host] user@domain [user | uid]
+	usage: mox quickstart [-skipdial] [-existing-webserver] [-hostname host] [-no-dnsbl | -dnsbl zone ...] [-acme-directory url] [-acme-contact email] [-tls-cert certfile -tls-key keyfile] user@domain [user@domain ...] [user | uid]
+	  -acme-contact string
+	    	email address to register at the acme provider, instead of the email address for the new account
+	  -acme-directory string
+	    	directory url of acme provider to request tls certificates from, instead of let's encrypt
+	  -dnsbl value
+	    	DNS block list zone to use for checking the host IPs, for monitoring and for incoming deliveries, instead of the default suggested (commented out) lists; can be specified multiple times
+	  -dnssec-required
+	    	require the dns resolvers to verify dnssec: quickstart fails if they don't, and the generated config makes mox refuse to start if they don't
 	  -existing-webserver
 	    	use if a webserver is already running, so mox won't listen on port 80 and 443; you'll have to provide tls certificates/keys, and configure the existing webserver as reverse proxy, forwarding requests to mox.
 	  -hostname string
 	    	hostname mox will run on, by default the hostname of the machine quickstart runs on; if specified, the IPs for the hostname are configured for the public listener
+	  -no-dnsbl
+	    	do not check the host IPs in DNS block lists, and do not configure (commented out) DNS block lists for incoming deliveries or for monitoring
 	  -skipdial
 	    	skip check for outgoing smtp (port 25) connectivity or for domain age with rdap
+	  -tls-cert string
+	    	file with pem-encoded tls certificate chain to use for the public listener instead of acme, must be valid for the hostname, and the mta-sts, autoconfig and mail subdomains of the domains; requires -tls-key
+	  -tls-key string
+	    	file with pem-encoded private key for -tls-cert
 
 # mox stop
 
@@ -296,6 +319,10 @@ Prints the message with its ID, last and next delivery attempts, last error.
 	    	number of messages to return
 	  -nextattempt string
 	    	filter by time of next delivery attempt relative to now, value must start with "<" (before now) or ">" (after now)
+	  -recipientdomain string
+	    	exact domain of recipient address, unicode
+	  -senderdomain string
+	    	exact domain of sender address, unicode
 	  -sort value
 	    	field to sort by, "nextattempt" (default) or "queued"
 	  -submitted string
@@ -325,6 +352,10 @@ otherwise handled by the admin.
 	    	number of messages to return
 	  -nextattempt string
 	    	filter by time of next delivery attempt relative to now, value must start with "<" (before now) or ">" (after now)
+	  -recipientdomain string
+	    	exact domain of recipient address, unicode
+	  -senderdomain string
+	    	exact domain of sender address, unicode
 	  -submitted string
 	    	filter by time of submission relative to now, value must start with "<" (before now) or ">" (after now)
 	  -to string
@@ -352,6 +383,10 @@ delivery attempt. See the "queue schedule" command.
 	    	number of messages to return
 	  -nextattempt string
 	    	filter by time of next delivery attempt relative to now, value must start with "<" (before now) or ">" (after now)
+	  -recipientdomain string
+	    	exact domain of recipient address, unicode
+	  -senderdomain string
+	    	exact domain of sender address, unicode
 	  -submitted string
 	    	filter by time of submission relative to now, value must start with "<" (before now) or ">" (after now)
 	  -to string
@@ -384,6 +419,10 @@ Schedule immediate delivery with "mox queue schedule -now 0".
 	    	filter by time of next delivery attempt relative to now, value must start with "<" (before now) or ">" (after now)
 	  -now
 	    	schedule for duration relative to current time instead of relative to current next delivery attempt for messages
+	  -recipientdomain string
+	    	exact domain of recipient address, unicode
+	  -senderdomain string
+	    	exact domain of sender address, unicode
 	  -submitted string
 	    	filter by time of submission relative to now, value must start with "<" (before now) or ">" (after now)
 	  -to string
@@ -413,6 +452,10 @@ another mail server or with connections over a SOCKS proxy.
 	    	number of messages to return
 	  -nextattempt string
 	    	filter by time of next delivery attempt relative to now, value must start with "<" (before now) or ">" (after now)
+	  -recipientdomain string
+	    	exact domain of recipient address, unicode
+	  -senderdomain string
+	    	exact domain of sender address, unicode
 	  -submitted string
 	    	filter by time of submission relative to now, value must start with "<" (before now) or ">" (after now)
 	  -to string
@@ -447,6 +490,10 @@ TLS.
 	    	number of messages to return
 	  -nextattempt string
 	    	filter by time of next delivery attempt relative to now, value must start with "<" (before now) or ">" (after now)
+	  -recipientdomain string
+	    	exact domain of recipient address, unicode
+	  -senderdomain string
+	    	exact domain of sender address, unicode
 	  -submitted string
 	    	filter by time of submission relative to now, value must start with "<" (before now) or ">" (after now)
 	  -to string
@@ -475,6 +522,10 @@ contains a line saying the message was canceled by the admin.
 	    	number of messages to return
 	  -nextattempt string
 	    	filter by time of next delivery attempt relative to now, value must start with "<" (before now) or ">" (after now)
+	  -recipientdomain string
+	    	exact domain of recipient address, unicode
+	  -senderdomain string
+	    	exact domain of sender address, unicode
 	  -submitted string
 	    	filter by time of submission relative to now, value must start with "<" (before now) or ">" (after now)
 	  -to string
@@ -502,6 +553,10 @@ the message, use "queue dump" before removing.
 	    	number of messages to return
 	  -nextattempt string
 	    	filter by time of next delivery attempt relative to now, value must start with "<" (before now) or ">" (after now)
+	  -recipientdomain string
+	    	exact domain of recipient address, unicode
+	  -senderdomain string
+	    	exact domain of sender address, unicode
 	  -submitted string
 	    	filter by time of submission relative to now, value must start with "<" (before now) or ">" (after now)
 	  -to string
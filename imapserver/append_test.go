@@ -132,4 +132,15 @@ func testAppend(t *testing.T, uidonly bool) {
 	tclimit.check(err, "write append message")
 	tclimit.response("no")
 	tclimit.xcodeWord("OVERQUOTA")
+
+	tccount := startArgs(t, uidonly, false, false, true, true, "countlimit")
+	defer tccount.close()
+	tccount.login("countlimit@mox.example", password0)
+	tccount.client.Select("inbox")
+	// Two messages are within the message count limit.
+	tccount.transactf("ok", "append inbox {1+}\r\nx {1+}\r\nx")
+	tccount.xuntagged(imapclient.UntaggedExists(2))
+	// Third message would take account past limit.
+	tccount.transactf("no", "append inbox {1+}\r\nx")
+	tccount.xcodeWord("OVERQUOTA")
 }
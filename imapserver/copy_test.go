@@ -82,4 +82,16 @@ func testCopy(t *testing.T, uidonly bool) {
 	// Second message would take account past limit.
 	tclimit.transactf("no", "uid copy 1:* Trash")
 	tclimit.xcodeWord("OVERQUOTA")
+
+	tccount := startArgs(t, uidonly, false, false, true, true, "countlimit")
+	defer tccount.close()
+	tccount.login("countlimit@mox.example", password0)
+	tccount.client.Select("inbox")
+	tccount.transactf("ok", "append inbox {1+}\r\nx")
+	tccount.xuntagged(imapclient.UntaggedExists(1))
+	// Copy of the first message is within the message count limit.
+	tccount.transactf("ok", "uid copy 1 Trash")
+	// Another copy would take account past limit.
+	tccount.transactf("no", "uid copy 1 Trash")
+	tccount.xcodeWord("OVERQUOTA")
 }
@@ -106,3 +106,22 @@ func testCreate(t *testing.T, uidonly bool) {
 	tc.transactf("ok", "create expungebox") // Existed in past.
 	tc.transactf("ok", "delete expungebox") // Gone again.
 }
+
+func TestCreateMaxMailboxes(t *testing.T) {
+	tc := startArgs(t, false, true, false, true, true, "limit")
+	defer tc.close()
+
+	tc.login("limit@mox.example", password0)
+
+	// Account starts with 6 mailboxes, limit is 8.
+	tc.transactf("ok", "create a")
+	tc.transactf("no", "create b/c") // Would need 2 new mailboxes.
+	tc.xcodeWord("LIMIT")
+	tc.transactf("ok", "create b")
+	tc.transactf("no", "create c")
+	tc.xcodeWord("LIMIT")
+
+	// After removing a mailbox, we can create a new one again.
+	tc.transactf("ok", "delete a")
+	tc.transactf("ok", "create c")
+}
@@ -114,4 +114,18 @@ func testMove(t *testing.T, uidonly bool) {
 	} else {
 		tc3.xuntagged(imapclient.UntaggedExpunge(1), imapclient.UntaggedExpunge(1))
 	}
+
+	// Moving messages doesn't change the number of messages, so it is allowed for an
+	// account at its message count limit.
+	tccount := startArgs(t, uidonly, false, false, true, true, "countlimit")
+	defer tccount.close()
+	tccount.login("countlimit@mox.example", password0)
+	tccount.client.Select("inbox")
+	tccount.transactf("ok", "append inbox {1+}\r\nx {1+}\r\nx")
+	tccount.xuntagged(imapclient.UntaggedExists(2))
+	tccount.transactf("ok", "uid move 1:* Trash")
+	// But copying back would take account past limit.
+	tccount.client.Select("Trash")
+	tccount.transactf("no", "uid copy 1 inbox")
+	tccount.xcodeWord("OVERQUOTA")
 }
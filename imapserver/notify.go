@@ -91,7 +91,7 @@ func (n notify) match(c *conn, xtxfn func() *bstore.Tx, mailboxID int64, mailbox
 				}
 
 				for _, rs := range dest.Rulesets {
-					if rs.ListAllowDomain == "" && rs.Mailbox == mailbox {
+					if !rs.Disabled && rs.ListAllowDomain == "" && rs.Mailbox == mailbox {
 						match = true
 						break Match
 					}
@@ -297,6 +297,9 @@ func (c *conn) cmdxReplace(isUID bool, tag, cmd string, p *parser) {
 			}
 
 			err = c.account.MessageAdd(c.log, tx, &mbDst, &nm, file, store.AddOpts{})
+			if errors.Is(err, store.ErrOverQuota) {
+				xusercodeErrorf("OVERQUOTA", "%s", err)
+			}
 			xcheckf(err, "delivering message")
 			newID = nm.ID
 
@@ -238,7 +238,7 @@ func (c *conn) cmdxSearch(isUID, isE bool, tag, cmd string, p *parser) {
 						}
 
 						for _, rs := range dest.Rulesets {
-							if rs.ListAllowDomain != "" || rs.Mailbox == "" {
+							if rs.Disabled || rs.ListAllowDomain != "" || rs.Mailbox == "" {
 								continue
 							}
 
@@ -110,6 +110,8 @@ var limiterConnectionrate, limiterConnections *ratelimit.Limiter
 func init() {
 	// Also called by tests, so they don't trigger the rate limiter.
 	limitersInit()
+
+	mox.ListenerReloaders["imap"] = ListenerReload
 }
 
 func limitersInit() {
@@ -382,9 +384,48 @@ type msgseq uint32
 
 // Listen initializes all imap listeners for the configuration, and stores them for Serve to start them.
 func Listen() {
-	names := slices.Sorted(maps.Keys(mox.Conf.Static.Listeners))
+	log := mlog.New("imapserver", nil)
+
+	activeListenersMutex.Lock()
+	defer activeListenersMutex.Unlock()
+
+	for _, l := range listenerSockets(mox.Conf.Static) {
+		if os.Getuid() == 0 {
+			log.Print("listening for imap",
+				slog.String("listener", l.name),
+				slog.String("addr", l.addr),
+				slog.String("protocol", l.protocol))
+		}
+		ln, err := mox.Listen(l.network, l.addr)
+		if err != nil {
+			log.Fatalx("imap: listen for imap", err, slog.String("protocol", l.protocol), slog.String("listener", l.name))
+		}
+		activeListeners[l.key()] = activeListener{l, ln}
+		servers = append(servers, func() { l.serve(ln) })
+	}
+}
+
+// listenerSocket is a socket for a protocol on an address of a configured
+// listener.
+type listenerSocket struct {
+	protocol string
+	name     string // Listener name.
+	network  string
+	addr     string
+	serve    func(ln net.Listener) // Accepts connections until ln is closed.
+}
+
+// key identifies a socket when comparing running sockets against a configuration.
+func (l listenerSocket) key() string {
+	return l.protocol + " " + l.name + " " + l.addr
+}
+
+// listenerSockets returns the sockets for the imap listeners in static.
+func listenerSockets(static config.Static) []listenerSocket {
+	var sockets []listenerSocket
+	names := slices.Sorted(maps.Keys(static.Listeners))
 	for _, name := range names {
-		listener := mox.Conf.Static.Listeners[name]
+		listener := static.Listeners[name]
 
 		var tlsConfig *tls.Config
 		var noTLSClientAuth bool
@@ -396,59 +437,123 @@ func Listen() {
 		if listener.IMAP.Enabled {
 			port := config.Port(listener.IMAP.Port, 143)
 			for _, ip := range listener.IPs {
-				listen1("imap", name, ip, port, tlsConfig, false, noTLSClientAuth, listener.IMAP.NoRequireSTARTTLS)
+				sockets = append(sockets, listen1("imap", name, ip, port, listener.ProxyProtocol, tlsConfig, false, noTLSClientAuth, listener.IMAP.NoRequireSTARTTLS))
 			}
 		}
 
 		if listener.IMAPS.Enabled {
 			port := config.Port(listener.IMAPS.Port, 993)
 			for _, ip := range listener.IPs {
-				listen1("imaps", name, ip, port, tlsConfig, true, noTLSClientAuth, false)
+				sockets = append(sockets, listen1("imaps", name, ip, port, listener.ProxyProtocol, tlsConfig, true, noTLSClientAuth, false))
 			}
 		}
 	}
+	return sockets
 }
 
 var servers []func()
 
-func listen1(protocol, listenerName, ip string, port int, tlsConfig *tls.Config, xtls, noTLSClientAuth, noRequireSTARTTLS bool) {
+// Running sockets by listenerSocket key, for ListenerReload.
+var (
+	activeListenersMutex sync.Mutex
+	activeListeners      = map[string]activeListener{}
+)
+
+// activeListener is a running socket, with the listener it accepts connections on.
+type activeListener struct {
+	socket listenerSocket
+	ln     net.Listener
+}
+
+func listen1(protocol, listenerName, ip string, port int, proxyProtocol *config.ProxyProtocol, tlsConfig *tls.Config, xtls, noTLSClientAuth, noRequireSTARTTLS bool) listenerSocket {
 	log := mlog.New("imapserver", nil)
 	addr := net.JoinHostPort(ip, fmt.Sprintf("%d", port))
-	if os.Getuid() == 0 {
-		log.Print("listening for imap",
-			slog.String("listener", listenerName),
-			slog.String("addr", addr),
-			slog.String("protocol", protocol))
-	}
-	network := mox.Network(ip)
-	ln, err := mox.Listen(network, addr)
-	if err != nil {
-		log.Fatalx("imap: listen for imap", err, slog.String("protocol", protocol), slog.String("listener", listenerName))
-	}
 
-	// Each listener gets its own copy of the config, so session keys between different
-	// ports on same listener aren't shared. We rotate session keys explicitly in this
-	// base TLS config because each connection clones the TLS config before using. The
-	// base TLS config would never get automatically managed/rotated session keys.
-	if tlsConfig != nil {
-		tlsConfig = tlsConfig.Clone()
-		mox.StartTLSSessionTicketKeyRefresher(mox.Shutdown, log, tlsConfig)
-	}
+	serveListener := func(ln net.Listener) {
+		ctx, cancel := context.WithCancel(mox.Shutdown)
+		defer cancel()
+
+		// Each listener gets its own copy of the config, so session keys between different
+		// ports on same listener aren't shared. We rotate session keys explicitly in this
+		// base TLS config because each connection clones the TLS config before using. The
+		// base TLS config would never get automatically managed/rotated session keys.
+		tlsConfig := tlsConfig
+		if tlsConfig != nil {
+			tlsConfig = tlsConfig.Clone()
+			mox.StartTLSSessionTicketKeyRefresher(ctx, log, tlsConfig)
+		}
 
-	serve := func() {
 		for {
 			conn, err := ln.Accept()
-			if err != nil {
+			if err != nil && errors.Is(err, net.ErrClosed) {
+				// Socket closed by ListenerReload.
+				log.Info("imap: listener closed", slog.String("protocol", protocol), slog.String("listener", listenerName), slog.String("addr", addr))
+				return
+			} else if err != nil {
 				log.Infox("imap: accept", err, slog.String("protocol", protocol), slog.String("listener", listenerName))
 				continue
 			}
 
 			metricIMAPConnection.WithLabelValues(protocol).Inc()
-			go serve(listenerName, mox.Cid(), tlsConfig, conn, xtls, noTLSClientAuth, noRequireSTARTTLS, false, "")
+			go func() {
+				conn := mox.ProxyProtocolConn(log, proxyProtocol, conn)
+				if conn == nil {
+					return
+				}
+				serve(listenerName, mox.Cid(), tlsConfig, conn, xtls, noTLSClientAuth, noRequireSTARTTLS, false, "")
+			}()
 		}
 	}
 
-	servers = append(servers, serve)
+	return listenerSocket{protocol, listenerName, mox.Network(ip), addr, serveListener}
+}
+
+// ListenerReload compares the imap sockets for the listeners in static against
+// the running sockets, returning the sockets to open and the sockets to close.
+// Calling apply with a listener for each socket to open starts serving on them and
+// closes the removed sockets. See smtpserver.ListenerReload for details.
+func ListenerReload(static config.Static) (add, remove []mox.ListenerSocket, apply func(lns []net.Listener)) {
+	activeListenersMutex.Lock()
+	defer activeListenersMutex.Unlock()
+
+	var sockets []listenerSocket
+	keep := map[string]bool{}
+	for _, l := range listenerSockets(static) {
+		key := l.key()
+		keep[key] = true
+		if _, ok := activeListeners[key]; !ok {
+			sockets = append(sockets, l)
+			add = append(add, mox.ListenerSocket{Key: key, Network: l.network, Address: l.addr})
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(activeListeners)) {
+		if !keep[key] {
+			l := activeListeners[key].socket
+			remove = append(remove, mox.ListenerSocket{Key: key, Network: l.network, Address: l.addr})
+		}
+	}
+
+	apply = func(lns []net.Listener) {
+		log := mlog.New("imapserver", nil)
+
+		activeListenersMutex.Lock()
+		defer activeListenersMutex.Unlock()
+
+		for _, s := range remove {
+			err := activeListeners[s.Key].ln.Close()
+			log.Check(err, "closing imap listener", slog.String("socket", s.Key))
+			delete(activeListeners, s.Key)
+		}
+		for i, l := range sockets {
+			log.Print("listening for imap",
+				slog.String("listener", l.name),
+				slog.String("addr", l.addr),
+				slog.String("protocol", l.protocol))
+			activeListeners[l.key()] = activeListener{l, lns[i]}
+			go l.serve(lns[i])
+		}
+	}
+	return add, remove, apply
 }
 
 // ServeTLSConn serves IMAP on a TLS connection.
@@ -3559,6 +3664,8 @@ func (c *conn) cmdCreate(tag, cmd string, p *parser) {
 			if exists {
 				// ../rfc/9051:1914
 				xuserErrorf("mailbox already exists")
+			} else if errors.Is(err, store.ErrMailboxLimit) {
+				xusercodeErrorf("LIMIT", "%s", err)
 			}
 			xcheckf(err, "creating mailbox")
 		})
@@ -4187,6 +4294,11 @@ func (c *conn) cmdAppend(tag, cmd string, p *parser) {
 				// ../rfc/9208:472
 				xusercodeErrorf("OVERQUOTA", "account over maximum total message size %d", maxSize)
 			}
+			ok, maxCount, err := c.account.CanAddMessageCount(tx, len(appends))
+			xcheckf(err, "checking quota")
+			if !ok {
+				xusercodeErrorf("OVERQUOTA", "account over maximum number of messages %d", maxCount)
+			}
 
 			modseq, err := c.account.NextModSeq(tx)
 			xcheckf(err, "get next mod seq")
@@ -4208,6 +4320,9 @@ func (c *conn) cmdAppend(tag, cmd string, p *parser) {
 
 				// todo: do a single junk training
 				err = c.account.MessageAdd(c.log, tx, &mb, &a.m, a.file, store.AddOpts{SkipDirSync: true})
+				if errors.Is(err, store.ErrOverQuota) {
+					xusercodeErrorf("OVERQUOTA", "%s", err)
+				}
 				xcheckf(err, "delivering message")
 
 				changes = append(changes, a.m.ChangeAddUID(mb))
@@ -4758,6 +4873,11 @@ func (c *conn) cmdxCopy(isUID bool, tag, cmd string, p *parser) {
 				// ../rfc/9051:5155 ../rfc/9208:472
 				xusercodeErrorf("OVERQUOTA", "account over maximum total message size %d", maxSize)
 			}
+			if ok, maxCount, err := c.account.CanAddMessageCount(tx, len(xmsgs)); err != nil {
+				xcheckf(err, "checking quota")
+			} else if !ok {
+				xusercodeErrorf("OVERQUOTA", "account over maximum number of messages %d", maxCount)
+			}
 			err = c.account.AddMessageSize(c.log, tx, totalSize)
 			xcheckf(err, "updating disk usage")
 
@@ -4932,6 +5052,9 @@ func (c *conn) cmdxMove(isUID bool, tag, cmd string, p *parser) {
 
 			uidFirst = mbDst.UIDNext
 
+			// Quota is not checked: the moved messages are removed from the source mailbox,
+			// so the total size and number of messages in the account don't change.
+
 			// Assign a new modseq, for the new records and for the expunged records.
 			var err error
 			modseq, err = c.account.NextModSeq(tx)
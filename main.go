@@ -2675,8 +2675,11 @@ The DNS should be configured as a TXT record at $selector._domainkey.$domain.
 	case ed25519.PrivateKey:
 		r.PublicKey = key.Public()
 		r.Key = "ed25519"
+	case *ecdsa.PrivateKey:
+		r.PublicKey = key.Public()
+		r.Key = "ecdsa"
 	default:
-		log.Fatalf("unsupported private key type %T, must be rsa or ed25519", privKey)
+		log.Fatalf("unsupported private key type %T, must be rsa, ed25519 or ecdsa", privKey)
 	}
 
 	record, err := r.Record()
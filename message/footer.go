@@ -0,0 +1,255 @@
+package message
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/quotedprintable"
+	"slices"
+	"strings"
+
+	"github.com/mjl-/mox/mlog"
+)
+
+// AddFooter returns a copy of the message in r with textFooter added to its
+// text/plain body and htmlFooter added to its text/html body, e.g. for a legal
+// disclaimer. For multipart/alternative, the footers are added to all
+// alternatives. For multipart/mixed and multipart/related, only the first part is
+// considered, the remaining parts are typically attachments. Footers are given
+// without content-transfer-encoding, with lines ending in lf or crlf.
+//
+// A nil buffer is returned if no footer was added. Messages that already have a
+// DKIM-Signature header, signed or encrypted messages, and automatically generated
+// messages (with an Auto-Submitted header other than "no") are not changed.
+func AddFooter(elog *slog.Logger, r io.ReaderAt, size int64, textFooter, htmlFooter string) ([]byte, error) {
+	log := mlog.New("message", elog)
+
+	p, err := Parse(log.Logger, false, r)
+	if err != nil {
+		return nil, fmt.Errorf("parsing message: %w", err)
+	}
+	if err := p.Walk(log.Logger, nil); err != nil {
+		return nil, fmt.Errorf("walking message: %w", err)
+	}
+	h, err := p.Header()
+	if err != nil {
+		return nil, fmt.Errorf("parsing message header: %w", err)
+	}
+	if h.Get("Dkim-Signature") != "" {
+		log.Debug("not adding footer to message with dkim signature")
+		return nil, nil
+	}
+	if v := h.Get("Auto-Submitted"); v != "" && !strings.EqualFold(strings.TrimSpace(v), "no") {
+		log.Debug("not adding footer to automatically submitted message", slog.String("autosubmitted", v))
+		return nil, nil
+	}
+
+	type edit struct {
+		start, end int64
+		data       []byte
+	}
+	var edits []edit
+
+	// Add footer to part, returning edits for the header and body.
+	addFooter := func(pp *Part, footer string, html bool, sub bool) error {
+		if pp.ContentDisposition != nil && strings.HasPrefix(strings.ToLower(*pp.ContentDisposition), "attachment") {
+			return nil
+		}
+
+		charset := strings.ToLower(pp.ContentTypeParams["charset"])
+		setCharset := false
+		if !isASCII(footer) {
+			switch charset {
+			case "utf-8", "utf8":
+			case "", "us-ascii":
+				setCharset = true
+			default:
+				log.Debug("not adding footer with non-ascii characters to part with charset", slog.String("charset", charset))
+				return nil
+			}
+		}
+
+		buf, err := io.ReadAll(pp.Reader())
+		if err != nil {
+			return fmt.Errorf("reading part: %w", err)
+		}
+		footer = strings.ReplaceAll(strings.ReplaceAll(footer, "\r\n", "\n"), "\n", "\r\n")
+		if !strings.HasSuffix(footer, "\r\n") {
+			footer += "\r\n"
+		}
+		text := string(buf)
+		if html {
+			i := strings.LastIndex(strings.ToLower(text), "</body>")
+			if i < 0 {
+				i = len(text)
+			}
+			text = text[:i] + footer + text[i:]
+		} else {
+			if text != "" && !strings.HasSuffix(text, "\r\n") {
+				text += "\r\n"
+			}
+			text += "\r\n" + footer
+		}
+
+		var cte string
+		if pp.ContentTransferEncoding != nil {
+			cte = strings.ToLower(*pp.ContentTransferEncoding)
+		}
+		ncte := cte
+		switch {
+		case cte == "base64":
+		case cte == "quoted-printable" || NeedsQuotedPrintable(text):
+			ncte = "quoted-printable"
+		case !isASCII(text) && cte != "8bit" && cte != "binary":
+			ncte = "quoted-printable"
+		}
+
+		var body []byte
+		switch ncte {
+		case "base64":
+			s := base64.StdEncoding.EncodeToString([]byte(text))
+			var b bytes.Buffer
+			for len(s) > 0 {
+				n := min(len(s), 76)
+				b.WriteString(s[:n] + "\r\n")
+				s = s[n:]
+			}
+			body = b.Bytes()
+		case "quoted-printable":
+			var b bytes.Buffer
+			qpw := quotedprintable.NewWriter(&b)
+			if _, err := qpw.Write([]byte(text)); err != nil {
+				return fmt.Errorf("writing quoted-printable: %w", err)
+			}
+			if err := qpw.Close(); err != nil {
+				return fmt.Errorf("closing quoted-printable: %w", err)
+			}
+			body = b.Bytes()
+			if !bytes.HasSuffix(body, []byte("\r\n")) {
+				body = append(body, "\r\n"...)
+			}
+		default:
+			body = []byte(text)
+		}
+		// The crlf before a multipart boundary belongs to the boundary.
+		if sub {
+			body = bytes.TrimSuffix(body, []byte("\r\n"))
+		}
+
+		if setCharset || ncte != cte {
+			hdr := make([]byte, pp.BodyOffset-pp.HeaderOffset)
+			if _, err := r.ReadAt(hdr, pp.HeaderOffset); err != nil {
+				return fmt.Errorf("reading part header: %w", err)
+			}
+			var fields []string
+			if setCharset {
+				params := map[string]string{}
+				for k, v := range pp.ContentTypeParams {
+					params[k] = v
+				}
+				params["charset"] = "utf-8"
+				mt := "text/plain"
+				if html {
+					mt = "text/html"
+				}
+				fields = append(fields, "Content-Type: "+mime.FormatMediaType(mt, params))
+			}
+			if ncte != cte {
+				fields = append(fields, "Content-Transfer-Encoding: "+ncte)
+			}
+			edits = append(edits, edit{pp.HeaderOffset, pp.BodyOffset, replaceHeaderFields(hdr, fields)})
+		}
+		edits = append(edits, edit{pp.BodyOffset, pp.EndOffset, body})
+		return nil
+	}
+
+	var gather func(pp *Part, sub bool) error
+	gather = func(pp *Part, sub bool) error {
+		switch pp.MediaType + "/" + pp.MediaSubType {
+		case "/", "TEXT/PLAIN":
+			if textFooter != "" {
+				return addFooter(pp, textFooter, false, sub)
+			}
+		case "TEXT/HTML":
+			if htmlFooter != "" {
+				return addFooter(pp, htmlFooter, true, sub)
+			}
+		case "MULTIPART/ALTERNATIVE":
+			for i := range pp.Parts {
+				if err := gather(&pp.Parts[i], true); err != nil {
+					return err
+				}
+			}
+		case "MULTIPART/MIXED", "MULTIPART/RELATED":
+			if len(pp.Parts) > 0 {
+				return gather(&pp.Parts[0], true)
+			}
+		}
+		// Other types, e.g. multipart/signed, multipart/encrypted and
+		// application/pkcs7-mime, are left alone.
+		return nil
+	}
+	if err := gather(&p, false); err != nil {
+		return nil, err
+	}
+	if len(edits) == 0 {
+		return nil, nil
+	}
+
+	slices.SortFunc(edits, func(a, b edit) int {
+		return int(a.start - b.start)
+	})
+	var out bytes.Buffer
+	var offset int64
+	for _, e := range edits {
+		if _, err := io.Copy(&out, io.NewSectionReader(r, offset, e.start-offset)); err != nil {
+			return nil, fmt.Errorf("copying message: %w", err)
+		}
+		out.Write(e.data)
+		offset = e.end
+	}
+	if _, err := io.Copy(&out, io.NewSectionReader(r, offset, size-offset)); err != nil {
+		return nil, fmt.Errorf("copying message: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// replaceHeaderFields returns the header in hdr, which includes the empty line
+// ending the header, with fields in the form "Key: value" replacing any existing
+// fields with the same key.
+func replaceHeaderFields(hdr []byte, fields []string) []byte {
+	var keys []string
+	for _, f := range fields {
+		k, _, _ := strings.Cut(f, ":")
+		keys = append(keys, strings.ToLower(k))
+	}
+
+	var out bytes.Buffer
+	var skip bool
+	lines := bytes.SplitAfter(hdr, []byte("\r\n"))
+	for _, line := range lines {
+		if len(line) == 0 || string(line) == "\r\n" {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			// Continuation of previous field.
+			if !skip {
+				out.Write(line)
+			}
+			continue
+		}
+		k, _, _ := bytes.Cut(line, []byte(":"))
+		skip = slices.Contains(keys, strings.ToLower(strings.TrimSpace(string(k))))
+		if !skip {
+			out.Write(line)
+		}
+	}
+	for _, f := range fields {
+		out.WriteString(f + "\r\n")
+	}
+	out.WriteString("\r\n")
+	return out.Bytes()
+}
@@ -0,0 +1,128 @@
+package message
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAddFooter(t *testing.T) {
+	test := func(msg, textFooter, htmlFooter, exp string) {
+		t.Helper()
+		msg = strings.ReplaceAll(msg, "\n", "\r\n")
+		exp = strings.ReplaceAll(exp, "\n", "\r\n")
+		buf, err := AddFooter(pkglog.Logger, strings.NewReader(msg), int64(len(msg)), textFooter, htmlFooter)
+		tcheck(t, err, "add footer")
+		tcompare(t, string(buf), exp)
+	}
+
+	// Plain text message.
+	test(`From: mjl@mox.example
+Subject: test
+
+hi
+`, "footer", "", `From: mjl@mox.example
+Subject: test
+
+hi
+
+footer
+`)
+
+	// No text part for footer.
+	test(`Content-Type: text/html
+
+<p>hi</p>
+`, "footer", "", "")
+
+	// Non-ascii footer makes the 7bit part quoted-printable utf-8.
+	test(`Subject: test
+
+hi
+`, "café", "", `Subject: test
+Content-Type: text/plain; charset=utf-8
+Content-Transfer-Encoding: quoted-printable
+
+hi
+
+caf=C3=A9
+`)
+
+	// Both alternatives get a footer, attachment does not.
+	test(`Content-Type: multipart/mixed; boundary=x
+
+--x
+Content-Type: multipart/alternative; boundary=y
+
+--y
+Content-Type: text/plain
+
+hi
+--y
+Content-Type: text/html
+
+<html><body>hi</body></html>
+--y--
+--x
+Content-Type: text/plain
+Content-Disposition: attachment
+
+attached
+--x--
+`, "footer", "<p>footer</p>", `Content-Type: multipart/mixed; boundary=x
+
+--x
+Content-Type: multipart/alternative; boundary=y
+
+--y
+Content-Type: text/plain
+
+hi
+
+footer
+--y
+Content-Type: text/html
+
+<html><body>hi<p>footer</p>
+</body></html>
+--y--
+--x
+Content-Type: text/plain
+Content-Disposition: attachment
+
+attached
+--x--
+`)
+
+	// Base64 part is re-encoded.
+	test(`Content-Type: text/plain
+Content-Transfer-Encoding: base64
+
+aGkNCg==
+`, "footer", "", `Content-Type: text/plain
+Content-Transfer-Encoding: base64
+
+aGkNCg0KZm9vdGVyDQo=
+`)
+
+	// Messages that are signed or automatically generated are not changed.
+	test(`DKIM-Signature: v=1; d=mox.example
+
+hi
+`, "footer", "", "")
+	test(`Auto-Submitted: auto-replied
+
+hi
+`, "footer", "", "")
+	test(`Content-Type: multipart/signed; boundary=x; protocol="application/pgp-signature"
+
+--x
+Content-Type: text/plain
+
+hi
+--x
+Content-Type: application/pgp-signature
+
+sig
+--x--
+`, "footer", "", "")
+}
@@ -31,6 +31,7 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"golang.org/x/text/unicode/norm"
 
@@ -74,6 +75,22 @@ var NewWebapiHandler = func(maxMsgSize int64, basePath string, isForwarded bool)
 
 var nopHandler = http.HandlerFunc(nil)
 
+// ListenerSocket is a socket for a protocol on an address of a listener, as
+// returned by ListenerReloaders.
+type ListenerSocket struct {
+	Key     string // Of the form "<protocol> <listener> <address>".
+	Network string
+	Address string
+}
+
+// ListenerReloaders are set by packages smtpserver and imapserver, by protocol, to
+// prevent cyclic dependencies. Each compares the sockets for the listeners in
+// static against its running sockets, and returns the sockets to open and the
+// running sockets to close, without changing anything. Calling apply with a
+// listener for each socket in add, in order, starts serving on them and closes
+// the sockets in remove. Used by admin.ListenerReload.
+var ListenerReloaders = map[string]func(static config.Static) (add, remove []ListenerSocket, apply func(lns []net.Listener)){}
+
 // Config as used in the code, a processed version of what is in the config file.
 //
 // Use methods to lookup a domain/account/address in the dynamic configuration.
@@ -95,6 +112,35 @@ type Config struct {
 
 	// Like AccountDestinationsLocked, but for aliases.
 	aliases map[string]config.Alias
+
+	// Dynamic config before the most recent WriteDynamicLocked, for undoing the last
+	// change. Cleared when domains.conf is reloaded after an external change.
+	dynamicPrevious *config.Dynamic
+
+	// Changes by WriteDynamicLocked for which the handlers registered with
+	// OnDynamicConfigChange are called after the dynamic config lock is released.
+	dynamicChanges []dynamicChange
+}
+
+type dynamicChange struct {
+	old, new config.Dynamic
+}
+
+var dynamicChangeHandlers struct {
+	sync.Mutex
+	l []func(old, new config.Dynamic)
+}
+
+// OnDynamicConfigChange registers fn to be called after each change of the
+// dynamic config (domains.conf) through WriteDynamicLocked, e.g. when adding a
+// domain or changing an account, with the configs before and after the change.
+// Handlers are called after the dynamic config lock is released, in order of
+// registration. Handlers must not modify the configs, which share data with the
+// active config.
+func OnDynamicConfigChange(fn func(old, new config.Dynamic)) {
+	dynamicChangeHandlers.Lock()
+	defer dynamicChangeHandlers.Unlock()
+	dynamicChangeHandlers.l = append(dynamicChangeHandlers.l, fn)
 }
 
 type AccountDestination struct {
@@ -162,7 +208,27 @@ func (c *Config) DynamicLockUnlock() func() {
 			}
 		}
 	}
-	return c.dynamicMutex.Unlock
+	return c.dynamicUnlock
+}
+
+// dynamicUnlock releases the dynamic config lock, then calls the handlers for
+// changes made while the lock was held.
+func (c *Config) dynamicUnlock() {
+	changes := c.dynamicChanges
+	c.dynamicChanges = nil
+	c.dynamicMutex.Unlock()
+
+	if len(changes) == 0 {
+		return
+	}
+	dynamicChangeHandlers.Lock()
+	handlers := slices.Clone(dynamicChangeHandlers.l)
+	dynamicChangeHandlers.Unlock()
+	for _, ch := range changes {
+		for _, fn := range handlers {
+			fn(ch.old, ch.new)
+		}
+	}
 }
 
 func (c *Config) withDynamicLock(fn func()) {
@@ -180,10 +246,45 @@ func (c *Config) loadDynamic() []error {
 	c.dynamicMtime = mtime
 	c.AccountDestinationsLocked = accDests
 	c.aliases = aliases
+	c.dynamicPrevious = nil
 	c.allowACMEHosts(pkglog, true)
 	return nil
 }
 
+// Reload parses the static and dynamic config files from disk, and if both are
+// valid, replaces the running dynamic config (domains.conf) with the one from
+// disk. Changes to the static config (mox.conf) are only checked, they take effect
+// after a restart. On errors, the running config is not changed.
+func (c *Config) Reload(ctx context.Context, log mlog.Log) []error {
+	if _, errs := ParseConfig(ctx, log, ConfigStaticPath, true, false, false); len(errs) > 0 {
+		return errs
+	}
+
+	c.dynamicMutex.Lock()
+	defer c.dynamicMutex.Unlock()
+	if errs := c.loadDynamic(); len(errs) > 0 {
+		return errs
+	}
+	c.DynamicLastCheck = time.Now()
+	return nil
+}
+
+// DynamicPreviousLocked returns the dynamic config as it was before the most
+// recent change through WriteDynamicLocked, if any. Must be called with dynamic
+// lock held.
+func (c *Config) DynamicPreviousLocked() (config.Dynamic, bool) {
+	if c.dynamicPrevious == nil {
+		return config.Dynamic{}, false
+	}
+	return *c.dynamicPrevious, true
+}
+
+// DynamicPreviousClearLocked forgets the dynamic config from before the most
+// recent change. Must be called with dynamic lock held.
+func (c *Config) DynamicPreviousClearLocked() {
+	c.dynamicPrevious = nil
+}
+
 // DynamicConfig returns a shallow copy of the dynamic config. Must not be modified.
 func (c *Config) DynamicConfig() (config config.Dynamic) {
 	c.withDynamicLock(func() {
@@ -391,24 +492,43 @@ func (c *Config) allowACMEHosts(log mlog.Log, checkACMEHosts bool) {
 
 // todo future: write config parsing & writing code that can read a config and remembers the exact tokens including newlines and comments, and can write back a modified file. the goal is to be able to write a config file automatically (after changing fields through the ui), but not loose comments and whitespace, to still get useful diffs for storing the config in a version control system.
 
-// WriteDynamicLocked prepares an updated internal state for the new dynamic
-// config, then writes it to disk and activates it.
+// CheckDynamicLocked validates the dynamic config like WriteDynamicLocked, but
+// does not write or activate it.
 //
 // Returns ErrConfig if the configuration is not valid.
 //
 // Must be called with config lock held.
-func WriteDynamicLocked(ctx context.Context, log mlog.Log, c config.Dynamic) error {
-	accDests, aliases, errs := prepareDynamicConfig(ctx, log, ConfigDynamicPath, Conf.Static, &c)
+func CheckDynamicLocked(ctx context.Context, log mlog.Log, c config.Dynamic) error {
+	_, _, err := checkDynamic(ctx, log, &c)
+	return err
+}
+
+func checkDynamic(ctx context.Context, log mlog.Log, c *config.Dynamic) (map[string]AccountDestination, map[string]config.Alias, error) {
+	accDests, aliases, errs := prepareDynamicConfig(ctx, log, ConfigDynamicPath, Conf.Static, c)
 	if len(errs) > 0 {
 		errstrs := make([]string, len(errs))
 		for i, err := range errs {
 			errstrs[i] = err.Error()
 		}
-		return fmt.Errorf("%w: %s", ErrConfig, strings.Join(errstrs, "; "))
+		return nil, nil, fmt.Errorf("%w: %s", ErrConfig, strings.Join(errstrs, "; "))
+	}
+	return accDests, aliases, nil
+}
+
+// WriteDynamicLocked prepares an updated internal state for the new dynamic
+// config, then writes it to disk and activates it.
+//
+// Returns ErrConfig if the configuration is not valid.
+//
+// Must be called with config lock held.
+func WriteDynamicLocked(ctx context.Context, log mlog.Log, c config.Dynamic) error {
+	accDests, aliases, err := checkDynamic(ctx, log, &c)
+	if err != nil {
+		return err
 	}
 
 	var b bytes.Buffer
-	err := sconf.Write(&b, c)
+	err = sconf.Write(&b, c)
 	if err != nil {
 		return err
 	}
@@ -448,9 +568,12 @@ func WriteDynamicLocked(ctx context.Context, log mlog.Log, c config.Dynamic) err
 
 	Conf.dynamicMtime = fi.ModTime()
 	Conf.DynamicLastCheck = time.Now()
+	prev := Conf.Dynamic
+	Conf.dynamicPrevious = &prev
 	Conf.Dynamic = c
 	Conf.AccountDestinationsLocked = accDests
 	Conf.aliases = aliases
+	Conf.dynamicChanges = append(Conf.dynamicChanges, dynamicChange{prev, c})
 
 	Conf.allowACMEHosts(log, true)
 
@@ -490,7 +613,7 @@ func LoadConfig(ctx context.Context, log mlog.Log, doLoadTLSKeyCerts, checkACMEH
 // SetConfig sets a new config. Not to be used during normal operation.
 func SetConfig(c *Config) {
 	// Cannot just assign *c to Conf, it would copy the mutex.
-	Conf = Config{c.Static, sync.Mutex{}, c.Log, sync.Mutex{}, c.Dynamic, c.dynamicMtime, c.DynamicLastCheck, c.AccountDestinationsLocked, c.aliases}
+	Conf = Config{c.Static, sync.Mutex{}, c.Log, sync.Mutex{}, c.Dynamic, c.dynamicMtime, c.DynamicLastCheck, c.AccountDestinationsLocked, c.aliases, nil, nil}
 
 	// If we have non-standard CA roots, use them for all HTTPS requests.
 	if Conf.Static.TLS.CertPool != nil {
@@ -629,6 +752,24 @@ func PrepareStaticConfig(ctx context.Context, log mlog.Log, configFile string, c
 		c.HostTLSRPT.ParsedLocalpart = tlsrptLocalpart
 	}
 
+	if cr := c.ConnectionReuse; cr != nil {
+		if len(cr.Domains) == 0 {
+			addErrorf("connection reuse requires at least one domain")
+		}
+		if cr.IdleTime < 0 || cr.IdleTime > 5*time.Minute {
+			addErrorf("connection reuse idle time must be between 0 and 5m")
+		}
+		cr.ParsedDomains = map[dns.Domain]struct{}{}
+		for _, s := range cr.Domains {
+			d, err := dns.ParseDomain(s)
+			if err != nil {
+				addErrorf("parsing connection reuse domain %q: %v", s, err)
+				continue
+			}
+			cr.ParsedDomains[d] = struct{}{}
+		}
+	}
+
 	// Return private key for host name for use with an ACME. Used to return the same
 	// private key as pre-generated for use with DANE, with its public key in DNS.
 	// We only use this key for Listener's that have this ACME configured, and for
@@ -912,6 +1053,20 @@ func PrepareStaticConfig(ctx context.Context, log mlog.Log, configFile string, c
 		if l.IPsNATed && len(l.NATIPs) > 0 {
 			addListenerErrorf("both IPsNATed and NATIPs configued (remove deprecated IPsNATed)")
 		}
+		if l.ProxyProtocol != nil {
+			if len(l.ProxyProtocol.TrustedNetworks) == 0 {
+				addListenerErrorf("proxy protocol requires at least one trusted network")
+			}
+			l.ProxyProtocol.TrustedIPNets = nil
+			for _, s := range l.ProxyProtocol.TrustedNetworks {
+				ipnet, err := ParseIPNet(s)
+				if err != nil {
+					addListenerErrorf("proxy protocol trusted network: %v", err)
+					continue
+				}
+				l.ProxyProtocol.TrustedIPNets = append(l.ProxyProtocol.TrustedIPNets, ipnet)
+			}
+		}
 		for _, ipstr := range l.NATIPs {
 			ip := net.ParseIP(ipstr)
 			if ip == nil {
@@ -1307,6 +1462,44 @@ func prepareDynamicConfig(ctx context.Context, log mlog.Log, dynamicPath string,
 			sepSeen[sep] = true
 		}
 
+		if domain.Footer != nil {
+			if err := CheckFooter(domain.Footer.Text, domain.Footer.HTML); err != nil {
+				addDomainErrorf("footer: %v", err)
+			}
+		}
+
+		domain.DNSBLZones = nil
+		for _, s := range domain.DNSBLs {
+			d, err := dns.ParseDomain(s)
+			if err != nil {
+				addDomainErrorf("parsing DNSBL zone %q: %s", s, err)
+				continue
+			}
+			if slices.Contains(domain.DNSBLZones, d) {
+				addDomainErrorf("duplicate DNSBL zone %s", d)
+				continue
+			}
+			domain.DNSBLZones = append(domain.DNSBLZones, d)
+		}
+
+		domain.SPFIncludeDomains = nil
+		for _, s := range domain.SPFIncludes {
+			d, err := dns.ParseDomainLax(s)
+			if err != nil {
+				addDomainErrorf("parsing SPF include domain %q: %s", s, err)
+				continue
+			}
+			if d == domain.Domain {
+				addDomainErrorf("SPF include of domain itself")
+				continue
+			}
+			if slices.Contains(domain.SPFIncludeDomains, d) {
+				addDomainErrorf("duplicate SPF include domain %s", d)
+				continue
+			}
+			domain.SPFIncludeDomains = append(domain.SPFIncludeDomains, d)
+		}
+
 		for _, sign := range domain.DKIM.Sign {
 			if _, ok := domain.DKIM.Selectors[sign]; !ok {
 				addDomainErrorf("unknown selector %s for signing", sign)
@@ -1375,6 +1568,15 @@ func prepareDynamicConfig(ctx context.Context, log mlog.Log, dynamicPath string,
 				}
 				sel.Key = k
 				sel.Algorithm = "ed25519"
+			case *ecdsa.PrivateKey:
+				if k.Curve != elliptic.P256() {
+					addSelectorErrorf("only ecdsa keys with curve p256 are supported")
+				}
+				if sel.HashEffective != "sha256" {
+					addSelectorErrorf("hash algorithm %q is not supported with ecdsa, only sha256 is", sel.HashEffective)
+				}
+				sel.Key = k
+				sel.Algorithm = "ecdsa-p256"
 			default:
 				addSelectorErrorf("private key type %T not yet supported", key)
 			}
@@ -1601,6 +1803,14 @@ func prepareDynamicConfig(ctx context.Context, log mlog.Log, dynamicPath string,
 				}
 			}
 
+			for _, fwd := range dest.Forward {
+				if fa, err := smtp.ParseAddress(fwd); err != nil {
+					addDestErrorf("parsing forward address %q: %v", fwd, err)
+				} else if fa.String() == addrName {
+					addDestErrorf("cannot forward to the destination address itself")
+				}
+			}
+
 			for i, rs := range dest.Rulesets {
 				addRulesetErrorf := func(format string, args ...any) {
 					addDestErrorf("ruleset %d: %s", i+1, fmt.Sprintf(format, args...))
@@ -1682,18 +1892,23 @@ func prepareDynamicConfig(ctx context.Context, log mlog.Log, dynamicPath string,
 				}
 			}
 
-			// Catchall destination for domain.
+			// Catchall destination for domain, or "@.<domain>" for the domain and its
+			// subdomains.
 			if strings.HasPrefix(addrName, "@") {
-				d, err := dns.ParseDomain(addrName[1:])
+				prefix := "@"
+				if strings.HasPrefix(addrName, "@.") {
+					prefix = "@."
+				}
+				d, err := dns.ParseDomain(addrName[len(prefix):])
 				if err != nil {
-					addDestErrorf("parsing domain %q", addrName[1:])
+					addDestErrorf("parsing domain %q", addrName[len(prefix):])
 					continue
 				} else if _, ok := c.Domains[d.Name()]; !ok {
 					addDestErrorf("unknown domain for address")
 					continue
 				}
 				domainHasAddress[d.Name()] = true
-				addrFull := "@" + d.Name()
+				addrFull := prefix + d.Name()
 				if _, ok := accDests[addrFull]; ok {
 					addDestErrorf("duplicate canonicalized catchall destination address %s", addrFull)
 				}
@@ -1766,6 +1981,8 @@ func prepareDynamicConfig(ctx context.Context, log mlog.Log, dynamicPath string,
 			// For domain catchall.
 			if _, ok := accDests["@"+a.Domain.Name()]; ok {
 				continue
+			} else if _, ok := accDests["@."+a.Domain.Name()]; ok {
+				continue
 			}
 			dc := c.Domains[a.Domain.Name()]
 			a.Localpart = CanonicalLocalpart(a.Localpart, dc)
@@ -1817,6 +2034,20 @@ func prepareDynamicConfig(ctx context.Context, log mlog.Log, dynamicPath string,
 			domainHasAddress[addrdom.Name()] = true
 		}
 
+		switch dmarc.Policy {
+		case "", "none", "quarantine", "reject":
+		default:
+			addDomainErrorf("unknown DMARC policy %q, must be none, quarantine or reject", dmarc.Policy)
+		}
+		if dmarc.Percentage < 0 || dmarc.Percentage > 100 {
+			addDomainErrorf("DMARC percentage %d must be between 0 and 100, 0 for the default of 100", dmarc.Percentage)
+		}
+		for _, s := range append(slices.Clone(dmarc.ExtraAggregateReportAddresses), dmarc.FailureReportAddresses...) {
+			if _, err := smtp.ParseAddress(s); err != nil {
+				addDomainErrorf("parsing DMARC report address %q: %v", s, err)
+			}
+		}
+
 		domain.DMARC.ParsedLocalpart = lp
 		domain.DMARC.DNSDomain = addrdom
 		c.Domains[d] = domain
@@ -1953,6 +2184,21 @@ func prepareDynamicConfig(ctx context.Context, log mlog.Log, dynamicPath string,
 				aa := config.AliasAddress{Address: da, AccountName: accDest.Account, Destination: accDest.Destination}
 				a.ParsedAddresses = append(a.ParsedAddresses, aa)
 			}
+			a.ParsedAllowMsgFromAddresses = nil
+			seenAllow := map[smtp.Address]bool{}
+			for _, s := range a.AllowMsgFromAddresses {
+				fa, err := smtp.ParseAddress(s)
+				if err != nil {
+					addAliasErrorf("parsing allowed message from address %q: %v", s, err)
+					continue
+				}
+				if seenAllow[fa] {
+					addAliasErrorf("duplicate allowed message from address %q", s)
+					continue
+				}
+				seenAllow[fa] = true
+				a.ParsedAllowMsgFromAddresses = append(a.ParsedAllowMsgFromAddresses, fa)
+			}
 			a.Domain = domain.Domain
 			c.Domains[d].Aliases[lpstr] = a
 			aliases[addr] = a
@@ -2144,6 +2390,24 @@ func prepareDynamicConfig(ctx context.Context, log mlog.Log, dynamicPath string,
 	return
 }
 
+// Maximum size of text and HTML footers for outgoing messages.
+const footerMaxSize = 4096
+
+// CheckFooter checks a text and HTML footer for outgoing messages. At least one
+// must be set, and neither can be too large.
+func CheckFooter(text, html string) error {
+	if text == "" && html == "" {
+		return errors.New("text and/or html footer required")
+	} else if len(text) > footerMaxSize {
+		return fmt.Errorf("text footer larger than %d bytes", footerMaxSize)
+	} else if len(html) > footerMaxSize {
+		return fmt.Errorf("html footer larger than %d bytes", footerMaxSize)
+	} else if !utf8.ValidString(text) || !utf8.ValidString(html) {
+		return errors.New("footer must be valid utf-8")
+	}
+	return nil
+}
+
 func loadPrivateKeyFile(keyPath string) (crypto.Signer, error) {
 	keyBuf, err := os.ReadFile(keyPath)
 	if err != nil {
@@ -14,11 +14,15 @@ import (
 	"github.com/mjl-/mox/smtp"
 )
 
-// DKIMSelectors returns the selectors to use for signing.
+// DKIMSelectors returns the selectors to use for signing. Disabled selectors are
+// skipped.
 func DKIMSelectors(dkimConf config.DKIM) []dkim.Selector {
 	var l []dkim.Selector
 	for _, sign := range dkimConf.Sign {
 		sel := dkimConf.Selectors[sign]
+		if sel.Disabled {
+			continue
+		}
 		s := dkim.Selector{
 			Hash:          sel.HashEffective,
 			HeaderRelaxed: sel.Canonicalization.HeaderRelaxed,
@@ -0,0 +1,39 @@
+package mox
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+)
+
+// MessageFooterAdd adds the footer of a domain to the outgoing message of size in
+// f, rewriting the file. Must be called before DKIM signing. If the message was
+// changed, its new size is returned, along with whether it has 8-bit data.
+func MessageFooterAdd(log mlog.Log, footer *config.Footer, f *os.File, size int64) (nsize int64, has8bit, changed bool, rerr error) {
+	if footer == nil {
+		return size, false, false, nil
+	}
+
+	buf, err := message.AddFooter(log.Logger, f, size, footer.Text, footer.HTML)
+	if err != nil {
+		return size, false, false, fmt.Errorf("adding footer to message: %w", err)
+	} else if buf == nil {
+		return size, false, false, nil
+	}
+	if _, err := f.WriteAt(buf, 0); err != nil {
+		return size, false, false, fmt.Errorf("writing message with footer: %v", err)
+	}
+	if err := f.Truncate(int64(len(buf))); err != nil {
+		return size, false, false, fmt.Errorf("truncating message with footer: %v", err)
+	}
+	for _, c := range buf {
+		if c >= 0x80 {
+			has8bit = true
+			break
+		}
+	}
+	return int64(len(buf)), has8bit, true, nil
+}
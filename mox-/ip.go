@@ -23,6 +23,24 @@ func Network(ip string) string {
 	return "tcp6"
 }
 
+// ParseIPNet parses an IP network in CIDR notation. A single IP address is
+// parsed as a network with just that address.
+func ParseIPNet(s string) (*net.IPNet, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		bits := 128
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, ipnet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("parsing ip network %q: %v", s, err)
+	}
+	return ipnet, nil
+}
+
 // DomainSPFIPs returns IPs to include in SPF records for domains. It includes the
 // IPs on listeners that have SMTP enabled, and includes IPs configured for SOCKS
 // transports.
@@ -2,6 +2,7 @@ package mox
 
 import (
 	"errors"
+	"slices"
 	"strings"
 
 	"github.com/mjl-/mox/config"
@@ -19,6 +20,11 @@ var (
 //
 // Can return ErrDomainNotFound and ErrAddressNotFound. If checkDomainDisabled is
 // set, returns ErrDomainDisabled if domain is disabled.
+//
+// If no address matches, a catchall destination "@<domain>" is used, and otherwise
+// a catchall "@.<domain>" that matches the domain and its subdomains. For a domain
+// that is not configured, the nearest configured parent domain with an
+// "@.<parent>" destination is used.
 func LookupAddress(localpart smtp.Localpart, domain dns.Domain, allowPostmaster, allowAlias, checkDomainDisabled bool) (accountName string, alias *config.Alias, canonicalAddress string, dest config.Destination, rerr error) {
 	if strings.EqualFold(string(localpart), "postmaster") {
 		localpart = "postmaster"
@@ -55,7 +61,9 @@ func LookupAddress(localpart smtp.Localpart, domain dns.Domain, allowPostmaster,
 	}
 
 	d, ok := Conf.Domain(domain)
-	if !ok || d.ReportsOnly {
+	if !ok {
+		return lookupSubdomainCatchall(domain, checkDomainDisabled)
+	} else if d.ReportsOnly {
 		// For ReportsOnly, we also return ErrDomainNotFound, so this domain isn't
 		// considered local/authoritative during delivery.
 		return "", nil, "", config.Destination{}, ErrDomainNotFound
@@ -74,17 +82,54 @@ func LookupAddress(localpart smtp.Localpart, domain dns.Domain, allowPostmaster,
 		}
 		return "", alias, canonical, config.Destination{}, nil
 	} else if !ok {
-		if accAddr, alias, ok = Conf.AccountDestination("@" + domain.Name()); !ok || alias != nil {
+		canonical = "@" + domain.Name()
+		accAddr, alias, ok = Conf.AccountDestination(canonical)
+		if !ok || alias != nil {
+			canonical = "@." + domain.Name()
+			accAddr, alias, ok = Conf.AccountDestination(canonical)
+		}
+		if !ok || alias != nil {
 			if localpart == "postmaster" && allowPostmaster {
 				return Conf.Static.Postmaster.Account, nil, "postmaster", config.Destination{Mailbox: Conf.Static.Postmaster.Mailbox}, nil
 			}
 			return "", nil, "", config.Destination{}, ErrAddressNotFound
 		}
-		canonical = "@" + domain.Name()
 	}
 	return accAddr.Account, nil, canonical, accAddr.Destination, nil
 }
 
+// lookupSubdomainCatchall looks up a catchall destination "@.<parent>" for the
+// nearest configured parent domain of domain, which is not configured itself.
+func lookupSubdomainCatchall(domain dns.Domain, checkDomainDisabled bool) (accountName string, alias *config.Alias, canonicalAddress string, dest config.Destination, rerr error) {
+	s := domain.ASCII
+	for {
+		_, rem, ok := strings.Cut(s, ".")
+		if !ok || !strings.Contains(rem, ".") {
+			// We don't look at top-level domains.
+			return "", nil, "", config.Destination{}, ErrDomainNotFound
+		}
+		s = rem
+		parent, err := dns.ParseDomain(s)
+		if err != nil {
+			return "", nil, "", config.Destination{}, ErrDomainNotFound
+		}
+		d, ok := Conf.Domain(parent)
+		if !ok || d.ReportsOnly {
+			continue
+		}
+		canonical := "@." + parent.Name()
+		accAddr, a, ok := Conf.AccountDestination(canonical)
+		if !ok || a != nil {
+			// The nearest configured domain has no subdomain catchall, the domain is not ours.
+			return "", nil, "", config.Destination{}, ErrDomainNotFound
+		}
+		if d.Disabled && checkDomainDisabled {
+			return "", nil, "", config.Destination{}, ErrDomainDisabled
+		}
+		return accAddr.Account, nil, canonical, accAddr.Destination, nil
+	}
+}
+
 // lp and rlp are both lower-case when domain localparts aren't case sensitive.
 func matchReportingSeparators(lp, rlp smtp.Localpart, d config.Domain) bool {
 	lps := string(lp)
@@ -149,3 +194,24 @@ func AllowMsgFrom(accountName string, msgFrom smtp.Address) (ok, domainDisabled
 	}
 	return accName == accountName, false
 }
+
+// AliasAllowedMsgFrom returns whether msgFrom, the address in the From header of
+// a message, is allowed to send a message to alias.
+func AliasAllowedMsgFrom(alias config.Alias, msgFrom smtp.Address) bool {
+	if len(alias.ParsedAllowMsgFromAddresses) > 0 {
+		return slices.Contains(alias.ParsedAllowMsgFromAddresses, msgFrom)
+	}
+	for _, aa := range alias.ParsedAddresses {
+		if aa.Address == msgFrom {
+			return true
+		}
+	}
+	lp, err := smtp.ParseLocalpart(alias.LocalpartStr)
+	if err != nil {
+		return false
+	}
+	if msgFrom == smtp.NewAddress(lp, alias.Domain) {
+		return alias.AllowMsgFrom
+	}
+	return alias.PostPublic
+}
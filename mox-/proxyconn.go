@@ -0,0 +1,32 @@
+package mox
+
+import (
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/proxyproto"
+)
+
+// ProxyProtocolConn reads the PROXY protocol header from conn if the listener
+// has the PROXY protocol configured and conn comes from a trusted network. The
+// returned connection has the source address from the header as remote address.
+// For connections from other networks, conn is returned as is, and any PROXY
+// header is not honored. If reading the header fails, conn is closed and nil is
+// returned.
+func ProxyProtocolConn(log mlog.Log, pp *config.ProxyProtocol, conn net.Conn) net.Conn {
+	if pp == nil || !proxyproto.Trusted(conn, pp.TrustedIPNets) {
+		return conn
+	}
+	pconn, err := proxyproto.Accept(conn, 30*time.Second)
+	if err != nil {
+		log.Infox("reading proxy protocol header, closing connection", err, slog.Any("remote", conn.RemoteAddr()))
+		err := conn.Close()
+		log.Check(err, "closing connection")
+		return nil
+	}
+	log.Debug("connection from proxy", slog.Any("proxy", conn.RemoteAddr()), slog.Any("remote", pconn.RemoteAddr()))
+	return pconn
+}
@@ -0,0 +1,220 @@
+// Package proxyproto implements reading the PROXY protocol header, version 1
+// (text) and 2 (binary), as sent by load balancers such as HAProxy.
+//
+// A load balancer in front of mox makes connections from its own IP address.
+// With the PROXY protocol, it starts each connection with a header holding the
+// original source and destination address. Only headers from trusted upstream
+// networks should be honored, anyone else could spoof their source IP.
+//
+// See https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt.
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMissing = errors.New("proxy protocol header missing")
+	ErrInvalid = errors.New("invalid proxy protocol header")
+)
+
+// Signature for version 2 of the protocol.
+var sigV2 = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// Conn is a connection with its remote and local address taken from the PROXY
+// protocol header.
+type Conn struct {
+	net.Conn
+	r      *bufio.Reader // Holds data read after the header.
+	remote net.Addr
+	local  net.Addr
+}
+
+// Read reads data following the PROXY protocol header.
+func (c *Conn) Read(buf []byte) (int, error) {
+	return c.r.Read(buf)
+}
+
+// RemoteAddr returns the original source address from the PROXY header.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// LocalAddr returns the original destination address from the PROXY header.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.local
+}
+
+// Trusted returns whether the remote address of conn is in one of the trusted
+// networks.
+func Trusted(conn net.Conn, trusted []*net.IPNet) bool {
+	a, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(a.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// Accept reads the PROXY protocol header from conn, which must come from a
+// trusted upstream network, and returns a connection with the addresses from the
+// header. If the header is of type LOCAL/UNKNOWN (e.g. health checks), the
+// original addresses are kept. The header must arrive within timeout.
+func Accept(conn net.Conn, timeout time.Duration) (*Conn, error) {
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, fmt.Errorf("setting deadline: %v", err)
+	}
+	r := bufio.NewReader(conn)
+	c := &Conn{conn, r, conn.RemoteAddr(), conn.LocalAddr()}
+	if err := c.readHeader(); err != nil {
+		return nil, err
+	}
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		return nil, fmt.Errorf("clearing deadline: %v", err)
+	}
+	return c, nil
+}
+
+func (c *Conn) readHeader() error {
+	// Version 1 starts with "PROXY ", version 2 with its signature. Peek at most the
+	// signature, the header can be shorter.
+	buf, err := c.r.Peek(len(sigV2))
+	if err != nil && len(buf) < len("PROXY ") {
+		if err == io.EOF {
+			return ErrMissing
+		}
+		return fmt.Errorf("reading proxy protocol header: %w", err)
+	}
+	if bytes.Equal(buf, sigV2) {
+		return c.readV2()
+	} else if bytes.HasPrefix(buf, []byte("PROXY ")) {
+		return c.readV1()
+	}
+	return ErrMissing
+}
+
+func (c *Conn) readV1() error {
+	// Line is at most 107 bytes, including CRLF.
+	var line []byte
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return fmt.Errorf("%w: reading line: %v", ErrInvalid, err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+		if len(line) >= 107 {
+			return fmt.Errorf("%w: line too long", ErrInvalid)
+		}
+	}
+	s, ok := strings.CutSuffix(string(line), "\r\n")
+	if !ok {
+		return fmt.Errorf("%w: line does not end with crlf", ErrInvalid)
+	}
+	t := strings.Split(s, " ")
+	if len(t) >= 2 && t[1] == "UNKNOWN" {
+		// Remainder of line must be ignored.
+		return nil
+	}
+	if len(t) != 6 {
+		return fmt.Errorf("%w: expected 6 fields, got %d", ErrInvalid, len(t))
+	}
+	srcIP := net.ParseIP(t[2])
+	dstIP := net.ParseIP(t[3])
+	if srcIP == nil || dstIP == nil {
+		return fmt.Errorf("%w: bad ip address", ErrInvalid)
+	}
+	switch t[1] {
+	case "TCP4":
+		if srcIP.To4() == nil || dstIP.To4() == nil {
+			return fmt.Errorf("%w: expected ipv4 addresses", ErrInvalid)
+		}
+	case "TCP6":
+		if srcIP.To4() != nil || dstIP.To4() != nil {
+			return fmt.Errorf("%w: expected ipv6 addresses", ErrInvalid)
+		}
+	default:
+		return fmt.Errorf("%w: unknown protocol %q", ErrInvalid, t[1])
+	}
+	parsePort := func(s string) (int, error) {
+		// No leading zeroes allowed.
+		if s != "0" && strings.HasPrefix(s, "0") {
+			return 0, fmt.Errorf("%w: port with leading zero", ErrInvalid)
+		}
+		v, err := strconv.ParseUint(s, 10, 16)
+		if err != nil {
+			return 0, fmt.Errorf("%w: bad port: %v", ErrInvalid, err)
+		}
+		return int(v), nil
+	}
+	srcPort, err := parsePort(t[4])
+	if err != nil {
+		return err
+	}
+	dstPort, err := parsePort(t[5])
+	if err != nil {
+		return err
+	}
+	c.remote = &net.TCPAddr{IP: srcIP, Port: srcPort}
+	c.local = &net.TCPAddr{IP: dstIP, Port: dstPort}
+	return nil
+}
+
+func (c *Conn) readV2() error {
+	var hdr [16]byte
+	if _, err := io.ReadFull(c.r, hdr[:]); err != nil {
+		return fmt.Errorf("%w: reading header: %v", ErrInvalid, err)
+	}
+	if hdr[12]>>4 != 2 {
+		return fmt.Errorf("%w: unknown version %d", ErrInvalid, hdr[12]>>4)
+	}
+	cmd := hdr[12] & 0x0f
+	family := hdr[13]
+	size := int(binary.BigEndian.Uint16(hdr[14:16]))
+	data := make([]byte, size)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return fmt.Errorf("%w: reading addresses: %v", ErrInvalid, err)
+	}
+	switch cmd {
+	case 0:
+		// LOCAL, e.g. health check by proxy itself. Keep original addresses.
+		return nil
+	case 1:
+		// PROXY
+	default:
+		return fmt.Errorf("%w: unknown command %d", ErrInvalid, cmd)
+	}
+
+	// We only handle TCP over IPv4 and IPv6. Data for other families is ignored, and
+	// any TLVs following the addresses as well.
+	switch family {
+	case 0x11:
+		if len(data) < 12 {
+			return fmt.Errorf("%w: short ipv4 addresses", ErrInvalid)
+		}
+		c.remote = &net.TCPAddr{IP: net.IP(data[0:4]), Port: int(binary.BigEndian.Uint16(data[8:10]))}
+		c.local = &net.TCPAddr{IP: net.IP(data[4:8]), Port: int(binary.BigEndian.Uint16(data[10:12]))}
+	case 0x21:
+		if len(data) < 36 {
+			return fmt.Errorf("%w: short ipv6 addresses", ErrInvalid)
+		}
+		c.remote = &net.TCPAddr{IP: net.IP(data[0:16]), Port: int(binary.BigEndian.Uint16(data[32:34]))}
+		c.local = &net.TCPAddr{IP: net.IP(data[16:32]), Port: int(binary.BigEndian.Uint16(data[34:36]))}
+	}
+	return nil
+}
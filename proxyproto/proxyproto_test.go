@@ -0,0 +1,106 @@
+package proxyproto
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAccept(t *testing.T) {
+	test := func(header []byte, expErr error, expRemote, expLocal string) {
+		t.Helper()
+
+		server, client := net.Pipe()
+		defer server.Close()
+		defer client.Close()
+
+		go func() {
+			client.Write(header)
+			client.Write([]byte("EHLO\r\n"))
+			client.Close()
+		}()
+
+		c, err := Accept(server, time.Second)
+		if (err == nil) != (expErr == nil) || err != nil && !errors.Is(err, expErr) {
+			t.Fatalf("got err %v, expected %v", err, expErr)
+		}
+		if err != nil {
+			return
+		}
+		if expRemote != "" && c.RemoteAddr().String() != expRemote {
+			t.Fatalf("got remote %s, expected %s", c.RemoteAddr(), expRemote)
+		}
+		if expLocal != "" && c.LocalAddr().String() != expLocal {
+			t.Fatalf("got local %s, expected %s", c.LocalAddr(), expLocal)
+		}
+		buf, err := io.ReadAll(c)
+		if err != nil {
+			t.Fatalf("reading data after header: %v", err)
+		}
+		if string(buf) != "EHLO\r\n" {
+			t.Fatalf("got data %q after header", buf)
+		}
+	}
+
+	test([]byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 25\r\n"), nil, "192.0.2.1:56324", "198.51.100.1:25")
+	test([]byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 993\r\n"), nil, "[2001:db8::1]:56324", "[2001:db8::2]:993")
+	test([]byte("PROXY UNKNOWN\r\n"), nil, "pipe", "pipe")
+	test([]byte("PROXY UNKNOWN ffff::1 ffff::2 1 2\r\n"), nil, "pipe", "pipe")
+	test([]byte("PROXY TCP4 2001:db8::1 198.51.100.1 56324 25\r\n"), ErrInvalid, "", "")
+	test([]byte("PROXY TCP4 192.0.2.1 198.51.100.1 056324 25\r\n"), ErrInvalid, "", "")
+	test([]byte("PROXY TCP4 192.0.2.1 198.51.100.1 65536 25\r\n"), ErrInvalid, "", "")
+	test([]byte("PROXY TCP4 192.0.2.1 198.51.100.1 1 25\n"), ErrInvalid, "", "")
+	test([]byte("PROXY UDP4 192.0.2.1 198.51.100.1 1 25\r\n"), ErrInvalid, "", "")
+	test([]byte(""), ErrMissing, "", "")
+
+	v2 := func(cmd, family byte, addrs []byte) []byte {
+		buf := append([]byte{}, sigV2...)
+		buf = append(buf, 0x20|cmd, family, 0, 0)
+		binary.BigEndian.PutUint16(buf[14:16], uint16(len(addrs)))
+		return append(buf, addrs...)
+	}
+	ipv4 := []byte{192, 0, 2, 1, 198, 51, 100, 1, 0xdc, 0x04, 0, 25}
+	test(v2(1, 0x11, ipv4), nil, "192.0.2.1:56324", "198.51.100.1:25")
+	ipv6 := append(append(net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")...), 0xdc, 0x04, 0x03, 0xe1)
+	test(v2(1, 0x21, ipv6), nil, "[2001:db8::1]:56324", "[2001:db8::2]:993")
+	// TLVs after the addresses are skipped.
+	test(v2(1, 0x11, append(ipv4, 0x04, 0, 1, 'x')), nil, "192.0.2.1:56324", "198.51.100.1:25")
+	test(v2(0, 0x00, nil), nil, "pipe", "pipe") // LOCAL
+	test(v2(1, 0x11, ipv4[:8]), ErrInvalid, "", "")
+	test(v2(2, 0x11, ipv4), ErrInvalid, "", "")
+}
+
+func TestTrusted(t *testing.T) {
+	_, n, err := net.ParseCIDR("192.0.2.0/24")
+	if err != nil {
+		t.Fatalf("parse cidr: %v", err)
+	}
+	trusted := []*net.IPNet{n}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	defer conn.Close()
+	if Trusted(conn, trusted) {
+		t.Fatalf("localhost unexpectedly trusted")
+	}
+	_, n, _ = net.ParseCIDR("127.0.0.0/8")
+	if !Trusted(conn, []*net.IPNet{n}) {
+		t.Fatalf("localhost not trusted")
+	}
+}
@@ -107,6 +107,8 @@ func flagFilterSort(fs *flag.FlagSet, f *queue.Filter, s *queue.Sort) {
 	fs.StringVar(&f.Account, "account", "", "account that queued the message")
 	fs.StringVar(&f.From, "from", "", `from address of message, use "@example.com" to match all messages for a domain`)
 	fs.StringVar(&f.To, "to", "", `recipient address of message, use "@example.com" to match all messages for a domain`)
+	fs.StringVar(&f.SenderDomain, "senderdomain", "", "exact domain of sender address, unicode")
+	fs.StringVar(&f.RecipientDomain, "recipientdomain", "", "exact domain of recipient address, unicode")
 	fs.StringVar(&f.Submitted, "submitted", "", `filter by time of submission relative to now, value must start with "<" (before now) or ">" (after now)`)
 	fs.StringVar(&f.NextAttempt, "nextattempt", "", `filter by time of next delivery attempt relative to now, value must start with "<" (before now) or ">" (after now)`)
 	fs.Func("transport", "transport to use for messages, empty string sets the default behaviour", func(v string) error {
@@ -0,0 +1,124 @@
+package queue
+
+import (
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/smtpclient"
+)
+
+// Outgoing SMTP connections for direct delivery can be kept open after delivery,
+// for recipient domains configured in ConnectionReuse, and reused for a next
+// delivery to the same host. Deliveries to a recipient domain are done one at a
+// time (see busyDomains), so at most one connection per recipient domain is in
+// use, and at most one is kept idle per recipient domain and host.
+
+// connReuseKey identifies connections that can be reused. A connection is only
+// reused for a delivery with the same TLS requirements as the delivery that made
+// the connection.
+type connReuseKey struct {
+	recipientDomain dns.Domain
+	host            string
+	transportName   string
+	ourHostname     dns.Domain
+	tlsMode         smtpclient.TLSMode
+	tlsPKIX         bool
+	tlsDANE         bool
+	daneVerified    bool
+	tlsRequiredNo   bool
+}
+
+type connReuse struct {
+	sc       *smtpclient.Client
+	conn     net.Conn // Underlying connection, registered in mox.Connections.
+	remoteIP net.IP
+}
+
+var connReuseIdle = struct {
+	sync.Mutex
+	conns map[connReuseKey]*connReuse
+}{conns: map[connReuseKey]*connReuse{}}
+
+// connReuseIdleTime returns whether connections for deliveries to the recipient
+// domain are to be reused, and how long idle connections are kept open.
+func connReuseIdleTime(recipientDomain dns.Domain) (time.Duration, bool) {
+	cr := mox.Conf.Static.ConnectionReuse
+	if cr == nil {
+		return 0, false
+	}
+	if _, ok := cr.ParsedDomains[recipientDomain]; !ok {
+		return 0, false
+	}
+	if cr.IdleTime == 0 {
+		return 30 * time.Second, true
+	}
+	return cr.IdleTime, true
+}
+
+// connReuseTake returns an idle connection for key, if any. The connection is
+// checked with an SMTP RSET command. If that fails, e.g. because the remote server
+// closed the connection, the connection is closed and nil is returned, and the
+// caller should make a new connection. The caller owns the returned connection.
+func connReuseTake(log mlog.Log, key connReuseKey) *connReuse {
+	connReuseIdle.Lock()
+	cr := connReuseIdle.conns[key]
+	delete(connReuseIdle.conns, key)
+	connReuseIdle.Unlock()
+	if cr == nil {
+		return nil
+	}
+
+	if err := cr.sc.Reset(); err != nil {
+		log.Debugx("reset of idle smtp connection failed, making new connection", err, slog.String("host", key.host))
+		cr.close(log)
+		return nil
+	}
+	log.Debug("reusing idle smtp connection", slog.String("host", key.host), slog.Any("remoteip", cr.remoteIP))
+	return cr
+}
+
+// connReusePut keeps the connection open for reuse for idle time. If the
+// connection isn't taken within that time, it is closed.
+func connReusePut(log mlog.Log, key connReuseKey, cr *connReuse, idle time.Duration) {
+	connReuseIdle.Lock()
+	prev := connReuseIdle.conns[key]
+	connReuseIdle.conns[key] = cr
+	connReuseIdle.Unlock()
+	if prev != nil {
+		prev.close(log)
+	}
+
+	time.AfterFunc(idle, func() {
+		connReuseIdle.Lock()
+		expired := connReuseIdle.conns[key] == cr
+		if expired {
+			delete(connReuseIdle.conns, key)
+		}
+		connReuseIdle.Unlock()
+		if expired {
+			cr.close(mlog.New("queue", nil))
+		}
+	})
+}
+
+// connReuseCloseAll closes all idle connections, e.g. when shutting down.
+func connReuseCloseAll(log mlog.Log) {
+	connReuseIdle.Lock()
+	conns := connReuseIdle.conns
+	connReuseIdle.conns = map[connReuseKey]*connReuse{}
+	connReuseIdle.Unlock()
+	for _, cr := range conns {
+		cr.close(log)
+	}
+}
+
+func (cr *connReuse) close(log mlog.Log) {
+	err := cr.sc.Close()
+	log.Check(err, "closing idle smtp connection")
+	mox.Connections.Unregister(cr.conn)
+}
@@ -0,0 +1,152 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/smtpclient"
+)
+
+func TestConnectionReuse(t *testing.T) {
+	_, cleanup := setup(t)
+	defer cleanup()
+
+	mox.Conf.Static.ConnectionReuse = &config.ConnectionReuse{
+		Domains:       []string{"mox.example"},
+		IdleTime:      time.Minute,
+		ParsedDomains: map[dns.Domain]struct{}{{ASCII: "mox.example"}: {}},
+	}
+	defer func() {
+		mox.Conf.Static.ConnectionReuse = nil
+		connReuseCloseAll(pkglog)
+	}()
+
+	resolver := dns.MockResolver{
+		A:  map[string][]string{"mail.mox.example.": {"127.0.0.1"}},
+		MX: map[string][]*net.MX{"mox.example.": {{Host: "mail.mox.example", Pref: 10}}},
+	}
+
+	// Fake SMTP server that handles transactions until QUIT or the connection is
+	// closed.
+	var mu sync.Mutex
+	var servers []net.Conn
+	var ndelivered, nrset int
+	fakeServer := func(server net.Conn) {
+		defer server.Close()
+
+		fmt.Fprintf(server, "220 mail.mox.example\r\n")
+		br := bufio.NewReader(server)
+		writeline := func(s string) {
+			fmt.Fprintf(server, "%s\r\n", s)
+		}
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(line)), " ")
+			switch cmd {
+			case "ehlo":
+				writeline("250-mail.mox.example")
+				writeline("250 pipelining")
+			case "mail", "rcpt":
+				writeline("250 ok")
+			case "data":
+				writeline("354 continue")
+				io.Copy(io.Discard, smtp.NewDataReader(br))
+				mu.Lock()
+				ndelivered++
+				mu.Unlock()
+				writeline("250 ok")
+			case "rset":
+				mu.Lock()
+				nrset++
+				mu.Unlock()
+				writeline("250 ok")
+			case "quit":
+				writeline("221 ok")
+				return
+			default:
+				writeline("500 unknown command")
+			}
+		}
+	}
+
+	smtpclient.DialHook = func(ctx context.Context, dialer smtpclient.Dialer, timeout time.Duration, addr string, laddr net.Addr) (net.Conn, error) {
+		server, client := net.Pipe()
+		mu.Lock()
+		servers = append(servers, server)
+		mu.Unlock()
+		go fakeServer(server)
+		return client, nil
+	}
+	defer func() {
+		smtpclient.DialHook = nil
+	}()
+
+	path := smtp.Path{Localpart: "mjl", IPDomain: dns.IPDomain{Domain: dns.Domain{ASCII: "mox.example"}}}
+	mf := prepareFile(t)
+	defer os.Remove(mf.Name())
+	defer mf.Close()
+
+	deliverOne := func() {
+		t.Helper()
+
+		qm := MakeMsg(path, path, false, false, int64(len(testmsg)), "<test@localhost>", nil, nil, time.Now(), "test")
+		err := Add(ctxbg, pkglog, "mjl", mf, qm)
+		tcheck(t, err, "add message to queue for delivery")
+
+		n := launchWork(pkglog, resolver, map[string]struct{}{})
+		tcompare(t, n, 1)
+		timer := time.NewTimer(time.Second)
+		defer timer.Stop()
+		select {
+		case <-deliveryResults:
+		case <-timer.C:
+			t.Fatalf("no delivery within 1s")
+		}
+
+		msgs, err := List(ctxbg, Filter{}, Sort{})
+		tcheck(t, err, "list queue")
+		tcompare(t, len(msgs), 0)
+	}
+	check := func(expDials, expDelivered, expRset int) {
+		t.Helper()
+		mu.Lock()
+		defer mu.Unlock()
+		tcompare(t, len(servers), expDials)
+		tcompare(t, ndelivered, expDelivered)
+		tcompare(t, nrset, expRset)
+	}
+
+	// First delivery makes a connection, the second reuses it after an RSET.
+	deliverOne()
+	check(1, 1, 0)
+	deliverOne()
+	check(1, 2, 1)
+
+	// If the remote server closed the idle connection, the RSET fails and a new
+	// connection is made.
+	mu.Lock()
+	servers[0].Close()
+	mu.Unlock()
+	deliverOne()
+	check(2, 3, 1)
+
+	// Without connection reuse for the domain, the idle connection isn't used.
+	mox.Conf.Static.ConnectionReuse.ParsedDomains = map[dns.Domain]struct{}{}
+	deliverOne()
+	check(3, 4, 1)
+}
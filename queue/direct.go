@@ -589,9 +589,19 @@ func deliverHost(log mlog.Log, resolver dns.Resolver, dialer smtpclient.Dialer,
 		return deliverResult{err: smtpErr}
 	}
 
-	// Dial the remote host given the IPs if no error yet.
+	// Reuse an idle connection to the host if configured for the recipient domain,
+	// otherwise dial the remote host given the IPs if no error yet.
 	var conn net.Conn
-	if err == nil {
+	var sc *smtpclient.Client
+	reuseIdle, reuse := connReuseIdleTime(m0.RecipientDomain.Domain)
+	reuseKey := connReuseKey{m0.RecipientDomain.Domain, host.String(), transportName, ourHostname, tlsMode, tlsPKIX, tlsDANE, len(daneRecords) > 0, tlsRequiredNo}
+	if err == nil && reuse {
+		if cr := connReuseTake(log, reuseKey); cr != nil {
+			conn, sc, remoteIP = cr.conn, cr.sc, cr.remoteIP
+		}
+	}
+	reused := sc != nil
+	if err == nil && !reused {
 		connectionCounter.Add(1)
 		conn, remoteIP, err = smtpclient.Dial(ctx, log.Logger, dialer, host, ips, 25, m0.DialedIPs, mox.Conf.Static.SpecifiedSMTPListenIPs)
 	}
@@ -600,6 +610,8 @@ func deliverHost(log mlog.Log, resolver dns.Resolver, dialer smtpclient.Dialer,
 	// Set error for metrics.
 	var dialResult string
 	switch {
+	case err == nil && reused:
+		dialResult = "reused"
 	case err == nil:
 		dialResult = "ok"
 	case errors.Is(err, os.ErrDeadlineExceeded), errors.Is(err, context.DeadlineExceeded):
@@ -624,7 +636,9 @@ func deliverHost(log mlog.Log, resolver dns.Resolver, dialer smtpclient.Dialer,
 	log = log.With(slog.Any("remoteip", remoteIP))
 	ctx, cancel = context.WithTimeout(mox.Shutdown, 30*time.Minute)
 	defer cancel()
-	mox.Connections.Register(conn, "smtpclient", "queue")
+	if !reused {
+		mox.Connections.Register(conn, "smtpclient", "queue")
+	}
 
 	// Initialize SMTP session, sending EHLO/HELO and STARTTLS with specified tls mode.
 	var firstHost dns.Domain
@@ -644,8 +658,16 @@ func deliverHost(log mlog.Log, resolver dns.Resolver, dialer smtpclient.Dialer,
 		RecipientDomainResult: recipientDomainResult,
 		HostResult:            &hostResult,
 	}
-	sc, err := smtpclient.New(ctx, log.Logger, conn, tlsMode, tlsPKIX, ourHostname, firstHost, opts)
+	if !reused {
+		sc, err = smtpclient.New(ctx, log.Logger, conn, tlsMode, tlsPKIX, ourHostname, firstHost, opts)
+	}
 	defer func() {
+		if sc != nil && reuse && !sc.Botched() {
+			// Keep connection for a next delivery. The SMTP session is in a known state,
+			// any transaction has been completed or rejected.
+			connReusePut(log, reuseKey, &connReuse{sc, conn, remoteIP}, reuseIdle)
+			return
+		}
 		if sc == nil {
 			err := conn.Close()
 			log.Check(err, "closing smtp tcp connection")
@@ -655,7 +677,7 @@ func deliverHost(log mlog.Log, resolver dns.Resolver, dialer smtpclient.Dialer,
 		}
 		mox.Connections.Unregister(conn)
 	}()
-	if err == nil && m0.SenderAccount != "" {
+	if err == nil && !reused && m0.SenderAccount != "" {
 		// Remember the STARTTLS and REQUIRETLS support for this recipient domain.
 		// It is used in the webmail client, to show the recipient domain security mechanisms.
 		// We always save only the last connection we actually encountered. There may be
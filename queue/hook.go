@@ -281,7 +281,7 @@ func (f HookFilter) apply(q *bstore.Query[Hook]) error {
 }
 
 type HookSort struct {
-	Field  string // "Queued" or "NextAttempt"/"".
+	Field  string // "Submitted" or "NextAttempt"/"".
 	LastID int64  // If > 0, we return objects beyond this, less/greater depending on Asc.
 	Last   any    // Value of Field for last object. Must be set iff LastID is set.
 	Asc    bool   // Ascending, or descending.
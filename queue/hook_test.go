@@ -683,3 +683,39 @@ func TestHookListFilterSort(t *testing.T) {
 	tcheck(t, err, "list single")
 	tcompare(t, lr, []HookRetired{hrlrev[0]})
 }
+
+func TestHookListAccount(t *testing.T) {
+	_, cleanup := setup(t)
+	defer cleanup()
+
+	now := time.Now().Round(0)
+	h := Hook{0, 0, "fromid", "messageid", "subj", nil, "mjl", "http://localhost", "", false, "delivered", "", now, 0, now, []HookResult{}}
+	hother := h
+	hother.Account = "other"
+	hother.NextAttempt = now.Add(time.Minute)
+	hl := []Hook{h, hother, h, hother, hother}
+	err := DB.Write(ctxbg, func(tx *bstore.Tx) error {
+		for i := range hl {
+			err := hookInsert(tx, &hl[i], now, time.Minute)
+			tcheck(t, err, "insert hook")
+		}
+		return nil
+	})
+	tcheck(t, err, "inserting hooks")
+
+	l, err := HookList(ctxbg, HookFilter{Account: "mjl"}, HookSort{Asc: true})
+	tcheck(t, err, "list")
+	tcompare(t, l, []Hook{hl[0], hl[2]})
+
+	l, err = HookList(ctxbg, HookFilter{Account: "other"}, HookSort{Asc: true})
+	tcheck(t, err, "list")
+	tcompare(t, l, []Hook{hl[1], hl[3], hl[4]})
+	for _, h := range l {
+		tcompare(t, h.Attempts, 0)
+		tcompare(t, h.NextAttempt, now.Add(time.Minute))
+	}
+
+	l, err = HookList(ctxbg, HookFilter{Account: "unknown"}, HookSort{})
+	tcheck(t, err, "list")
+	tcompare(t, len(l), 0)
+}
@@ -53,7 +53,7 @@ var (
 			Help: "Queue client connections, outgoing.",
 		},
 		[]string{
-			"result", // "ok", "timeout", "canceled", "error"
+			"result", // "ok", "reused", "timeout", "canceled", "error"
 		},
 	)
 	metricDelivery = promauto.NewHistogramVec(
@@ -406,6 +406,16 @@ type Filter struct {
 	Submitted   string // Whether submitted before/after a time relative to now. ">$duration" or "<$duration", also with "now" for duration.
 	NextAttempt string // ">$duration" or "<$duration", also with "now" for duration.
 	Transport   *string
+
+	// Exact domain of the sender or recipient, unicode. Can also be an IP address
+	// enclosed in [].
+	SenderDomain    string
+	RecipientDomain string
+
+	// Window for NextAttempt, for selecting messages scheduled in a period, or
+	// overdue. Both bounds are exclusive.
+	NextAttemptBefore *time.Time
+	NextAttemptAfter  *time.Time
 }
 
 func (f Filter) apply(q *bstore.Query[Msg]) error {
@@ -449,12 +459,24 @@ func (f Filter) apply(q *bstore.Query[Msg]) error {
 			return fmt.Errorf("applying filter for next attempt: %v", err)
 		}
 	}
+	if f.NextAttemptBefore != nil {
+		q.FilterLess("NextAttempt", *f.NextAttemptBefore)
+	}
+	if f.NextAttemptAfter != nil {
+		q.FilterGreater("NextAttempt", *f.NextAttemptAfter)
+	}
 	if f.Account != "" {
 		q.FilterNonzero(Msg{SenderAccount: f.Account})
 	}
 	if f.Transport != nil {
 		q.FilterEqual("Transport", *f.Transport)
 	}
+	if f.SenderDomain != "" {
+		q.FilterNonzero(Msg{SenderDomainStr: f.SenderDomain})
+	}
+	if f.RecipientDomain != "" {
+		q.FilterNonzero(Msg{RecipientDomainStr: f.RecipientDomain})
+	}
 	if f.From != "" || f.To != "" {
 		q.FilterFn(func(m Msg) bool {
 			return f.From != "" && strings.Contains(m.Sender().XString(true), f.From) || f.To != "" && strings.Contains(m.Recipient().XString(true), f.To)
@@ -824,6 +846,29 @@ func NextAttemptSet(ctx context.Context, filter Filter, t time.Time) (affected i
 	return n, nil
 }
 
+// Requeue sets NextAttempt to now and resets Attempts, and with it the backoff
+// for the next attempts, for all matching messages, and kicks the queue. Messages
+// on hold are updated too, but not delivered until their hold is removed.
+func Requeue(ctx context.Context, filter Filter) (affected int, err error) {
+	err = DB.Write(ctx, func(tx *bstore.Tx) error {
+		q := bstore.QueryTx[Msg](tx)
+		if err := filter.apply(q); err != nil {
+			return err
+		}
+		n, err := q.UpdateFields(map[string]any{"NextAttempt": time.Now(), "Attempts": 0})
+		if err != nil {
+			return fmt.Errorf("selecting and updating messages in queue: %v", err)
+		}
+		affected = n
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	msgqueueKick()
+	return affected, nil
+}
+
 // HoldSet sets Hold for all matching messages and kicks the queue.
 func HoldSet(ctx context.Context, filter Filter, hold bool) (affected int, err error) {
 	err = DB.Write(ctx, func(tx *bstore.Tx) error {
@@ -1173,6 +1218,7 @@ func startQueue(resolver dns.Resolver, done chan struct{}) {
 				domain := <-deliveryResults
 				delete(busyDomains, domain)
 			}
+			connReuseCloseAll(log)
 			done <- struct{}{}
 			return
 		case <-msgqueue:
@@ -1495,3 +1495,45 @@ func fakeCert(t *testing.T, name string, expired bool) tls.Certificate {
 	}
 	return c
 }
+
+func TestFilterNextAttemptWindow(t *testing.T) {
+	_, cleanup := setup(t)
+	defer cleanup()
+
+	path := smtp.Path{Localpart: "mjl", IPDomain: dns.IPDomain{Domain: dns.Domain{ASCII: "mox.example"}}}
+	mf := prepareFile(t)
+	defer os.Remove(mf.Name())
+	defer mf.Close()
+
+	// Messages overdue by an hour, due now, in 30 minutes and in 2 hours.
+	now := time.Now().Round(0)
+	var qml []Msg
+	for _, d := range []time.Duration{-time.Hour, 0, 30 * time.Minute, 2 * time.Hour} {
+		qm := MakeMsg(path, path, false, false, int64(len(testmsg)), "<test@localhost>", nil, nil, now, "test")
+		qm.Queued = now
+		qm.NextAttempt = now.Add(d)
+		qml = append(qml, qm)
+	}
+	err := Add(ctxbg, pkglog, "mjl", mf, qml...)
+	tcheck(t, err, "add messages to queue")
+
+	ptr := func(t time.Time) *time.Time { return &t }
+
+	test := func(f Filter, exp ...Msg) {
+		t.Helper()
+		l, err := List(ctxbg, f, Sort{Asc: true})
+		tcheck(t, err, "list messages")
+		tcompare(t, l, exp)
+	}
+
+	test(Filter{NextAttemptBefore: ptr(now.Add(-time.Minute))}, qml[0])
+	test(Filter{NextAttemptAfter: ptr(now.Add(time.Minute))}, qml[2], qml[3])
+	test(Filter{NextAttemptAfter: ptr(now.Add(-time.Minute)), NextAttemptBefore: ptr(now.Add(time.Hour))}, qml[1], qml[2])
+	test(Filter{NextAttemptAfter: ptr(now.Add(time.Hour)), NextAttemptBefore: ptr(now.Add(time.Minute))}, []Msg{}...)
+
+	// Fail uses the same filter.
+	n, err := Fail(ctxbg, pkglog, Filter{NextAttemptAfter: ptr(now.Add(time.Hour))})
+	tcheck(t, err, "fail messages")
+	tcompare(t, n, 1)
+	test(Filter{}, qml[0], qml[1], qml[2])
+}
@@ -85,6 +85,66 @@ func SuppressionRemove(ctx context.Context, account string, address smtp.Path) e
 	return nil
 }
 
+// SuppressionImport adds suppressions for account in a single transaction, e.g.
+// for migrating suppression lists from another instance. BaseAddress must be set
+// for each entry and must be a valid address. OriginalAddress is set to
+// BaseAddress if empty. The ID and Account fields of entries are ignored, Created
+// is kept if set. Entries with a base address already present for the account
+// are skipped. The number of added suppressions is returned.
+//
+// SuppressionImport does not check if an account exists.
+func SuppressionImport(ctx context.Context, account string, entries []webapi.Suppression) (added int, rerr error) {
+	if account == "" {
+		return 0, fmt.Errorf("account required")
+	}
+
+	l := make([]webapi.Suppression, len(entries))
+	for i, sup := range entries {
+		if _, err := smtp.ParseAddress(sup.BaseAddress); err != nil {
+			return 0, fmt.Errorf("parsing base address %q: %v", sup.BaseAddress, err)
+		}
+		if sup.OriginalAddress == "" {
+			sup.OriginalAddress = sup.BaseAddress
+		} else if _, err := smtp.ParseAddress(sup.OriginalAddress); err != nil {
+			return 0, fmt.Errorf("parsing original address %q: %v", sup.OriginalAddress, err)
+		}
+		sup.ID = 0
+		sup.Account = account
+		l[i] = sup
+	}
+
+	err := DB.Write(ctx, func(tx *bstore.Tx) error {
+		for _, sup := range l {
+			exists, err := bstore.QueryTx[webapi.Suppression](tx).FilterNonzero(webapi.Suppression{Account: account, BaseAddress: sup.BaseAddress}).Exists()
+			if err != nil {
+				return fmt.Errorf("checking if address is in suppression list: %v", err)
+			} else if exists {
+				continue
+			}
+			if err := tx.Insert(&sup); err != nil {
+				return fmt.Errorf("inserting suppression for %q: %v", sup.BaseAddress, err)
+			}
+			added++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return added, nil
+}
+
+// SuppressionExport returns all suppressions for account, ordered by base
+// address, for importing with SuppressionImport.
+//
+// SuppressionExport does not check if an account exists.
+func SuppressionExport(ctx context.Context, account string) ([]webapi.Suppression, error) {
+	q := bstore.QueryDB[webapi.Suppression](ctx, DB)
+	q.FilterNonzero(webapi.Suppression{Account: account})
+	q.SortAsc("BaseAddress", "ID")
+	return q.List()
+}
+
 type suppressionCheck struct {
 	MsgID     int64
 	Account   string
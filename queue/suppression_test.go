@@ -2,7 +2,9 @@ package queue
 
 import (
 	"testing"
+	"time"
 
+	"github.com/mjl-/mox/dns"
 	"github.com/mjl-/mox/smtp"
 	"github.com/mjl-/mox/webapi"
 )
@@ -103,3 +105,60 @@ func TestSuppression(t *testing.T) {
 	err = SuppressionRemove(ctxbg, "bogus", path1)
 	tcheck(t, err, "remove suppression")
 }
+
+func TestSuppressionImportExport(t *testing.T) {
+	_, cleanup := setup(t)
+	defer cleanup()
+
+	now := time.Now().Round(0)
+	entries := []webapi.Suppression{
+		{BaseAddress: "zz@mox.example", OriginalAddress: "z.z+x@mox.example", Manual: true, Reason: "manual", Created: now.Add(-time.Hour)},
+		{BaseAddress: "aa@mox.example", Reason: "bounce"},
+		{BaseAddress: "aa@mox.example", Reason: "duplicate"},
+	}
+	added, err := SuppressionImport(ctxbg, "mjl", entries)
+	tcheck(t, err, "import")
+	tcompare(t, added, 2)
+
+	// Entries for other accounts are not exported.
+	err = SuppressionAdd(ctxbg, smtp.Path{Localpart: "other", IPDomain: dns.IPDomain{Domain: dns.Domain{ASCII: "mox.example"}}}, &webapi.Suppression{Account: "other"})
+	tcheck(t, err, "add suppression")
+
+	l, err := SuppressionExport(ctxbg, "mjl")
+	tcheck(t, err, "export")
+	tcompare(t, len(l), 2)
+	tcompare(t, l[0].BaseAddress, "aa@mox.example")
+	tcompare(t, l[0].OriginalAddress, "aa@mox.example")
+	tcompare(t, l[0].Reason, "bounce")
+	tcompare(t, l[1].BaseAddress, "zz@mox.example")
+	tcompare(t, l[1].OriginalAddress, "z.z+x@mox.example")
+	tcompare(t, l[1].Manual, true)
+	tcompare(t, l[1].Created.Equal(now.Add(-time.Hour)), true)
+
+	// Importing the export into another account results in the same list.
+	added, err = SuppressionImport(ctxbg, "mjl2", l)
+	tcheck(t, err, "import export")
+	tcompare(t, added, 2)
+	l2, err := SuppressionExport(ctxbg, "mjl2")
+	tcheck(t, err, "export")
+	for i := range l2 {
+		tcompare(t, l2[i].Account, "mjl2")
+		l2[i].ID = l[i].ID
+		l2[i].Account = l[i].Account
+	}
+	tcompare(t, l2, l)
+
+	// Re-importing skips existing entries.
+	added, err = SuppressionImport(ctxbg, "mjl", l)
+	tcheck(t, err, "reimport")
+	tcompare(t, added, 0)
+
+	// Invalid addresses fail the whole import.
+	_, err = SuppressionImport(ctxbg, "mjl", []webapi.Suppression{{BaseAddress: "new@mox.example"}, {BaseAddress: "bogus"}})
+	tcompare(t, err == nil, false)
+	_, err = SuppressionImport(ctxbg, "mjl", []webapi.Suppression{{BaseAddress: "new@mox.example", OriginalAddress: "bogus"}})
+	tcompare(t, err == nil, false)
+	l, err = SuppressionExport(ctxbg, "mjl")
+	tcheck(t, err, "export")
+	tcompare(t, len(l), 2)
+}
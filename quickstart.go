@@ -8,6 +8,7 @@ import (
 	"crypto/elliptic"
 	cryptorand "crypto/rand"
 	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
@@ -45,16 +46,29 @@ import (
 //go:embed mox.service
 var moxService string
 
+//go:embed mox.rc
+var moxRC string
+
+//go:embed mox.plist
+var moxPlist string
+
 func cmdQuickstart(c *cmd) {
-	c.params = "[-skipdial] [-existing-webserver] [-hostname host] user@domain [user | uid]"
+	c.params = "[-skipdial] [-existing-webserver] [-hostname host] [-no-dnsbl | -dnsbl zone ...] [-acme-directory url] [-acme-contact email] [-tls-cert certfile -tls-key keyfile] user@domain [user@domain ...] [user | uid]"
 	c.help = `Quickstart generates configuration files and prints instructions to quickly set up a mox instance.
 
 Quickstart writes configuration files, prints initial admin and account
-passwords, DNS records you should create. If you run it on Linux it writes a
-systemd service file and prints commands to enable and start mox as service.
+passwords, DNS records you should create. It writes a service file and prints
+commands to enable and start mox as service: a systemd service file on Linux,
+an rc.d script on FreeBSD and a launchd plist on macOS.
 
 All output is written to quickstart.log for later reference.
 
+Multiple email addresses can be specified to configure multiple domains from the
+start. The first address is used for the primary domain, its account receives
+messages for postmaster. Each further address configures an additional domain,
+with its own DKIM keys, and an account named after the localpart of the address.
+Addresses with the same localpart are added to a single account.
+
 The user or uid is optional, defaults to "mox", and is the user or uid/gid mox
 will run as after initialization.
 
@@ -67,7 +81,9 @@ you specified. Use flag -hostname to explicitly specify the hostname mox will
 run on.
 
 Mox is by far easiest to operate if you let it listen on port 443 (HTTPS) and
-80 (HTTP). TLS will be fully automatic with ACME with Let's Encrypt.
+80 (HTTP). TLS will be fully automatic with ACME with Let's Encrypt. Use flag
+-acme-directory for a different ACME provider, or flags -tls-cert and -tls-key
+to use an existing TLS certificate instead of ACME.
 
 You can run mox along with an existing webserver, but because of MTA-STS and
 autoconfig, you'll need to forward HTTPS traffic for two domains to mox. Run
@@ -85,13 +101,68 @@ output of "mox config describe-domains" and see the output of
 	var existingWebserver bool
 	var hostname string
 	var skipDial bool
+	var noDNSBL bool
+	var dnsblZones stringList
+	var acmeDirectory, acmeContact string
+	var tlsCertFile, tlsKeyFile string
+	var dnssecRequired bool
 	c.flag.BoolVar(&existingWebserver, "existing-webserver", false, "use if a webserver is already running, so mox won't listen on port 80 and 443; you'll have to provide tls certificates/keys, and configure the existing webserver as reverse proxy, forwarding requests to mox.")
 	c.flag.StringVar(&hostname, "hostname", "", "hostname mox will run on, by default the hostname of the machine quickstart runs on; if specified, the IPs for the hostname are configured for the public listener")
 	c.flag.BoolVar(&skipDial, "skipdial", false, "skip check for outgoing smtp (port 25) connectivity or for domain age with rdap")
+	c.flag.BoolVar(&noDNSBL, "no-dnsbl", false, "do not check the host IPs in DNS block lists, and do not configure (commented out) DNS block lists for incoming deliveries or for monitoring")
+	c.flag.Var(&dnsblZones, "dnsbl", "DNS block list zone to use for checking the host IPs, for monitoring and for incoming deliveries, instead of the default suggested (commented out) lists; can be specified multiple times")
+	c.flag.StringVar(&acmeDirectory, "acme-directory", "", "directory url of acme provider to request tls certificates from, instead of let's encrypt")
+	c.flag.StringVar(&acmeContact, "acme-contact", "", "email address to register at the acme provider, instead of the email address for the new account")
+	c.flag.StringVar(&tlsCertFile, "tls-cert", "", "file with pem-encoded tls certificate chain to use for the public listener instead of acme, must be valid for the hostname, and the mta-sts, autoconfig and mail subdomains of the domains; requires -tls-key")
+	c.flag.StringVar(&tlsKeyFile, "tls-key", "", "file with pem-encoded private key for -tls-cert")
+	c.flag.BoolVar(&dnssecRequired, "dnssec-required", false, "require the dns resolvers to verify dnssec: quickstart fails if they don't, and the generated config makes mox refuse to start if they don't")
 	args := c.Parse()
-	if len(args) != 1 && len(args) != 2 {
+	// The optional last parameter is the user to run as, recognized by not being an
+	// email address.
+	user := "mox"
+	if len(args) >= 2 && !strings.Contains(args[len(args)-1], "@") {
+		user = args[len(args)-1]
+		args = args[:len(args)-1]
+	}
+	if len(args) == 0 {
 		c.Usage()
 	}
+	if (tlsCertFile == "") != (tlsKeyFile == "") {
+		log.Fatalf("-tls-cert and -tls-key must both be set")
+	}
+	if tlsCertFile != "" && (acmeDirectory != "" || acmeContact != "") {
+		log.Fatalf("cannot have both -tls-cert/-tls-key and -acme-directory/-acme-contact")
+	}
+	if existingWebserver && (tlsCertFile != "" || acmeDirectory != "" || acmeContact != "") {
+		log.Fatalf("cannot have -existing-webserver with -tls-cert/-tls-key or -acme-directory/-acme-contact")
+	}
+	if acmeDirectory != "" {
+		if u, err := url.Parse(acmeDirectory); err != nil || u.Scheme != "https" || u.Host == "" {
+			log.Fatalf("acme directory must be an https url")
+		}
+	}
+	if acmeContact != "" {
+		if _, err := smtp.ParseAddress(acmeContact); err != nil {
+			log.Fatalf("parsing acme contact email address: %v", err)
+		}
+	}
+	if tlsCertFile != "" {
+		// Config paths are relative to the config directory, we use absolute paths.
+		var err error
+		tlsCertFile, err = filepath.Abs(tlsCertFile)
+		if err == nil {
+			tlsKeyFile, err = filepath.Abs(tlsKeyFile)
+		}
+		if err != nil {
+			log.Fatalf("absolute path for tls certificate and key files: %v", err)
+		}
+		if _, err := tls.LoadX509KeyPair(tlsCertFile, tlsKeyFile); err != nil {
+			log.Fatalf("loading tls certificate and key: %v", err)
+		}
+	}
+	if noDNSBL && len(dnsblZones) > 0 {
+		log.Fatalf("cannot have both -no-dnsbl and -dnsbl")
+	}
 
 	// Write all output to quickstart.log.
 	logfile, err := os.Create("quickstart.log")
@@ -164,21 +235,37 @@ output of "mox config describe-domains" and see the output of
 		}
 	}
 
-	addr, err := smtp.ParseAddress(args[0])
-	if err != nil {
-		fatalf("parsing email address: %s", err)
+	var addrs []smtp.Address
+	for _, arg := range args {
+		a, err := smtp.ParseAddress(arg)
+		if err != nil {
+			fatalf("parsing email address %q: %s", arg, err)
+		}
+		if slices.ContainsFunc(addrs, func(o smtp.Address) bool { return o.Domain == a.Domain }) {
+			fatalf("domain %s specified multiple times", a.Domain)
+		}
+		addrs = append(addrs, a)
 	}
+	addr := addrs[0]
 	accountName := addr.Localpart.String()
 	domain := addr.Domain
 
-	for _, c := range accountName {
-		if c > 0x7f {
-			fmt.Printf(`NOTE: Username %q is not ASCII-only. It is recommended you also configure an
+	noted := map[string]bool{}
+	for _, a := range addrs {
+		name := a.Localpart.String()
+		if noted[name] {
+			continue
+		}
+		noted[name] = true
+		for _, c := range name {
+			if c > 0x7f {
+				fmt.Printf(`NOTE: Username %q is not ASCII-only. It is recommended you also configure an
 ASCII-only alias. Both for delivery of email from other systems, and for
 logging in with IMAP.
 
-`, accountName)
-			break
+`, name)
+				break
+			}
 		}
 	}
 
@@ -189,13 +276,12 @@ logging in with IMAP.
 	resolveCtx, resolveCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer resolveCancel()
 
-	// Some DNSSEC-verifying resolvers return unauthentic data for ".", so we check "com".
 	fmt.Printf("Checking if DNS resolvers are DNSSEC-verifying...")
-	_, resolverDNSSECResult, err := resolver.LookupNS(resolveCtx, "com.")
-	if err != nil {
+	err = dns.CheckResolverDNSSEC(resolveCtx, resolver)
+	if err != nil && !errors.Is(err, dns.ErrResolverNoDNSSEC) {
 		fmt.Println("")
 		fatalf("checking dnssec support in resolver: %v", err)
-	} else if !resolverDNSSECResult.Authentic {
+	} else if err != nil {
 		fmt.Printf(`
 
 WARNING: It looks like the DNS resolvers configured on your system do not
@@ -234,6 +320,9 @@ Troubleshooting hints:
 - Increase logging in unbound, see options "verbosity" and "log-queries".
 
 `)
+		if dnssecRequired {
+			fatalf("dns resolvers do not verify dnssec, but -dnssec-required was specified")
+		}
 	} else {
 		fmt.Println(" OK")
 	}
@@ -610,46 +699,67 @@ in mox.conf and use it in "Routes" in domains.conf. See
 `)
 		}
 
-		// Check if domain is recently registered.
-		rdapctx, rdapcancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer rdapcancel()
-		orgdom := publicsuffix.Lookup(rdapctx, c.log.Logger, domain)
-		fmt.Printf("\nChecking if domain %s was registered recently...", orgdom)
-		registration, err := rdap.LookupLastDomainRegistration(rdapctx, c.log, orgdom)
-		rdapcancel()
-		if err != nil {
-			fmt.Printf(" error: %s (continuing)\n\n", err)
-		} else {
-			age := time.Since(registration)
-			const day = 24 * time.Hour
-			const year = 365 * day
-			years := age / year
-			days := (age - years*year) / day
-			var s string
-			if years == 1 {
-				s = "1 year, "
-			} else if years > 0 {
-				s = fmt.Sprintf("%d years, ", years)
-			}
-			if days == 1 {
-				s += "1 day"
-			} else {
-				s += fmt.Sprintf("%d days", days)
+		// Check if domains are recently registered.
+		rdapChecked := map[dns.Domain]bool{}
+		for _, a := range addrs {
+			rdapctx, rdapcancel := context.WithTimeout(context.Background(), 10*time.Second)
+			orgdom := publicsuffix.Lookup(rdapctx, c.log.Logger, a.Domain)
+			if rdapChecked[orgdom] {
+				rdapcancel()
+				continue
 			}
-			fmt.Printf(" %s", s)
-			// 6 weeks is a guess, mail servers/service providers will have different policies.
-			if age < 6*7*day {
-				fmt.Printf(" (recent!)\nWARNING: Mail servers may treat messages coming from recently registered domains\n(in the order of weeks to months) with suspicion, with higher probability of\nmessages being classified as junk.\n\n")
+			rdapChecked[orgdom] = true
+			fmt.Printf("\nChecking if domain %s was registered recently...", orgdom)
+			registration, err := rdap.LookupLastDomainRegistration(rdapctx, c.log, orgdom)
+			rdapcancel()
+			if err != nil {
+				fmt.Printf(" error: %s (continuing)\n\n", err)
 			} else {
-				fmt.Printf(" OK\n\n")
+				age := time.Since(registration)
+				const day = 24 * time.Hour
+				const year = 365 * day
+				years := age / year
+				days := (age - years*year) / day
+				var s string
+				if years == 1 {
+					s = "1 year, "
+				} else if years > 0 {
+					s = fmt.Sprintf("%d years, ", years)
+				}
+				if days == 1 {
+					s += "1 day"
+				} else {
+					s += fmt.Sprintf("%d days", days)
+				}
+				fmt.Printf(" %s", s)
+				// 6 weeks is a guess, mail servers/service providers will have different policies.
+				if age < 6*7*day {
+					fmt.Printf(" (recent!)\nWARNING: Mail servers may treat messages coming from recently registered domains\n(in the order of weeks to months) with suspicion, with higher probability of\nmessages being classified as junk.\n\n")
+				} else {
+					fmt.Printf(" OK\n\n")
+				}
 			}
 		}
 	}
 
+	// Without explicitly configured DNSBLs, we suggest popular ones but comment them
+	// out in the config file.
 	zones := []dns.Domain{
 		{ASCII: "sbl.spamhaus.org"},
 		{ASCII: "bl.spamcop.net"},
 	}
+	if noDNSBL {
+		zones = nil
+	} else if len(dnsblZones) > 0 {
+		zones = nil
+		for _, s := range dnsblZones {
+			zone, err := dns.ParseDomain(s)
+			if err != nil {
+				fatalf("parsing dnsbl zone %q: %v", s, err)
+			}
+			zones = append(zones, zone)
+		}
+	}
 	if len(hostIPs) > 0 {
 		fmt.Printf("Checking whether host name IPs are listed in popular DNS block lists...")
 		var listed bool
@@ -714,11 +824,6 @@ many authentication failures).
 
 	fmt.Printf("\n")
 
-	user := "mox"
-	if len(args) == 2 {
-		user = args[1]
-	}
-
 	dc := config.Dynamic{}
 	sc := config.Static{
 		DataDir:           filepath.FromSlash("../data"),
@@ -726,6 +831,7 @@ many authentication failures).
 		LogLevel:          "debug", // Help new users, they'll bring it back to info when it all works.
 		Hostname:          dnshostname.Name(),
 		AdminPasswordFile: "adminpasswd",
+		DNSSECRequired:    dnssecRequired,
 	}
 
 	// todo: let user specify an alternative fallback address?
@@ -737,12 +843,27 @@ many authentication failures).
 	} else {
 		contactEmail = addr.Pack(false)
 	}
-	if !existingWebserver {
+	if acmeContact != "" {
+		contactEmail = acmeContact
+	}
+	// Name of the ACME provider, and domain name of the certificate authority for CAA
+	// records, only known for Let's Encrypt.
+	acmeName := "letsencrypt"
+	certIssuerDomainName := "letsencrypt.org"
+	if acmeDirectory == "" {
+		acmeDirectory = "https://acme-v02.api.letsencrypt.org/directory"
+	} else if acmeDirectory != "https://acme-v02.api.letsencrypt.org/directory" {
+		acmeName = "acme"
+		certIssuerDomainName = ""
+	}
+	if existingWebserver || tlsCertFile != "" {
+		certIssuerDomainName = ""
+	} else {
 		sc.ACME = map[string]config.ACME{
-			"letsencrypt": {
-				DirectoryURL:     "https://acme-v02.api.letsencrypt.org/directory",
+			acmeName: {
+				DirectoryURL:     acmeDirectory,
 				ContactEmail:     contactEmail,
-				IssuerDomainName: "letsencrypt.org",
+				IssuerDomainName: certIssuerDomainName,
 			},
 		}
 	}
@@ -767,18 +888,19 @@ many authentication failures).
 
 	if existingWebserver {
 		hostbase := filepath.FromSlash("path/to/" + dnshostname.Name())
-		mtastsbase := filepath.FromSlash("path/to/mta-sts." + domain.Name())
-		autoconfigbase := filepath.FromSlash("path/to/autoconfig." + domain.Name())
-		mailbase := filepath.FromSlash("path/to/mail." + domain.Name())
 		public.TLS = &config.TLS{
 			KeyCerts: []config.KeyCert{
 				{CertFile: hostbase + "-chain.crt.pem", KeyFile: hostbase + ".key.pem"},
-				{CertFile: mtastsbase + "-chain.crt.pem", KeyFile: mtastsbase + ".key.pem"},
-				{CertFile: autoconfigbase + "-chain.crt.pem", KeyFile: autoconfigbase + ".key.pem"},
 			},
 		}
-		if mailbase != hostbase {
-			public.TLS.KeyCerts = append(public.TLS.KeyCerts, config.KeyCert{CertFile: mailbase + "-chain.crt.pem", KeyFile: mailbase + ".key.pem"})
+		for _, a := range addrs {
+			for _, sub := range []string{"mta-sts.", "autoconfig.", "mail."} {
+				base := filepath.FromSlash("path/to/" + sub + a.Domain.Name())
+				if base == hostbase {
+					continue
+				}
+				public.TLS.KeyCerts = append(public.TLS.KeyCerts, config.KeyCert{CertFile: base + "-chain.crt.pem", KeyFile: base + ".key.pem"})
+			}
 		}
 
 		fmt.Println(
@@ -790,6 +912,32 @@ To configure DANE (which requires DNSSEC), set config field HostPrivateKeyFiles
 in the "public" Listener to both RSA 2048-bit and ECDSA P-256 private key files
 and check the admin page for the needed DNS records.`)
 
+	} else if tlsCertFile != "" {
+		public.TLS = &config.TLS{
+			KeyCerts: []config.KeyCert{
+				{CertFile: tlsCertFile, KeyFile: tlsKeyFile},
+			},
+		}
+		public.AutoconfigHTTPS.Enabled = true
+		public.MTASTSHTTPS.Enabled = true
+		public.WebserverHTTP.Enabled = true
+		public.WebserverHTTPS.Enabled = true
+
+		names := []string{dnshostname.Name()}
+		for _, a := range addrs {
+			for _, sub := range []string{"mta-sts.", "autoconfig.", "mail."} {
+				if name := sub + a.Domain.Name(); name != dnshostname.Name() {
+					names = append(names, name)
+				}
+			}
+		}
+
+		fmt.Printf(`The TLS certificate from %s is used for the public listener. The
+certificate must be valid for %s and
+%s, and must be renewed before it expires.
+
+No private keys for the public listener have been generated for use with DANE.
+`, tlsCertFile, strings.Join(names[:len(names)-1], ", "), names[len(names)-1])
 	} else {
 		// todo: we may want to generate a second set of keys, make the user already add it to the DNS, but keep the private key offline. would require config option to specify a public key only, so the dane records can be generated.
 		hostRSAPrivateKey, err := rsa.GenerateKey(cryptorand.Reader, 2048)
@@ -824,7 +972,7 @@ and check the admin page for the needed DNS records.`)
 		xwritehostkeyfile(filepath.Join("config", hostECDSAPrivateKeyFile), hostECDSAPrivateKey)
 
 		public.TLS = &config.TLS{
-			ACME: "letsencrypt",
+			ACME: acmeName,
 			HostPrivateKeyFiles: []string{
 				hostRSAPrivateKeyFile,
 				hostECDSAPrivateKeyFile,
@@ -838,7 +986,8 @@ and check the admin page for the needed DNS records.`)
 		public.WebserverHTTPS.Enabled = true
 	}
 
-	// Suggest blocklists, but we'll comment them out after generating the config.
+	// Suggest blocklists. Unless explicitly specified, we'll comment them out after
+	// generating the config.
 	for _, zone := range zones {
 		public.SMTP.DNSBLs = append(public.SMTP.DNSBLs, zone.Name())
 	}
@@ -893,32 +1042,38 @@ and check the admin page for the needed DNS records.`)
 
 	mox.Conf.DynamicLastCheck = time.Now() // Prevent error logging by Make calls below.
 
-	accountConf := admin.MakeAccountConfig(addr)
-	const withMTASTS = true
-	confDomain, keyPaths, err := admin.MakeDomainConfig(context.Background(), domain, dnshostname, accountName, withMTASTS)
-	if err != nil {
-		fatalf("making domain config: %s", err)
-	}
-	cleanupPaths = append(cleanupPaths, keyPaths...)
+	dc.Domains = map[string]config.Domain{}
+	dc.Accounts = map[string]config.Account{}
+	// Account names, in order of addresses, and the first address of each account.
+	var accountNames []string
+	accountAddrs := map[string]smtp.Address{}
+	for _, a := range addrs {
+		name := a.Localpart.String()
+		if accConf, ok := dc.Accounts[name]; ok {
+			accConf.Destinations[a.String()] = config.Destination{}
+			dc.Accounts[name] = accConf
+		} else {
+			dc.Accounts[name] = admin.MakeAccountConfig(a)
+			accountNames = append(accountNames, name)
+			accountAddrs[name] = a
+		}
 
-	dc.Domains = map[string]config.Domain{
-		domain.Name(): confDomain,
-	}
-	dc.Accounts = map[string]config.Account{
-		accountName: accountConf,
+		const withMTASTS = true
+		confDomain, keyPaths, err := admin.MakeDomainConfig(context.Background(), a.Domain, dnshostname, name, withMTASTS, false, admin.MakeDomainConfigOpts{})
+		if err != nil {
+			fatalf("making domain config for %s: %s", a.Domain, err)
+		}
+		cleanupPaths = append(cleanupPaths, keyPaths...)
+		dc.Domains[a.Domain.Name()] = confDomain
 	}
 
-	// Build config in memory, so we can easily comment out the DNSBLs config.
-	var sb strings.Builder
-	sc.CheckUpdates = true // Commented out below.
-	if err := sconf.WriteDocs(&sb, &sc); err != nil {
-		fatalf("generating static config: %v", err)
+	var commentDNSBLs []string
+	if len(dnsblZones) == 0 {
+		commentDNSBLs = public.SMTP.DNSBLs
 	}
-	confstr := sb.String()
-	confstr = strings.ReplaceAll(confstr, "\nCheckUpdates: true\n", "\n#\n# RECOMMENDED: please enable to stay up to date\n#\n#CheckUpdates: true\n")
-	confstr = strings.ReplaceAll(confstr, "DNSBLs:\n", "#DNSBLs:\n")
-	for _, bl := range public.SMTP.DNSBLs {
-		confstr = strings.ReplaceAll(confstr, "- "+bl+"\n", "#- "+bl+"\n")
+	confstr, err := quickstartStaticConfig(sc, commentDNSBLs)
+	if err != nil {
+		fatalf("generating static config: %v", err)
 	}
 	xwritefile(filepath.FromSlash("config/mox.conf"), []byte(confstr), 0660)
 
@@ -980,39 +1135,47 @@ and check the admin page for the needed DNS records.`)
 	// and set a passsword, and the public key for the DKIM private keys
 	// are available for generating the DKIM DNS records below.
 
-	confDomain, ok := mc.Domain(domain)
-	if !ok {
-		fatalf("cannot find domain in new config")
-	}
-
-	acc, _, _, err := store.OpenEmail(c.log, args[0], false)
-	if err != nil {
-		fatalf("open account: %s", err)
-	}
-	cleanupPaths = append(cleanupPaths, dataDir, filepath.Join(dataDir, "accounts"), filepath.Join(dataDir, "accounts", accountName), filepath.Join(dataDir, "accounts", accountName, "index.db"))
+	cleanupPaths = append(cleanupPaths, dataDir, filepath.Join(dataDir, "accounts"))
+	for _, name := range accountNames {
+		a := accountAddrs[name]
+		acc, _, _, err := store.OpenEmail(c.log, a.String(), false)
+		if err != nil {
+			fatalf("open account: %s", err)
+		}
+		cleanupPaths = append(cleanupPaths, filepath.Join(dataDir, "accounts", name), filepath.Join(dataDir, "accounts", name, "index.db"))
 
-	password := mox.GeneratePassword()
+		password := mox.GeneratePassword()
 
-	// Kludge to cause no logging to be printed about setting a new password.
-	loglevel := mox.Conf.Log[""]
-	mox.Conf.Log[""] = mlog.LevelWarn
-	mlog.SetConfig(mox.Conf.Log)
-	if err := acc.SetPassword(c.log, password); err != nil {
-		fatalf("setting password: %s", err)
-	}
-	mox.Conf.Log[""] = loglevel
-	mlog.SetConfig(mox.Conf.Log)
+		// Kludge to cause no logging to be printed about setting a new password.
+		loglevel := mox.Conf.Log[""]
+		mox.Conf.Log[""] = mlog.LevelWarn
+		mlog.SetConfig(mox.Conf.Log)
+		if err := acc.SetPassword(c.log, password); err != nil {
+			fatalf("setting password: %s", err)
+		}
+		mox.Conf.Log[""] = loglevel
+		mlog.SetConfig(mox.Conf.Log)
 
-	if err := acc.Close(); err != nil {
-		fatalf("closing account: %s", err)
+		if err := acc.Close(); err != nil {
+			fatalf("closing account: %s", err)
+		}
+		fmt.Printf("IMAP, SMTP submission and HTTP account password for %s: %s\n\n", a.String(), password)
 	}
-	fmt.Printf("IMAP, SMTP submission and HTTP account password for %s: %s\n\n", args[0], password)
 	fmt.Printf(`When configuring your email client, use the email address as username. If
 autoconfig/autodiscover does not work, use these settings:
 `)
-	printClientConfig(domain)
+	for _, a := range addrs {
+		if len(addrs) > 1 {
+			fmt.Printf("\nFor domain %s:\n", a.Domain)
+		}
+		printClientConfig(a.Domain)
+	}
 
 	if existingWebserver {
+		var forwardURLs string
+		for _, a := range addrs {
+			forwardURLs += fmt.Sprintf("\thttps://mta-sts.%s/\n\thttps://autoconfig.%s/\n", a.Domain.ASCII, a.Domain.ASCII)
+		}
 		fmt.Printf(`
 Configuration files have been written to config/mox.conf and
 config/domains.conf.
@@ -1023,9 +1186,7 @@ records, and has a page to check they have been configured correctly.
 
 You must configure your existing webserver to forward requests for:
 
-	https://mta-sts.%s/
-	https://autoconfig.%s/
-
+%s
 To mox, at:
 
 	http://127.0.0.1:81
@@ -1040,7 +1201,7 @@ config is valid, run:
 	./mox config test
 
 The DNS records to add:
-`, domain.ASCII, domain.ASCII, dnshostname.ASCII)
+`, forwardURLs, dnshostname.ASCII)
 	} else {
 		fmt.Printf(`
 Configuration files have been written to config/mox.conf and
@@ -1056,11 +1217,20 @@ have been configured correctly. The DNS records to add:
 	// priming dns caches with negative/absent records, causing our "quick setup" to
 	// appear to fail or take longer than "quick".
 
-	records, err := admin.DomainRecords(confDomain, domain, domainDNSSECResult.Authentic, "letsencrypt.org", "")
-	if err != nil {
-		fatalf("making required DNS records")
+	for _, a := range addrs {
+		confDomain, ok := mc.Domain(a.Domain)
+		if !ok {
+			fatalf("cannot find domain %s in new config", a.Domain)
+		}
+		records, err := admin.DomainRecords(confDomain, a.Domain, domainDNSSECResult.Authentic, certIssuerDomainName, "")
+		if err != nil {
+			fatalf("making required DNS records for %s", a.Domain)
+		}
+		if len(addrs) > 1 {
+			fmt.Printf("\n\n; DNS records for domain %s.", a.Domain)
+		}
+		fmt.Print("\n\n" + strings.Join(records, "\n") + "\n\n\n\n")
 	}
-	fmt.Print("\n\n" + strings.Join(records, "\n") + "\n\n\n\n")
 
 	fmt.Printf(`WARNING: The configuration and DNS records above assume you do not currently
 have email configured for your domain. If you do already have email configured,
@@ -1079,27 +1249,59 @@ You can now start the mox container.
 	}
 	fmt.Printf(`
 File ownership and permissions are automatically set correctly by mox when
-starting up. On linux, you may want to enable mox as a systemd service.
+starting up. You may want to enable mox as a service, started at boot.
 
 `)
 
-	// For now, we only give service config instructions for linux when not running in docker.
-	if runtime.GOOS == "linux" && os.Getenv("MOX_DOCKER") == "" {
+	// For now, we only give service config instructions when not running in docker.
+	if os.Getenv("MOX_DOCKER") == "" {
 		pwd, err := os.Getwd()
 		if err != nil {
 			log.Printf("current working directory: %v", err)
 			pwd = "/home/mox"
 		}
-		service := strings.ReplaceAll(moxService, "/home/mox", pwd)
-		xwritefile("mox.service", []byte(service), 0644)
-		cleanupPaths = append(cleanupPaths, "mox.service")
-		fmt.Printf(`See mox.service for a systemd service file. To enable and start:
+		switch runtime.GOOS {
+		case "linux":
+			service := strings.ReplaceAll(moxService, "/home/mox", pwd)
+			xwritefile("mox.service", []byte(service), 0644)
+			cleanupPaths = append(cleanupPaths, "mox.service")
+			fmt.Printf(`See mox.service for a systemd service file. To enable and start:
 
 	sudo chmod 644 mox.service
 	sudo systemctl enable $PWD/mox.service
 	sudo systemctl start mox.service
 	sudo journalctl -f -u mox.service # See logs
 `)
+		case "freebsd":
+			rc := strings.ReplaceAll(moxRC, "/home/mox", pwd)
+			xwritefile("mox.rc", []byte(rc), 0755)
+			cleanupPaths = append(cleanupPaths, "mox.rc")
+			fmt.Printf(`See mox.rc for an rc.d script. To enable and start:
+
+	sudo install -o root -g wheel -m 755 mox.rc /usr/local/etc/rc.d/mox
+	sudo sysrc mox_enable=YES
+	sudo service mox start
+	tail -f /var/log/messages # See logs
+`)
+		case "darwin":
+			plist := strings.ReplaceAll(moxPlist, "/home/mox", pwd)
+			xwritefile("mox.plist", []byte(plist), 0644)
+			cleanupPaths = append(cleanupPaths, "mox.plist")
+			fmt.Printf(`See mox.plist for a launchd daemon. To enable and start:
+
+	sudo install -o root -g wheel -m 644 mox.plist /Library/LaunchDaemons/org.xmox.mox.plist
+	sudo launchctl bootstrap system /Library/LaunchDaemons/org.xmox.mox.plist
+	tail -f $PWD/mox.log # See logs
+`)
+		default:
+			service := strings.ReplaceAll(moxService, "/home/mox", pwd)
+			xwritefile("mox.service", []byte(service), 0644)
+			cleanupPaths = append(cleanupPaths, "mox.service")
+			fmt.Printf(`See mox.service for a systemd service file. No service configuration is
+available for %s, but the systemd service file shows how mox should be started:
+as root, with the working directory set, running "mox serve".
+`, runtime.GOOS)
+		}
 	}
 
 	fmt.Printf(`
@@ -1128,3 +1330,35 @@ and 80, see "mox help quickstart" with the -existing-webserver option.
 
 	cleanupPaths = nil
 }
+
+// quickstartStaticConfig returns the text for mox.conf, with CheckUpdates and
+// the DNSBLs in commentDNSBLs commented out.
+func quickstartStaticConfig(sc config.Static, commentDNSBLs []string) (string, error) {
+	// Build config in memory, so we can easily comment out the DNSBLs config.
+	var sb strings.Builder
+	sc.CheckUpdates = true // Commented out below.
+	if err := sconf.WriteDocs(&sb, &sc); err != nil {
+		return "", err
+	}
+	confstr := sb.String()
+	confstr = strings.ReplaceAll(confstr, "\nCheckUpdates: true\n", "\n#\n# RECOMMENDED: please enable to stay up to date\n#\n#CheckUpdates: true\n")
+	if len(commentDNSBLs) > 0 {
+		confstr = strings.ReplaceAll(confstr, "DNSBLs:\n", "#DNSBLs:\n")
+		for _, bl := range commentDNSBLs {
+			confstr = strings.ReplaceAll(confstr, "- "+bl+"\n", "#- "+bl+"\n")
+		}
+	}
+	return confstr, nil
+}
+
+// stringList is a flag value that can be specified multiple times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
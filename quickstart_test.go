@@ -0,0 +1,59 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mjl-/sconf"
+
+	"github.com/mjl-/mox/config"
+)
+
+func TestQuickstartStaticConfig(t *testing.T) {
+	sc := config.Static{
+		DataDir:        "../data",
+		LogLevel:       "debug",
+		Hostname:       "mail.mox.example",
+		DNSSECRequired: true,
+	}
+	var public config.Listener
+	public.IPs = []string{"0.0.0.0"}
+	public.SMTP.Enabled = true
+	public.SMTP.DNSBLs = []string{"sbl.spamhaus.org", "bl.spamcop.net"}
+	sc.Listeners = map[string]config.Listener{"public": public}
+	sc.Postmaster.Account = "mjl"
+	sc.Postmaster.Mailbox = "Postmaster"
+
+	confstr, err := quickstartStaticConfig(sc, public.SMTP.DNSBLs)
+	if err != nil {
+		t.Fatalf("generating config: %v", err)
+	}
+
+	var nsc config.Static
+	if err := sconf.Parse(strings.NewReader(confstr), &nsc); err != nil {
+		t.Fatalf("parsing generated config: %v", err)
+	}
+	if !nsc.DNSSECRequired {
+		t.Fatalf("DNSSECRequired not set in generated config")
+	}
+	if nsc.CheckUpdates {
+		t.Fatalf("CheckUpdates not commented out in generated config")
+	}
+	if l := nsc.Listeners["public"].SMTP.DNSBLs; len(l) != 0 {
+		t.Fatalf("DNSBLs not commented out in generated config: %v", l)
+	}
+
+	// Without DNSBLs to comment out, they are kept.
+	confstr, err = quickstartStaticConfig(sc, nil)
+	if err != nil {
+		t.Fatalf("generating config: %v", err)
+	}
+	nsc = config.Static{}
+	if err := sconf.Parse(strings.NewReader(confstr), &nsc); err != nil {
+		t.Fatalf("parsing generated config: %v", err)
+	}
+	if l := nsc.Listeners["public"].SMTP.DNSBLs; !slices.Equal(l, public.SMTP.DNSBLs) {
+		t.Fatalf("got DNSBLs %v, expected %v", l, public.SMTP.DNSBLs)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"time"
@@ -70,6 +71,15 @@ func start(mtastsdbRefresher, sendDMARCReports, sendTLSReports, skipForkExec boo
 		}
 	}
 
+	if mox.Conf.Static.DNSSECRequired {
+		ctx, cancel := context.WithTimeout(mox.Context, 30*time.Second)
+		err := dns.CheckResolverDNSSEC(ctx, dns.StrictResolver{Pkg: "serve"})
+		cancel()
+		if err != nil {
+			return fmt.Errorf("checking dnssec support in resolver, required by config: %v", err)
+		}
+	}
+
 	if err := mtastsdb.Init(mtastsdbRefresher); err != nil {
 		return fmt.Errorf("mtastsdb init: %s", err)
 	}
@@ -174,6 +174,53 @@ test email
 	})
 }
 
+// Only listed senders can deliver to alias with allowed message from addresses,
+// not other senders, also not members.
+func TestAliasDeliverAllowMsgFromAddresses(t *testing.T) {
+	resolver := dns.MockResolver{
+		A: map[string][]string{
+			"example.org.": {"127.0.0.10"}, // For mx check.
+		},
+		PTR: map[string][]string{
+			"127.0.0.10": {"example.org."}, // To get passed junk filter.
+		},
+	}
+	ts := newTestServer(t, filepath.FromSlash("../testdata/smtp/mox.conf"), resolver)
+	defer ts.close()
+
+	for _, from := range []string{"other@example.org", "mjl@mox.example"} {
+		msg := strings.ReplaceAll(`From: <`+from+`>
+To: <restricted@mox.example>
+Subject: test
+
+test email
+`, "\n", "\r\n")
+
+		ts.run(func(client *smtpclient.Client) {
+			mailFrom := "other@example.org"
+			rcptTo := "restricted@mox.example"
+			err := client.Deliver(ctxbg, mailFrom, rcptTo, int64(len(msg)), strings.NewReader(msg), false, false, false)
+			ts.smtpErr(err, &smtpclient.Error{Permanent: true, Code: smtp.C550MailboxUnavail, Secode: smtp.SePol7ExpnProhibited2})
+		})
+	}
+
+	msg := strings.ReplaceAll(`From: <allowed@example.org>
+To: <restricted@mox.example>
+Subject: test
+
+test email
+`, "\n", "\r\n")
+
+	ts.run(func(client *smtpclient.Client) {
+		mailFrom := "allowed@example.org"
+		rcptTo := "restricted@mox.example"
+		err := client.Deliver(ctxbg, mailFrom, rcptTo, int64(len(msg)), strings.NewReader(msg), false, false, false)
+		ts.smtpErr(err, nil)
+
+		ts.checkCount("Inbox", 2) // Receiving for both mjl@ and móx@.
+	})
+}
+
 // Member can deliver to private list, but still not with alias address as message
 // from. Message with alias from address as message from is allowed.
 func TestAliasDeliverMember(t *testing.T) {
@@ -193,17 +193,10 @@ func analyze(ctx context.Context, log mlog.Log, resolver dns.Resolver, d deliver
 		return analysis{d, false, "", smtp.C452StorageFull, smtp.SeMailbox2Full2, true, err.Error(), err, nil, nil, reasonHighRate, reasonText, "", headers}
 	}
 
-	mailbox := d.destination.Mailbox
-	if mailbox == "" {
-		mailbox = "Inbox"
-	}
-
 	// If destination mailbox has a mailing list domain (for SPF/DKIM) configured,
 	// check it for a pass.
 	rs := store.MessageRuleset(log, d.destination, d.m, d.m.MsgPrefix, d.dataFile)
-	if rs != nil {
-		mailbox = rs.Mailbox
-	}
+	mailbox := store.DeliveryMailbox(d.destination, rs)
 	if rs != nil && !rs.ListAllowDNSDomain.IsZero() {
 		// todo: on temporary failures, reject temporarily?
 		if isListDomain(d, rs.ListAllowDNSDomain) {
@@ -581,27 +574,27 @@ func analyze(ctx context.Context, log mlog.Log, resolver dns.Resolver, d deliver
 		// With an iprev fail, non-TLS connection or our address not in To/Cc header, we set a higher bar for content.
 		reason = reasonJunkContent
 		var thresholdRemark string
-		if suspiciousIPrevFail && threshold > 0.25 {
-			threshold = 0.25
+		if suspiciousIPrevFail && threshold > store.JunkThresholdStrict {
+			threshold = store.JunkThresholdStrict
 			log.Info("setting junk threshold due to iprev fail", slog.Float64("threshold", threshold))
 			reason = reasonJunkContentStrict
 			thresholdRemark = " (stricter due to reverse ip mismatch)"
-		} else if !d.tls && threshold > 0.25 {
-			threshold = 0.25
+		} else if !d.tls && threshold > store.JunkThresholdStrict {
+			threshold = store.JunkThresholdStrict
 			log.Info("setting junk threshold due to plaintext smtp", slog.Float64("threshold", threshold))
 			reason = reasonJunkContentStrict
 			thresholdRemark = " (stricter due to missing tls)"
-		} else if (rs == nil || !rs.IsForward) && threshold > 0.25 && !rcptToMatch(d.msgTo) && !rcptToMatch(d.msgCc) {
+		} else if (rs == nil || !rs.IsForward) && threshold > store.JunkThresholdStrict && !rcptToMatch(d.msgTo) && !rcptToMatch(d.msgCc) {
 			// A common theme in junk messages is your recipient address not being in the To/Cc
 			// headers. We may be in Bcc, but that's unusual for first-time senders. Some
 			// providers (e.g. gmail) does not DKIM-sign Bcc headers, so junk messages can be
 			// sent with matching Bcc headers. We don't get here for known senders.
-			threshold = 0.25
+			threshold = store.JunkThresholdStrict
 			log.Info("setting junk threshold due to smtp rcpt to and message to/cc address mismatch", slog.Float64("threshold", threshold))
 			reason = reasonJunkContentStrict
 			thresholdRemark = " (stricter due to recipient address not in to/cc header)"
 		}
-		accept = result.Probability <= threshold || (!result.Significant && !suspiciousIPrevFail)
+		accept = store.JunkAccept(result, threshold, suspiciousIPrevFail)
 		junkSubjectpass = result.Probability < threshold-0.2
 		log.Info("content analyzed",
 			slog.Bool("accept", accept),
@@ -5,6 +5,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/md5"
 	cryptorand "crypto/rand"
@@ -84,6 +85,8 @@ const rcptToLimit = 1000
 func init() {
 	// Also called by tests, so they don't trigger the rate limiter.
 	limitersInit()
+
+	mox.ListenerReloaders["smtp"] = ListenerReload
 }
 
 func limitersInit() {
@@ -201,9 +204,48 @@ func durationDefault(delay *time.Duration, def time.Duration) time.Duration {
 // Listen initializes network listeners for incoming SMTP connection.
 // The listeners are stored for a later call to Serve.
 func Listen() {
-	names := slices.Sorted(maps.Keys(mox.Conf.Static.Listeners))
+	log := mlog.New("smtpserver", nil)
+
+	activeListenersMutex.Lock()
+	defer activeListenersMutex.Unlock()
+
+	for _, l := range listenerSockets(mox.Conf.Static) {
+		if os.Getuid() == 0 {
+			log.Print("listening for smtp",
+				slog.String("listener", l.name),
+				slog.String("address", l.addr),
+				slog.String("protocol", l.protocol))
+		}
+		ln, err := mox.Listen(l.network, l.addr)
+		if err != nil {
+			log.Fatalx("smtp: listen for smtp", err, slog.String("protocol", l.protocol), slog.String("listener", l.name))
+		}
+		activeListeners[l.key()] = activeListener{l, ln}
+		servers = append(servers, func() { l.serve(ln) })
+	}
+}
+
+// listenerSocket is a socket for a protocol on an address of a configured
+// listener.
+type listenerSocket struct {
+	protocol string
+	name     string // Listener name.
+	network  string
+	addr     string
+	serve    func(ln net.Listener) // Accepts connections until ln is closed.
+}
+
+// key identifies a socket when comparing running sockets against a configuration.
+func (l listenerSocket) key() string {
+	return l.protocol + " " + l.name + " " + l.addr
+}
+
+// listenerSockets returns the sockets for the smtp listeners in static.
+func listenerSockets(static config.Static) []listenerSocket {
+	var sockets []listenerSocket
+	names := slices.Sorted(maps.Keys(static.Listeners))
 	for _, name := range names {
-		listener := mox.Conf.Static.Listeners[name]
+		listener := static.Listeners[name]
 
 		var tlsConfig, tlsConfigDelivery *tls.Config
 		var noTLSClientAuth bool
@@ -222,7 +264,7 @@ func Listen() {
 		}
 
 		if listener.SMTP.Enabled {
-			hostname := mox.Conf.Static.HostnameDomain
+			hostname := static.HostnameDomain
 			if listener.Hostname != "" {
 				hostname = listener.HostnameDomain
 			}
@@ -236,74 +278,142 @@ func Listen() {
 					// https://github.com/golang/go/issues/70232.
 					tlsConfigDelivery.SessionTicketsDisabled = listener.SMTP.TLSSessionTicketsDisabled == nil || *listener.SMTP.TLSSessionTicketsDisabled
 				}
-				listen1("smtp", name, ip, port, hostname, tlsConfigDelivery, false, false, noTLSClientAuth, maxMsgSize, false, listener.SMTP.RequireSTARTTLS, !listener.SMTP.NoRequireTLS, listener.SMTP.DNSBLZones, firstTimeSenderDelay)
+				sockets = append(sockets, listen1("smtp", name, ip, port, listener.ProxyProtocol, hostname, tlsConfigDelivery, false, false, noTLSClientAuth, maxMsgSize, false, listener.SMTP.RequireSTARTTLS, !listener.SMTP.NoRequireTLS, listener.SMTP.DNSBLZones, firstTimeSenderDelay))
 			}
 		}
 		if listener.Submission.Enabled {
-			hostname := mox.Conf.Static.HostnameDomain
+			hostname := static.HostnameDomain
 			if listener.Hostname != "" {
 				hostname = listener.HostnameDomain
 			}
 			port := config.Port(listener.Submission.Port, 587)
 			for _, ip := range listener.IPs {
-				listen1("submission", name, ip, port, hostname, tlsConfig, true, false, noTLSClientAuth, maxMsgSize, !listener.Submission.NoRequireSTARTTLS, !listener.Submission.NoRequireSTARTTLS, true, nil, 0)
+				sockets = append(sockets, listen1("submission", name, ip, port, listener.ProxyProtocol, hostname, tlsConfig, true, false, noTLSClientAuth, maxMsgSize, !listener.Submission.NoRequireSTARTTLS, !listener.Submission.NoRequireSTARTTLS, true, nil, 0))
 			}
 		}
 
 		if listener.Submissions.Enabled {
-			hostname := mox.Conf.Static.HostnameDomain
+			hostname := static.HostnameDomain
 			if listener.Hostname != "" {
 				hostname = listener.HostnameDomain
 			}
 			port := config.Port(listener.Submissions.Port, 465)
 			for _, ip := range listener.IPs {
-				listen1("submissions", name, ip, port, hostname, tlsConfig, true, true, noTLSClientAuth, maxMsgSize, true, true, true, nil, 0)
+				sockets = append(sockets, listen1("submissions", name, ip, port, listener.ProxyProtocol, hostname, tlsConfig, true, true, noTLSClientAuth, maxMsgSize, true, true, true, nil, 0))
 			}
 		}
 	}
+	return sockets
 }
 
 var servers []func()
 
-func listen1(protocol, name, ip string, port int, hostname dns.Domain, tlsConfig *tls.Config, submission, xtls, noTLSClientAuth bool, maxMessageSize int64, requireTLSForAuth, requireTLSForDelivery, requireTLS bool, dnsBLs []dns.Domain, firstTimeSenderDelay time.Duration) {
+// Running sockets by listenerSocket key, for ListenerReload.
+var (
+	activeListenersMutex sync.Mutex
+	activeListeners      = map[string]activeListener{}
+)
+
+// activeListener is a running socket, with the listener it accepts connections on.
+type activeListener struct {
+	socket listenerSocket
+	ln     net.Listener
+}
+
+func listen1(protocol, name, ip string, port int, proxyProtocol *config.ProxyProtocol, hostname dns.Domain, tlsConfig *tls.Config, submission, xtls, noTLSClientAuth bool, maxMessageSize int64, requireTLSForAuth, requireTLSForDelivery, requireTLS bool, dnsBLs []dns.Domain, firstTimeSenderDelay time.Duration) listenerSocket {
 	log := mlog.New("smtpserver", nil)
 	addr := net.JoinHostPort(ip, fmt.Sprintf("%d", port))
-	if os.Getuid() == 0 {
-		log.Print("listening for smtp",
-			slog.String("listener", name),
-			slog.String("address", addr),
-			slog.String("protocol", protocol))
-	}
-	network := mox.Network(ip)
-	ln, err := mox.Listen(network, addr)
-	if err != nil {
-		log.Fatalx("smtp: listen for smtp", err, slog.String("protocol", protocol), slog.String("listener", name))
-	}
 
-	// Each listener gets its own copy of the config, so session keys between different
-	// ports on same listener aren't shared. We rotate session keys explicitly in this
-	// base TLS config because each connection clones the TLS config before using. The
-	// base TLS config would never get automatically managed/rotated session keys.
-	if tlsConfig != nil {
-		tlsConfig = tlsConfig.Clone()
-		mox.StartTLSSessionTicketKeyRefresher(mox.Shutdown, log, tlsConfig)
-	}
+	serveListener := func(ln net.Listener) {
+		ctx, cancel := context.WithCancel(mox.Shutdown)
+		defer cancel()
+
+		// Each listener gets its own copy of the config, so session keys between different
+		// ports on same listener aren't shared. We rotate session keys explicitly in this
+		// base TLS config because each connection clones the TLS config before using. The
+		// base TLS config would never get automatically managed/rotated session keys.
+		tlsConfig := tlsConfig
+		if tlsConfig != nil {
+			tlsConfig = tlsConfig.Clone()
+			mox.StartTLSSessionTicketKeyRefresher(ctx, log, tlsConfig)
+		}
 
-	serve := func() {
 		for {
 			conn, err := ln.Accept()
-			if err != nil {
+			if err != nil && errors.Is(err, net.ErrClosed) {
+				// Socket closed by ListenerReload.
+				log.Info("smtp: listener closed", slog.String("protocol", protocol), slog.String("listener", name), slog.String("address", addr))
+				return
+			} else if err != nil {
 				log.Infox("smtp: accept", err, slog.String("protocol", protocol), slog.String("listener", name))
 				continue
 			}
 
 			// Package is set on the resolver by the dkim/spf/dmarc/etc packages.
 			resolver := dns.StrictResolver{Log: log.Logger}
-			go serve(name, mox.Cid(), hostname, tlsConfig, conn, resolver, submission, xtls, false, noTLSClientAuth, maxMessageSize, requireTLSForAuth, requireTLSForDelivery, requireTLS, dnsBLs, firstTimeSenderDelay)
+			go func() {
+				conn := mox.ProxyProtocolConn(log, proxyProtocol, conn)
+				if conn == nil {
+					return
+				}
+				serve(name, mox.Cid(), hostname, tlsConfig, conn, resolver, submission, xtls, false, noTLSClientAuth, maxMessageSize, requireTLSForAuth, requireTLSForDelivery, requireTLS, dnsBLs, firstTimeSenderDelay)
+			}()
+		}
+	}
+
+	return listenerSocket{protocol, name, mox.Network(ip), addr, serveListener}
+}
+
+// ListenerReload compares the smtp sockets for the listeners in static against
+// the running sockets. It returns the sockets for new listeners, protocols or
+// addresses, which must be opened by the caller, and the running sockets that are
+// no longer in the configuration. Nothing is changed until apply is called with a
+// listener for each socket in add, in order: it starts serving on them and closes
+// the removed sockets. Existing connections, also those accepted on closed
+// sockets, are not affected. Changed settings of a remaining socket are not
+// applied, they require a restart.
+func ListenerReload(static config.Static) (add, remove []mox.ListenerSocket, apply func(lns []net.Listener)) {
+	activeListenersMutex.Lock()
+	defer activeListenersMutex.Unlock()
+
+	var sockets []listenerSocket
+	keep := map[string]bool{}
+	for _, l := range listenerSockets(static) {
+		key := l.key()
+		keep[key] = true
+		if _, ok := activeListeners[key]; !ok {
+			sockets = append(sockets, l)
+			add = append(add, mox.ListenerSocket{Key: key, Network: l.network, Address: l.addr})
 		}
 	}
+	for _, key := range slices.Sorted(maps.Keys(activeListeners)) {
+		if !keep[key] {
+			l := activeListeners[key].socket
+			remove = append(remove, mox.ListenerSocket{Key: key, Network: l.network, Address: l.addr})
+		}
+	}
+
+	apply = func(lns []net.Listener) {
+		log := mlog.New("smtpserver", nil)
 
-	servers = append(servers, serve)
+		activeListenersMutex.Lock()
+		defer activeListenersMutex.Unlock()
+
+		for _, s := range remove {
+			err := activeListeners[s.Key].ln.Close()
+			log.Check(err, "closing smtp listener", slog.String("socket", s.Key))
+			delete(activeListeners, s.Key)
+		}
+		for i, l := range sockets {
+			log.Print("listening for smtp",
+				slog.String("listener", l.name),
+				slog.String("address", l.addr),
+				slog.String("protocol", l.protocol))
+			activeListeners[l.key()] = activeListener{l, lns[i]}
+			go l.serve(lns[i])
+		}
+	}
+	return add, remove, apply
 }
 
 // Serve starts serving on all listeners, launching a goroutine per listener.
@@ -2435,6 +2545,15 @@ func (c *conn) submit(ctx context.Context, recvHdrFor func(string) string, msgWr
 		xsmtpServerErrorf(codes{smtp.C451LocalErr, smtp.SeSys3Other0}, "domain of message from header is temporarily disabled")
 	}
 
+	// Add footer of domain, before signing.
+	if size, has8bit, changed, err := mox.MessageFooterAdd(c.log, confDom.Footer, dataFile, msgWriter.Size); err != nil {
+		c.log.Errorx("adding footer to message", err)
+		xsmtpServerErrorf(errCodes(smtp.C451LocalErr, smtp.SeSys3Other0, err), "error adding footer to message")
+	} else if changed {
+		msgWriter.Size = size
+		msgWriter.Has8bit = msgWriter.Has8bit || has8bit
+	}
+
 	selectors := mox.DKIMSelectors(confDom.DKIM)
 	if len(selectors) > 0 {
 		canonical := mox.CanonicalLocalpart(msgFrom.Localpart, confDom)
@@ -2604,6 +2723,50 @@ func (c *conn) xlocalserveError(lp smtp.Localpart) {
 	}
 }
 
+// forward queues copies of a message delivered to d.deliverTo to the forward
+// addresses of its destination.
+//
+// The destination address is used as SMTP MAIL FROM, so SPF at the forward
+// targets is verified against this host, and DSNs for failed forwards are
+// delivered back to the account. The original message is not modified, only a
+// Delivered-To and Received header are added. DKIM signatures of the original
+// message remain valid and can result in a DMARC pass at the forward targets.
+// Messages without (valid) DKIM signatures fail DMARC alignment, and may be
+// rejected.
+//
+// If the message already has a Delivered-To header for the address, the message
+// is not forwarded again, preventing loops. ../rfc/9228:274
+func (c *conn) forward(log mlog.Log, d delivery, headers textproto.MIMEHeader, recvHdr string, msgWriter *message.Writer, messageID string, dataFile *os.File) {
+	deliveredTo := d.deliverTo.XString(c.msgsmtputf8)
+	for _, v := range headers.Values("Delivered-To") {
+		if strings.EqualFold(strings.TrimSpace(v), deliveredTo) {
+			log.Info("not forwarding message that was already delivered to address", slog.String("address", deliveredTo))
+			return
+		}
+	}
+
+	prefix := []byte("Delivered-To: " + deliveredTo + "\r\n" + recvHdr)
+	size := int64(len(prefix)) + msgWriter.Size
+	var qml []queue.Msg
+	for _, s := range d.destination.Forward {
+		addr, err := smtp.ParseAddress(s)
+		if err != nil {
+			log.Errorx("parsing forward address", err, slog.String("address", s))
+			continue
+		}
+		qm := queue.MakeMsg(d.deliverTo, addr.Path(), msgWriter.Has8bit, c.msgsmtputf8, size, messageID, prefix, nil, time.Now(), headers.Get("Subject"))
+		qml = append(qml, qm)
+	}
+	if len(qml) == 0 {
+		return
+	}
+	if err := queue.Add(context.Background(), log, d.acc.Name, dataFile, qml...); err != nil {
+		log.Errorx("queueing forwarded message", err, slog.Any("forward", d.destination.Forward))
+		return
+	}
+	log.Info("incoming message queued for forwarding", slog.Any("forward", d.destination.Forward))
+}
+
 // deliver is called for incoming messages from external, typically untrusted
 // sources. i.e. not submitted by authenticated users.
 func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgWriter *message.Writer, iprevStatus iprev.Status, iprevAuthentic bool, dataFile *os.File) {
@@ -2702,6 +2865,8 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 					}
 					if _, ok := sel.Key.(ed25519.PrivateKey); ok {
 						dkimr.Key = "ed25519"
+					} else if _, ok := sel.Key.(*ecdsa.PrivateKey); ok {
+						dkimr.Key = "ecdsa"
 					} else if _, ok := sel.Key.(*rsa.PrivateKey); !ok {
 						err := fmt.Errorf("unrecognized private key for DKIM selector %q: %T", name, sel.Key)
 						xcheckf(err, "making dkim record")
@@ -3100,7 +3265,21 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 			msgTo = envelope.To
 			msgCc = envelope.CC
 		}
-		d := delivery{c.tls, &m, dataFile, smtpRcptTo, deliverTo, destination, canonicalAddr, acc, msgTo, msgCc, msgFrom, c.dnsBLs, dmarcUse, dmarcResult, dkimResults, iprevStatus, c.smtputf8}
+		// DNSBLs configured for the recipient domain take precedence over those of the
+		// listener. For a subdomain catchall "@.<parent>", the recipient domain may not be
+		// configured itself, the parent domain is.
+		rcptDomain := smtpRcptTo.IPDomain.Domain
+		if s, ok := strings.CutPrefix(canonicalAddr, "@."); ok {
+			if d, err := dns.ParseDomain(s); err == nil {
+				rcptDomain = d
+			}
+		}
+		dnsBLs := c.dnsBLs
+		if dc, ok := mox.Conf.Domain(rcptDomain); ok && len(dc.DNSBLZones) > 0 {
+			dnsBLs = dc.DNSBLZones
+		}
+
+		d := delivery{c.tls, &m, dataFile, smtpRcptTo, deliverTo, destination, canonicalAddr, acc, msgTo, msgCc, msgFrom, dnsBLs, dmarcUse, dmarcResult, dkimResults, iprevStatus, c.smtputf8}
 
 		r := analyze(ctx, log, c.resolver, d)
 		return &r, nil
@@ -3145,7 +3324,7 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 		if rcpt.Alias != nil {
 			// Check if msgFrom address is acceptable. This doesn't take validation into
 			// consideration. If the header was forged, the message may be rejected later on.
-			if !aliasAllowedMsgFrom(rcpt.Alias.Alias, msgFrom) {
+			if !mox.AliasAllowedMsgFrom(rcpt.Alias.Alias, msgFrom) {
 				addError(rcpt, smtp.C550MailboxUnavail, smtp.SePol7ExpnProhibited2, true, "not allowed to send to destination")
 				return
 			}
@@ -3582,6 +3761,12 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 					err = queue.Incoming(context.Background(), log, a.d.acc, messageID, *a.d.m, part, a.mailbox)
 					log.Check(err, "queueing webhook for incoming delivery")
 				}
+
+				// Messages we would have rejected but accepted into a mailbox due to a ruleset
+				// are not forwarded, we don't want to pass on spam.
+				if len(a.d.destination.Forward) > 0 && !a.d.m.IsReject {
+					c.forward(log, a.d, headers, recvHdrFor(a.d.deliverTo.String()), msgWriter, messageID, dataFile)
+				}
 			} else if nerr > 0 && ndelivered == 0 {
 				// Don't continue if we had an error and haven't delivered yet. If we only had
 				// quota-related errors, we keep trying for an account to deliver to.
@@ -3693,21 +3878,6 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 	c.xwritecodeline(smtp.C250Completed, smtp.SeMailbox2Other0, "it is done", nil)
 }
 
-// Return whether msgFrom address is allowed to send a message to alias.
-func aliasAllowedMsgFrom(alias config.Alias, msgFrom smtp.Address) bool {
-	for _, aa := range alias.ParsedAddresses {
-		if aa.Address == msgFrom {
-			return true
-		}
-	}
-	lp, err := smtp.ParseLocalpart(alias.LocalpartStr)
-	xcheckf(err, "parsing alias localpart")
-	if msgFrom == smtp.NewAddress(lp, alias.Domain) {
-		return alias.AllowMsgFrom
-	}
-	return alias.PostPublic
-}
-
 // ecode returns either ecode, or a more specific error based on err.
 // For example, ecode can be turned from an "other system" error into a "mail
 // system full" if the error indicates no disk space is available.
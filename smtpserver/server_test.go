@@ -13,7 +13,9 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"maps"
 	"math/big"
 	"mime/quotedprintable"
 	"net"
@@ -757,6 +759,11 @@ func TestSpam(t *testing.T) {
 		ts.checkCount("mjl2junk", 1) // In ruleset rejects mailbox.
 		ts.checkCount("Rejects", 1)  // Same as before.
 		checkEvaluationCount(t, 0)   // This is not an actual accept.
+
+		// Rejected message is not forwarded to the forward addresses of the destination.
+		msgs, err := queue.List(ctxbg, queue.Filter{}, queue.Sort{})
+		tcheck(t, err, "listing queue")
+		tcompare(t, len(msgs), 0)
 	})
 
 	// Mark the messages as having good reputation.
@@ -1019,6 +1026,64 @@ func TestDMARCSent(t *testing.T) {
 	})
 }
 
+// Test DNSBLs configured for the recipient domain take precedence over those of
+// the listener.
+func TestDomainDNSBL(t *testing.T) {
+	resolver := &dns.MockResolver{
+		A: map[string][]string{
+			"example.org.":              {"127.0.0.10"}, // For mx check.
+			"2.0.0.127.dnsbl.example.":  {"127.0.0.2"},  // For healthcheck.
+			"10.0.0.127.dnsbl.example.": {"127.0.0.10"}, // Where our connection pretends to come from.
+			"2.0.0.127.clean.example.":  {"127.0.0.2"},  // For healthcheck.
+		},
+		TXT: map[string][]string{
+			"10.0.0.127.dnsbl.example.": {"blocklisted"},
+			"example.org.":              {"v=spf1 ip4:127.0.0.10 -all"},
+			"_dmarc.example.org.":       {"v=DMARC1;p=reject"},
+		},
+		PTR: map[string][]string{
+			"127.0.0.10": {"example.org."}, // For iprev check.
+		},
+	}
+	ts := newTestServer(t, filepath.FromSlash("../testdata/smtp/mox.conf"), resolver)
+	defer ts.close()
+
+	setDomainDNSBLs := func(domain string, zones []dns.Domain) {
+		dc := mox.Conf.Dynamic.Domains[domain]
+		dc.DNSBLZones = zones
+		mox.Conf.Dynamic.Domains[domain] = dc
+	}
+	defer setDomainDNSBLs("mox.example", nil)
+	defer setDomainDNSBLs("catchall.example", nil)
+
+	deliver := func(rcptTo string, expErr *smtpclient.Error) {
+		t.Helper()
+		ts.run(func(client *smtpclient.Client) {
+			mailFrom := "remote@example.org"
+			err := client.Deliver(ctxbg, mailFrom, rcptTo, int64(len(deliverMessage)), strings.NewReader(deliverMessage), false, false, false)
+			ts.smtpErr(err, expErr)
+		})
+	}
+
+	// Listener DNSBL doesn't list the IP, but domain DNSBL does.
+	ts.dnsbls = []dns.Domain{{ASCII: "clean.example"}}
+	setDomainDNSBLs("mox.example", []dns.Domain{{ASCII: "dnsbl.example"}})
+	deliver("mjl@mox.example", &smtpclient.Error{Permanent: false, Code: smtp.C451LocalErr, Secode: smtp.SeSys3Other0})
+
+	// Listener DNSBL lists the IP, but domain DNSBL doesn't.
+	ts.dnsbls = []dns.Domain{{ASCII: "dnsbl.example"}}
+	setDomainDNSBLs("mox.example", []dns.Domain{{ASCII: "clean.example"}})
+	deliver("mjl@mox.example", nil)
+
+	// For a subdomain catchall, the DNSBLs of the parent domain apply.
+	ts.dnsbls = []dns.Domain{{ASCII: "clean.example"}}
+	setDomainDNSBLs("catchall.example", []dns.Domain{{ASCII: "dnsbl.example"}})
+	deliver("mjl@sub.catchall.example", &smtpclient.Error{Permanent: false, Code: smtp.C451LocalErr, Secode: smtp.SeSys3Other0})
+
+	setDomainDNSBLs("catchall.example", nil)
+	deliver("mjl@sub.catchall.example", nil)
+}
+
 // Test DNSBL, then getting through with subjectpass.
 func TestBlocklistedSubjectpass(t *testing.T) {
 	// Set up a DNSBL on dnsbl.example, and get DMARC pass.
@@ -1508,6 +1573,10 @@ func TestQuota(t *testing.T) {
 	}
 
 	testDeliver("mjl@mox.example", &smtpclient.Error{Code: smtp.C452StorageFull, Secode: smtp.SeMailbox2Full2})
+
+	// Account with a maximum number of messages.
+	testDeliver("count@mox.example", nil)
+	testDeliver("count@mox.example", &smtpclient.Error{Code: smtp.C452StorageFull, Secode: smtp.SeMailbox2Full2})
 }
 
 // Test with catchall destination address.
@@ -1575,7 +1644,12 @@ func TestDKIMSign(t *testing.T) {
 	ts := newTestServer(t, filepath.FromSlash("../testdata/smtp/mox.conf"), resolver)
 	defer ts.close()
 
-	// Set DKIM signing config.
+	// Set DKIM signing config. The config is changed in place, the original domains
+	// are restored after the test so they don't leak into other tests.
+	origDomains := maps.Clone(mox.Conf.Dynamic.Domains)
+	t.Cleanup(func() {
+		mox.Conf.Dynamic.Domains = origDomains
+	})
 	var gen byte
 	genDKIM := func(domain string) string {
 		dom, _ := mox.Conf.Domain(dns.Domain{ASCII: domain})
@@ -1602,6 +1676,11 @@ func TestDKIMSign(t *testing.T) {
 	dkimtxt := genDKIM("mox.example")
 	dkimtxt2 := genDKIM("mox2.example")
 
+	// Footer is added before signing.
+	dom, _ := mox.Conf.Domain(dns.Domain{ASCII: "mox.example"})
+	dom.Footer = &config.Footer{Text: "disclaimer"}
+	mox.Conf.Dynamic.Domains["mox.example"] = dom
+
 	// DKIM verify needs to find the key.
 	resolver.TXT = map[string][]string{
 		"testsel._domainkey.mox.example.":  {dkimtxt},
@@ -1613,7 +1692,7 @@ func TestDKIMSign(t *testing.T) {
 	ts.pass = password0
 
 	n := 0
-	testSubmit := func(mailFrom, msgFrom string) {
+	testSubmit := func(mailFrom, msgFrom string, expFooter bool) {
 		t.Helper()
 		ts.run(func(client *smtpclient.Client) {
 			t.Helper()
@@ -1645,11 +1724,18 @@ test email
 			tcompare(t, len(results), 1)
 			tcompare(t, results[0].Status, dkim.StatusPass)
 			tcompare(t, results[0].Sig.Domain.ASCII, strings.Split(msgFrom, "@")[1])
+
+			f2, err := queue.OpenMessage(ctxbg, msgs[0].ID)
+			tcheck(t, err, "open message in queue")
+			defer f2.Close()
+			buf, err := io.ReadAll(f2)
+			tcheck(t, err, "read message")
+			tcompare(t, strings.HasSuffix(string(buf), "test email\r\n\r\ndisclaimer\r\n"), expFooter)
 		})
 	}
 
-	testSubmit("mjl@mox.example", "mjl@mox.example")
-	testSubmit("mjl@mox.example", "mjl@mox2.example") // DKIM signature will be for mox2.example.
+	testSubmit("mjl@mox.example", "mjl@mox.example", true)
+	testSubmit("mjl@mox.example", "mjl@mox2.example", false) // DKIM signature will be for mox2.example.
 }
 
 // Test to postmaster addresses.
@@ -2154,3 +2240,49 @@ func TestDestinationMessageAuthRequiredSMTPError(t *testing.T) {
 		ts.smtpErr(err, nil)
 	})
 }
+
+// TestDestinationForward checks messages delivered to a destination with Forward
+// are queued for delivery to the forward addresses.
+func TestDestinationForward(t *testing.T) {
+	resolver := dns.MockResolver{
+		A: map[string][]string{
+			"example.org.": {"127.0.0.10"}, // For mx check.
+		},
+		PTR: map[string][]string{
+			"127.0.0.10": {"example.org."},
+		},
+	}
+
+	ts := newTestServer(t, filepath.FromSlash("../testdata/smtp/mox.conf"), resolver)
+	defer ts.close()
+
+	testDeliver := func(msg string) {
+		t.Helper()
+		ts.run(func(client *smtpclient.Client) {
+			mailFrom := "remote@example.org"
+			rcptTo := "forward@mox.example"
+			err := client.Deliver(ctxbg, mailFrom, rcptTo, int64(len(msg)), strings.NewReader(msg), false, false, false)
+			ts.smtpErr(err, nil)
+		})
+	}
+
+	testDeliver(deliverMessage)
+
+	msgs, err := queue.List(ctxbg, queue.Filter{}, queue.Sort{})
+	tcheck(t, err, "listing queue")
+	tcompare(t, len(msgs), 1)
+	qm := msgs[0]
+	tcompare(t, qm.SenderAccount, "mjl")
+	tcompare(t, qm.Sender().String(), "forward@mox.example")
+	tcompare(t, qm.Recipient().String(), "remote@example.org")
+	if !strings.HasPrefix(string(qm.MsgPrefix), "Delivered-To: forward@mox.example\r\n") {
+		t.Fatalf("msg prefix %q does not start with Delivered-To header", qm.MsgPrefix)
+	}
+	tcompare(t, qm.Size, int64(len(qm.MsgPrefix)+len(deliverMessage)))
+
+	// Message that was already delivered to the address is not forwarded again.
+	testDeliver("Delivered-To: forward@mox.example\r\n" + deliverMessage2)
+	msgs, err = queue.List(ctxbg, queue.Filter{}, queue.Sort{})
+	tcheck(t, err, "listing queue")
+	tcompare(t, len(msgs), 1)
+}
@@ -77,6 +77,7 @@ var (
 	ErrUnknownCredentials = errors.New("credentials not found")
 	ErrAccountUnknown     = errors.New("no such account")
 	ErrOverQuota          = errors.New("account over quota")
+	ErrMailboxLimit       = errors.New("maximum number of mailboxes reached")
 	ErrLoginDisabled      = errors.New("login disabled for account")
 )
 
@@ -1763,6 +1764,97 @@ func (a *Account) Remove(ctx context.Context) error {
 	return nil
 }
 
+// RenameAccount moves the account directory for oldName to newName, and updates
+// references to the account name in the auth database. The account must not be
+// open. The directory for newName must not exist. If the account directory for
+// oldName does not exist, e.g. because the account was never opened, only the
+// references are updated.
+//
+// The caller must ensure the account configuration is updated, and should hold
+// the dynamic config lock so the account is not opened by its old or new name
+// until then.
+func RenameAccount(ctx context.Context, log mlog.Log, oldName, newName string) error {
+	openAccounts.Lock()
+	defer openAccounts.Unlock()
+
+	if _, ok := openAccounts.names[oldName]; ok {
+		return fmt.Errorf("account is in use")
+	}
+	if _, ok := openAccounts.names[newName]; ok {
+		return fmt.Errorf("new account name is in use")
+	}
+
+	odir := filepath.Join(mox.DataDirPath("accounts"), oldName)
+	ndir := filepath.Join(mox.DataDirPath("accounts"), newName)
+	if _, err := os.Stat(ndir); err == nil {
+		return fmt.Errorf("account directory %q already/still exists", ndir)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf(`stat account directory %q, expected "does not exist": %v`, ndir, err)
+	}
+	renamed := false
+	if _, err := os.Stat(odir); err == nil {
+		if err := os.Rename(odir, ndir); err != nil {
+			return fmt.Errorf("moving account directory %q to %q: %v", odir, ndir, err)
+		}
+		renamed = true
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("stat account directory %q: %v", odir, err)
+	}
+
+	err := AuthDB.Write(ctx, func(tx *bstore.Tx) error {
+		q := bstore.QueryTx[TLSPublicKey](tx)
+		q.FilterNonzero(TLSPublicKey{Account: oldName})
+		if _, err := q.UpdateNonzero(TLSPublicKey{Account: newName}); err != nil {
+			return fmt.Errorf("updating tls public keys: %v", err)
+		}
+		// Login attempts are keyed by a hash that includes the account name.
+		qa := bstore.QueryTx[LoginAttempt](tx)
+		qa.FilterNonzero(LoginAttempt{AccountName: oldName})
+		attempts, err := qa.List()
+		if err != nil {
+			return fmt.Errorf("listing login attempts: %v", err)
+		}
+		for _, la := range attempts {
+			if err := tx.Delete(&la); err != nil {
+				return fmt.Errorf("removing login attempt: %v", err)
+			}
+			la.AccountName = newName
+			la.Key = la.calculateKey()
+			xla := LoginAttempt{Key: la.Key}
+			if err := tx.Get(&xla); err == nil {
+				xla.Count += la.Count
+				if la.First.Before(xla.First) {
+					xla.First = la.First
+				}
+				if la.Last.After(xla.Last) {
+					xla.Last = la.Last
+				}
+				err = tx.Update(&xla)
+			} else if errors.Is(err, bstore.ErrAbsent) {
+				err = tx.Insert(&la)
+			}
+			if err != nil {
+				return fmt.Errorf("storing login attempt for new account name: %v", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if renamed {
+			xerr := os.Rename(ndir, odir)
+			log.Check(xerr, "moving account directory back after error", slog.String("dir", odir))
+		}
+		return err
+	}
+
+	sessions.Lock()
+	delete(sessions.accounts, oldName)
+	delete(sessions.pendingFlushes, oldName)
+	sessions.Unlock()
+
+	return nil
+}
+
 // WaitClosed waits until the last reference to this account is gone and the
 // account is closed. Used during tests, to ensure the consistency checks run after
 // expunged messages have been erased.
@@ -2278,6 +2370,15 @@ func (a *Account) MessageAdd(log mlog.Log, tx *bstore.Tx, mb *Mailbox, m *Messag
 			if maxSize > 0 && m.Size > maxSize-du.MessageSize {
 				return fmt.Errorf("%w: max size %d bytes", ErrOverQuota, maxSize)
 			}
+			if maxCount := a.QuotaMessageCount(); maxCount > 0 {
+				n, err := a.messageCount(tx, mb)
+				if err != nil {
+					return fmt.Errorf("counting messages: %v", err)
+				}
+				if n >= int64(maxCount) {
+					return fmt.Errorf("%w: max %d messages", ErrOverQuota, maxCount)
+				}
+			}
 		}
 
 		if !opts.SkipUpdateDiskUsage {
@@ -2826,9 +2927,32 @@ func (a *Account) SubscriptionEnsure(tx *bstore.Tx, name string) ([]Change, erro
 // MessageRuleset returns the first ruleset (if any) that matches the message
 // represented by msgPrefix and msgFile, with smtp and validation fields from m.
 func MessageRuleset(log mlog.Log, dest config.Destination, m *Message, msgPrefix []byte, msgFile *os.File) *config.Ruleset {
-	if len(dest.Rulesets) == 0 {
+	i := MessageRulesetIndex(log, dest, m, msgPrefix, msgFile)
+	if i < 0 {
 		return nil
 	}
+	rs := dest.Rulesets[i]
+	return &rs
+}
+
+// DeliveryMailbox returns the mailbox to deliver a message for dest to, given the
+// ruleset that matched the message, if any: the mailbox of the ruleset, otherwise
+// the mailbox of the destination, defaulting to Inbox.
+func DeliveryMailbox(dest config.Destination, rs *config.Ruleset) string {
+	if rs != nil {
+		return rs.Mailbox
+	} else if dest.Mailbox != "" {
+		return dest.Mailbox
+	}
+	return "Inbox"
+}
+
+// MessageRulesetIndex is like MessageRuleset, but returns the index of the
+// matching ruleset in dest.Rulesets, or -1 if none matches.
+func MessageRulesetIndex(log mlog.Log, dest config.Destination, m *Message, msgPrefix []byte, msgFile *os.File) int {
+	if len(dest.Rulesets) == 0 {
+		return -1
+	}
 
 	mr := FileMsgReader(msgPrefix, msgFile) // We don't close, it would close the msgFile.
 	p, err := message.Parse(log.Logger, false, mr)
@@ -2841,11 +2965,14 @@ func MessageRuleset(log mlog.Log, dest config.Destination, m *Message, msgPrefix
 	if err != nil {
 		log.Errorx("parsing message headers for evaluating rulesets, delivering to default mailbox", err, slog.String("parse", ""))
 		// todo: reject message?
-		return nil
+		return -1
 	}
 
 ruleset:
-	for _, rs := range dest.Rulesets {
+	for i, rs := range dest.Rulesets {
+		if rs.Disabled {
+			continue
+		}
 		if rs.SMTPMailFromRegexpCompiled != nil {
 			if !rs.SMTPMailFromRegexpCompiled.MatchString(m.MailFrom) {
 				continue ruleset
@@ -2894,9 +3021,9 @@ ruleset:
 			}
 			continue ruleset
 		}
-		return &rs
+		return i
 	}
-	return nil
+	return -1
 }
 
 // MessagePath returns the file system path of a message.
@@ -2951,6 +3078,15 @@ func (a *Account) DeliverMailbox(log mlog.Log, mailbox string, m *Message, msgFi
 	}()
 
 	err := a.DB.Write(context.TODO(), func(tx *bstore.Tx) error {
+		// Mailboxes from rulesets are created automatically, but count towards the maximum
+		// number of mailboxes. Rather than failing the delivery, we deliver to the Inbox.
+		if ok, maxMailboxes, err := a.CanAddMailbox(tx, mailbox); err != nil {
+			return fmt.Errorf("checking maximum number of mailboxes: %v", err)
+		} else if !ok {
+			log.Info("cannot create mailbox for delivery, maximum number of mailboxes reached, delivering to inbox instead", slog.String("mailbox", mailbox), slog.Int("maxmailboxes", maxMailboxes))
+			mailbox = "Inbox"
+		}
+
 		mb, chl, err := a.MailboxEnsure(tx, mailbox, true, SpecialUse{}, &m.ModSeq)
 		if err != nil {
 			return fmt.Errorf("ensuring mailbox: %w", err)
@@ -3214,6 +3350,44 @@ func (a *Account) QuotaMessageSize() int64 {
 	return size
 }
 
+// QuotaMessageCount returns the maximum number of messages for an account.
+// Returns 0 if there is no maximum.
+func (a *Account) QuotaMessageCount() int {
+	conf, _ := a.Conf()
+	return max(conf.QuotaMessageCount, 0)
+}
+
+// messageCount returns the number of messages in the account, including messages
+// marked \Deleted. The counts of mb are taken from mb, it may have pending
+// changes.
+func (a *Account) messageCount(tx *bstore.Tx, mb *Mailbox) (int64, error) {
+	n := mb.Total + mb.Deleted
+	err := bstore.QueryTx[Mailbox](tx).FilterEqual("Expunged", false).FilterNotEqual("ID", mb.ID).ForEach(func(omb Mailbox) error {
+		n += omb.Total + omb.Deleted
+		return nil
+	})
+	return n, err
+}
+
+// CanAddMessageCount checks if n messages can be added, depending on the number
+// of messages and configured message count quota for account.
+func (a *Account) CanAddMessageCount(tx *bstore.Tx, n int) (ok bool, maxCount int, err error) {
+	maxCount = a.QuotaMessageCount()
+	if maxCount <= 0 {
+		return true, 0, nil
+	}
+
+	var count int64
+	err = bstore.QueryTx[Mailbox](tx).FilterEqual("Expunged", false).ForEach(func(mb Mailbox) error {
+		count += mb.Total + mb.Deleted
+		return nil
+	})
+	if err != nil {
+		return false, maxCount, fmt.Errorf("counting messages: %v", err)
+	}
+	return count+int64(n) <= int64(maxCount), maxCount, nil
+}
+
 // CanAddMessageSize checks if a message of size bytes can be added, depending on
 // total message size and configured quota for account.
 func (a *Account) CanAddMessageSize(tx *bstore.Tx, size int64) (ok bool, maxSize int64, err error) {
@@ -3229,6 +3403,49 @@ func (a *Account) CanAddMessageSize(tx *bstore.Tx, size int64) (ok bool, maxSize
 	return du.MessageSize+size <= maxSize, maxSize, nil
 }
 
+// MaxMailboxes returns the effective maximum number of mailboxes for an account.
+// Returns 0 if there is no maximum.
+func (a *Account) MaxMailboxes() int {
+	conf, _ := a.Conf()
+	n := conf.MaxMailboxes
+	if n == 0 {
+		n = mox.Conf.Static.MaxMailboxes
+	}
+	if n < 0 {
+		n = 0
+	}
+	return n
+}
+
+// CanAddMailbox checks if mailbox name, including any missing parent mailboxes,
+// can be created without going over the maximum number of mailboxes for the
+// account. Also returns true if the mailbox already exists.
+func (a *Account) CanAddMailbox(tx *bstore.Tx, name string) (ok bool, maxMailboxes int, err error) {
+	maxMailboxes = a.MaxMailboxes()
+	if maxMailboxes <= 0 {
+		return true, 0, nil
+	}
+
+	q := bstore.QueryTx[Mailbox](tx)
+	q.FilterEqual("Expunged", false)
+	l, err := q.List()
+	if err != nil {
+		return false, maxMailboxes, fmt.Errorf("list mailboxes: %v", err)
+	}
+	have := map[string]bool{}
+	for _, mb := range l {
+		have[mb.Name] = true
+	}
+	var missing int
+	elems := strings.Split(name, "/")
+	for i := range elems {
+		if !have[strings.Join(elems[:i+1], "/")] {
+			missing++
+		}
+	}
+	return missing == 0 || len(l)+missing <= maxMailboxes, maxMailboxes, nil
+}
+
 // We keep a cache of recent successful authentications, so we don't have to bcrypt successful calls each time.
 var authCache = struct {
 	sync.Mutex
@@ -3546,7 +3763,7 @@ func CheckKeyword(kw string) error {
 // SendLimitReached checks whether sending a message to recipients would reach
 // the limit of outgoing messages for the account. If so, the message should
 // not be sent. If the returned numbers are >= 0, the limit was reached and the
-// values are the configured limits.
+// values are the configured limits. A negative configured limit means no limit.
 //
 // To limit damage to the internet and our reputation in case of account
 // compromise, we limit the max number of messages sent in a 24 hour window, both
@@ -3564,6 +3781,9 @@ func (a *Account) SendLimitReached(tx *bstore.Tx, recipients []smtp.Path) (msgli
 		// case of a compromise, a spammer will probably try to send to many new addresses.
 		rcptmax = 200
 	}
+	if msgmax < 0 && rcptmax < 0 {
+		return -1, -1, nil
+	}
 
 	rcpts := map[string]time.Time{}
 	n := 0
@@ -3577,13 +3797,13 @@ func (a *Account) SendLimitReached(tx *bstore.Tx, recipients []smtp.Path) (msgli
 	if err != nil {
 		return -1, -1, fmt.Errorf("querying message recipients in past 24h: %w", err)
 	}
-	if n+len(recipients) > msgmax {
+	if msgmax > 0 && n+len(recipients) > msgmax {
 		return msgmax, -1, nil
 	}
 
 	// Only check if max first-time recipients is reached if there are enough messages
 	// to trigger the limit.
-	if n+len(recipients) < rcptmax {
+	if rcptmax < 0 || n+len(recipients) < rcptmax {
 		return -1, -1, nil
 	}
 
@@ -3638,6 +3858,12 @@ func MailboxID(tx *bstore.Tx, id int64) (Mailbox, error) {
 //
 // Name must be in normalized form, see CheckMailboxName.
 func (a *Account) MailboxCreate(tx *bstore.Tx, name string, specialUse SpecialUse) (nmb Mailbox, changes []Change, created []string, exists bool, rerr error) {
+	if ok, maxMailboxes, err := a.CanAddMailbox(tx, name); err != nil {
+		return Mailbox{}, nil, nil, false, fmt.Errorf("checking maximum number of mailboxes: %v", err)
+	} else if !ok {
+		return Mailbox{}, nil, nil, false, fmt.Errorf("%w (%d)", ErrMailboxLimit, maxMailboxes)
+	}
+
 	elems := strings.Split(name, "/")
 	var p strings.Builder
 	var modseq ModSeq
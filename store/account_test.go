@@ -314,6 +314,13 @@ test
 		t.Fatalf("expected no ruleset match")
 	}
 
+	// Disabled rulesets don't match.
+	dest.Rulesets[0].Disabled = true
+	c = MessageRuleset(log, dest, &Message{}, msgBuf, f)
+	if c != nil {
+		t.Fatalf("expected no match for disabled ruleset")
+	}
+
 	// todo: test the SMTPMailFrom and VerifiedDomains rule.
 }
 
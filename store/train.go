@@ -19,6 +19,20 @@ import (
 // ErrNoJunkFilter indicates user did not configure/enable a junk filter.
 var ErrNoJunkFilter = errors.New("junkfilter: not configured")
 
+// JunkThresholdStrict is the junk threshold for incoming messages from senders
+// without reputation that show other bad signals, such as a failing reverse IP
+// lookup, delivery without TLS, or the recipient address missing from the To/Cc
+// message headers. Only used when lower than the configured threshold.
+const JunkThresholdStrict = 0.25
+
+// JunkAccept returns whether an incoming message with junk filter classification
+// result is acceptable at threshold. A result that is not significant is accepted,
+// unless requireSignificant is set, e.g. for a sender with a failing reverse IP
+// lookup.
+func JunkAccept(result junk.Result, threshold float64, requireSignificant bool) bool {
+	return result.Probability <= threshold || (!result.Significant && !requireSignificant)
+}
+
 func (a *Account) HasJunkFilter() bool {
 	conf, _ := a.Conf()
 	return conf.JunkFilter != nil
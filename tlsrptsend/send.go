@@ -296,11 +296,11 @@ func sendReportDomain(ctx context.Context, log mlog.Log, resolver dns.Resolver,
 		confDom, ok := mox.Conf.Domain(fromDom)
 		if confDom.Disabled {
 			return true, fmt.Errorf("domain is temporarily disabled")
-		} else if len(confDom.DKIM.Sign) > 0 {
+		} else if len(mox.DKIMSelectors(confDom.DKIM)) > 0 {
 			confDKIM = confDom.DKIM
 			break
 		} else if ok {
-			return true, fmt.Errorf("domain for mail host does not have dkim signing configured, report message cannot be dkim-signed")
+			return true, fmt.Errorf("domain for mail host does not have (enabled) dkim signing configured, report message cannot be dkim-signed")
 		}
 
 		// Remove least significant label.
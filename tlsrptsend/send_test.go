@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -517,4 +518,15 @@ func TestSendReports(t *testing.T) {
 		"tlsreports@xn--74h.example":           {report1},
 		"tlsreports2@mailhost.xn--74h.example": {report2},
 	})
+
+	// With the only dkim selector disabled, reports cannot be signed and aren't sent.
+	domains := maps.Clone(mox.Conf.Dynamic.Domains)
+	dom := domains["mox.example"]
+	dom.DKIM.Selectors = maps.Clone(dom.DKIM.Selectors)
+	sel := dom.DKIM.Selectors["testsel"]
+	sel.Disabled = true
+	dom.DKIM.Selectors["testsel"] = sel
+	domains["mox.example"] = dom
+	mox.Conf.Dynamic.Domains = domains
+	test(tlsResults, map[string][]tlsrpt.Report{})
 }
@@ -507,6 +507,7 @@ func (Account) DestinationSave(ctx context.Context, destName string, oldDest, ne
 		newDest.DMARCReports = curDest.DMARCReports
 		newDest.HostTLSReports = curDest.HostTLSReports
 		newDest.DomainTLSReports = curDest.DomainTLSReports
+		newDest.Forward = curDest.Forward
 
 		// Make copy of reference values.
 		nd := map[string]config.Destination{}
@@ -1755,6 +1755,7 @@ const destination = async (name: string) => {
 		acceptRejectsToMailbox: HTMLInputElement
 		mailbox: HTMLInputElement
 		comment: HTMLInputElement
+		disabled: HTMLInputElement // Checkbox
 	}
 
 	let rulesetsTbody = dom.tbody()
@@ -1803,6 +1804,7 @@ const destination = async (name: string) => {
 		let acceptRejectsToMailbox: HTMLInputElement
 		let mailbox: HTMLInputElement
 		let comment: HTMLInputElement
+		let disabled: HTMLInputElement // Checkbox
 
 		const root = dom.tr(
 			dom.td(smtpMailFromRegexp=dom.input(attr.value(rs.SMTPMailFromRegexp || ''))),
@@ -1814,6 +1816,7 @@ const destination = async (name: string) => {
 			dom.td(acceptRejectsToMailbox=dom.input(attr.value(rs.AcceptRejectsToMailbox || ''))),
 			dom.td(mailbox=dom.input(attr.value(rs.Mailbox || ''))),
 			dom.td(comment=dom.input(attr.value(rs.Comment || ''))),
+			dom.td(dom.label(disabled=dom.input(attr.type('checkbox'), rs.Disabled ? attr.checked('') : [] ))),
 			dom.td(
 				dom.clickbutton('Remove ruleset', function click() {
 					row.root.remove()
@@ -1832,6 +1835,7 @@ const destination = async (name: string) => {
 			acceptRejectsToMailbox: acceptRejectsToMailbox,
 			mailbox: mailbox,
 			comment: comment,
+			disabled: disabled,
 		}
 		rulesetsRows.push(row)
 
@@ -1908,13 +1912,14 @@ const destination = async (name: string) => {
 					dom.th('Allow rejects to mailbox', attr.title("Influences spam filtering only, this option does not change whether a message matches this ruleset. If a message is classified as spam, it isn't rejected during the SMTP transaction (the normal behaviour), but accepted during the SMTP transaction and delivered to the specified mailbox. The specified mailbox is not automatically cleaned up like the account global Rejects mailbox, unless set to that Rejects mailbox.")),
 					dom.th('Mailbox', attr.title('Mailbox to deliver to if this ruleset matches.')),
 					dom.th('Comment', attr.title('Free-form comments.')),
+					dom.th('Disabled', attr.title('If set, the ruleset is skipped when evaluating rulesets for incoming messages, e.g. while debugging delivery to mailboxes.')),
 					dom.th('Action'),
 				)
 			),
 			rulesetsTbody,
 			dom.tfoot(
 				dom.tr(
-					dom.td(attr.colspan('9')),
+					dom.td(attr.colspan('10')),
 					dom.td(
 						dom.clickbutton('Add ruleset', function click() {
 							addRulesetsRow({
@@ -1927,6 +1932,7 @@ const destination = async (name: string) => {
 								AcceptRejectsToMailbox: '',
 								Mailbox: '',
 								Comment: '',
+								Disabled: false,
 								VerifiedDNSDomain: {ASCII: '', Unicode: ''},
 								ListAllowDNSDomain: {ASCII: '', Unicode: ''},
 							})
@@ -1951,6 +1957,7 @@ const destination = async (name: string) => {
 						AcceptRejectsToMailbox: row.acceptRejectsToMailbox.value,
 						Mailbox: row.mailbox.value,
 						Comment: row.comment.value,
+						Disabled: row.disabled.checked,
 						VerifiedDNSDomain: {ASCII: '', Unicode: ''},
 						ListAllowDNSDomain: {ASCII: '', Unicode: ''},
 					}
@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"context"
 	"crypto"
+	"crypto/ecdsa"
 	"crypto/ed25519"
 	cryptorand "crypto/rand"
 	"crypto/rsa"
@@ -31,6 +32,7 @@ import (
 	"runtime/debug"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -1073,6 +1075,13 @@ EOF
 					}
 				case ed25519.PublicKey:
 					pk = []byte(k)
+				case *ecdsa.PublicKey:
+					var err error
+					pk, err = x509.MarshalPKIXPublicKey(k)
+					if err != nil {
+						addf(&r.DKIM.Errors, "Marshal public key for %q to compare against DNS: %s", sel, err)
+						continue
+					}
 				default:
 					addf(&r.DKIM.Errors, "Internal error: unknown public key type %T.", pubKey)
 					continue
@@ -1098,6 +1107,8 @@ EOF
 			case *rsa.PublicKey:
 			case ed25519.PublicKey:
 				dkimr.Key = "ed25519"
+			case *ecdsa.PublicKey:
+				dkimr.Key = "ecdsa"
 			default:
 				addf(&r.DKIM.Errors, "Internal error: unknown public key type %T.", dkimr.PublicKey)
 			}
@@ -2040,22 +2051,8 @@ func (Admin) AccountSettingsSave(ctx context.Context, accountName string, maxOut
 
 // AccountLoginDisabledSave saves the LoginDisabled field of an account.
 func (Admin) AccountLoginDisabledSave(ctx context.Context, accountName string, loginDisabled string) {
-	log := pkglog.WithContext(ctx)
-
-	acc, err := store.OpenAccount(log, accountName, false)
-	xcheckf(ctx, err, "open account")
-	defer func() {
-		err := acc.Close()
-		log.Check(err, "closing account")
-	}()
-
-	err = admin.AccountSave(ctx, accountName, func(acc *config.Account) {
-		acc.LoginDisabled = loginDisabled
-	})
+	err := admin.AccountLoginDisable(ctx, accountName, loginDisabled)
 	xcheckf(ctx, err, "saving login disabled account")
-
-	err = acc.SessionsClear(ctx, log)
-	xcheckf(ctx, err, "removing current sessions")
 }
 
 // ClientConfigsDomain returns configurations for email clients, IMAP and
@@ -2529,48 +2526,23 @@ func (Admin) DomainDescriptionSave(ctx context.Context, domainName, descr string
 
 // DomainClientSettingsDomainSave saves the client settings domain for a domain.
 func (Admin) DomainClientSettingsDomainSave(ctx context.Context, domainName, clientSettingsDomain string) {
-	err := admin.DomainSave(ctx, domainName, func(domain *config.Domain) error {
-		domain.ClientSettingsDomain = clientSettingsDomain
-		return nil
-	})
+	d, err := dns.ParseDomain(domainName)
+	xcheckuserf(ctx, err, "parsing domain")
+	var csd dns.Domain
+	if clientSettingsDomain != "" {
+		csd, err = dns.ParseDomain(clientSettingsDomain)
+		xcheckuserf(ctx, err, "parsing client settings domain")
+	}
+	err = admin.DomainClientSettingsDomainSave(ctx, d, csd)
 	xcheckf(ctx, err, "saving client settings domain")
 }
 
 // DomainLocalpartConfigSave saves the localpart catchall and case-sensitive
 // settings for a domain.
 func (Admin) DomainLocalpartConfigSave(ctx context.Context, domainName string, localpartCatchallSeparators []string, localpartCaseSensitive bool) {
-	err := admin.DomainSave(ctx, domainName, func(domain *config.Domain) error {
-		// We don't allow introducing new catchall separators that are used in DMARC/TLS
-		// reporting. Can occur in existing configs for backwards compatibility.
-		containsSep := func(seps []string) bool {
-			for _, sep := range seps {
-				if domain.DMARC != nil && strings.Contains(domain.DMARC.Localpart, sep) {
-					return true
-				}
-				if domain.TLSRPT != nil && strings.Contains(domain.TLSRPT.Localpart, sep) {
-					return true
-				}
-			}
-			return false
-		}
-		if !containsSep(domain.LocalpartCatchallSeparatorsEffective) && containsSep(localpartCatchallSeparators) {
-			xusererrorf(ctx, "cannot add localpart catchall separators that are used in dmarc and/or tls reporting addresses, change reporting addresses first")
-		}
-
-		domain.LocalpartCatchallSeparatorsEffective = localpartCatchallSeparators
-		// If there is a single separator, we prefer the non-list form, it's easier to
-		// read/edit and should suffice for most setups.
-		domain.LocalpartCatchallSeparator = ""
-		domain.LocalpartCatchallSeparators = nil
-		if len(localpartCatchallSeparators) == 1 {
-			domain.LocalpartCatchallSeparator = localpartCatchallSeparators[0]
-		} else {
-			domain.LocalpartCatchallSeparators = localpartCatchallSeparators
-		}
-
-		domain.LocalpartCaseSensitive = localpartCaseSensitive
-		return nil
-	})
+	d, err := dns.ParseDomain(domainName)
+	xcheckuserf(ctx, err, "parsing domain")
+	err = admin.DomainLocalpartConfigSave(ctx, d, localpartCatchallSeparators, localpartCaseSensitive)
 	xcheckf(ctx, err, "saving localpart settings for domain")
 }
 
@@ -2593,12 +2565,20 @@ func (Admin) DomainDMARCAddressSave(ctx context.Context, domainName, localpart,
 		if localpart == "" {
 			d.DMARC = nil
 		} else {
-			d.DMARC = &config.DMARC{
+			ndmarc := &config.DMARC{
 				Localpart: localpart,
 				Domain:    domain,
 				Account:   account,
 				Mailbox:   mailbox,
 			}
+			// Keep the settings for the DNS record.
+			if d.DMARC != nil {
+				ndmarc.Policy = d.DMARC.Policy
+				ndmarc.Percentage = d.DMARC.Percentage
+				ndmarc.ExtraAggregateReportAddresses = d.DMARC.ExtraAggregateReportAddresses
+				ndmarc.FailureReportAddresses = d.DMARC.FailureReportAddresses
+			}
+			d.DMARC = ndmarc
 		}
 		return nil
 	})
@@ -2656,13 +2636,20 @@ func (Admin) DomainMTASTSSave(ctx context.Context, domainName, policyID string,
 }
 
 // DomainDKIMAdd adds a DKIM selector for a domain, generating a new private
-// key. The selector is not enabled for signing.
+// key. The selector is not enabled for signing. Algorithm is "rsa", "ed25519" or
+// "ecdsa". An rsa key size can be specified as e.g. "rsa-4096".
 func (Admin) DomainDKIMAdd(ctx context.Context, domainName, selector, algorithm, hash string, headerRelaxed, bodyRelaxed, seal bool, headers []string, lifetime time.Duration) {
 	d, err := dns.ParseDomain(domainName)
 	xcheckuserf(ctx, err, "parsing domain")
 	s, err := dns.ParseDomain(selector)
 	xcheckuserf(ctx, err, "parsing selector")
-	err = admin.DKIMAdd(ctx, d, s, algorithm, hash, headerRelaxed, bodyRelaxed, seal, headers, lifetime)
+	var bits int
+	if t := strings.SplitN(algorithm, "-", 2); len(t) == 2 && t[0] == "rsa" {
+		algorithm = t[0]
+		bits, err = strconv.Atoi(t[1])
+		xcheckuserf(ctx, err, "parsing rsa key size")
+	}
+	err = admin.DKIMAdd(ctx, d, s, algorithm, bits, hash, headerRelaxed, bodyRelaxed, seal, headers, lifetime)
 	xcheckf(ctx, err, "adding dkim key")
 }
 
@@ -2708,6 +2695,7 @@ func (Admin) DomainDKIMSave(ctx context.Context, domainName string, selectors ma
 				Expiration:       nsel.Expiration,
 
 				PrivateKeyFile: osel.PrivateKeyFile,
+				Disabled:       osel.Disabled,
 			}
 			if !slices.Equal(osel.HeadersEffective, nsel.Headers) {
 				xsel.Headers = nsel.Headers
@@ -2729,10 +2717,9 @@ func (Admin) DomainDKIMSave(ctx context.Context, domainName string, selectors ma
 // rejects incoming/outgoing messages involving the domain and does not request new
 // TLS certificats with ACME.
 func (Admin) DomainDisabledSave(ctx context.Context, domainName string, disabled bool) {
-	err := admin.DomainSave(ctx, domainName, func(d *config.Domain) error {
-		d.Disabled = disabled
-		return nil
-	})
+	d, err := dns.ParseDomain(domainName)
+	xcheckuserf(ctx, err, "parsing domain")
+	err = admin.DomainDisable(ctx, d, disabled)
 	xcheckf(ctx, err, "saving disabled setting for domain")
 }
 
@@ -2746,7 +2733,7 @@ func xparseAddress(ctx context.Context, lp, domain string) smtp.Address {
 
 func (Admin) AliasAdd(ctx context.Context, aliaslp string, domainName string, alias config.Alias) {
 	addr := xparseAddress(ctx, aliaslp, domainName)
-	err := admin.AliasAdd(ctx, addr, alias)
+	_, err := admin.AliasAdd(ctx, addr, alias, false)
 	xcheckf(ctx, err, "adding alias")
 }
 
@@ -2769,7 +2756,7 @@ func (Admin) AliasRemove(ctx context.Context, aliaslp string, domainName string)
 
 func (Admin) AliasAddressesAdd(ctx context.Context, aliaslp string, domainName string, addresses []string) {
 	addr := xparseAddress(ctx, aliaslp, domainName)
-	err := admin.AliasAddressesAdd(ctx, addr, addresses)
+	_, err := admin.AliasAddressesAdd(ctx, addr, addresses, false)
 	xcheckf(ctx, err, "adding address to alias")
 }
 
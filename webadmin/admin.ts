@@ -1013,13 +1013,13 @@ const account = async (name: string) => {
 			fieldsetSettings=dom.fieldset(
 				dom.label(
 					style({display: 'block', marginBottom: '.5ex'}),
-					dom.span('Maximum outgoing messages per day', attr.title('Maximum number of outgoing messages for this account in a 24 hour window. This limits the damage to recipients and the reputation of this mail server in case of account compromise. Default 1000. MaxOutgoingMessagesPerDay in configuration file.')),
+					dom.span('Maximum outgoing messages per day', attr.title('Maximum number of outgoing messages for this account in a 24 hour window. This limits the damage to recipients and the reputation of this mail server in case of account compromise. Default 1000. A negative value means no limit. MaxOutgoingMessagesPerDay in configuration file.')),
 					dom.br(),
 					maxOutgoingMessagesPerDay=dom.input(attr.type('number'), attr.required(''), attr.value(''+(config.MaxOutgoingMessagesPerDay || 1000))),
 				),
 				dom.label(
 					style({display: 'block', marginBottom: '.5ex'}),
-					dom.span('Maximum first-time recipients per day', attr.title('Maximum number of first-time recipients in outgoing messages for this account in a 24 hour window. This limits the damage to recipients and the reputation of this mail server in case of account compromise. Default 200. MaxFirstTimeRecipientsPerDay in configuration file.')),
+					dom.span('Maximum first-time recipients per day', attr.title('Maximum number of first-time recipients in outgoing messages for this account in a 24 hour window. This limits the damage to recipients and the reputation of this mail server in case of account compromise. Default 200. A negative value means no limit. MaxFirstTimeRecipientsPerDay in configuration file.')),
 					dom.br(),
 					maxFirstTimeRecipientsPerDay=dom.input(attr.type('number'), attr.required(''), attr.value(''+(config.MaxFirstTimeRecipientsPerDay || 200))),
 				),
@@ -1340,8 +1340,8 @@ const domain = async (d: string) => {
 							dom.label(
 								style({display: 'block', marginBottom: '1ex'}),
 								'Algorithm',
-								attr.title('For signing messages. RSA is common at the time of writing, not all mail servers recognize ed25519 signature.'),
-								dom.div(algorithm=dom.select(dom.option('rsa'), dom.option('ed25519'))),
+								attr.title('For signing messages. RSA is common at the time of writing, not all mail servers recognize ed25519 signatures. ECDSA is not standardized for DKIM, few mail servers verify it.'),
+								dom.div(algorithm=dom.select(dom.option('rsa'), dom.option('rsa-3072'), dom.option('rsa-4096'), dom.option('ed25519'), dom.option('ecdsa'))),
 							),
 							dom.label(
 								style({display: 'block', marginBottom: '1ex'}),
@@ -3345,7 +3345,7 @@ const dnsbl = async () => {
 }
 
 const queueList = async () => {
-	let filter: api.Filter = {Max: parseInt(localStorageGet('adminpaginationsize') || '') || 100, IDs: [], Account: '', From: '', To: '', Hold: null, Submitted: '', NextAttempt: '', Transport: null}
+	let filter: api.Filter = {Max: parseInt(localStorageGet('adminpaginationsize') || '') || 100, IDs: [], Account: '', From: '', To: '', Hold: null, Submitted: '', NextAttempt: '', Transport: null, SenderDomain: '', RecipientDomain: ''}
 	let sort: api.Sort = {Field: "NextAttempt", LastID: 0, Last: null, Asc: true}
 	let [holdRules, msgs0, transports] = await Promise.all([
 		client.QueueHoldRuleList(),
@@ -3398,6 +3398,8 @@ const queueList = async () => {
 			Submitted: '',
 			NextAttempt: '',
 			Transport: null,
+			SenderDomain: '',
+			RecipientDomain: '',
 		}
 		// Don't want to accidentally operate on all messages.
 		if ((f.IDs || []).length === 0) {
@@ -3589,6 +3591,8 @@ const queueList = async () => {
 					Submitted: filterSubmitted.value,
 					NextAttempt: filterNextAttempt.value,
 					Transport: !filterTransport.value ? null : (filterTransport.value === '(default)' ? '' : filterTransport.value),
+					SenderDomain: '',
+					RecipientDomain: '',
 				}
 				sort = {
 					Field: sortElem.value.startsWith('nextattempt') ? 'NextAttempt' : 'Queued',
@@ -981,6 +981,14 @@ func (s server) Send(ctx context.Context, req webapi.SendRequest) (resp webapi.S
 	if confDom.Disabled {
 		xcheckuserf(mox.ErrDomainDisabled, "checking domain")
 	}
+	// Add footer of domain, before signing.
+	if size, has8bit, changed, err := mox.MessageFooterAdd(log, confDom.Footer, dataFile, xc.Size); err != nil {
+		xcheckf(err, "adding footer")
+	} else if changed {
+		xc.Size = size
+		xc.Has8bit = xc.Has8bit || has8bit
+	}
+
 	selectors := mox.DKIMSelectors(confDom.DKIM)
 	if len(selectors) > 0 {
 		dkimHeaders, err := dkim.Sign(ctx, log.Logger, from.Address.Localpart, fd, selectors, smtputf8, dataFile)
@@ -938,6 +938,14 @@ func (w Webmail) MessageSubmit(ctx context.Context, m SubmitMessage) {
 	if confDom.Disabled {
 		xcheckuserf(ctx, mox.ErrDomainDisabled, "checking domain")
 	}
+	// Add footer of domain, before signing.
+	if size, has8bit, changed, err := mox.MessageFooterAdd(log, confDom.Footer, dataFile, xc.Size); err != nil {
+		xcheckf(ctx, err, "adding footer")
+	} else if changed {
+		xc.Size = size
+		xc.Has8bit = xc.Has8bit || has8bit
+	}
+
 	selectors := mox.DKIMSelectors(confDom.DKIM)
 	if len(selectors) > 0 {
 		dkimHeaders, err := dkim.Sign(ctx, log.Logger, fromAddr.Address.Localpart, fd, selectors, smtputf8, dataFile)
@@ -1224,6 +1232,8 @@ func (Webmail) MailboxCreate(ctx context.Context, name string) {
 			_, changes, _, exists, err = acc.MailboxCreate(tx, name, store.SpecialUse{})
 			if exists {
 				xcheckuserf(ctx, errors.New("mailbox already exists"), "creating mailbox")
+			} else if errors.Is(err, store.ErrMailboxLimit) {
+				xcheckuserf(ctx, err, "creating mailbox")
 			}
 			xcheckf(ctx, err, "creating mailbox")
 		})
@@ -650,7 +650,7 @@ func serveEvents(ctx context.Context, log mlog.Log, accountPath string, w http.R
 		}
 		var ma MessageAddress
 		if strings.HasPrefix(a, "@") {
-			dom, err := dns.ParseDomain(a[1:])
+			dom, err := dns.ParseDomain(strings.TrimPrefix(a[1:], "."))
 			xcheckf(ctx, err, "parsing destination address for account")
 			ma = MessageAddress{Domain: dom}
 		} else {